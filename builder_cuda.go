@@ -0,0 +1,292 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/dereklstinson/cutil"
+	"github.com/dereklstinson/gocudnn/curand"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
+	"github.com/dereklstinson/gocunets/layers"
+	"github.com/dereklstinson/gocunets/layers/activation"
+	"github.com/dereklstinson/gocunets/layers/batchnorm"
+	"github.com/dereklstinson/gocunets/layers/cnn"
+	"github.com/dereklstinson/gocunets/layers/cnntranspose"
+	"github.com/dereklstinson/gocunets/layers/dropout"
+	"github.com/dereklstinson/gocunets/layers/pooling"
+)
+
+//Builder will create layers with the flags set within the struct
+type Builder struct {
+	h         *Handle
+	gpurng    *curand.Generator
+	Frmt      TensorFormat
+	Dtype     DataType
+	Cmode     ConvolutionMode
+	Mtype     MathType
+	Pmode     PoolingMode
+	AMode     ActivationMode
+	BNMode    BatchNormMode
+	Nan       NanProp
+	curngtype curand.RngType
+	cpu       bool       //set by CreateCPUBuilder
+	rng       *rand.Rand //used by the cpu layers
+	//	src       rand.Source
+}
+
+//CreateBuilder creates a Builder.  Flags can be set by flags' methods inside of Builder.
+//Default Flags are set at:
+//
+//	Frmt.NCHW()
+//
+//	Mtype.Default()
+//
+//	Nan.NotPropigate()
+//
+//	Cmode.CrossCorrelation()
+//
+//	Dtype.Float()
+//
+//	Pmode.AverageCountExcludePadding()
+//
+//  BNMode.Spatial()
+//
+//	AMode.Leaky()
+func CreateBuilder(h *Handle) (b *Builder) {
+	b = new(Builder)
+	b.h = h
+	//	b.src = rand.NewSource(seed)
+	//	b.rng = rand.New(b.src)
+	b.Frmt.NCHW()
+	b.Mtype.Default()
+	b.Cmode.CrossCorrelation()
+	b.Nan.NotPropigate()
+	b.Dtype.Float()
+	b.AMode.Leaky()
+	b.Pmode.AverageCountExcludePadding()
+	b.BNMode.Spatial()
+	b.curngtype.PseudoDefault()
+	b.gpurng = curand.CreateGeneratorEx(b.h.Handler.Worker, b.curngtype)
+	return b
+}
+
+//GetHandle returns the handle
+func (l *Builder) GetHandle() *Handle {
+	return l.h
+}
+
+//sync syncs the handle of the builder.  Host layers are done when they return so there isn't anything to sync on the cpu.
+func (l *Builder) sync() error {
+	if l.cpu {
+		return nil
+	}
+	return l.h.Sync()
+}
+
+//gpuonly returns an error for fn if l is a cpu Builder
+func (l *Builder) gpuonly(fn string) error {
+	if l != nil && l.cpu {
+		return fmt.Errorf("%s: not supported on the cpu", fn)
+	}
+	return nil
+}
+
+//loadvalues loads values into t with the handle of the builder
+func (l *Builder) loadvalues(t *Tensor, values []float32) error {
+	return loadslice(l.h, t, values)
+}
+
+//AllocateMemory allocates memory
+func (l *Builder) AllocateMemory(sib uint) (cutil.Pointer, error) {
+	if err := l.gpuonly("(l *Builder) AllocateMemory"); err != nil {
+		return nil, err
+	}
+	return nvidia.MallocGlobal(l.h.Handler.Worker, sib)
+}
+
+//CreateTensor creates a tensor
+func (l *Builder) CreateTensor(dims []int32) (t *Tensor, err error) {
+	if l.cpu {
+		return l.createhosttensor(dims)
+	}
+	//	err = l.h.w.Work(func() error {
+	t = new(Tensor)
+
+	t.Tensor, err = layers.CreateTensor(l.h.Handler, l.Frmt.TensorFormat, l.Dtype.DataType, dims)
+	if err != nil {
+		err = fmt.Errorf(" (l *Builder) CreateTensor, Err: %v, input dims: %v", err, dims)
+	}
+	return t, err
+
+}
+
+//CreateRandomTensor creates a random tensor
+func (l *Builder) CreateRandomTensor(dims []int32, mean, std float32, seed uint64) (t *Tensor, err error) {
+	if l.cpu {
+		return l.createhostrandomtensor(dims, mean, std, seed)
+	}
+	//	err = l.h.w.Work(func() error {
+	//	var err1 error
+	t = new(Tensor)
+	t.Tensor, err = layers.BuildRandomTensor(l.h.Handler, l.Frmt.TensorFormat, l.Dtype.DataType, dims, mean, std)
+	//return nil, err1
+	//	})
+	return t, err
+
+}
+
+//PoolingLayer creates a pooling layer with flags set in Builder
+func (l *Builder) PoolingLayer(id int64, window, padding, stride []int32) (p *Layer, err error) {
+	if l.cpu {
+		return l.hostpooling(id, window, padding, stride)
+	}
+
+	player, err := pooling.SetupNoOutput(l.Pmode.PoolingMode, l.Nan.NANProp, window, padding, stride)
+	if err != nil {
+		return nil, err
+	}
+	p, err = createlayer(id, l.h, player)
+
+	return
+}
+
+//BatchNorm is the batch norm layer
+func (l *Builder) BatchNorm(id int64) (batch *Layer, err error) {
+	if l.cpu {
+		return l.hostbatchnorm(id)
+	}
+	var blayer *batchnorm.Layer
+
+	switch l.BNMode {
+	case bprflags.BNMode.PerActivation():
+		blayer, err = batchnorm.PerActivationPreset(l.h.Handler)
+	case bprflags.BNMode.Spatial():
+		blayer, err = batchnorm.SpatialPreset(l.h.Handler)
+	case bprflags.BNMode.SpatialPersistent():
+		blayer, err = batchnorm.SpatialPersistantPreset(l.h.Handler)
+	}
+	if err != nil {
+		return nil, err
+	}
+	batch, err = createlayer(id, l.h, blayer)
+
+	return batch, err
+}
+
+//ConvolutionLayer creates a convolution layer
+func (l *Builder) ConvolutionLayer(id int64, groupcount int32, w, dw, b, db *Tensor, pad, stride, dilation []int32) (conv *Layer, err error) {
+	if l.cpu {
+		return l.hostconvolution(id, groupcount, w, dw, b, db, pad, stride, dilation, false)
+	}
+	//err = l.h.w.Work(func() error {
+	clayer, err := cnn.SetupBasic(
+		l.h.Handler,
+		l.Frmt.TensorFormat,
+		l.Dtype.DataType,
+		l.Mtype.MathType,
+		groupcount,
+		w.Tensor, dw.Tensor, b.Tensor, db.Tensor,
+		l.Cmode.ConvolutionMode,
+		pad,
+		stride,
+		dilation)
+	if err != nil {
+		return nil, err
+	}
+	conv, err = createlayer(id, l.h, clayer)
+	//	return nil, nil
+	//	})
+	if err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+//Dropout creates an Dropout layer
+func (l *Builder) Dropout(id int64, dropoutpercent float32, seed uint64) (d *Layer, err error) {
+	if l.cpu {
+		return l.hostdropout(id, dropoutpercent, seed)
+	}
+	dlayer, err := dropout.Preset(l.h.Handler, dropoutpercent, seed)
+	if err != nil {
+		return nil, err
+	}
+	d, err = createlayer(id, l.h, dlayer)
+
+	return d, err
+}
+
+//Activation creates an activation layer
+func (l *Builder) Activation(id int64) (a *Layer, err error) {
+	if l.cpu {
+		return l.hostactivation(id, -1)
+	}
+	var act *activation.Layer
+	aflg := l.AMode
+	switch l.AMode {
+	case aflg.Leaky():
+		act, err = activation.Leaky(l.h.Handler, l.Dtype.DataType)
+	case aflg.ClippedRelu():
+		act, err = activation.ClippedRelu(l.h.Handler, l.Dtype.DataType)
+	case aflg.Relu():
+		act, err = activation.Relu(l.h.Handler, l.Dtype.DataType)
+	case aflg.Elu():
+		act, err = activation.Elu(l.h.Handler, l.Dtype.DataType)
+	case aflg.Threshhold():
+		act, err = activation.Threshhold(l.h.Handler, l.Dtype.DataType, -.2, -.001, -2, 2, 1, 3, true)
+	case aflg.Sigmoid():
+		act, err = activation.Sigmoid(l.h.Handler, l.Dtype.DataType)
+	case aflg.Tanh():
+		act, err = activation.Tanh(l.h.Handler, l.Dtype.DataType)
+	case aflg.PRelu():
+		act, err = activation.PRelu(l.h.Handler, l.Dtype.DataType, true)
+	default:
+		return nil, errors.New("AppendActivation:  Not supported Activation Layer")
+	}
+	if err != nil {
+		return nil, err
+	}
+	a, err = createlayer(id, l.h, act)
+
+	return a, err
+}
+
+//ActivationWithCoef creates an activation layer with the AMode flag set in Builder that uses coef.
+//coef is the alpha for Leaky and Elu and the ceiling for ClippedRelu.
+func (l *Builder) ActivationWithCoef(id int64, coef float64) (a *Layer, err error) {
+	if l.cpu {
+		return l.hostactivation(id, coef)
+	}
+	act, err := activation.WithCoef(l.h.Handler, l.AMode.Mode, l.Dtype.DataType, coef)
+	if err != nil {
+		return nil, err
+	}
+	return createlayer(id, l.h, act)
+}
+
+//ReverseConvolutionLayer creates a reverse convolution layer
+func (l *Builder) ReverseConvolutionLayer(id int64, groupcount int32, w, dw, b, db *Tensor, pad, stride, dilation []int32) (rconv *Layer, err error) {
+	if l.cpu {
+		return l.hostconvolution(id, groupcount, w, dw, b, db, pad, stride, dilation, true)
+	}
+	clayer, err := cnntranspose.SetupBasic(l.h.Handler,
+		l.Frmt.TensorFormat,
+		l.Dtype.DataType,
+		l.Mtype.MathType,
+		groupcount,
+		w.Tensor,
+		dw.Tensor,
+		b.Tensor,
+		db.Tensor,
+		l.Cmode.ConvolutionMode,
+		pad, stride, dilation)
+	if err != nil {
+		return nil, err
+	}
+	rconv, err = createlayer(id, l.h, clayer)
+	return rconv, err
+}
@@ -0,0 +1,72 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+import "math/rand"
+
+//Builder will create layers with the flags set within the struct.
+//Without the cuda tag only CreateCPUBuilder can make one, and its layers and tensors are on the host.
+type Builder struct {
+	Frmt   TensorFormat
+	Dtype  DataType
+	Cmode  ConvolutionMode
+	Mtype  MathType
+	Pmode  PoolingMode
+	AMode  ActivationMode
+	BNMode BatchNormMode
+	Nan    NanProp
+	cpu    bool       //set by CreateCPUBuilder
+	rng    *rand.Rand //used by the cpu layers
+}
+
+//loadvalues loads values into t
+func (l *Builder) loadvalues(t *Tensor, values []float32) error {
+	return t.host.LoadValuesFromSLice(values)
+}
+
+//CreateTensor creates a tensor
+func (l *Builder) CreateTensor(dims []int32) (t *Tensor, err error) {
+	return l.createhosttensor(dims)
+}
+
+//CreateRandomTensor creates a random tensor
+func (l *Builder) CreateRandomTensor(dims []int32, mean, std float32, seed uint64) (t *Tensor, err error) {
+	return l.createhostrandomtensor(dims, mean, std, seed)
+}
+
+//PoolingLayer creates a pooling layer with flags set in Builder
+func (l *Builder) PoolingLayer(id int64, window, padding, stride []int32) (p *Layer, err error) {
+	return l.hostpooling(id, window, padding, stride)
+}
+
+//BatchNorm is the batch norm layer
+func (l *Builder) BatchNorm(id int64) (batch *Layer, err error) {
+	return l.hostbatchnorm(id)
+}
+
+//ConvolutionLayer creates a convolution layer
+func (l *Builder) ConvolutionLayer(id int64, groupcount int32, w, dw, b, db *Tensor, pad, stride, dilation []int32) (conv *Layer, err error) {
+	return l.hostconvolution(id, groupcount, w, dw, b, db, pad, stride, dilation, false)
+}
+
+//Dropout creates an Dropout layer
+func (l *Builder) Dropout(id int64, dropoutpercent float32, seed uint64) (d *Layer, err error) {
+	return l.hostdropout(id, dropoutpercent, seed)
+}
+
+//Activation creates an activation layer
+func (l *Builder) Activation(id int64) (a *Layer, err error) {
+	return l.hostactivation(id, -1)
+}
+
+//ActivationWithCoef creates an activation layer with the AMode flag set in Builder that uses coef.
+//coef is the alpha for Leaky and Elu and the ceiling for ClippedRelu.
+func (l *Builder) ActivationWithCoef(id int64, coef float64) (a *Layer, err error) {
+	return l.hostactivation(id, coef)
+}
+
+//ReverseConvolutionLayer creates a reverse convolution layer
+func (l *Builder) ReverseConvolutionLayer(id int64, groupcount int32, w, dw, b, db *Tensor, pad, stride, dilation []int32) (rconv *Layer, err error) {
+	return l.hostconvolution(id, groupcount, w, dw, b, db, pad, stride, dilation, true)
+}
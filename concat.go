@@ -1,3 +1,6 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 import (
@@ -91,6 +94,7 @@ func (c *ReverseConcat) FindOutputDimsfromInputDims(src []int32, ndests int32, f
 		if destchansize*ndests > channels {
 			underflow = true
 		}
+		destdims := make([][]int32, ndests)
 		for i := int32(0); i < ndests; i++ {
 
 			destdims[i] = make([]int32, len(src))
@@ -0,0 +1,441 @@
+package cpu
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+//ActivationMode is a flag for the activation function used by Activation
+type ActivationMode int32
+
+//Relu sets and returns the Relu flag
+func (a *ActivationMode) Relu() ActivationMode { *a = ActivationMode(0); return *a }
+
+//Leaky sets and returns the Leaky flag
+func (a *ActivationMode) Leaky() ActivationMode { *a = ActivationMode(1); return *a }
+
+//ClippedRelu sets and returns the ClippedRelu flag
+func (a *ActivationMode) ClippedRelu() ActivationMode { *a = ActivationMode(2); return *a }
+
+//Elu sets and returns the Elu flag
+func (a *ActivationMode) Elu() ActivationMode { *a = ActivationMode(3); return *a }
+
+//Sigmoid sets and returns the Sigmoid flag
+func (a *ActivationMode) Sigmoid() ActivationMode { *a = ActivationMode(4); return *a }
+
+//Tanh sets and returns the Tanh flag
+func (a *ActivationMode) Tanh() ActivationMode { *a = ActivationMode(5); return *a }
+
+//PRelu sets and returns the PRelu flag
+func (a *ActivationMode) PRelu() ActivationMode { *a = ActivationMode(6); return *a }
+
+//Threshhold sets and returns the Threshhold flag
+func (a *ActivationMode) Threshhold() ActivationMode { *a = ActivationMode(7); return *a }
+
+//Identity sets and returns the Identity flag
+func (a *ActivationMode) Identity() ActivationMode { *a = ActivationMode(8); return *a }
+
+func (a ActivationMode) String() string {
+	var f ActivationMode
+	switch a {
+	case f.Relu():
+		return "Relu"
+	case f.Leaky():
+		return "Leaky"
+	case f.ClippedRelu():
+		return "ClippedRelu"
+	case f.Elu():
+		return "Elu"
+	case f.Sigmoid():
+		return "Sigmoid"
+	case f.Tanh():
+		return "Tanh"
+	case f.PRelu():
+		return "PRelu"
+	case f.Threshhold():
+		return "Threshhold"
+	case f.Identity():
+		return "Identity"
+	}
+	return "Unsupported Activation"
+}
+
+//Activation is an activation layer that runs on the cpu.
+//
+//PRelu and Threshhold have trainable coefs that are made the first time Forward is called.
+//Their dims are the same as x with the batch dim set to 1. This is the same way the gpu layer does it.
+type Activation struct {
+	mode                ActivationMode
+	coef                float64
+	fwd, bwd, bwp       scalars
+	rng                 *rand.Rand
+	negcoefs, dnegcoefs *Tensor
+	poscoefs, dposcoefs *Tensor
+	thresh, dthresh     *Tensor
+	negtrain, postrain  Trainer
+	threshtrain         Trainer
+	l1, l2              float32
+}
+
+//CreateActivation creates an activation layer. coef is used for Leaky (negative slope), ClippedRelu (ceiling),
+//and Elu (alpha).  seed is used to init the coefs of PRelu and Threshhold.
+func CreateActivation(mode ActivationMode, coef float64, seed int64) (*Activation, error) {
+	var f ActivationMode
+	switch mode {
+	case f.Relu(), f.Leaky(), f.ClippedRelu(), f.Elu(), f.Sigmoid(), f.Tanh(), f.PRelu(), f.Threshhold(), f.Identity():
+	default:
+		return nil, errors.New("cpu.CreateActivation: Not supported Activation Layer")
+	}
+	return &Activation{
+		mode: mode,
+		coef: coef,
+		fwd:  defaultscalars,
+		bwd:  defaultscalars,
+		bwp:  defaultfilterscalars,
+		rng:  rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+//Relu returns a relu activation layer
+func Relu() *Activation {
+	var f ActivationMode
+	a, _ := CreateActivation(f.Relu(), 0, 0)
+	return a
+}
+
+//Leaky returns a leaky activation layer with a coef of .01
+func Leaky() *Activation {
+	var f ActivationMode
+	a, _ := CreateActivation(f.Leaky(), .01, 0)
+	return a
+}
+
+//ClippedRelu returns a clipped relu activation layer with a ceiling of 6
+func ClippedRelu() *Activation {
+	var f ActivationMode
+	a, _ := CreateActivation(f.ClippedRelu(), 6, 0)
+	return a
+}
+
+//Elu returns an elu activation layer with an alpha of 1
+func Elu() *Activation {
+	var f ActivationMode
+	a, _ := CreateActivation(f.Elu(), 1, 0)
+	return a
+}
+
+//Sigmoid returns a sigmoid activation layer
+func Sigmoid() *Activation {
+	var f ActivationMode
+	a, _ := CreateActivation(f.Sigmoid(), 0, 0)
+	return a
+}
+
+//Tanh returns a tanh activation layer
+func Tanh() *Activation {
+	var f ActivationMode
+	a, _ := CreateActivation(f.Tanh(), 0, 0)
+	return a
+}
+
+//Mode returns the mode of the activation
+func (a *Activation) Mode() ActivationMode { return a.mode }
+
+//GetOutputDims returns the dims of x
+func (a *Activation) GetOutputDims(x *Tensor) ([]int32, error) {
+	return x.Dims(), nil
+}
+
+func (a *Activation) buildcoefs(x *Tensor) (err error) {
+	var f ActivationMode
+	if a.mode != f.PRelu() && a.mode != f.Threshhold() {
+		return nil
+	}
+	adims := x.Dims()
+	adims[0] = 1
+	if a.negcoefs != nil {
+		if Volume(adims) != a.negcoefs.Vol() {
+			return errors.New("Threshhold and Prelu Function have set number of weights.  Not able to change have dynamic sizing input")
+		}
+		return nil
+	}
+	if a.negcoefs, err = CreateTensor(x.frmt, adims); err != nil {
+		return err
+	}
+	if a.dnegcoefs, err = CreateTensor(x.frmt, adims); err != nil {
+		return err
+	}
+	if a.mode == f.PRelu() {
+		a.negcoefs.SetValues(.01)
+		return nil
+	}
+	//Same ranges used by the builder for the gpu Threshhold
+	setuniform(a.rng, a.negcoefs.data, -.2, -.001)
+	if a.poscoefs, err = CreateTensor(x.frmt, adims); err != nil {
+		return err
+	}
+	if a.dposcoefs, err = CreateTensor(x.frmt, adims); err != nil {
+		return err
+	}
+	setuniform(a.rng, a.poscoefs.data, 1, 3)
+	if a.thresh, err = CreateTensor(x.frmt, adims); err != nil {
+		return err
+	}
+	if a.dthresh, err = CreateTensor(x.frmt, adims); err != nil {
+		return err
+	}
+	setuniform(a.rng, a.thresh.data, -2, 2)
+	return nil
+}
+
+func setuniform(rng *rand.Rand, x []float32, min, max float32) {
+	for i := range x {
+		x[i] = min + rng.Float32()*(max-min)
+	}
+}
+
+//NegCoefs returns the neg coefs for prelu or threshhold.  It is nil until Forward is called.
+func (a *Activation) NegCoefs() *Tensor { return a.negcoefs }
+
+//PosCoefs returns the pos coefs for threshhold. It is nil until Forward is called.
+func (a *Activation) PosCoefs() *Tensor { return a.poscoefs }
+
+//Threshhold returns the threshhold values for threshhold. It is nil until Forward is called.
+func (a *Activation) Threshhold() *Tensor { return a.thresh }
+
+func (a *Activation) op(x float32, i int) float32 {
+	var f ActivationMode
+	c := float32(a.coef)
+	switch a.mode {
+	case f.Relu():
+		if x > 0 {
+			return x
+		}
+		return 0
+	case f.Leaky():
+		if x > 0 {
+			return x
+		}
+		return c * x
+	case f.ClippedRelu():
+		if x <= 0 {
+			return 0
+		}
+		if x >= c {
+			return c
+		}
+		return x
+	case f.Elu():
+		if x > 0 {
+			return x
+		}
+		return c * float32(math.Expm1(float64(x)))
+	case f.Sigmoid():
+		return float32(1 / (1 + math.Exp(-float64(x))))
+	case f.Tanh():
+		return float32(math.Tanh(float64(x)))
+	case f.PRelu():
+		if x > 0 {
+			return x
+		}
+		return a.negcoefs.data[i] * x
+	case f.Threshhold():
+		if x > a.thresh.data[i] {
+			return a.poscoefs.data[i] * x
+		}
+		return a.negcoefs.data[i] * x
+	}
+	return x
+}
+
+//grad returns dy/dx with x being the input and y the output of the function
+func (a *Activation) grad(x, y float32, i int) float32 {
+	var f ActivationMode
+	c := float32(a.coef)
+	switch a.mode {
+	case f.Relu():
+		if x > 0 {
+			return 1
+		}
+		return 0
+	case f.Leaky():
+		if x > 0 {
+			return 1
+		}
+		return c
+	case f.ClippedRelu():
+		if x > 0 && x < c {
+			return 1
+		}
+		return 0
+	case f.Elu():
+		if x > 0 {
+			return 1
+		}
+		return y + c
+	case f.Sigmoid():
+		return y * (1 - y)
+	case f.Tanh():
+		return 1 - y*y
+	case f.PRelu():
+		if x > 0 {
+			return 1
+		}
+		return a.negcoefs.data[i]
+	case f.Threshhold():
+		if x > a.thresh.data[i] {
+			return a.poscoefs.data[i]
+		}
+		return a.negcoefs.data[i]
+	}
+	return 1
+}
+
+//Forward does y = alpha*act(x) + beta*y
+func (a *Activation) Forward(x, y *Tensor) error {
+	if len(x.data) != len(y.data) {
+		return errors.New("(a *Activation) Forward: x and y need to be the same size")
+	}
+	err := a.buildcoefs(x)
+	if err != nil {
+		return err
+	}
+	tmp := make([]float32, len(x.data))
+	cvol := 1
+	if a.negcoefs != nil {
+		cvol = len(a.negcoefs.data)
+	}
+	for i := range x.data {
+		tmp[i] = a.op(x.data[i], i%cvol)
+	}
+	blend(y.data, tmp, a.fwd.alpha, a.fwd.beta)
+	return nil
+}
+
+//Inference is the same as Forward
+func (a *Activation) Inference(x, y *Tensor) error {
+	return a.Forward(x, y)
+}
+
+//Backward does dx = alpha*(dy*act'(x)) + beta*dx.
+//y needs to hold the output of the last Forward.
+//If the activation has coefs their gradients are found as well.
+func (a *Activation) Backward(x, dx, y, dy *Tensor) error {
+	if len(x.data) != len(dx.data) || len(y.data) != len(dy.data) || len(x.data) != len(y.data) {
+		return errors.New("(a *Activation) Backward: x, dx, y and dy need to be the same size")
+	}
+	var f ActivationMode
+	err := a.buildcoefs(x)
+	if err != nil {
+		return err
+	}
+	cvol := 1
+	if a.negcoefs != nil {
+		cvol = len(a.negcoefs.data)
+	}
+	tmp := make([]float32, len(x.data))
+	for i := range x.data {
+		tmp[i] = dy.data[i] * a.grad(x.data[i], y.data[i], i%cvol)
+	}
+	switch a.mode {
+	case f.PRelu():
+		dneg := make([]float32, cvol)
+		for i := range x.data {
+			if x.data[i] <= 0 {
+				dneg[i%cvol] += dy.data[i] * x.data[i]
+			}
+		}
+		blend(a.dnegcoefs.data, dneg, a.bwp.alpha, a.bwp.beta)
+	case f.Threshhold():
+		dneg := make([]float32, cvol)
+		dpos := make([]float32, cvol)
+		//the threshhold isn't differentiable so its delta stays zero. It only moves with the trainer's decay.
+		dthresh := make([]float32, cvol)
+		for i := range x.data {
+			j := i % cvol
+			if x.data[i] > a.thresh.data[j] {
+				dpos[j] += dy.data[i] * x.data[i]
+			} else {
+				dneg[j] += dy.data[i] * x.data[i]
+			}
+		}
+		blend(a.dnegcoefs.data, dneg, a.bwp.alpha, a.bwp.beta)
+		blend(a.dposcoefs.data, dpos, a.bwp.alpha, a.bwp.beta)
+		blend(a.dthresh.data, dthresh, a.bwp.alpha, a.bwp.beta)
+	}
+	if dx == nil {
+		return nil
+	}
+	blend(dx.data, tmp, a.bwd.alpha, a.bwd.beta)
+	return nil
+}
+
+//TrainersNeeded returns the number of trainers needed. 1 for PRelu, 3 for Threshhold (neg,pos,thresh), and 0 for the rest.
+func (a *Activation) TrainersNeeded() int {
+	var f ActivationMode
+	switch a.mode {
+	case f.PRelu():
+		return 1
+	case f.Threshhold():
+		return 3
+	}
+	return 0
+}
+
+//LoadTrainers loads the trainers in the order of neg coefs, pos coefs, and threshhold.
+func (a *Activation) LoadTrainers(trainers ...Trainer) error {
+	if len(trainers) != a.TrainersNeeded() {
+		return errors.New("(a *Activation) LoadTrainers: wrong number of trainers")
+	}
+	switch len(trainers) {
+	case 1:
+		a.negtrain = trainers[0]
+	case 3:
+		a.negtrain, a.postrain, a.threshtrain = trainers[0], trainers[1], trainers[2]
+	}
+	return nil
+}
+
+//UpdateWeights updates the coefs if the activation has them.
+func (a *Activation) UpdateWeights(batch, counter int) error {
+	if a.TrainersNeeded() == 0 || a.negcoefs == nil {
+		return nil
+	}
+	if a.negtrain == nil {
+		return errors.New("(a *Activation) UpdateWeights: trainers not loaded")
+	}
+	err := a.negtrain.UpdateWeights(a.dnegcoefs, a.negcoefs, batch, counter)
+	if err != nil {
+		return err
+	}
+	a.l1, a.l2 = a.negtrain.L1L2Loss()
+	if a.postrain == nil {
+		return nil
+	}
+	err = a.postrain.UpdateWeights(a.dposcoefs, a.poscoefs, batch, counter)
+	if err != nil {
+		return err
+	}
+	l1, l2 := a.postrain.L1L2Loss()
+	a.l1, a.l2 = a.l1+l1, a.l2+l2
+	err = a.threshtrain.UpdateWeights(a.dthresh, a.thresh, batch, counter)
+	if err != nil {
+		return err
+	}
+	l1, l2 = a.threshtrain.L1L2Loss()
+	a.l1, a.l2 = a.l1+l1, a.l2+l2
+	return nil
+}
+
+//L1L2Loss returns the l1 and l2 loss of the coefs from the last update
+func (a *Activation) L1L2Loss() (l1, l2 float32) { return a.l1, a.l2 }
+
+//SetForwardScalars sets the forward scalars.  Default is alpha 1, beta 0.
+func (a *Activation) SetForwardScalars(alpha, beta float64) { a.fwd = scalars{alpha, beta} }
+
+//SetBackwardScalars sets the backward scalars.  Default is alpha 1, beta 0.
+func (a *Activation) SetBackwardScalars(alpha, beta float64) { a.bwd = scalars{alpha, beta} }
+
+//SetOtherScalars sets the scalars for the coef gradients.  Default is alpha 1, beta 1.
+func (a *Activation) SetOtherScalars(alpha, beta float64) { a.bwp = scalars{alpha, beta} }
@@ -0,0 +1,289 @@
+package cpu
+
+import (
+	"errors"
+	"math"
+)
+
+//BatchNormMode is the flag for the batch norm mode
+type BatchNormMode int32
+
+//PerActivation sets and returns the PerActivation flag. Stats are found for each c,h,w over the batch.
+func (b *BatchNormMode) PerActivation() BatchNormMode { *b = BatchNormMode(0); return *b }
+
+//Spatial sets and returns the Spatial flag. Stats are found for each channel over n,h,w.
+func (b *BatchNormMode) Spatial() BatchNormMode { *b = BatchNormMode(1); return *b }
+
+//BatchNorm is a batch norm layer that runs on the cpu.
+//The scale, bias, and running stats are made the first time Forward is called.
+//Like the gpu layer the running stats use an averaging factor of 1/(1+counter) with the counter capped at 128.
+type BatchNorm struct {
+	mode                  BatchNormMode
+	eps                   float64
+	counter, countermax   uint64
+	fw, bwd, bwp          scalars
+	scale, dscale         *Tensor
+	bias, dbias           *Tensor
+	rmean, rvar           *Tensor
+	scaletrain, biastrain Trainer
+}
+
+//CreateBatchNorm creates a batch norm layer with an eps of 1e-5
+func CreateBatchNorm(mode BatchNormMode) (*BatchNorm, error) {
+	var f BatchNormMode
+	switch mode {
+	case f.PerActivation(), f.Spatial():
+	default:
+		return nil, errors.New("cpu.CreateBatchNorm: unsupported mode")
+	}
+	return &BatchNorm{
+		mode:       mode,
+		eps:        1e-5,
+		countermax: 128,
+		fw:         defaultscalars,
+		bwd:        defaultscalars,
+		bwp:        defaultfilterscalars,
+	}, nil
+}
+
+//SetEps sets epsilon. It can't be less than 1e-5
+func (b *BatchNorm) SetEps(eps float64) {
+	if eps >= float64(1e-5) {
+		b.eps = eps
+	}
+}
+
+//GetOutputDims returns the dims of x
+func (b *BatchNorm) GetOutputDims(x *Tensor) ([]int32, error) {
+	return x.Dims(), nil
+}
+
+//Scale returns the scale.  It is nil until Forward is called.
+func (b *BatchNorm) Scale() *Tensor { return b.scale }
+
+//Bias returns the bias.  It is nil until Forward is called.
+func (b *BatchNorm) Bias() *Tensor { return b.bias }
+
+//RunningMean returns the running mean.  It is nil until Forward is called.
+func (b *BatchNorm) RunningMean() *Tensor { return b.rmean }
+
+//RunningVariance returns the running variance.  It is nil until Forward is called.
+func (b *BatchNorm) RunningVariance() *Tensor { return b.rvar }
+
+//groups returns the number of groups that the stats are found for and a function that returns the group of element i.
+func (b *BatchNorm) groups(x *Tensor) (int, func(i int) int) {
+	var f BatchNormMode
+	if b.mode == f.Spatial() {
+		return x.channelinfo()
+	}
+	per := len(x.data) / int(x.dims[0])
+	return per, func(i int) int { return i % per }
+}
+
+func (b *BatchNorm) paramdims(x *Tensor) []int32 {
+	var f BatchNormMode
+	var flg TensorFormat
+	dims := make([]int32, len(x.dims))
+	for i := range dims {
+		dims[i] = 1
+	}
+	if b.mode == f.PerActivation() {
+		copy(dims[1:], x.dims[1:])
+		return dims
+	}
+	if x.frmt == flg.NHWC() {
+		dims[len(dims)-1] = x.dims[len(dims)-1]
+	} else {
+		dims[1] = x.dims[1]
+	}
+	return dims
+}
+
+func (b *BatchNorm) setup(x *Tensor) (err error) {
+	if len(x.dims) < 2 {
+		return errors.New("(b *BatchNorm) setup: x needs at least 2 dims")
+	}
+	pdims := b.paramdims(x)
+	if b.scale != nil {
+		if !comparedims(pdims, b.scale.dims) {
+			return errors.New("(b *BatchNorm) setup: x dims changed since the layer was setup")
+		}
+		return nil
+	}
+	if b.scale, err = CreateTensor(x.frmt, pdims); err != nil {
+		return err
+	}
+	b.scale.SetValues(1)
+	if b.dscale, err = CreateTensor(x.frmt, pdims); err != nil {
+		return err
+	}
+	if b.bias, err = CreateTensor(x.frmt, pdims); err != nil {
+		return err
+	}
+	if b.dbias, err = CreateTensor(x.frmt, pdims); err != nil {
+		return err
+	}
+	if b.rmean, err = CreateTensor(x.frmt, pdims); err != nil {
+		return err
+	}
+	if b.rvar, err = CreateTensor(x.frmt, pdims); err != nil {
+		return err
+	}
+	b.rvar.SetValues(1)
+	return nil
+}
+
+//stats returns the batch mean and biased variance of each group and the number of elements in each group.
+func (b *BatchNorm) stats(x *Tensor) (mean, variance []float64, count int) {
+	ngroups, grp := b.groups(x)
+	mean = make([]float64, ngroups)
+	variance = make([]float64, ngroups)
+	count = len(x.data) / ngroups
+	for i, v := range x.data {
+		mean[grp(i)] += float64(v)
+	}
+	for j := range mean {
+		mean[j] /= float64(count)
+	}
+	for i, v := range x.data {
+		d := float64(v) - mean[grp(i)]
+		variance[grp(i)] += d * d
+	}
+	for j := range variance {
+		variance[j] /= float64(count)
+	}
+	return mean, variance, count
+}
+
+//Forward does the training forward.  The batch stats are used and the running stats are updated.
+func (b *BatchNorm) Forward(x, y *Tensor) error {
+	if len(x.data) != len(y.data) {
+		return errors.New("(b *BatchNorm) Forward: x and y need to be the same size")
+	}
+	err := b.setup(x)
+	if err != nil {
+		return err
+	}
+	mean, variance, count := b.stats(x)
+	_, grp := b.groups(x)
+	tmp := make([]float32, len(x.data))
+	for i, v := range x.data {
+		j := grp(i)
+		xhat := (float64(v) - mean[j]) / math.Sqrt(variance[j]+b.eps)
+		tmp[i] = float32(xhat)*b.scale.data[j] + b.bias.data[j]
+	}
+	blend(y.data, tmp, b.fw.alpha, b.fw.beta)
+	af := 1.0 / (1.0 + float64(b.counter))
+	unbias := 1.0
+	if count > 1 {
+		unbias = float64(count) / float64(count-1)
+	}
+	for j := range mean {
+		b.rmean.data[j] = float32((1-af)*float64(b.rmean.data[j]) + af*mean[j])
+		b.rvar.data[j] = float32((1-af)*float64(b.rvar.data[j]) + af*variance[j]*unbias)
+	}
+	if b.counter < b.countermax {
+		b.counter++
+	}
+	return nil
+}
+
+//Inference uses the running stats found during training
+func (b *BatchNorm) Inference(x, y *Tensor) error {
+	if len(x.data) != len(y.data) {
+		return errors.New("(b *BatchNorm) Inference: x and y need to be the same size")
+	}
+	err := b.setup(x)
+	if err != nil {
+		return err
+	}
+	_, grp := b.groups(x)
+	tmp := make([]float32, len(x.data))
+	for i, v := range x.data {
+		j := grp(i)
+		xhat := (float64(v) - float64(b.rmean.data[j])) / math.Sqrt(float64(b.rvar.data[j])+b.eps)
+		tmp[i] = float32(xhat)*b.scale.data[j] + b.bias.data[j]
+	}
+	blend(y.data, tmp, b.fw.alpha, b.fw.beta)
+	return nil
+}
+
+//Backward finds dx, dscale, and dbias using the batch stats of x.
+func (b *BatchNorm) Backward(x, dx, y, dy *Tensor) error {
+	if len(x.data) != len(dy.data) {
+		return errors.New("(b *BatchNorm) Backward: x and dy need to be the same size")
+	}
+	err := b.setup(x)
+	if err != nil {
+		return err
+	}
+	mean, variance, count := b.stats(x)
+	ngroups, grp := b.groups(x)
+	sumdy := make([]float64, ngroups)
+	sumdyxhat := make([]float64, ngroups)
+	xhat := make([]float64, len(x.data))
+	for i, v := range x.data {
+		j := grp(i)
+		xhat[i] = (float64(v) - mean[j]) / math.Sqrt(variance[j]+b.eps)
+		sumdy[j] += float64(dy.data[i])
+		sumdyxhat[j] += float64(dy.data[i]) * xhat[i]
+	}
+	tmps := make([]float32, ngroups)
+	tmpb := make([]float32, ngroups)
+	for j := range tmps {
+		tmps[j] = float32(sumdyxhat[j])
+		tmpb[j] = float32(sumdy[j])
+	}
+	blend(b.dscale.data, tmps, b.bwp.alpha, b.bwp.beta)
+	blend(b.dbias.data, tmpb, b.bwp.alpha, b.bwp.beta)
+	if dx == nil {
+		return nil
+	}
+	m := float64(count)
+	tmp := make([]float32, len(x.data))
+	for i := range x.data {
+		j := grp(i)
+		invstd := 1 / math.Sqrt(variance[j]+b.eps)
+		tmp[i] = float32(float64(b.scale.data[j]) * invstd / m * (m*float64(dy.data[i]) - sumdy[j] - xhat[i]*sumdyxhat[j]))
+	}
+	blend(dx.data, tmp, b.bwd.alpha, b.bwd.beta)
+	return nil
+}
+
+//TrainersNeeded returns 2
+func (b *BatchNorm) TrainersNeeded() int { return 2 }
+
+//LoadTrainers loads the scale trainer and then the bias trainer.  Like the gpu layer the decays are set to zero.
+func (b *BatchNorm) LoadTrainers(trainers ...Trainer) error {
+	if len(trainers) != 2 {
+		return errors.New("(b *BatchNorm) LoadTrainers: needs 2 trainers")
+	}
+	b.scaletrain, b.biastrain = trainers[0], trainers[1]
+	b.scaletrain.SetDecays(0, 0)
+	b.biastrain.SetDecays(0, 0)
+	return nil
+}
+
+//UpdateWeights updates the scale and bias
+func (b *BatchNorm) UpdateWeights(batch, counter int) error {
+	if b.scale == nil {
+		return nil
+	}
+	if b.scaletrain == nil || b.biastrain == nil {
+		return errors.New("(b *BatchNorm) UpdateWeights: trainers not loaded")
+	}
+	err := b.biastrain.UpdateWeights(b.dbias, b.bias, batch, counter)
+	if err != nil {
+		return err
+	}
+	return b.scaletrain.UpdateWeights(b.dscale, b.scale, batch, counter)
+}
+
+//SetForwardScalars sets the forward scalars.  Default is alpha 1, beta 0.
+func (b *BatchNorm) SetForwardScalars(alpha, beta float64) { b.fw = scalars{alpha, beta} }
+
+//SetBackwardScalars sets the backward data scalars.  Default is alpha 1, beta 0.
+func (b *BatchNorm) SetBackwardScalars(alpha, beta float64) { b.bwd = scalars{alpha, beta} }
+
+//SetOtherScalars sets the scalars for dscale and dbias.  Default is alpha 1, beta 1.
+func (b *BatchNorm) SetOtherScalars(alpha, beta float64) { b.bwp = scalars{alpha, beta} }
@@ -1 +1,281 @@
 package cpu
+
+import (
+	"errors"
+)
+
+//convgeom holds the geometry of a 2d convolution.
+type convgeom struct {
+	pad, stride, dilation [2]int
+	groups                int
+}
+
+func makeconvgeom(groupcount int32, pad, stride, dilation []int32) (convgeom, error) {
+	var g convgeom
+	if len(pad) != 2 || len(stride) != 2 || len(dilation) != 2 {
+		return g, errors.New("cpu: pad, stride, and dilation need to have a length of 2")
+	}
+	if groupcount < 1 {
+		groupcount = 1
+	}
+	g.groups = int(groupcount)
+	for i := 0; i < 2; i++ {
+		if stride[i] < 1 || dilation[i] < 1 || pad[i] < 0 {
+			return g, errors.New("cpu: stride and dilation need to be > 0 and pad >= 0")
+		}
+		g.pad[i], g.stride[i], g.dilation[i] = int(pad[i]), int(stride[i]), int(dilation[i])
+	}
+	return g, nil
+}
+
+//outdim is the same formula found in dimoutput used by the modules
+func (g convgeom) outdim(i, f, dim int) int {
+	return 1 + (i+2*g.pad[dim]-((f-1)*g.dilation[dim]+1))/g.stride[dim]
+}
+
+//reversedim is the output dim of a transposed convolution
+func (g convgeom) reversedim(i, f, dim int) int {
+	return (i-1)*g.stride[dim] - 2*g.pad[dim] + (f-1)*g.dilation[dim] + 1
+}
+
+//each calls fn for every x, w, y index triple that contributes to a convolution where y is the output.
+//x and y are 4d tensors, w is a filter with dims KCRS (NCHW) or KRSC (NHWC) where C is the input channels per group.
+func (g convgeom) each(x, w, y *Tensor, fn func(xi, wi, yi int)) error {
+	xd, xs, err := x.shape4d()
+	if err != nil {
+		return err
+	}
+	wd, ws, err := w.shape4d()
+	if err != nil {
+		return err
+	}
+	yd, ys, err := y.shape4d()
+	if err != nil {
+		return err
+	}
+	if xd[1] != wd[1]*g.groups || yd[1] != wd[0] || wd[0]%g.groups != 0 || xd[0] != yd[0] {
+		return errors.New("cpu: convolution channel dims don't match")
+	}
+	if yd[2] != g.outdim(xd[2], wd[2], 0) || yd[3] != g.outdim(xd[3], wd[3], 1) {
+		return errors.New("cpu: convolution spacial dims don't match")
+	}
+	kpergroup := wd[0] / g.groups
+	for n := 0; n < yd[0]; n++ {
+		for k := 0; k < yd[1]; k++ {
+			cstart := (k / kpergroup) * wd[1]
+			for oh := 0; oh < yd[2]; oh++ {
+				for ow := 0; ow < yd[3]; ow++ {
+					yi := n*ys[0] + k*ys[1] + oh*ys[2] + ow*ys[3]
+					for c := 0; c < wd[1]; c++ {
+						for r := 0; r < wd[2]; r++ {
+							ih := oh*g.stride[0] - g.pad[0] + r*g.dilation[0]
+							if ih < 0 || ih >= xd[2] {
+								continue
+							}
+							for s := 0; s < wd[3]; s++ {
+								iw := ow*g.stride[1] - g.pad[1] + s*g.dilation[1]
+								if iw < 0 || iw >= xd[3] {
+									continue
+								}
+								fn(n*xs[0]+(cstart+c)*xs[1]+ih*xs[2]+iw*xs[3],
+									k*ws[0]+c*ws[1]+r*ws[2]+s*ws[3],
+									yi)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//addbias adds the per channel bias to y
+func addbias(b, y []float32, ychan func(i int) int) {
+	for i := range y {
+		y[i] += b[ychan(i)]
+	}
+}
+
+//sumperchannel sums dy for each channel into db
+func sumperchannel(dy, db []float32, ychan func(i int) int) {
+	for i := range dy {
+		db[ychan(i)] += dy[i]
+	}
+}
+
+//Convolution is a convolution layer that runs on the cpu.
+type Convolution struct {
+	geom            convgeom
+	w, dw, b, db    *Tensor
+	fwd, bwdd, bwdf scalars
+	wtrain, btrain  Trainer
+	l1w, l2w        float32
+	l1b, l2b        float32
+}
+
+//CreateConvolution creates a convolution layer.  w and dw need to have the same dims,
+//and the format of w needs to match the format of the tensors passed to it.
+//b and db need to have a channel dim equal to the number of output channels.
+func CreateConvolution(groupcount int32, w, dw, b, db *Tensor, pad, stride, dilation []int32) (*Convolution, error) {
+	if w == nil || dw == nil || b == nil || db == nil {
+		return nil, errors.New("cpu.CreateConvolution: w, dw, b, and db need to be set")
+	}
+	if !comparedims(w.dims, dw.dims) || !comparedims(b.dims, db.dims) {
+		return nil, errors.New("cpu.CreateConvolution: weights and delta weights need the same dims")
+	}
+	geom, err := makeconvgeom(groupcount, pad, stride, dilation)
+	if err != nil {
+		return nil, err
+	}
+	if b.Vol() != w.dims[0] {
+		return nil, errors.New("cpu.CreateConvolution: bias volume needs to equal the number of output channels")
+	}
+	return &Convolution{
+		geom: geom,
+		w:    w,
+		dw:   dw,
+		b:    b,
+		db:   db,
+		fwd:  defaultscalars,
+		bwdd: defaultscalars,
+		bwdf: defaultfilterscalars,
+	}, nil
+}
+
+//GetOutputDims returns the output dims of the convolution
+func (c *Convolution) GetOutputDims(x *Tensor) ([]int32, error) {
+	xd, _, err := x.shape4d()
+	if err != nil {
+		return nil, err
+	}
+	wd, _, err := c.w.shape4d()
+	if err != nil {
+		return nil, err
+	}
+	if x.frmt != c.w.frmt {
+		return nil, errors.New("(c *Convolution) GetOutputDims: x and w formats don't match")
+	}
+	h := int32(c.geom.outdim(xd[2], wd[2], 0))
+	w := int32(c.geom.outdim(xd[3], wd[3], 1))
+	if h < 1 || w < 1 {
+		return nil, errors.New("(c *Convolution) GetOutputDims: output spacial dims would be less than 1")
+	}
+	var flg TensorFormat
+	if x.frmt == flg.NHWC() {
+		return []int32{x.dims[0], h, w, c.w.dims[0]}, nil
+	}
+	return []int32{x.dims[0], c.w.dims[0], h, w}, nil
+}
+
+//Weights returns the weights and bias
+func (c *Convolution) Weights() (w, b *Tensor) { return c.w, c.b }
+
+//DeltaWeights returns the delta weights and delta bias
+func (c *Convolution) DeltaWeights() (dw, db *Tensor) { return c.dw, c.db }
+
+//Forward does the forward propagation y = alpha*(conv(x,w)+b) + beta*y
+func (c *Convolution) Forward(x, y *Tensor) error {
+	tmp := make([]float32, len(y.data))
+	err := c.geom.each(x, c.w, y, func(xi, wi, yi int) {
+		tmp[yi] += x.data[xi] * c.w.data[wi]
+	})
+	if err != nil {
+		return err
+	}
+	_, ychan := y.channelinfo()
+	addbias(c.b.data, tmp, ychan)
+	blend(y.data, tmp, c.fwd.alpha, c.fwd.beta)
+	return nil
+}
+
+//Inference is the same as Forward
+func (c *Convolution) Inference(x, y *Tensor) error {
+	return c.Forward(x, y)
+}
+
+//BackwardData does dx = alpha * convbackwarddata(w,dy) + beta*dx
+func (c *Convolution) BackwardData(dx, dy *Tensor) error {
+	tmp := make([]float32, len(dx.data))
+	err := c.geom.each(dx, c.w, dy, func(xi, wi, yi int) {
+		tmp[xi] += dy.data[yi] * c.w.data[wi]
+	})
+	if err != nil {
+		return err
+	}
+	blend(dx.data, tmp, c.bwdd.alpha, c.bwdd.beta)
+	return nil
+}
+
+//BackwardFilter does dw = alpha*convbackwardfilter(x,dy) + beta*dw and the same with db
+func (c *Convolution) BackwardFilter(x, dy *Tensor) error {
+	tmp := make([]float32, len(c.dw.data))
+	err := c.geom.each(x, c.dw, dy, func(xi, wi, yi int) {
+		tmp[wi] += dy.data[yi] * x.data[xi]
+	})
+	if err != nil {
+		return err
+	}
+	blend(c.dw.data, tmp, c.bwdf.alpha, c.bwdf.beta)
+	tmpb := make([]float32, len(c.db.data))
+	_, ychan := dy.channelinfo()
+	sumperchannel(dy.data, tmpb, ychan)
+	blend(c.db.data, tmpb, c.bwdf.alpha, c.bwdf.beta)
+	return nil
+}
+
+//Backward does the backward filter and then the backward data. If dx is nil only the backward filter is done.
+func (c *Convolution) Backward(x, dx, y, dy *Tensor) error {
+	err := c.BackwardFilter(x, dy)
+	if err != nil {
+		return err
+	}
+	if dx == nil {
+		return nil
+	}
+	return c.BackwardData(dx, dy)
+}
+
+//LoadTrainers loads the weight trainer and then the bias trainer
+func (c *Convolution) LoadTrainers(trainers ...Trainer) error {
+	if len(trainers) != 2 {
+		return errors.New("(c *Convolution) LoadTrainers: needs 2 trainers")
+	}
+	c.wtrain, c.btrain = trainers[0], trainers[1]
+	return nil
+}
+
+//TrainersNeeded returns 2
+func (c *Convolution) TrainersNeeded() int { return 2 }
+
+//UpdateWeights updates the weights and bias with the trainers loaded
+func (c *Convolution) UpdateWeights(batch, counter int) error {
+	if c.wtrain == nil || c.btrain == nil {
+		return errors.New("(c *Convolution) UpdateWeights: trainers not loaded")
+	}
+	err := c.wtrain.UpdateWeights(c.dw, c.w, batch, counter)
+	if err != nil {
+		return err
+	}
+	c.l1w, c.l2w = c.wtrain.L1L2Loss()
+	err = c.btrain.UpdateWeights(c.db, c.b, batch, counter)
+	if err != nil {
+		return err
+	}
+	c.l1b, c.l2b = c.btrain.L1L2Loss()
+	return nil
+}
+
+//L1L2Loss returns the combined l1 and l2 loss of the weights and bias from the last update
+func (c *Convolution) L1L2Loss() (l1, l2 float32) {
+	return c.l1w + c.l1b, c.l2w + c.l2b
+}
+
+//SetForwardScalars sets the forward scalars.  Default is alpha 1, beta 0.
+func (c *Convolution) SetForwardScalars(alpha, beta float64) { c.fwd = scalars{alpha, beta} }
+
+//SetBackwardScalars sets the backward data scalars.  Default is alpha 1, beta 0.
+func (c *Convolution) SetBackwardScalars(alpha, beta float64) { c.bwdd = scalars{alpha, beta} }
+
+//SetOtherScalars sets the backward filter scalars.  Default is alpha 1, beta 1.
+func (c *Convolution) SetOtherScalars(alpha, beta float64) { c.bwdf = scalars{alpha, beta} }
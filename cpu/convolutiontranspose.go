@@ -0,0 +1,188 @@
+package cpu
+
+import (
+	"errors"
+)
+
+//ConvolutionTranspose is a transposed (reverse) convolution layer that runs on the cpu.
+//The forward pass is the backward data of a convolution, and the backward data is the forward of a convolution.
+//
+//Weights are CKRS (NCHW) or CRSK (NHWC), where C is the input channels and K is the output channels per group.
+type ConvolutionTranspose struct {
+	geom            convgeom
+	w, dw, b, db    *Tensor
+	fwd, bwdd, bwdf scalars
+	wtrain, btrain  Trainer
+	l1w, l2w        float32
+	l1b, l2b        float32
+}
+
+//CreateConvolutionTranspose creates a transposed convolution layer.  b and db need a volume equal to the output channels.
+func CreateConvolutionTranspose(groupcount int32, w, dw, b, db *Tensor, pad, stride, dilation []int32) (*ConvolutionTranspose, error) {
+	if w == nil || dw == nil || b == nil || db == nil {
+		return nil, errors.New("cpu.CreateConvolutionTranspose: w, dw, b, and db need to be set")
+	}
+	if !comparedims(w.dims, dw.dims) || !comparedims(b.dims, db.dims) {
+		return nil, errors.New("cpu.CreateConvolutionTranspose: weights and delta weights need the same dims")
+	}
+	geom, err := makeconvgeom(groupcount, pad, stride, dilation)
+	if err != nil {
+		return nil, err
+	}
+	wd, _, err := w.shape4d()
+	if err != nil {
+		return nil, err
+	}
+	if int(b.Vol()) != wd[1]*geom.groups {
+		return nil, errors.New("cpu.CreateConvolutionTranspose: bias volume needs to equal the number of output channels")
+	}
+	return &ConvolutionTranspose{
+		geom: geom,
+		w:    w,
+		dw:   dw,
+		b:    b,
+		db:   db,
+		fwd:  defaultscalars,
+		bwdd: defaultscalars,
+		bwdf: defaultfilterscalars,
+	}, nil
+}
+
+//GetOutputDims returns the output dims of the transposed convolution
+func (c *ConvolutionTranspose) GetOutputDims(x *Tensor) ([]int32, error) {
+	xd, _, err := x.shape4d()
+	if err != nil {
+		return nil, err
+	}
+	wd, _, err := c.w.shape4d()
+	if err != nil {
+		return nil, err
+	}
+	if x.frmt != c.w.frmt {
+		return nil, errors.New("(c *ConvolutionTranspose) GetOutputDims: x and w formats don't match")
+	}
+	h := int32(c.geom.reversedim(xd[2], wd[2], 0))
+	w := int32(c.geom.reversedim(xd[3], wd[3], 1))
+	if h < 1 || w < 1 {
+		return nil, errors.New("(c *ConvolutionTranspose) GetOutputDims: output spacial dims would be less than 1")
+	}
+	k := int32(wd[1] * c.geom.groups)
+	var flg TensorFormat
+	if x.frmt == flg.NHWC() {
+		return []int32{x.dims[0], h, w, k}, nil
+	}
+	return []int32{x.dims[0], k, h, w}, nil
+}
+
+//Weights returns the weights and bias
+func (c *ConvolutionTranspose) Weights() (w, b *Tensor) { return c.w, c.b }
+
+//DeltaWeights returns the delta weights and delta bias
+func (c *ConvolutionTranspose) DeltaWeights() (dw, db *Tensor) { return c.dw, c.db }
+
+//Forward does y = alpha*(convtranspose(x,w)+b) + beta*y
+func (c *ConvolutionTranspose) Forward(x, y *Tensor) error {
+	tmp := make([]float32, len(y.data))
+	//y takes the place of x in the convolution and x takes the place of y.
+	err := c.geom.each(y, c.w, x, func(yi, wi, xi int) {
+		tmp[yi] += x.data[xi] * c.w.data[wi]
+	})
+	if err != nil {
+		return err
+	}
+	_, ychan := y.channelinfo()
+	addbias(c.b.data, tmp, ychan)
+	blend(y.data, tmp, c.fwd.alpha, c.fwd.beta)
+	return nil
+}
+
+//Inference is the same as Forward
+func (c *ConvolutionTranspose) Inference(x, y *Tensor) error {
+	return c.Forward(x, y)
+}
+
+//BackwardData does dx = alpha*conv(dy,w) + beta*dx
+func (c *ConvolutionTranspose) BackwardData(dx, dy *Tensor) error {
+	tmp := make([]float32, len(dx.data))
+	err := c.geom.each(dy, c.w, dx, func(yi, wi, xi int) {
+		tmp[xi] += dy.data[yi] * c.w.data[wi]
+	})
+	if err != nil {
+		return err
+	}
+	blend(dx.data, tmp, c.bwdd.alpha, c.bwdd.beta)
+	return nil
+}
+
+//BackwardFilter does dw = alpha*backwardfilter(x,dy) + beta*dw and the same with db
+func (c *ConvolutionTranspose) BackwardFilter(x, dy *Tensor) error {
+	tmp := make([]float32, len(c.dw.data))
+	err := c.geom.each(dy, c.dw, x, func(yi, wi, xi int) {
+		tmp[wi] += dy.data[yi] * x.data[xi]
+	})
+	if err != nil {
+		return err
+	}
+	blend(c.dw.data, tmp, c.bwdf.alpha, c.bwdf.beta)
+	tmpb := make([]float32, len(c.db.data))
+	_, ychan := dy.channelinfo()
+	sumperchannel(dy.data, tmpb, ychan)
+	blend(c.db.data, tmpb, c.bwdf.alpha, c.bwdf.beta)
+	return nil
+}
+
+//Backward does the backward filter and then the backward data. If dx is nil only the backward filter is done.
+func (c *ConvolutionTranspose) Backward(x, dx, y, dy *Tensor) error {
+	err := c.BackwardFilter(x, dy)
+	if err != nil {
+		return err
+	}
+	if dx == nil {
+		return nil
+	}
+	return c.BackwardData(dx, dy)
+}
+
+//LoadTrainers loads the weight trainer and then the bias trainer
+func (c *ConvolutionTranspose) LoadTrainers(trainers ...Trainer) error {
+	if len(trainers) != 2 {
+		return errors.New("(c *ConvolutionTranspose) LoadTrainers: needs 2 trainers")
+	}
+	c.wtrain, c.btrain = trainers[0], trainers[1]
+	return nil
+}
+
+//TrainersNeeded returns 2
+func (c *ConvolutionTranspose) TrainersNeeded() int { return 2 }
+
+//UpdateWeights updates the weights and bias with the trainers loaded
+func (c *ConvolutionTranspose) UpdateWeights(batch, counter int) error {
+	if c.wtrain == nil || c.btrain == nil {
+		return errors.New("(c *ConvolutionTranspose) UpdateWeights: trainers not loaded")
+	}
+	err := c.wtrain.UpdateWeights(c.dw, c.w, batch, counter)
+	if err != nil {
+		return err
+	}
+	c.l1w, c.l2w = c.wtrain.L1L2Loss()
+	err = c.btrain.UpdateWeights(c.db, c.b, batch, counter)
+	if err != nil {
+		return err
+	}
+	c.l1b, c.l2b = c.btrain.L1L2Loss()
+	return nil
+}
+
+//L1L2Loss returns the combined l1 and l2 loss of the weights and bias from the last update
+func (c *ConvolutionTranspose) L1L2Loss() (l1, l2 float32) {
+	return c.l1w + c.l1b, c.l2w + c.l2b
+}
+
+//SetForwardScalars sets the forward scalars.  Default is alpha 1, beta 0.
+func (c *ConvolutionTranspose) SetForwardScalars(alpha, beta float64) { c.fwd = scalars{alpha, beta} }
+
+//SetBackwardScalars sets the backward data scalars.  Default is alpha 1, beta 0.
+func (c *ConvolutionTranspose) SetBackwardScalars(alpha, beta float64) { c.bwdd = scalars{alpha, beta} }
+
+//SetOtherScalars sets the backward filter scalars.  Default is alpha 1, beta 1.
+func (c *ConvolutionTranspose) SetOtherScalars(alpha, beta float64) { c.bwdf = scalars{alpha, beta} }
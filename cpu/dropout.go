@@ -0,0 +1,87 @@
+package cpu
+
+import (
+	"errors"
+	"math/rand"
+)
+
+//Dropout is a dropout layer that runs on the cpu.
+//Kept values are scaled by 1/(1-dropout) in Forward so Inference just passes x through.
+type Dropout struct {
+	nontrainable
+	dropout  float32
+	rng      *rand.Rand
+	mask     []float32
+	fwd, bwd scalars
+}
+
+//CreateDropout creates a dropout layer. The mask is made from a rng seeded with seed.
+func CreateDropout(dropout float32, seed int64) (*Dropout, error) {
+	if dropout >= 1 || dropout < 0 {
+		return nil, errors.New("Dropout can't be greater than or equal to 1 or less than 0")
+	}
+	return &Dropout{
+		dropout: dropout,
+		rng:     rand.New(rand.NewSource(seed)),
+		fwd:     defaultscalars,
+		bwd:     defaultscalars,
+	}, nil
+}
+
+//GetOutputDims returns the dims of x
+func (d *Dropout) GetOutputDims(x *Tensor) ([]int32, error) {
+	return x.Dims(), nil
+}
+
+//Forward makes a new mask and does y = alpha*(x*mask) + beta*y
+func (d *Dropout) Forward(x, y *Tensor) error {
+	if len(x.data) != len(y.data) {
+		return errors.New("(d *Dropout) Forward: x and y need to be the same size")
+	}
+	if len(d.mask) != len(x.data) {
+		d.mask = make([]float32, len(x.data))
+	}
+	keep := 1 / (1 - d.dropout)
+	tmp := make([]float32, len(x.data))
+	for i := range d.mask {
+		if d.rng.Float32() < d.dropout {
+			d.mask[i] = 0
+		} else {
+			d.mask[i] = keep
+		}
+		tmp[i] = x.data[i] * d.mask[i]
+	}
+	blend(y.data, tmp, d.fwd.alpha, d.fwd.beta)
+	return nil
+}
+
+//Inference does y = alpha*x + beta*y
+func (d *Dropout) Inference(x, y *Tensor) error {
+	if len(x.data) != len(y.data) {
+		return errors.New("(d *Dropout) Inference: x and y need to be the same size")
+	}
+	blend(y.data, x.data, d.fwd.alpha, d.fwd.beta)
+	return nil
+}
+
+//Backward uses the mask of the last Forward and does dx = alpha*(dy*mask) + beta*dx
+func (d *Dropout) Backward(x, dx, y, dy *Tensor) error {
+	if dx == nil {
+		return nil
+	}
+	if len(d.mask) != len(dy.data) || len(dx.data) != len(dy.data) {
+		return errors.New("(d *Dropout) Backward: Forward needs to be called with the same sized tensors first")
+	}
+	tmp := make([]float32, len(dy.data))
+	for i := range tmp {
+		tmp[i] = dy.data[i] * d.mask[i]
+	}
+	blend(dx.data, tmp, d.bwd.alpha, d.bwd.beta)
+	return nil
+}
+
+//SetForwardScalars sets the forward scalars.  Default is alpha 1, beta 0.
+func (d *Dropout) SetForwardScalars(alpha, beta float64) { d.fwd = scalars{alpha, beta} }
+
+//SetBackwardScalars sets the backward scalars.  Default is alpha 1, beta 0.
+func (d *Dropout) SetBackwardScalars(alpha, beta float64) { d.bwd = scalars{alpha, beta} }
@@ -0,0 +1,43 @@
+package cpu
+
+//Operation is the host counterpart of the gocunets Operation interface.
+//Every layer in this package satisfies it.  A gocunets Builder made with CreateCPUBuilder makes its layers out of them,
+//so a network can be built and trained with the same Builder and Layer api on a machine without a gpu.
+//
+//The scalars work the same way they do with cudnn. Y=(alpha *Op)+(beta*Y).
+type Operation interface {
+	Forward(x, y *Tensor) error
+	Inference(x, y *Tensor) error
+	Backward(x, dx, y, dy *Tensor) error
+	UpdateWeights(batch, counter int) error
+	LoadTrainers(trainers ...Trainer) error
+	TrainersNeeded() int
+	SetOtherScalars(alpha, beta float64)
+	SetForwardScalars(alpha, beta float64)
+	SetBackwardScalars(alpha, beta float64)
+	GetOutputDims(x *Tensor) ([]int32, error)
+}
+
+type scalars struct {
+	alpha, beta float64
+}
+
+var defaultscalars = scalars{alpha: 1, beta: 0}
+
+//defaultfilterscalars accumulate into the delta weights the same way the cnn layer does on the gpu.
+var defaultfilterscalars = scalars{alpha: 1, beta: 1}
+
+//nontrainable is embedded in layers that don't have any weights.
+type nontrainable struct{}
+
+//UpdateWeights does nothing for layers without weights
+func (nontrainable) UpdateWeights(batch, counter int) error { return nil }
+
+//LoadTrainers does nothing for layers without weights
+func (nontrainable) LoadTrainers(trainers ...Trainer) error { return nil }
+
+//TrainersNeeded returns 0 for layers without weights
+func (nontrainable) TrainersNeeded() int { return 0 }
+
+//SetOtherScalars does nothing for layers without weights
+func (nontrainable) SetOtherScalars(alpha, beta float64) {}
@@ -0,0 +1,326 @@
+package cpu_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/cpu"
+)
+
+//gradcheck compares the dx found by op.Backward to a numerical gradient of sum(y*r) where r is random.
+func gradcheck(t *testing.T, name string, op cpu.Operation, x *cpu.Tensor) {
+	rng := rand.New(rand.NewSource(2))
+	odims, err := op.GetOutputDims(x)
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	y, _ := cpu.CreateTensor(x.Format(), odims)
+	dy, _ := cpu.CreateTensor(x.Format(), odims)
+	dx, _ := cpu.CreateTensor(x.Format(), x.Dims())
+	dy.SetRandomNormal(rng, 0, 1)
+	loss := func() float64 {
+		if err := op.Forward(x, y); err != nil {
+			t.Fatal(name, err)
+		}
+		var l float64
+		for i := range y.Data() {
+			l += float64(y.Data()[i]) * float64(dy.Data()[i])
+		}
+		return l
+	}
+	loss()
+	if err = op.Backward(x, dx, y, dy); err != nil {
+		t.Fatal(name, err)
+	}
+	const h = 1e-2
+	for i := range x.Data() {
+		orig := x.Data()[i]
+		x.Data()[i] = orig + h
+		lp := loss()
+		x.Data()[i] = orig - h
+		lm := loss()
+		x.Data()[i] = orig
+		numeric := (lp - lm) / (2 * h)
+		if math.Abs(numeric-float64(dx.Data()[i])) > 2e-2*math.Max(1, math.Abs(numeric)) {
+			t.Errorf("%s: dx[%d] analytic %v numeric %v", name, i, dx.Data()[i], numeric)
+			return
+		}
+	}
+}
+
+func randomtensor(frmt cpu.TensorFormat, dims []int32, seed int64) *cpu.Tensor {
+	x, err := cpu.CreateTensor(frmt, dims)
+	if err != nil {
+		panic(err)
+	}
+	x.SetRandomNormal(rand.New(rand.NewSource(seed)), 0, 1)
+	return x
+}
+
+func TestConvolutionGradients(t *testing.T) {
+	var frmt cpu.TensorFormat
+	for _, nhwc := range []bool{false, true} {
+		xdims, wdims := []int32{2, 4, 7, 6}, []int32{6, 2, 3, 3}
+		frmt.NCHW()
+		if nhwc {
+			xdims, wdims = []int32{2, 7, 6, 4}, []int32{6, 3, 3, 2}
+			frmt.NHWC()
+		}
+		w := randomtensor(frmt, wdims, 3)
+		dw, _ := cpu.CreateTensor(frmt, wdims)
+		b := randomtensor(frmt, []int32{1, 1, 1, 6}, 4)
+		db, _ := cpu.CreateTensor(frmt, []int32{1, 1, 1, 6})
+		conv, err := cpu.CreateConvolution(2, w, dw, b, db, []int32{1, 2}, []int32{2, 1}, []int32{1, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		x := randomtensor(frmt, xdims, 5)
+		gradcheck(t, "Convolution "+frmt.String(), conv, x)
+
+		//check dw against the numerical gradient
+		conv.SetOtherScalars(1, 0)
+		odims, _ := conv.GetOutputDims(x)
+		y, _ := cpu.CreateTensor(frmt, odims)
+		dy := randomtensor(frmt, odims, 6)
+		if err = conv.BackwardFilter(x, dy); err != nil {
+			t.Fatal(err)
+		}
+		for i := range w.Data() {
+			orig := w.Data()[i]
+			var l [2]float64
+			for j, h := range []float32{1e-2, -1e-2} {
+				w.Data()[i] = orig + h
+				conv.Forward(x, y)
+				for k := range y.Data() {
+					l[j] += float64(y.Data()[k]) * float64(dy.Data()[k])
+				}
+			}
+			w.Data()[i] = orig
+			numeric := (l[0] - l[1]) / 2e-2
+			if math.Abs(numeric-float64(dw.Data()[i])) > 2e-2*math.Max(1, math.Abs(numeric)) {
+				t.Errorf("Convolution %s: dw[%d] analytic %v numeric %v", frmt, i, dw.Data()[i], numeric)
+				break
+			}
+		}
+	}
+}
+
+func TestConvolutionTransposeGradients(t *testing.T) {
+	var frmt cpu.TensorFormat
+	w := randomtensor(frmt.NCHW(), []int32{3, 2, 4, 4}, 7)
+	dw, _ := cpu.CreateTensor(frmt, []int32{3, 2, 4, 4})
+	b := randomtensor(frmt, []int32{1, 2, 1, 1}, 8)
+	db, _ := cpu.CreateTensor(frmt, []int32{1, 2, 1, 1})
+	conv, err := cpu.CreateConvolutionTranspose(1, w, dw, b, db, []int32{1, 1}, []int32{2, 2}, []int32{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := randomtensor(frmt, []int32{2, 3, 4, 4}, 9)
+	odims, err := conv.GetOutputDims(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if odims[2] != 8 || odims[3] != 8 || odims[1] != 2 {
+		t.Error("ConvolutionTranspose output dims", odims)
+	}
+	gradcheck(t, "ConvolutionTranspose", conv, x)
+}
+
+func TestActivationGradients(t *testing.T) {
+	var mode cpu.ActivationMode
+	for _, m := range []cpu.ActivationMode{mode.Relu(), mode.Leaky(), mode.ClippedRelu(), mode.Elu(), mode.Sigmoid(), mode.Tanh(), mode.PRelu()} {
+		act, err := cpu.CreateActivation(m, .2, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var frmt cpu.TensorFormat
+		x := randomtensor(frmt.NCHW(), []int32{2, 3, 4, 4}, 10)
+		//keep the values away from the kinks at 0 and .2 so the numerical gradient is valid
+		for i, v := range x.Data() {
+			if math.Abs(float64(v)) < .05 || math.Abs(float64(v)-.2) < .05 {
+				x.Data()[i] = v + .1
+			}
+		}
+		gradcheck(t, m.String(), act, x)
+	}
+}
+
+func TestPoolingGradients(t *testing.T) {
+	var mode cpu.PoolingMode
+	var frmt cpu.TensorFormat
+	for _, m := range []cpu.PoolingMode{mode.Max(), mode.AverageCountIncludePadding(), mode.AverageCountExcludePadding()} {
+		p, err := cpu.CreatePooling(m, []int32{3, 3}, []int32{1, 1}, []int32{2, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		//values are spread out so that max pooling doesn't have ties with in the numerical step
+		x, _ := cpu.CreateTensor(frmt.NHWC(), []int32{2, 7, 7, 3})
+		for i, j := range rand.New(rand.NewSource(11)).Perm(int(x.Vol())) {
+			x.Data()[i] = float32(j) * .1
+		}
+		gradcheck(t, "Pooling", p, x)
+	}
+}
+
+func TestBatchNormGradients(t *testing.T) {
+	var mode cpu.BatchNormMode
+	var frmt cpu.TensorFormat
+	for _, m := range []cpu.BatchNormMode{mode.Spatial(), mode.PerActivation()} {
+		bn, err := cpu.CreateBatchNorm(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x := randomtensor(frmt.NCHW(), []int32{4, 3, 3, 3}, 12)
+		y, _ := cpu.CreateTensor(frmt, x.Dims())
+		bn.Forward(x, y)
+		bn.Scale().SetRandomNormal(rand.New(rand.NewSource(13)), 1, .5)
+		gradcheck(t, "BatchNorm", bn, x)
+	}
+}
+
+func TestSoftMaxGradients(t *testing.T) {
+	var algo cpu.SoftMaxAlgo
+	var mode cpu.SoftMaxMode
+	var frmt cpu.TensorFormat
+	for _, a := range []cpu.SoftMaxAlgo{algo.Accurate(), algo.Log()} {
+		for _, m := range []cpu.SoftMaxMode{mode.Channel(), mode.Instance()} {
+			s, err := cpu.CreateSoftMax(a, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gradcheck(t, "SoftMax", s, randomtensor(frmt.NCHW(), []int32{2, 4, 2, 3}, 14))
+		}
+	}
+}
+
+func TestReshapeOps(t *testing.T) {
+	var mode cpu.ReshapeMode
+	var frmt cpu.TensorFormat
+	x := randomtensor(frmt.NCHW(), []int32{2, 3, 5, 4}, 15)
+	for _, m := range []cpu.ReshapeMode{mode.Transpose(), mode.S2B()} {
+		r, err := cpu.CreateReshape(m, []int32{3, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		odims, err := r.GetOutputDims(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, _ := cpu.CreateTensor(r.OutputFormat(x.Format()), odims)
+		dx, _ := cpu.CreateTensor(x.Format(), x.Dims())
+		if err = r.Forward(x, y); err != nil {
+			t.Fatal(err)
+		}
+		if err = r.Backward(x, dx, y, y); err != nil {
+			t.Fatal(err)
+		}
+		for i := range x.Data() {
+			if x.Data()[i] != dx.Data()[i] {
+				t.Error("Reshape backward doesn't undo forward")
+				break
+			}
+		}
+	}
+}
+
+func TestNetworkTrains(t *testing.T) {
+	var frmt cpu.TensorFormat
+	var pmode cpu.PoolingMode
+	var smode cpu.SoftMaxMode
+	var salgo cpu.SoftMaxAlgo
+	rng := rand.New(rand.NewSource(1))
+	frmt.NCHW()
+	w, _ := cpu.CreateTensor(frmt, []int32{4, 1, 3, 3})
+	w.SetRandom(rng, 9)
+	dw, _ := cpu.CreateTensor(frmt, []int32{4, 1, 3, 3})
+	b, _ := cpu.CreateTensor(frmt, []int32{1, 4, 1, 1})
+	db, _ := cpu.CreateTensor(frmt, []int32{1, 4, 1, 1})
+	conv, _ := cpu.CreateConvolution(1, w, dw, b, db, []int32{1, 1}, []int32{1, 1}, []int32{1, 1})
+	w2, _ := cpu.CreateTensor(frmt, []int32{2, 4, 2, 2})
+	w2.SetRandom(rng, 16)
+	dw2, _ := cpu.CreateTensor(frmt, []int32{2, 4, 2, 2})
+	b2, _ := cpu.CreateTensor(frmt, []int32{1, 2, 1, 1})
+	db2, _ := cpu.CreateTensor(frmt, []int32{1, 2, 1, 1})
+	conv2, _ := cpu.CreateConvolution(1, w2, dw2, b2, db2, []int32{0, 0}, []int32{1, 1}, []int32{1, 1})
+	pool, _ := cpu.CreatePooling(pmode.Max(), []int32{2, 2}, []int32{0, 0}, []int32{2, 2})
+	soft, _ := cpu.CreateSoftMax(salgo.Accurate(), smode.Channel())
+	ops := []cpu.Operation{conv, cpu.Leaky(), pool, conv2}
+	for _, op := range ops {
+		trainers := make([]cpu.Trainer, op.TrainersNeeded())
+		for i := range trainers {
+			a := cpu.CreateAdam(0, 0)
+			a.SetRates(.01, 1)
+			trainers[i] = a
+		}
+		if err := op.LoadTrainers(trainers...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//class 0 is a vertical line and class 1 is a horizontal line
+	const batch = 8
+	x, _ := cpu.CreateTensor(frmt, []int32{batch, 1, 4, 4})
+	target := make([]float32, batch*2)
+	for i := 0; i < batch; i++ {
+		for j := 0; j < 4; j++ {
+			if i%2 == 0 {
+				x.Data()[i*16+j*4+i%4] = 1
+			} else {
+				x.Data()[i*16+(i%4)*4+j] = 1
+			}
+		}
+		target[i*2+i%2] = 1
+	}
+	//xs[i] and dxs[i] are the input of ops[i]. The last ones are the output.
+	xs, dxs := []*cpu.Tensor{x}, []*cpu.Tensor{nil}
+	for _, op := range ops {
+		dims, err := op.GetOutputDims(xs[len(xs)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, _ := cpu.CreateTensor(frmt, dims)
+		dy, _ := cpu.CreateTensor(frmt, dims)
+		xs, dxs = append(xs, y), append(dxs, dy)
+	}
+	out, dy := xs[len(ops)], dxs[len(ops)]
+	y, _ := cpu.CreateTensor(frmt, []int32{batch, 2, 1, 1})
+	crossentropy := func(y *cpu.Tensor) (loss float64) {
+		for i := range target {
+			if target[i] == 1 {
+				loss -= math.Log(float64(y.Data()[i]) + 1e-7)
+			}
+		}
+		return loss / batch
+	}
+	var first, last float64
+	for epoch := 0; epoch < 200; epoch++ {
+		for i, op := range ops {
+			if err := op.Forward(xs[i], xs[i+1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := soft.Forward(out, y); err != nil {
+			t.Fatal(err)
+		}
+		if epoch == 0 {
+			first = crossentropy(y)
+		}
+		last = crossentropy(y)
+		for i := range target {
+			dy.Data()[i] = y.Data()[i] - target[i]
+		}
+		for i := len(ops) - 1; i >= 0; i-- {
+			if err := ops[i].Backward(xs[i], dxs[i], xs[i+1], dxs[i+1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, op := range ops {
+			if err := op.UpdateWeights(batch, epoch); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if last > first/4 {
+		t.Error("network didn't train", first, last)
+	}
+}
@@ -0,0 +1,350 @@
+package cpu
+
+import (
+	"fmt"
+	"math"
+)
+
+//optimizer holds what the host trainers share.  The gradient of a weight is dwalpha*dw/batch plus the l1 and l2 decay.
+type optimizer struct {
+	name           string
+	rate, dwalpha  float32
+	eps            float32
+	decay1, decay2 float32
+	l1, l2         float32
+	g              []float32
+	state          [][]float32
+}
+
+func makeoptimizer(name string, states int, rate, eps, decay1, decay2 float32) optimizer {
+	return optimizer{
+		name:    name,
+		rate:    rate,
+		dwalpha: 1,
+		eps:     eps,
+		decay1:  decay1,
+		decay2:  decay2,
+		state:   make([][]float32, states),
+	}
+}
+
+//SetRates sets the learning rate and the dwalpha.  dwalpha scales the delta weights before they are used.
+func (o *optimizer) SetRates(rate, dwalpha float32) {
+	o.rate, o.dwalpha = rate, dwalpha
+}
+
+//SetDecays sets the l1 and l2 decay
+func (o *optimizer) SetDecays(l1, l2 float32) {
+	o.decay1, o.decay2 = l1, l2
+}
+
+//SetEps sets eps
+func (o *optimizer) SetEps(eps float32) {
+	o.eps = eps
+}
+
+//L1L2Loss returns the l1 and l2 loss of the last update
+func (o *optimizer) L1L2Loss() (float32, float32) {
+	return o.l1, o.l2
+}
+
+//State returns the memory the trainer keeps between updates for weights of size n. It is made if it hasn't been.
+//The slices are the ones the trainer uses, so they can be loaded before an update and read after.
+func (o *optimizer) State(n int) [][]float32 {
+	if len(o.g) != n {
+		o.g = make([]float32, n)
+		for i := range o.state {
+			o.state[i] = make([]float32, n)
+		}
+	}
+	return o.state
+}
+
+//gradients checks dw and w, finds the l1 and l2 loss and puts the gradient of each weight in o.g.
+//dw is zeroed.
+func (o *optimizer) gradients(dw, w *Tensor, batch int) error {
+	if len(dw.data) != len(w.data) {
+		return fmt.Errorf("(%s) UpdateWeights: dw and w need to be the same size", o.name)
+	}
+	if batch < 1 {
+		return fmt.Errorf("(%s) UpdateWeights: batch needs to be greater than zero", o.name)
+	}
+	if o.g != nil && len(o.g) != len(w.data) {
+		return fmt.Errorf("(%s) UpdateWeights: trainer was used with different sized weights", o.name)
+	}
+	o.State(len(w.data))
+	var l1, l2 float32
+	for i, x := range w.data {
+		l1 += float32(math.Abs(float64(x))) * o.decay1
+		l2 += x * x * o.decay2 / 2
+		g := o.dwalpha*dw.data[i]/float32(batch) + o.decay2*x
+		if x > 0 {
+			g += o.decay1
+		} else if x < 0 {
+			g -= o.decay1
+		}
+		o.g[i] = g
+		dw.data[i] = 0
+	}
+	o.l1, o.l2 = l1, l2
+	return nil
+}
+
+func sqrt32(x float32) float32 {
+	return float32(math.Sqrt(float64(x)))
+}
+
+//SGD is stochastic gradient descent with momentum.
+//
+//v = momentum*v + g. w -= rate*v, or with nesterov w -= rate*(g + momentum*v).
+type SGD struct {
+	optimizer
+	momentum float32
+	nesterov bool
+}
+
+//CreateSGD creates an SGD trainer with a rate of .01.  A momentum of 0 is vanilla gradient descent.
+func CreateSGD(decay1, decay2, momentum float32, nesterov bool) *SGD {
+	return &SGD{
+		optimizer: makeoptimizer("s *SGD", 1, .01, 0, decay1, decay2),
+		momentum:  momentum,
+		nesterov:  nesterov,
+	}
+}
+
+//SetMomentum sets the momentum and if it is nesterov momentum
+func (s *SGD) SetMomentum(momentum float32, nesterov bool) {
+	s.momentum, s.nesterov = momentum, nesterov
+}
+
+//UpdateWeights updates w with dw. counter isn't used.
+func (s *SGD) UpdateWeights(dw, w *Tensor, batch, counter int) error {
+	err := s.gradients(dw, w, batch)
+	if err != nil {
+		return err
+	}
+	v := s.state[0]
+	for i, g := range s.g {
+		v[i] = s.momentum*v[i] + g
+		if s.nesterov {
+			w.data[i] -= s.rate * (g + s.momentum*v[i])
+		} else {
+			w.data[i] -= s.rate * v[i]
+		}
+	}
+	return nil
+}
+
+//RMSProp divides the gradient by a running average of its size.
+//
+//s = rho*s + (1-rho)*g^2. w -= rate*g/(sqrt(s)+eps).
+type RMSProp struct {
+	optimizer
+	rho float32
+}
+
+//CreateRMSProp creates an RMSProp trainer with a rate of .001, rho .9 and eps 1e-8
+func CreateRMSProp(decay1, decay2 float32) *RMSProp {
+	return &RMSProp{
+		optimizer: makeoptimizer("r *RMSProp", 1, .001, 1e-8, decay1, decay2),
+		rho:       .9,
+	}
+}
+
+//SetRho sets the decay of the running average
+func (r *RMSProp) SetRho(rho float32) {
+	r.rho = rho
+}
+
+//UpdateWeights updates w with dw. counter isn't used.
+func (r *RMSProp) UpdateWeights(dw, w *Tensor, batch, counter int) error {
+	err := r.gradients(dw, w, batch)
+	if err != nil {
+		return err
+	}
+	s := r.state[0]
+	for i, g := range r.g {
+		s[i] = r.rho*s[i] + (1-r.rho)*g*g
+		w.data[i] -= r.rate * g / (sqrt32(s[i]) + r.eps)
+	}
+	return nil
+}
+
+//AdaGrad divides the gradient by the root of the sum of all the squared gradients.
+//
+//s += g^2. w -= rate*g/(sqrt(s)+eps).
+type AdaGrad struct {
+	optimizer
+}
+
+//CreateAdaGrad creates an AdaGrad trainer with a rate of .01 and eps 1e-8
+func CreateAdaGrad(decay1, decay2 float32) *AdaGrad {
+	return &AdaGrad{
+		optimizer: makeoptimizer("a *AdaGrad", 1, .01, 1e-8, decay1, decay2),
+	}
+}
+
+//UpdateWeights updates w with dw. counter isn't used.
+func (a *AdaGrad) UpdateWeights(dw, w *Tensor, batch, counter int) error {
+	err := a.gradients(dw, w, batch)
+	if err != nil {
+		return err
+	}
+	s := a.state[0]
+	for i, g := range a.g {
+		s[i] += g * g
+		w.data[i] -= a.rate * g / (sqrt32(s[i]) + a.eps)
+	}
+	return nil
+}
+
+//AdaDelta scales the gradient by the ratio of running averages of the size of the updates and of the gradients.
+//
+//s = rho*s + (1-rho)*g^2. d = sqrt(u+eps)/sqrt(s+eps)*g. u = rho*u + (1-rho)*d^2. w -= rate*d.
+type AdaDelta struct {
+	optimizer
+	rho float32
+}
+
+//CreateAdaDelta creates an AdaDelta trainer with a rate of 1, rho .95 and eps 1e-6
+func CreateAdaDelta(decay1, decay2 float32) *AdaDelta {
+	return &AdaDelta{
+		optimizer: makeoptimizer("a *AdaDelta", 2, 1, 1e-6, decay1, decay2),
+		rho:       .95,
+	}
+}
+
+//SetRho sets the decay of the running averages
+func (a *AdaDelta) SetRho(rho float32) {
+	a.rho = rho
+}
+
+//UpdateWeights updates w with dw. counter isn't used.
+func (a *AdaDelta) UpdateWeights(dw, w *Tensor, batch, counter int) error {
+	err := a.gradients(dw, w, batch)
+	if err != nil {
+		return err
+	}
+	s, u := a.state[0], a.state[1]
+	for i, g := range a.g {
+		s[i] = a.rho*s[i] + (1-a.rho)*g*g
+		d := sqrt32(u[i]+a.eps) / sqrt32(s[i]+a.eps) * g
+		u[i] = a.rho*u[i] + (1-a.rho)*d*d
+		w.data[i] -= a.rate * d
+	}
+	return nil
+}
+
+//adamstep puts the bias corrected adam step of each weight in o.g
+func (o *optimizer) adamstep(beta1, beta2 float32, counter int) {
+	t := float64(counter + 1)
+	correction1 := float32(1 - math.Pow(float64(beta1), t))
+	correction2 := float32(1 - math.Pow(float64(beta2), t))
+	m, v := o.state[0], o.state[1]
+	for i, g := range o.g {
+		m[i] = beta1*m[i] + (1-beta1)*g
+		v[i] = beta2*v[i] + (1-beta2)*g*g
+		o.g[i] = (m[i] / correction1) / (sqrt32(v[i]/correction2) + o.eps)
+	}
+}
+
+//AdamW is adam with decoupled weight decay. The weight decay is not part of the gradient so it isn't scaled by the moments.
+//
+//w -= rate*(mhat/(sqrt(vhat)+eps) + weightdecay*w).
+type AdamW struct {
+	optimizer
+	beta1, beta2 float32
+	weightdecay  float32
+}
+
+//CreateAdamW creates an AdamW trainer with a rate of .001, beta1 .9, beta2 .999 and eps 1e-8.
+//decay1 and decay2 are added to the gradient like they are with adam and should usually be 0.
+func CreateAdamW(decay1, decay2, weightdecay float32) *AdamW {
+	return &AdamW{
+		optimizer:   makeoptimizer("a *AdamW", 2, .001, 1e-8, decay1, decay2),
+		beta1:       .9,
+		beta2:       .999,
+		weightdecay: weightdecay,
+	}
+}
+
+//SetBetas sets beta1 and beta2
+func (a *AdamW) SetBetas(beta1, beta2 float32) {
+	a.beta1, a.beta2 = beta1, beta2
+}
+
+//SetWeightDecay sets the decoupled weight decay
+func (a *AdamW) SetWeightDecay(weightdecay float32) {
+	a.weightdecay = weightdecay
+}
+
+//UpdateWeights updates w with dw. counter is the number of updates done so far starting at 0.
+func (a *AdamW) UpdateWeights(dw, w *Tensor, batch, counter int) error {
+	err := a.gradients(dw, w, batch)
+	if err != nil {
+		return err
+	}
+	a.adamstep(a.beta1, a.beta2, counter)
+	for i, step := range a.g {
+		w.data[i] -= a.rate * (step + a.weightdecay*w.data[i])
+	}
+	return nil
+}
+
+//LAMB is AdamW where the step of each tensor is scaled by the trust ratio ||w||/||u|| so that it is the same size relative to the weights in every layer.
+//
+//u = mhat/(sqrt(vhat)+eps) + weightdecay*w. w -= rate*||w||/||u||*u.  The ratio is 1 if either norm is 0.
+type LAMB struct {
+	optimizer
+	beta1, beta2 float32
+	weightdecay  float32
+	ratio        float32
+}
+
+//CreateLAMB creates a LAMB trainer with a rate of .001, beta1 .9, beta2 .999 and eps 1e-6
+func CreateLAMB(decay1, decay2, weightdecay float32) *LAMB {
+	return &LAMB{
+		optimizer:   makeoptimizer("l *LAMB", 2, .001, 1e-6, decay1, decay2),
+		beta1:       .9,
+		beta2:       .999,
+		weightdecay: weightdecay,
+	}
+}
+
+//SetBetas sets beta1 and beta2
+func (l *LAMB) SetBetas(beta1, beta2 float32) {
+	l.beta1, l.beta2 = beta1, beta2
+}
+
+//SetWeightDecay sets the decoupled weight decay
+func (l *LAMB) SetWeightDecay(weightdecay float32) {
+	l.weightdecay = weightdecay
+}
+
+//TrustRatio returns the trust ratio of the last update
+func (l *LAMB) TrustRatio() float32 {
+	return l.ratio
+}
+
+//UpdateWeights updates w with dw. counter is the number of updates done so far starting at 0.
+func (l *LAMB) UpdateWeights(dw, w *Tensor, batch, counter int) error {
+	err := l.gradients(dw, w, batch)
+	if err != nil {
+		return err
+	}
+	l.adamstep(l.beta1, l.beta2, counter)
+	var wnorm, unorm float64
+	for i := range l.g {
+		l.g[i] += l.weightdecay * w.data[i]
+		wnorm += float64(w.data[i]) * float64(w.data[i])
+		unorm += float64(l.g[i]) * float64(l.g[i])
+	}
+	l.ratio = 1
+	if wnorm > 0 && unorm > 0 {
+		l.ratio = float32(math.Sqrt(wnorm / unorm))
+	}
+	for i, u := range l.g {
+		w.data[i] -= l.rate * l.ratio * u
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package cpu_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/cpu"
+)
+
+//step runs two updates of tr on w = [1, -2] with dw = [.5, 1] and then [-1, 2] and a batch of 1.
+func step(t *testing.T, tr cpu.Trainer) []float32 {
+	var frmt cpu.TensorFormat
+	w, _ := cpu.CreateTensorFromSlice(frmt.NCHW(), []int32{2}, []float32{1, -2})
+	for i, g := range [][]float32{{.5, 1}, {-1, 2}} {
+		dw, _ := cpu.CreateTensorFromSlice(frmt.NCHW(), []int32{2}, g)
+		if err := tr.UpdateWeights(dw, w, 1, i); err != nil {
+			t.Fatal(err)
+		}
+		if dw.Data()[0] != 0 || dw.Data()[1] != 0 {
+			t.Fatal("dw should be zeroed")
+		}
+	}
+	return w.Data()
+}
+
+func TestOptimizers(t *testing.T) {
+	sqrt := func(x float64) float64 { return math.Sqrt(x) }
+	adamw := func(w, g1, g2 float64) float64 {
+		m, v := .1*g1, .001*g1*g1
+		w -= .001 * (m/.1/(sqrt(v/.001)+1e-8) + .01*w)
+		m, v = .9*m+.1*g2, .999*v+.001*g2*g2
+		return w - .001*(m/.19/(sqrt(v/(1-.999*.999))+1e-8)+.01*w)
+	}
+	adadelta := func(w, g1, g2 float64) float64 {
+		var s, u float64
+		for _, g := range []float64{g1, g2} {
+			s = .95*s + .05*g*g
+			d := sqrt(u+1e-6) / sqrt(s+1e-6) * g
+			u = .95*u + .05*d*d
+			w -= d
+		}
+		return w
+	}
+	for _, c := range []struct {
+		name     string
+		tr       cpu.Trainer
+		expected [2]float64
+	}{
+		//v = .5, then .9*.5-1 = -.55
+		{"SGD", cpu.CreateSGD(0, 0, .9, false), [2]float64{1 - .01*.5 + .01*.55, -2 - .01*1 - .01*2.9}},
+		{"Nesterov", cpu.CreateSGD(0, 0, .9, true), [2]float64{1 - .01*(.5+.45) - .01*(-1-.9*.55), -2 - .01*(1+.9) - .01*(2+.9*2.9)}},
+		{"RMSProp", cpu.CreateRMSProp(0, 0), [2]float64{
+			1 - .001*.5/(sqrt(.025)+1e-8) + .001/(sqrt(.9*.025+.1)+1e-8),
+			-2 - .001/(sqrt(.1)+1e-8) - .001*2/(sqrt(.09+.4)+1e-8)}},
+		{"AdaGrad", cpu.CreateAdaGrad(0, 0), [2]float64{
+			1 - .01*.5/(.5+1e-8) + .01/(sqrt(1.25)+1e-8),
+			-2 - .01/(1+1e-8) - .01*2/(sqrt(5)+1e-8)}},
+		{"AdaDelta", cpu.CreateAdaDelta(0, 0), [2]float64{adadelta(1, .5, -1), adadelta(-2, 1, 2)}},
+		{"AdamW", cpu.CreateAdamW(0, 0, .01), [2]float64{adamw(1, .5, -1), adamw(-2, 1, 2)}},
+	} {
+		w := step(t, c.tr)
+		for i := range w {
+			if math.Abs(float64(w[i])-c.expected[i]) > 1e-5 {
+				t.Errorf("%s: w[%d] is %v expected %v", c.name, i, w[i], c.expected[i])
+			}
+		}
+	}
+
+	//AdamW without weight decay is adam
+	adam, adamw0 := step(t, cpu.CreateAdam(.01, .02)), step(t, cpu.CreateAdamW(.01, .02, 0))
+	for i := range adam {
+		if math.Abs(float64(adam[i]-adamw0[i])) > 1e-6 {
+			t.Errorf("AdamW without weight decay is %v adam is %v", adamw0, adam)
+		}
+	}
+
+	//The first step of LAMB has a size of rate*||w|| no matter the size of the gradient
+	var frmt cpu.TensorFormat
+	lamb := cpu.CreateLAMB(0, 0, 0)
+	w, _ := cpu.CreateTensorFromSlice(frmt.NCHW(), []int32{2}, []float32{3, 4})
+	dw, _ := cpu.CreateTensorFromSlice(frmt.NCHW(), []int32{2}, []float32{100, -.1})
+	if err := lamb.UpdateWeights(dw, w, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if r := lamb.TrustRatio(); math.Abs(float64(r)-5/math.Sqrt2) > 1e-4 {
+		t.Errorf("trust ratio is %v", r)
+	}
+	if math.Abs(float64(w.Data()[0])-(3-.001*5/math.Sqrt2)) > 1e-6 || math.Abs(float64(w.Data()[1])-(4+.001*5/math.Sqrt2)) > 1e-6 {
+		t.Errorf("LAMB weights are %v", w.Data())
+	}
+
+	//State is the memory used by the trainer
+	sgd := cpu.CreateSGD(0, 0, .5, false)
+	sgd.State(2)[0][0] = 1
+	w, _ = cpu.CreateTensorFromSlice(frmt.NCHW(), []int32{2}, []float32{0, 0})
+	dw, _ = cpu.CreateTensorFromSlice(frmt.NCHW(), []int32{2}, []float32{0, 0})
+	if err := sgd.UpdateWeights(dw, w, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if w.Data()[0] != -.01*.5 || sgd.State(2)[0][0] != .5 {
+		t.Errorf("state wasn't used: w %v state %v", w.Data(), sgd.State(2)[0])
+	}
+	big, _ := cpu.CreateTensorFromSlice(frmt.NCHW(), []int32{3}, []float32{0, 0, 0})
+	if err := sgd.UpdateWeights(big, big, 1, 1); err == nil {
+		t.Error("trainer should not be used with different sized weights")
+	}
+}
@@ -0,0 +1,188 @@
+package cpu
+
+import (
+	"errors"
+	"math"
+)
+
+//PoolingMode is the flag for the pooling mode
+type PoolingMode int32
+
+//Max sets and returns the Max flag
+func (p *PoolingMode) Max() PoolingMode { *p = PoolingMode(0); return *p }
+
+//AverageCountIncludePadding sets and returns the AverageCountIncludePadding flag
+func (p *PoolingMode) AverageCountIncludePadding() PoolingMode { *p = PoolingMode(1); return *p }
+
+//AverageCountExcludePadding sets and returns the AverageCountExcludePadding flag
+func (p *PoolingMode) AverageCountExcludePadding() PoolingMode { *p = PoolingMode(2); return *p }
+
+//Pooling is a 2d pooling layer that runs on the cpu
+type Pooling struct {
+	nontrainable
+	mode                    PoolingMode
+	window, padding, stride [2]int
+	fwd, bwd                scalars
+}
+
+//CreatePooling creates a pooling layer. window, padding, and stride need a length of 2.
+func CreatePooling(mode PoolingMode, window, padding, stride []int32) (*Pooling, error) {
+	if len(window) != 2 || len(padding) != 2 || len(stride) != 2 {
+		return nil, errors.New("cpu.CreatePooling: window, padding, and stride need to have a length of 2")
+	}
+	var f PoolingMode
+	switch mode {
+	case f.Max(), f.AverageCountIncludePadding(), f.AverageCountExcludePadding():
+	default:
+		return nil, errors.New("cpu.CreatePooling: unsupported pooling mode")
+	}
+	p := &Pooling{mode: mode, fwd: defaultscalars, bwd: defaultscalars}
+	for i := 0; i < 2; i++ {
+		if window[i] < 1 || stride[i] < 1 || padding[i] < 0 {
+			return nil, errors.New("cpu.CreatePooling: window and stride need to be > 0 and padding >= 0")
+		}
+		p.window[i], p.padding[i], p.stride[i] = int(window[i]), int(padding[i]), int(stride[i])
+	}
+	return p, nil
+}
+
+func (p *Pooling) outdim(i, dim int) int {
+	return 1 + (i+2*p.padding[dim]-p.window[dim])/p.stride[dim]
+}
+
+//GetOutputDims returns the output dims
+func (p *Pooling) GetOutputDims(x *Tensor) ([]int32, error) {
+	xd, _, err := x.shape4d()
+	if err != nil {
+		return nil, err
+	}
+	h, w := int32(p.outdim(xd[2], 0)), int32(p.outdim(xd[3], 1))
+	if h < 1 || w < 1 {
+		return nil, errors.New("(p *Pooling) GetOutputDims: output spacial dims would be less than 1")
+	}
+	var flg TensorFormat
+	if x.frmt == flg.NHWC() {
+		return []int32{x.dims[0], h, w, x.dims[3]}, nil
+	}
+	return []int32{x.dims[0], x.dims[1], h, w}, nil
+}
+
+//each calls fn for every window of the pooling with the y index and the x indexes inside of the window.
+//count is the divisor used for the average modes.
+func (p *Pooling) each(x, y *Tensor, fn func(yi int, xis []int, count int)) error {
+	xd, xs, err := x.shape4d()
+	if err != nil {
+		return err
+	}
+	yd, ys, err := y.shape4d()
+	if err != nil {
+		return err
+	}
+	if xd[0] != yd[0] || xd[1] != yd[1] || yd[2] != p.outdim(xd[2], 0) || yd[3] != p.outdim(xd[3], 1) {
+		return errors.New("cpu: pooling dims don't match")
+	}
+	var f PoolingMode
+	xis := make([]int, 0, p.window[0]*p.window[1])
+	for n := 0; n < yd[0]; n++ {
+		for c := 0; c < yd[1]; c++ {
+			for oh := 0; oh < yd[2]; oh++ {
+				for ow := 0; ow < yd[3]; ow++ {
+					xis = xis[:0]
+					for r := 0; r < p.window[0]; r++ {
+						ih := oh*p.stride[0] - p.padding[0] + r
+						if ih < 0 || ih >= xd[2] {
+							continue
+						}
+						for s := 0; s < p.window[1]; s++ {
+							iw := ow*p.stride[1] - p.padding[1] + s
+							if iw < 0 || iw >= xd[3] {
+								continue
+							}
+							xis = append(xis, n*xs[0]+c*xs[1]+ih*xs[2]+iw*xs[3])
+						}
+					}
+					count := len(xis)
+					if p.mode == f.AverageCountIncludePadding() {
+						count = p.window[0] * p.window[1]
+					}
+					fn(n*ys[0]+c*ys[1]+oh*ys[2]+ow*ys[3], xis, count)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//Forward does y = alpha*pool(x) + beta*y
+func (p *Pooling) Forward(x, y *Tensor) error {
+	var f PoolingMode
+	tmp := make([]float32, len(y.data))
+	err := p.each(x, y, func(yi int, xis []int, count int) {
+		if p.mode == f.Max() {
+			max := float32(math.Inf(-1))
+			for _, xi := range xis {
+				if x.data[xi] > max {
+					max = x.data[xi]
+				}
+			}
+			tmp[yi] = max
+			return
+		}
+		var sum float32
+		for _, xi := range xis {
+			sum += x.data[xi]
+		}
+		if count > 0 {
+			tmp[yi] = sum / float32(count)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	blend(y.data, tmp, p.fwd.alpha, p.fwd.beta)
+	return nil
+}
+
+//Inference is the same as Forward
+func (p *Pooling) Inference(x, y *Tensor) error {
+	return p.Forward(x, y)
+}
+
+//Backward does dx = alpha*poolbackward(x,dy) + beta*dx.  For max pooling the gradient goes to the first max in the window.
+func (p *Pooling) Backward(x, dx, y, dy *Tensor) error {
+	if dx == nil {
+		return nil
+	}
+	var f PoolingMode
+	tmp := make([]float32, len(dx.data))
+	err := p.each(x, dy, func(yi int, xis []int, count int) {
+		if len(xis) == 0 {
+			return
+		}
+		if p.mode == f.Max() {
+			maxi := xis[0]
+			for _, xi := range xis {
+				if x.data[xi] > x.data[maxi] {
+					maxi = xi
+				}
+			}
+			tmp[maxi] += dy.data[yi]
+			return
+		}
+		g := dy.data[yi] / float32(count)
+		for _, xi := range xis {
+			tmp[xi] += g
+		}
+	})
+	if err != nil {
+		return err
+	}
+	blend(dx.data, tmp, p.bwd.alpha, p.bwd.beta)
+	return nil
+}
+
+//SetForwardScalars sets the forward scalars.  Default is alpha 1, beta 0.
+func (p *Pooling) SetForwardScalars(alpha, beta float64) { p.fwd = scalars{alpha, beta} }
+
+//SetBackwardScalars sets the backward scalars.  Default is alpha 1, beta 0.
+func (p *Pooling) SetBackwardScalars(alpha, beta float64) { p.bwd = scalars{alpha, beta} }
@@ -0,0 +1,162 @@
+package cpu
+
+import (
+	"errors"
+)
+
+//ReshapeMode is the flag for the reshape mode
+type ReshapeMode int32
+
+//Transpose sets and returns the Transpose flag.  It changes a NCHW tensor to NHWC and the other way around.
+func (r *ReshapeMode) Transpose() ReshapeMode { *r = ReshapeMode(0); return *r }
+
+//S2B sets and returns the S2B (shape to batch) flag.
+func (r *ReshapeMode) S2B() ReshapeMode { *r = ReshapeMode(1); return *r }
+
+//Reshape is a reshape layer that runs on the cpu.
+//
+//S2B cuts the h and w of x into windows and places each window into its own batch.
+//Windows don't overlap and the values that go past the edge of x are zero.
+//Example NCHW vector of [2,5,8,8].  If window of [3,3]. The vector output will be [18,5,3,3].
+//Placing batches is row dominant like in C.
+type Reshape struct {
+	nontrainable
+	mode   ReshapeMode
+	window [2]int
+}
+
+//CreateReshape creates a reshape layer. window is only used for S2B.
+func CreateReshape(mode ReshapeMode, window []int32) (*Reshape, error) {
+	var f ReshapeMode
+	r := &Reshape{mode: mode}
+	switch mode {
+	case f.Transpose():
+	case f.S2B():
+		if len(window) != 2 || window[0] < 1 || window[1] < 1 {
+			return nil, errors.New("cpu.CreateReshape: window needs to have a length of 2 and be > 0")
+		}
+		r.window = [2]int{int(window[0]), int(window[1])}
+	default:
+		return nil, errors.New("cpu.CreateReshape: unsupported mode")
+	}
+	return r, nil
+}
+
+//OutputFormat returns the format of the output for an input format
+func (r *Reshape) OutputFormat(frmt TensorFormat) TensorFormat {
+	var f ReshapeMode
+	var flg TensorFormat
+	if r.mode == f.Transpose() {
+		if frmt == flg.NCHW() {
+			return flg.NHWC()
+		}
+		return flg.NCHW()
+	}
+	return frmt
+}
+
+//GetOutputDims returns the output dims in the order of the output format
+func (r *Reshape) GetOutputDims(x *Tensor) ([]int32, error) {
+	xd, _, err := x.shape4d()
+	if err != nil {
+		return nil, err
+	}
+	var f ReshapeMode
+	var flg TensorFormat
+	n, c, h, w := xd[0], xd[1], xd[2], xd[3]
+	if r.mode == f.S2B() {
+		n1 := int(intceiling(int32(h), int32(r.window[0])))
+		n2 := int(intceiling(int32(w), int32(r.window[1])))
+		n, h, w = n*n1*n2, r.window[0], r.window[1]
+	}
+	if r.OutputFormat(x.frmt) == flg.NHWC() {
+		return []int32{int32(n), int32(h), int32(w), int32(c)}, nil
+	}
+	return []int32{int32(n), int32(c), int32(h), int32(w)}, nil
+}
+
+//each calls fn with the index of x and y for each value in y that comes from x
+func (r *Reshape) each(x, y *Tensor, fn func(xi, yi int)) error {
+	odims, err := r.GetOutputDims(x)
+	if err != nil {
+		return err
+	}
+	if !comparedims(odims, y.dims) || r.OutputFormat(x.frmt) != y.frmt {
+		return errors.New("cpu: reshape output dims or format don't match")
+	}
+	xd, xs, _ := x.shape4d()
+	yd, ys, err := y.shape4d()
+	if err != nil {
+		return err
+	}
+	var f ReshapeMode
+	if r.mode == f.Transpose() {
+		for n := 0; n < xd[0]; n++ {
+			for c := 0; c < xd[1]; c++ {
+				for h := 0; h < xd[2]; h++ {
+					for w := 0; w < xd[3]; w++ {
+						fn(n*xs[0]+c*xs[1]+h*xs[2]+w*xs[3], n*ys[0]+c*ys[1]+h*ys[2]+w*ys[3])
+					}
+				}
+			}
+		}
+		return nil
+	}
+	n1 := int(intceiling(int32(xd[2]), int32(r.window[0])))
+	n2 := int(intceiling(int32(xd[3]), int32(r.window[1])))
+	for b := 0; b < yd[0]; b++ {
+		n, i, j := b/(n1*n2), (b/n2)%n1, b%n2
+		for c := 0; c < yd[1]; c++ {
+			for l := 0; l < yd[2]; l++ {
+				h := i*r.window[0] + l
+				if h >= xd[2] {
+					continue
+				}
+				for m := 0; m < yd[3]; m++ {
+					w := j*r.window[1] + m
+					if w >= xd[3] {
+						continue
+					}
+					fn(n*xs[0]+c*xs[1]+h*xs[2]+w*xs[3], b*ys[0]+c*ys[1]+l*ys[2]+m*ys[3])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//Forward reshapes x into y
+func (r *Reshape) Forward(x, y *Tensor) error {
+	tmp := make([]float32, len(y.data))
+	err := r.each(x, y, func(xi, yi int) { tmp[yi] = x.data[xi] })
+	if err != nil {
+		return err
+	}
+	copy(y.data, tmp)
+	return nil
+}
+
+//Inference is the same as Forward
+func (r *Reshape) Inference(x, y *Tensor) error {
+	return r.Forward(x, y)
+}
+
+//Backward places dy back into dx
+func (r *Reshape) Backward(x, dx, y, dy *Tensor) error {
+	if dx == nil {
+		return nil
+	}
+	tmp := make([]float32, len(dx.data))
+	err := r.each(dx, dy, func(xi, yi int) { tmp[xi] = dy.data[yi] })
+	if err != nil {
+		return err
+	}
+	copy(dx.data, tmp)
+	return nil
+}
+
+//SetForwardScalars does nothing. Reshape only moves values.
+func (r *Reshape) SetForwardScalars(alpha, beta float64) {}
+
+//SetBackwardScalars does nothing. Reshape only moves values.
+func (r *Reshape) SetBackwardScalars(alpha, beta float64) {}
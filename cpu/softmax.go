@@ -0,0 +1,152 @@
+package cpu
+
+import (
+	"errors"
+	"math"
+)
+
+//SoftMaxAlgo is the flag for the softmax algorithm
+type SoftMaxAlgo int32
+
+//Accurate sets and returns the Accurate flag
+func (s *SoftMaxAlgo) Accurate() SoftMaxAlgo { *s = SoftMaxAlgo(0); return *s }
+
+//Log sets and returns the Log flag
+func (s *SoftMaxAlgo) Log() SoftMaxAlgo { *s = SoftMaxAlgo(1); return *s }
+
+//SoftMaxMode is the flag for the softmax mode
+type SoftMaxMode int32
+
+//Channel sets and returns the Channel flag. Softmax is done over c for each n,h,w.
+func (s *SoftMaxMode) Channel() SoftMaxMode { *s = SoftMaxMode(0); return *s }
+
+//Instance sets and returns the Instance flag. Softmax is done over c,h,w for each n.
+func (s *SoftMaxMode) Instance() SoftMaxMode { *s = SoftMaxMode(1); return *s }
+
+//SoftMax is a softmax layer that runs on the cpu
+type SoftMax struct {
+	nontrainable
+	algo     SoftMaxAlgo
+	mode     SoftMaxMode
+	fwd, bwd scalars
+}
+
+//CreateSoftMax creates a softmax layer
+func CreateSoftMax(algo SoftMaxAlgo, mode SoftMaxMode) (*SoftMax, error) {
+	var a SoftMaxAlgo
+	var m SoftMaxMode
+	if (algo != a.Accurate() && algo != a.Log()) || (mode != m.Channel() && mode != m.Instance()) {
+		return nil, errors.New("cpu.CreateSoftMax: unsupported algo or mode")
+	}
+	return &SoftMax{algo: algo, mode: mode, fwd: defaultscalars, bwd: defaultscalars}, nil
+}
+
+//GetOutputDims returns the dims of x
+func (s *SoftMax) GetOutputDims(x *Tensor) ([]int32, error) {
+	return x.Dims(), nil
+}
+
+//groups returns the indexes of each set of values the softmax is done over
+func (s *SoftMax) groups(x *Tensor) [][]int {
+	var m SoftMaxMode
+	var flg TensorFormat
+	n := int(x.dims[0])
+	per := len(x.data) / n
+	if s.mode == m.Instance() || len(x.dims) < 3 {
+		groups := make([][]int, n)
+		for i := range groups {
+			groups[i] = make([]int, per)
+			for j := range groups[i] {
+				groups[i][j] = i*per + j
+			}
+		}
+		return groups
+	}
+	c, _ := x.channelinfo()
+	spacial := per / c
+	groups := make([][]int, 0, n*spacial)
+	for i := 0; i < n; i++ {
+		for sp := 0; sp < spacial; sp++ {
+			g := make([]int, c)
+			for j := range g {
+				if x.frmt == flg.NHWC() {
+					g[j] = i*per + sp*c + j
+				} else {
+					g[j] = i*per + j*spacial + sp
+				}
+			}
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+//Forward does y = alpha*softmax(x) + beta*y
+func (s *SoftMax) Forward(x, y *Tensor) error {
+	if len(x.data) != len(y.data) {
+		return errors.New("(s *SoftMax) Forward: x and y need to be the same size")
+	}
+	var a SoftMaxAlgo
+	tmp := make([]float32, len(x.data))
+	for _, g := range s.groups(x) {
+		max := math.Inf(-1)
+		for _, i := range g {
+			max = math.Max(max, float64(x.data[i]))
+		}
+		var sum float64
+		for _, i := range g {
+			sum += math.Exp(float64(x.data[i]) - max)
+		}
+		for _, i := range g {
+			if s.algo == a.Log() {
+				tmp[i] = float32(float64(x.data[i]) - max - math.Log(sum))
+			} else {
+				tmp[i] = float32(math.Exp(float64(x.data[i])-max) / sum)
+			}
+		}
+	}
+	blend(y.data, tmp, s.fwd.alpha, s.fwd.beta)
+	return nil
+}
+
+//Inference is the same as Forward
+func (s *SoftMax) Inference(x, y *Tensor) error {
+	return s.Forward(x, y)
+}
+
+//Backward uses y from the last Forward and does dx = alpha*softmaxbackward(y,dy) + beta*dx
+func (s *SoftMax) Backward(x, dx, y, dy *Tensor) error {
+	if dx == nil {
+		return nil
+	}
+	if len(dx.data) != len(dy.data) || len(y.data) != len(dy.data) {
+		return errors.New("(s *SoftMax) Backward: dx, y and dy need to be the same size")
+	}
+	var a SoftMaxAlgo
+	tmp := make([]float32, len(dx.data))
+	for _, g := range s.groups(y) {
+		var sum float64
+		for _, i := range g {
+			if s.algo == a.Log() {
+				sum += float64(dy.data[i])
+			} else {
+				sum += float64(dy.data[i]) * float64(y.data[i])
+			}
+		}
+		for _, i := range g {
+			if s.algo == a.Log() {
+				tmp[i] = float32(float64(dy.data[i]) - math.Exp(float64(y.data[i]))*sum)
+			} else {
+				tmp[i] = float32(float64(y.data[i]) * (float64(dy.data[i]) - sum))
+			}
+		}
+	}
+	blend(dx.data, tmp, s.bwd.alpha, s.bwd.beta)
+	return nil
+}
+
+//SetForwardScalars sets the forward scalars.  Default is alpha 1, beta 0.
+func (s *SoftMax) SetForwardScalars(alpha, beta float64) { s.fwd = scalars{alpha, beta} }
+
+//SetBackwardScalars sets the backward scalars.  Default is alpha 1, beta 0.
+func (s *SoftMax) SetBackwardScalars(alpha, beta float64) { s.bwd = scalars{alpha, beta} }
@@ -0,0 +1,194 @@
+package cpu
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+//TensorFormat is the memory layout of a host Tensor. Dims are always given in the order of the layout.
+type TensorFormat int32
+
+//NCHW sets and returns the NCHW flag
+func (t *TensorFormat) NCHW() TensorFormat {
+	*t = TensorFormat(0)
+	return *t
+}
+
+//NHWC sets and returns the NHWC flag
+func (t *TensorFormat) NHWC() TensorFormat {
+	*t = TensorFormat(1)
+	return *t
+}
+
+func (t TensorFormat) String() string {
+	var flg TensorFormat
+	switch t {
+	case flg.NCHW():
+		return "NCHW"
+	case flg.NHWC():
+		return "NHWC"
+	}
+	return "Unsupported Format"
+}
+
+//Tensor is a float32 tensor that lives in host memory.
+//It is the cpu counterpart of layers.Tensor.
+type Tensor struct {
+	frmt TensorFormat
+	dims []int32
+	data []float32
+}
+
+//CreateTensor creates a zeroed tensor with the format and dims passed.
+func CreateTensor(frmt TensorFormat, dims []int32) (*Tensor, error) {
+	vol := Volume(dims)
+	if vol <= 0 {
+		return nil, errors.New("cpu.CreateTensor: dims need to be greater than zero")
+	}
+	return &Tensor{
+		frmt: frmt,
+		dims: copydims(dims),
+		data: make([]float32, vol),
+	}, nil
+}
+
+//CreateTensorFromSlice creates a tensor that uses data as its memory.  len(data) must equal the volume of dims.
+func CreateTensorFromSlice(frmt TensorFormat, dims []int32, data []float32) (*Tensor, error) {
+	if int(Volume(dims)) != len(data) {
+		return nil, errors.New("cpu.CreateTensorFromSlice: len(data) doesn't match the volume of dims")
+	}
+	return &Tensor{
+		frmt: frmt,
+		dims: copydims(dims),
+		data: data,
+	}, nil
+}
+
+//Dims returns a copy of the dims of the tensor
+func (t *Tensor) Dims() []int32 {
+	return copydims(t.dims)
+}
+
+//Format returns the format of the tensor
+func (t *Tensor) Format() TensorFormat {
+	return t.frmt
+}
+
+//Properties returns the format and dims of the tensor
+func (t *Tensor) Properties() (TensorFormat, []int32) {
+	return t.frmt, t.Dims()
+}
+
+//Vol returns the number of elements in the tensor
+func (t *Tensor) Vol() int32 {
+	return int32(len(t.data))
+}
+
+//Data returns the backing slice of the tensor.  Changes to the slice are changes to the tensor.
+func (t *Tensor) Data() []float32 {
+	return t.data
+}
+
+//LoadValuesFromSLice copies input into the tensor.  The length of input needs to be the volume of the tensor.
+func (t *Tensor) LoadValuesFromSLice(input []float32) error {
+	if len(input) != len(t.data) {
+		return errors.New("(t *Tensor) LoadValuesFromSLice: len(input) doesn't match tensor volume")
+	}
+	copy(t.data, input)
+	return nil
+}
+
+//SetValues sets all the values in the tensor to input
+func (t *Tensor) SetValues(input float32) {
+	for i := range t.data {
+		t.data[i] = input
+	}
+}
+
+//ScaleValues scales all the values in the tensor by alpha
+func (t *Tensor) ScaleValues(alpha float32) {
+	for i := range t.data {
+		t.data[i] *= alpha
+	}
+}
+
+//SetRandomNormal sets the values of the tensor to a random normal distribution with the mean and std passed.
+func (t *Tensor) SetRandomNormal(rng *rand.Rand, mean, std float32) {
+	for i := range t.data {
+		t.data[i] = float32(rng.NormFloat64())*std + mean
+	}
+}
+
+//SetRandom sets the values of the tensor with a normal distribution with a std of sqrt(2/fanin).
+//This is the same init that is used for the cnn layers on the gpu.
+func (t *Tensor) SetRandom(rng *rand.Rand, fanin int32) {
+	t.SetRandomNormal(rng, 0, float32(math.Sqrt(2/float64(fanin))))
+}
+
+//shape4d returns the n,c,h,w of a 4d tensor and the strides of each one so that
+//the index of an element is n*sn+c*sc+h*sh+w*sw regardless of the format.
+func (t *Tensor) shape4d() (dims [4]int, strides [4]int, err error) {
+	if len(t.dims) != 4 {
+		return dims, strides, errors.New("cpu: only 4d tensors are supported for this operation")
+	}
+	var flg TensorFormat
+	switch t.frmt {
+	case flg.NCHW():
+		dims = [4]int{int(t.dims[0]), int(t.dims[1]), int(t.dims[2]), int(t.dims[3])}
+		strides = [4]int{dims[1] * dims[2] * dims[3], dims[2] * dims[3], dims[3], 1}
+	case flg.NHWC():
+		dims = [4]int{int(t.dims[0]), int(t.dims[3]), int(t.dims[1]), int(t.dims[2])}
+		strides = [4]int{dims[1] * dims[2] * dims[3], 1, dims[3] * dims[1], dims[1]}
+	default:
+		return dims, strides, errors.New("cpu: unsupported tensor format")
+	}
+	return dims, strides, nil
+}
+
+//channelinfo returns the number of channels and a function that returns the channel of element i.
+func (t *Tensor) channelinfo() (int, func(i int) int) {
+	var flg TensorFormat
+	if len(t.dims) < 2 {
+		return 1, func(i int) int { return 0 }
+	}
+	if t.frmt == flg.NHWC() {
+		c := int(t.dims[len(t.dims)-1])
+		return c, func(i int) int { return i % c }
+	}
+	c := int(t.dims[1])
+	spacial := int(Volume(t.dims[2:]))
+	return c, func(i int) int { return (i / spacial) % c }
+}
+
+func copydims(dims []int32) []int32 {
+	x := make([]int32, len(dims))
+	copy(x, dims)
+	return x
+}
+
+func comparedims(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//blend does dst = alpha*src + beta*dst.  If beta is zero dst is overwritten so NaNs in dst won't propagate.
+func blend(dst, src []float32, alpha, beta float64) {
+	a, b := float32(alpha), float32(beta)
+	if b == 0 {
+		for i := range dst {
+			dst[i] = a * src[i]
+		}
+		return
+	}
+	for i := range dst {
+		dst[i] = a*src[i] + b*dst[i]
+	}
+}
@@ -0,0 +1,101 @@
+package cpu
+
+import (
+	"errors"
+	"math"
+)
+
+//Trainer is the host counterpart of trainer.Trainer.
+type Trainer interface {
+	UpdateWeights(dw, w *Tensor, batch, counter int) error
+	L1L2Loss() (float32, float32)
+	SetRates(rate, dwalpha float32)
+	SetDecays(l1, l2 float32)
+}
+
+//Adam is the host version of the adam trainer.  It uses the same defaults as trainer.Adam.
+//The delta weights are zeroed after each update.
+type Adam struct {
+	gsum, xsum     []float32
+	rate, dwalpha  float32
+	beta1, beta2   float32
+	eps            float32
+	decay1, decay2 float32
+	l1, l2         float32
+}
+
+//CreateAdam creates an adam trainer with default rate .001, beta1 .9, beta2 .999 and eps 1e-8
+func CreateAdam(decay1, decay2 float32) *Adam {
+	return &Adam{
+		rate:    .001,
+		dwalpha: 1,
+		beta1:   .9,
+		beta2:   .999,
+		eps:     1e-8,
+		decay1:  decay1,
+		decay2:  decay2,
+	}
+}
+
+//SetRates sets the learning rate and the dwalpha.  dwalpha scales the delta weights before they are used.
+func (a *Adam) SetRates(rate, dwalpha float32) {
+	a.rate, a.dwalpha = rate, dwalpha
+}
+
+//SetDecays sets the l1 and l2 decay
+func (a *Adam) SetDecays(l1, l2 float32) {
+	a.decay1, a.decay2 = l1, l2
+}
+
+//SetBetas sets beta1 and beta2
+func (a *Adam) SetBetas(beta1, beta2 float32) {
+	a.beta1, a.beta2 = beta1, beta2
+}
+
+//SetEps sets eps
+func (a *Adam) SetEps(eps float32) {
+	a.eps = eps
+}
+
+//L1L2Loss returns the l1 and l2 loss of the last update
+func (a *Adam) L1L2Loss() (float32, float32) {
+	return a.l1, a.l2
+}
+
+//UpdateWeights updates w with dw. counter is the number of updates done so far starting at 0.
+func (a *Adam) UpdateWeights(dw, w *Tensor, batch, counter int) error {
+	if len(dw.data) != len(w.data) {
+		return errors.New("(a *Adam) UpdateWeights: dw and w need to be the same size")
+	}
+	if batch < 1 {
+		return errors.New("(a *Adam) UpdateWeights: batch needs to be greater than zero")
+	}
+	if a.gsum == nil {
+		a.gsum = make([]float32, len(w.data))
+		a.xsum = make([]float32, len(w.data))
+	} else if len(a.gsum) != len(w.data) {
+		return errors.New("(a *Adam) UpdateWeights: trainer was used with different sized weights")
+	}
+	t := float64(counter + 1)
+	correction1 := float32(1 - math.Pow(float64(a.beta1), t))
+	correction2 := float32(1 - math.Pow(float64(a.beta2), t))
+	var l1, l2 float32
+	for i := range w.data {
+		l1 += float32(math.Abs(float64(w.data[i]))) * a.decay1
+		l2 += w.data[i] * w.data[i] * a.decay2 / 2
+		g := a.dwalpha*dw.data[i]/float32(batch) + a.decay2*w.data[i]
+		if w.data[i] > 0 {
+			g += a.decay1
+		} else if w.data[i] < 0 {
+			g -= a.decay1
+		}
+		a.gsum[i] = a.beta1*a.gsum[i] + (1-a.beta1)*g
+		a.xsum[i] = a.beta2*a.xsum[i] + (1-a.beta2)*g*g
+		mhat := a.gsum[i] / correction1
+		vhat := a.xsum[i] / correction2
+		w.data[i] -= a.rate * mhat / (float32(math.Sqrt(float64(vhat))) + a.eps)
+		dw.data[i] = 0
+	}
+	a.l1, a.l2 = l1, l2
+	return nil
+}
@@ -0,0 +1,144 @@
+//Package augment has image augmentations that run on the host.  Ops are put together into a Chain that is a data.Transform,
+//so it can be given to a DataLoader.  MixUp and CutMix mix the samples of a batch and are data.BatchTransforms.
+//
+//Every random choice is made with the rng that is passed in.  The DataLoader seeds it for each sample with data.SampleSeed,
+//so the augmentations of a run can be made again.
+//
+//Values aren't clamped, so the ops work on images that are scaled or not.
+package augment
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math/rand"
+
+	"github.com/dereklstinson/gocunets/data"
+	"github.com/dereklstinson/gocunets/data/datasets"
+)
+
+//Shape is the shape of an image sample
+type Shape struct {
+	Channels int
+	Height   int
+	Width    int
+	Layout   data.Layout
+}
+
+//Size returns the number of values in an image of shape s
+func (s Shape) Size() int {
+	return s.Channels * s.Height * s.Width
+}
+
+//index returns the position of the value of channel c of pixel (x,y)
+func (s Shape) index(c, y, x int) int {
+	if s.Layout == data.HWC {
+		return (y*s.Width+x)*s.Channels + c
+	}
+	return (c*s.Height+y)*s.Width + x
+}
+
+func (s Shape) check() error {
+	if s.Channels < 1 || s.Height < 1 || s.Width < 1 {
+		return fmt.Errorf("shape %dx%dx%d needs dims of at least 1", s.Channels, s.Height, s.Width)
+	}
+	if s.Layout != data.CHW && s.Layout != data.HWC {
+		return fmt.Errorf("unsupported layout %d", s.Layout)
+	}
+	return nil
+}
+
+//Op is one step of a Chain
+type Op interface {
+	//Out returns the shape of the images that Do makes out of images of shape in
+	Out(in Shape) (Shape, error)
+	//Do changes values, an image of shape in.  values belong to the Chain and can be changed in place.
+	//Ops that change the shape return a new slice.
+	Do(values []float32, in Shape, r *rand.Rand) []float32
+}
+
+//Chain runs ops one after the other on images of shape In.  It satisfies data.Transform.  Targets aren't changed.
+type Chain struct {
+	in     Shape
+	out    Shape
+	shapes []Shape
+	ops    []Op
+}
+
+//CreateChain creates a Chain of ops for images of shape in
+func CreateChain(in Shape, ops ...Op) (*Chain, error) {
+	err := in.check()
+	if err != nil {
+		return nil, fmt.Errorf("CreateChain: %v", err)
+	}
+	c := &Chain{in: in, ops: ops, shapes: make([]Shape, len(ops))}
+	s := in
+	for i, op := range ops {
+		c.shapes[i] = s
+		s, err = op.Out(s)
+		if err != nil {
+			return nil, fmt.Errorf("CreateChain: op %d: %v", i, err)
+		}
+	}
+	c.out = s
+	return c, nil
+}
+
+//In returns the shape of the images the Chain takes
+func (c *Chain) In() Shape {
+	return c.in
+}
+
+//Out returns the shape of the images the Chain makes
+func (c *Chain) Out() Shape {
+	return c.out
+}
+
+//Apply satisfies data.Transform
+func (c *Chain) Apply(input, target []float32, r *rand.Rand) (newinput, newtarget []float32, err error) {
+	if len(input) != c.in.Size() {
+		return nil, nil, fmt.Errorf("(c *Chain) Apply: input has %d values but the shape needs %d", len(input), c.in.Size())
+	}
+	values := make([]float32, len(input))
+	copy(values, input)
+	for i, op := range c.ops {
+		values = op.Do(values, c.shapes[i], r)
+	}
+	return values, target, nil
+}
+
+//ApplyTensor runs the chain on each of the n images in values, which is an NCHW or NHWC tensor that matches the layout of the chain.
+//Image i is changed with an rng seeded with data.SampleSeed(seed, 0, i).  values isn't changed.
+func (c *Chain) ApplyTensor(values []float32, n int, seed int64) ([]float32, error) {
+	if n < 1 || len(values) != n*c.in.Size() {
+		return nil, fmt.Errorf("(c *Chain) ApplyTensor: %d values aren't %d images of %d", len(values), n, c.in.Size())
+	}
+	out := make([]float32, 0, n*c.out.Size())
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < n; i++ {
+		r.Seed(data.SampleSeed(seed, 0, i))
+		img, _, err := c.Apply(values[i*c.in.Size():(i+1)*c.in.Size()], nil, r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, img...)
+	}
+	return out, nil
+}
+
+//ApplyImage runs the chain on img.  img needs to be the height and width of the chain.
+//Values are from 0 to 255 and are clamped to that when the image is made.
+func (c *Chain) ApplyImage(img image.Image, r *rand.Rand) (image.Image, error) {
+	b := img.Bounds()
+	if b.Dx() != c.in.Width || b.Dy() != c.in.Height {
+		return nil, fmt.Errorf("(c *Chain) ApplyImage: image is %dx%d but the chain takes %dx%d", b.Dx(), b.Dy(), c.in.Width, c.in.Height)
+	}
+	if c.in.Channels != 1 && c.in.Channels != 3 {
+		return nil, errors.New("(c *Chain) ApplyImage: images need 1 or 3 channels")
+	}
+	values, _, err := c.Apply(datasets.ImageValues(img, c.in.Width, c.in.Height, c.in.Channels, c.in.Layout), nil, r)
+	if err != nil {
+		return nil, err
+	}
+	return ToImage(values, c.out)
+}
@@ -0,0 +1,153 @@
+package augment
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/data"
+)
+
+func ramp(s Shape) []float32 {
+	values := make([]float32, s.Size())
+	for i := range values {
+		values[i] = float32(i + 1)
+	}
+	return values
+}
+
+func TestChain(t *testing.T) {
+	in := Shape{Channels: 3, Height: 8, Width: 6, Layout: data.HWC}
+	c, err := CreateChain(in,
+		RandomCrop{Height: 6, Width: 6, Pad: 2},
+		Flip{Horizontal: .5},
+		Rotate{Degrees: 15},
+		ScaleJitter{Min: .8, Max: 1.2},
+		ColorJitter{Brightness: .2, Contrast: .2, Saturation: .2},
+		Cutout{Size: 2},
+		Resize{Height: 4, Width: 5},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := c.Out(); out.Height != 4 || out.Width != 5 || out.Channels != 3 {
+		t.Fatalf("out shape %v", out)
+	}
+	input := ramp(in)
+	a, _, err := c.Apply(input, nil, rand.New(rand.NewSource(7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := c.Apply(input, nil, rand.New(rand.NewSource(7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != c.Out().Size() {
+		t.Fatalf("got %d values expected %d", len(a), c.Out().Size())
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatal("same seed gave different images")
+		}
+	}
+	for i, v := range ramp(in) {
+		if input[i] != v {
+			t.Fatal("input was changed")
+		}
+	}
+	_, err = CreateChain(in, RandomCrop{Height: 13, Width: 6, Pad: 2})
+	if err == nil {
+		t.Error("crop bigger than the padded image should error")
+	}
+}
+
+func TestOps(t *testing.T) {
+	s := Shape{Channels: 2, Height: 3, Width: 4, Layout: data.CHW}
+	r := rand.New(rand.NewSource(1))
+	flipped := Flip{Horizontal: 1, Vertical: 1}.Do(ramp(s), s, r)
+	orig := ramp(s)
+	for c := 0; c < 2; c++ {
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				if flipped[s.index(c, y, x)] != orig[s.index(c, 2-y, 3-x)] {
+					t.Fatalf("flip wrong at %d,%d,%d", c, y, x)
+				}
+			}
+		}
+	}
+	same := Rotate{}.Do(ramp(s), s, r)
+	for i := range same {
+		if same[i] != orig[i] {
+			t.Fatal("rotate by 0 changed the image")
+		}
+	}
+	same = Resize{Height: 3, Width: 4}.Do(ramp(s), s, r)
+	for i := range same {
+		if same[i] != orig[i] {
+			t.Fatal("resize to the same size changed the image")
+		}
+	}
+	crop := RandomCrop{Height: 3, Width: 4}.Do(ramp(s), s, r)
+	for i := range crop {
+		if crop[i] != orig[i] {
+			t.Fatal("crop of the whole image changed it")
+		}
+	}
+	cut := Cutout{Size: 100}.Do(ramp(s), s, r)
+	for i := range cut {
+		if cut[i] != 0 {
+			t.Fatal("cutout bigger than the image didn't zero it")
+		}
+	}
+}
+
+func TestMix(t *testing.T) {
+	s := Shape{Channels: 1, Height: 4, Width: 4, Layout: data.CHW}
+	b := &data.Batch{Count: 3}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < s.Size(); j++ {
+			b.Inputs = append(b.Inputs, float32(i))
+		}
+		b.Targets = append(b.Targets, data.OneHot(i, 4)...)
+	}
+	err := CutMix{Alpha: 1, Shape: s}.ApplyBatch(b, s.Size(), 4, rand.New(rand.NewSource(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		target := b.Targets[i*4 : (i+1)*4]
+		var sum float32
+		for _, v := range target {
+			sum += v
+		}
+		if sum < .999 || sum > 1.001 || target[3] != 0 {
+			t.Errorf("sample %d has target %v", i, target)
+		}
+		var own int
+		for _, v := range b.Inputs[i*s.Size() : (i+1)*s.Size()] {
+			if v == float32(i) {
+				own++
+			}
+		}
+		if lam := float32(own) / float32(s.Size()); target[i] < lam-.001 {
+			t.Errorf("sample %d keeps %v of its image but its target is %v", i, lam, target)
+		}
+	}
+	for _, v := range b.Inputs[3*s.Size():] {
+		if v != 3 {
+			t.Fatal("padding sample was mixed")
+		}
+	}
+	err = MixUp{Alpha: .4}.ApplyBatch(b, s.Size(), 4, rand.New(rand.NewSource(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		var sum float32
+		for _, v := range b.Targets[i*4 : (i+1)*4] {
+			sum += v
+		}
+		if sum < .999 || sum > 1.001 {
+			t.Errorf("mixup target %d sums to %v", i, sum)
+		}
+	}
+}
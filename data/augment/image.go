@@ -0,0 +1,73 @@
+package augment
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+//ToImage makes an image out of values, an image of shape s with 1 or 3 channels.  Values are clamped to 0 to 255.
+func ToImage(values []float32, s Shape) (image.Image, error) {
+	if err := s.check(); err != nil {
+		return nil, fmt.Errorf("ToImage: %v", err)
+	}
+	if len(values) != s.Size() {
+		return nil, fmt.Errorf("ToImage: %d values but the shape has %d", len(values), s.Size())
+	}
+	rect := image.Rect(0, 0, s.Width, s.Height)
+	switch s.Channels {
+	case 1:
+		img := image.NewGray(rect)
+		for y := 0; y < s.Height; y++ {
+			for x := 0; x < s.Width; x++ {
+				img.SetGray(x, y, color.Gray{Y: tobyte(values[s.index(0, y, x)])})
+			}
+		}
+		return img, nil
+	case 3:
+		img := image.NewRGBA(rect)
+		for y := 0; y < s.Height; y++ {
+			for x := 0; x < s.Width; x++ {
+				img.SetRGBA(x, y, color.RGBA{
+					R: tobyte(values[s.index(0, y, x)]),
+					G: tobyte(values[s.index(1, y, x)]),
+					B: tobyte(values[s.index(2, y, x)]),
+					A: 255,
+				})
+			}
+		}
+		return img, nil
+	}
+	return nil, fmt.Errorf("ToImage: images need 1 or 3 channels not %d", s.Channels)
+}
+
+func tobyte(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + .5)
+}
+
+//ResizeImage scales img to width x height by nearest neighbor.  If width or height is 0 it is found from the other one
+//so the aspect ratio stays the same.
+func ResizeImage(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	if width == 0 && height == 0 {
+		width, height = b.Dx(), b.Dy()
+	} else if width == 0 {
+		width = (b.Dx()*height + b.Dy()/2) / b.Dy()
+	} else if height == 0 {
+		height = (b.Dy()*width + b.Dx()/2) / b.Dx()
+	}
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := b.Min.Y + y*b.Dy()/height
+		for x := 0; x < width; x++ {
+			out.Set(x, y, img.At(b.Min.X+x*b.Dx()/width, sy))
+		}
+	}
+	return out
+}
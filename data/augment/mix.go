@@ -0,0 +1,128 @@
+package augment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/dereklstinson/gocunets/data"
+)
+
+//MixUp mixes each sample of a batch with another sample of the batch.  The inputs and the targets are mixed by
+//lam*a + (1-lam)*b where lam is drawn once for the batch from Beta(Alpha, Alpha).  It satisfies data.BatchTransform.
+//
+//Targets need to be one hot or another kind that can be mixed.
+type MixUp struct {
+	Alpha float64
+}
+
+//ApplyBatch satisfies data.BatchTransform
+func (m MixUp) ApplyBatch(b *data.Batch, inputsize, targetsize int, r *rand.Rand) error {
+	if m.Alpha <= 0 {
+		return errors.New("(m MixUp) ApplyBatch: Alpha needs to be more than 0")
+	}
+	if b.Count < 2 {
+		return nil
+	}
+	lam := float32(beta(r, m.Alpha))
+	perm := r.Perm(b.Count)
+	mix(b.Inputs, inputsize, b.Count, perm, lerp(lam))
+	mix(b.Targets, targetsize, b.Count, perm, lerp(lam))
+	return nil
+}
+
+//CutMix pastes a box from another sample of the batch over each sample.  The area of the box is 1-lam of the image
+//where lam is drawn once for the batch from Beta(Alpha, Alpha).  The targets are mixed by the area of the box that is in the image.
+//It satisfies data.BatchTransform.
+type CutMix struct {
+	Alpha float64
+	Shape Shape
+}
+
+//ApplyBatch satisfies data.BatchTransform
+func (m CutMix) ApplyBatch(b *data.Batch, inputsize, targetsize int, r *rand.Rand) error {
+	if m.Alpha <= 0 {
+		return errors.New("(m CutMix) ApplyBatch: Alpha needs to be more than 0")
+	}
+	if err := m.Shape.check(); err != nil {
+		return fmt.Errorf("(m CutMix) ApplyBatch: %v", err)
+	}
+	if inputsize != m.Shape.Size() {
+		return fmt.Errorf("(m CutMix) ApplyBatch: inputs have %d values but the shape has %d", inputsize, m.Shape.Size())
+	}
+	if b.Count < 2 {
+		return nil
+	}
+	s := m.Shape
+	cut := math.Sqrt(1 - beta(r, m.Alpha))
+	w, h := int(float64(s.Width)*cut), int(float64(s.Height)*cut)
+	cx, cy := r.Intn(s.Width), r.Intn(s.Height)
+	x1, y1, x2, y2 := box(s, cx-w/2, cy-h/2, w, h)
+	perm := r.Perm(b.Count)
+	mix(b.Inputs, inputsize, b.Count, perm, func(dst, a, other []float32) {
+		for c := 0; c < s.Channels; c++ {
+			for y := y1; y < y2; y++ {
+				for x := x1; x < x2; x++ {
+					i := s.index(c, y, x)
+					dst[i] = other[i]
+				}
+			}
+		}
+	})
+	lam := 1 - float32((x2-x1)*(y2-y1))/float32(s.Height*s.Width)
+	mix(b.Targets, targetsize, b.Count, perm, lerp(lam))
+	return nil
+}
+
+//mix calls f for each of the count samples of values with the sample, the sample it is mixed with, and where to put the mix.
+//The samples are copied first so f sees the samples from before the batch was changed.
+func mix(values []float32, size, count int, perm []int, f func(dst, a, other []float32)) {
+	if size == 0 {
+		return
+	}
+	orig := make([]float32, size*count)
+	copy(orig, values)
+	for i, j := range perm {
+		f(values[i*size:(i+1)*size], orig[i*size:(i+1)*size], orig[j*size:(j+1)*size])
+	}
+}
+
+//lerp returns a mix that puts lam*a + (1-lam)*other into dst
+func lerp(lam float32) func(dst, a, other []float32) {
+	return func(dst, a, other []float32) {
+		for i := range dst {
+			dst[i] = lam*a[i] + (1-lam)*other[i]
+		}
+	}
+}
+
+//beta draws from Beta(a, a)
+func beta(r *rand.Rand, a float64) float64 {
+	x, y := gamma(r, a), gamma(r, a)
+	if x+y == 0 {
+		return .5
+	}
+	return x / (x + y)
+}
+
+//gamma draws from Gamma(a, 1) with the method of Marsaglia and Tsang
+func gamma(r *rand.Rand, a float64) float64 {
+	if a < 1 {
+		return gamma(r, a+1) * math.Pow(r.Float64(), 1/a)
+	}
+	d := a - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := r.Float64()
+		if math.Log(u) < .5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
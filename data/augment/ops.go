@@ -0,0 +1,300 @@
+package augment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+//RandomCrop crops a random Height x Width window out of the image after it is padded with Pad zeros on each side
+type RandomCrop struct {
+	Height int
+	Width  int
+	Pad    int
+}
+
+//Out satisfies Op
+func (o RandomCrop) Out(in Shape) (Shape, error) {
+	if o.Height < 1 || o.Width < 1 || o.Pad < 0 {
+		return in, errors.New("RandomCrop: Height and Width need to be at least 1 and Pad can't be negative")
+	}
+	if o.Height > in.Height+2*o.Pad || o.Width > in.Width+2*o.Pad {
+		return in, fmt.Errorf("RandomCrop: %dx%d is bigger than the padded image", o.Width, o.Height)
+	}
+	in.Height, in.Width = o.Height, o.Width
+	return in, nil
+}
+
+//Do satisfies Op
+func (o RandomCrop) Do(values []float32, in Shape, r *rand.Rand) []float32 {
+	out, _ := o.Out(in)
+	y0 := r.Intn(in.Height+2*o.Pad-o.Height+1) - o.Pad
+	x0 := r.Intn(in.Width+2*o.Pad-o.Width+1) - o.Pad
+	crop := make([]float32, out.Size())
+	for c := 0; c < in.Channels; c++ {
+		for y := 0; y < out.Height; y++ {
+			sy := y0 + y
+			if sy < 0 || sy >= in.Height {
+				continue
+			}
+			for x := 0; x < out.Width; x++ {
+				sx := x0 + x
+				if sx < 0 || sx >= in.Width {
+					continue
+				}
+				crop[out.index(c, y, x)] = values[in.index(c, sy, sx)]
+			}
+		}
+	}
+	return crop
+}
+
+//Flip mirrors the image left to right with a chance of Horizontal and top to bottom with a chance of Vertical
+type Flip struct {
+	Horizontal float64
+	Vertical   float64
+}
+
+//Out satisfies Op
+func (o Flip) Out(in Shape) (Shape, error) {
+	return in, nil
+}
+
+//Do satisfies Op
+func (o Flip) Do(values []float32, in Shape, r *rand.Rand) []float32 {
+	h := r.Float64() < o.Horizontal
+	v := r.Float64() < o.Vertical
+	for c := 0; c < in.Channels; c++ {
+		if h {
+			for y := 0; y < in.Height; y++ {
+				for x := 0; x < in.Width/2; x++ {
+					i, j := in.index(c, y, x), in.index(c, y, in.Width-1-x)
+					values[i], values[j] = values[j], values[i]
+				}
+			}
+		}
+		if v {
+			for y := 0; y < in.Height/2; y++ {
+				for x := 0; x < in.Width; x++ {
+					i, j := in.index(c, y, x), in.index(c, in.Height-1-y, x)
+					values[i], values[j] = values[j], values[i]
+				}
+			}
+		}
+	}
+	return values
+}
+
+//Rotate turns the image around its center by a random angle from -Degrees to Degrees.  The corners are filled with zeros.
+type Rotate struct {
+	Degrees float64
+}
+
+//Out satisfies Op
+func (o Rotate) Out(in Shape) (Shape, error) {
+	return in, nil
+}
+
+//Do satisfies Op
+func (o Rotate) Do(values []float32, in Shape, r *rand.Rand) []float32 {
+	a := (2*r.Float64() - 1) * o.Degrees * math.Pi / 180
+	cos, sin := math.Cos(a), math.Sin(a)
+	return sample(values, in, in, [4]float64{cos, sin, -sin, cos}, false)
+}
+
+//ScaleJitter zooms the image around its center by a random scale from Min to Max.  The shape stays the same,
+//so scales over 1 crop the edges and scales under 1 leave a border of zeros.
+type ScaleJitter struct {
+	Min float64
+	Max float64
+}
+
+//Out satisfies Op
+func (o ScaleJitter) Out(in Shape) (Shape, error) {
+	if o.Min <= 0 || o.Max < o.Min {
+		return in, errors.New("ScaleJitter: Min needs to be more than 0 and not more than Max")
+	}
+	return in, nil
+}
+
+//Do satisfies Op
+func (o ScaleJitter) Do(values []float32, in Shape, r *rand.Rand) []float32 {
+	s := o.Min + r.Float64()*(o.Max-o.Min)
+	return sample(values, in, in, [4]float64{1 / s, 0, 0, 1 / s}, false)
+}
+
+//Resize scales the image to Height x Width with bilinear interpolation
+type Resize struct {
+	Height int
+	Width  int
+}
+
+//Out satisfies Op
+func (o Resize) Out(in Shape) (Shape, error) {
+	if o.Height < 1 || o.Width < 1 {
+		return in, errors.New("Resize: Height and Width need to be at least 1")
+	}
+	in.Height, in.Width = o.Height, o.Width
+	return in, nil
+}
+
+//Do satisfies Op
+func (o Resize) Do(values []float32, in Shape, r *rand.Rand) []float32 {
+	out, _ := o.Out(in)
+	sx := float64(in.Width) / float64(out.Width)
+	sy := float64(in.Height) / float64(out.Height)
+	return sample(values, in, out, [4]float64{sx, 0, 0, sy}, true)
+}
+
+//sample makes an image of shape out with bilinear interpolation.  m maps a pixel of out to a pixel of in, with both measured from their centers:
+//
+//	inx = m[0]*outx + m[1]*outy
+//	iny = m[2]*outx + m[3]*outy
+//
+//If clampedges is true the pixels past the edges of in are the edge pixels.  Otherwise they are zeros.
+func sample(values []float32, in, out Shape, m [4]float64, clampedges bool) []float32 {
+	img := make([]float32, out.Size())
+	icx, icy := float64(in.Width-1)/2, float64(in.Height-1)/2
+	ocx, ocy := float64(out.Width-1)/2, float64(out.Height-1)/2
+	var xs, ys [2]int
+	var ws [2][2]float64
+	for y := 0; y < out.Height; y++ {
+		dy := float64(y) - ocy
+		for x := 0; x < out.Width; x++ {
+			dx := float64(x) - ocx
+			fx := m[0]*dx + m[1]*dy + icx
+			fy := m[2]*dx + m[3]*dy + icy
+			if clampedges {
+				fx = math.Max(0, math.Min(fx, float64(in.Width-1)))
+				fy = math.Max(0, math.Min(fy, float64(in.Height-1)))
+			}
+			x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+			wx, wy := fx-float64(x0), fy-float64(y0)
+			xs, ys = [2]int{x0, x0 + 1}, [2]int{y0, y0 + 1}
+			ws = [2][2]float64{{(1 - wx) * (1 - wy), wx * (1 - wy)}, {(1 - wx) * wy, wx * wy}}
+			for c := 0; c < in.Channels; c++ {
+				var v float64
+				for j, sy := range ys {
+					if sy < 0 || sy >= in.Height {
+						continue
+					}
+					for i, sx := range xs {
+						if sx < 0 || sx >= in.Width || ws[j][i] == 0 {
+							continue
+						}
+						v += ws[j][i] * float64(values[in.index(c, sy, sx)])
+					}
+				}
+				img[out.index(c, y, x)] = float32(v)
+			}
+		}
+	}
+	return img
+}
+
+//ColorJitter changes the brightness, contrast and saturation of the image by random factors.
+//Each factor is from 1-x to 1+x where x is its field.  Saturation is only changed in images with 3 channels.
+type ColorJitter struct {
+	Brightness float64
+	Contrast   float64
+	Saturation float64
+}
+
+//Out satisfies Op
+func (o ColorJitter) Out(in Shape) (Shape, error) {
+	if o.Brightness < 0 || o.Contrast < 0 || o.Saturation < 0 {
+		return in, errors.New("ColorJitter: fields can't be negative")
+	}
+	return in, nil
+}
+
+//Do satisfies Op
+func (o ColorJitter) Do(values []float32, in Shape, r *rand.Rand) []float32 {
+	factor := func(x float64) float32 {
+		return float32(math.Max(0, 1+(2*r.Float64()-1)*x))
+	}
+	b, c, s := factor(o.Brightness), factor(o.Contrast), factor(o.Saturation)
+	pixels := in.Height * in.Width
+	for i := range values {
+		values[i] *= b
+	}
+	gray := func(y, x int) float32 {
+		if in.Channels != 3 {
+			return values[in.index(0, y, x)]
+		}
+		return .299*values[in.index(0, y, x)] + .587*values[in.index(1, y, x)] + .114*values[in.index(2, y, x)]
+	}
+	var mean float32
+	for y := 0; y < in.Height; y++ {
+		for x := 0; x < in.Width; x++ {
+			mean += gray(y, x)
+		}
+	}
+	mean /= float32(pixels)
+	for i, v := range values {
+		values[i] = (v-mean)*c + mean
+	}
+	if in.Channels != 3 {
+		return values
+	}
+	for y := 0; y < in.Height; y++ {
+		for x := 0; x < in.Width; x++ {
+			g := gray(y, x)
+			for ch := 0; ch < 3; ch++ {
+				i := in.index(ch, y, x)
+				values[i] = (values[i]-g)*s + g
+			}
+		}
+	}
+	return values
+}
+
+//Cutout zeros a Size x Size square centered on a random pixel.  The square is cut off at the edges of the image.
+type Cutout struct {
+	Size int
+}
+
+//Out satisfies Op
+func (o Cutout) Out(in Shape) (Shape, error) {
+	if o.Size < 1 {
+		return in, errors.New("Cutout: Size needs to be at least 1")
+	}
+	return in, nil
+}
+
+//Do satisfies Op
+func (o Cutout) Do(values []float32, in Shape, r *rand.Rand) []float32 {
+	cy, cx := r.Intn(in.Height), r.Intn(in.Width)
+	zerobox(values, in, cx-o.Size/2, cy-o.Size/2, o.Size, o.Size)
+	return values
+}
+
+//box returns the part of the w x h box at (x0,y0) that is in an image of shape s
+func box(s Shape, x0, y0, w, h int) (x1, y1, x2, y2 int) {
+	x1, y1, x2, y2 = x0, y0, x0+w, y0+h
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > s.Width {
+		x2 = s.Width
+	}
+	if y2 > s.Height {
+		y2 = s.Height
+	}
+	return x1, y1, x2, y2
+}
+
+func zerobox(values []float32, s Shape, x0, y0, w, h int) {
+	x1, y1, x2, y2 := box(s, x0, y0, w, h)
+	for c := 0; c < s.Channels; c++ {
+		for y := y1; y < y2; y++ {
+			for x := x1; x < x2; x++ {
+				values[s.index(c, y, x)] = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+//Package data has datasets and a DataLoader that makes batches out of them on the host.
+//
+//Nothing in this package uses the gpu.  gocunets.LoadBatch puts a Batch into the tensors of a network.
+package data
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Dataset is a set of samples.  Each sample is an input and a target that are flattened to float32 slices.
+//Every sample of a dataset needs to have inputs of the same length and targets of the same length.
+type Dataset interface {
+	//Len returns the number of samples
+	Len() int
+	//Get returns sample i.  The slices might be held by the dataset, so they shouldn't be changed.
+	//Get needs to be safe to use from more than one goroutine if the dataset is used with Prefetch.
+	Get(i int) (input, target []float32, err error)
+}
+
+//SliceDataset is a dataset of samples that are already in memory
+type SliceDataset struct {
+	Inputs  [][]float32
+	Targets [][]float32
+}
+
+//CreateSliceDataset creates a SliceDataset.  inputs and targets need to have the same number of samples.
+func CreateSliceDataset(inputs, targets [][]float32) (*SliceDataset, error) {
+	if len(inputs) != len(targets) {
+		return nil, fmt.Errorf("CreateSliceDataset: %d inputs but %d targets", len(inputs), len(targets))
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("CreateSliceDataset: no samples")
+	}
+	return &SliceDataset{Inputs: inputs, Targets: targets}, nil
+}
+
+//Len satisfies Dataset
+func (s *SliceDataset) Len() int {
+	return len(s.Inputs)
+}
+
+//Get satisfies Dataset
+func (s *SliceDataset) Get(i int) (input, target []float32, err error) {
+	if i < 0 || i >= len(s.Inputs) {
+		return nil, nil, fmt.Errorf("(s *SliceDataset) Get: index %d out of range of %d samples", i, len(s.Inputs))
+	}
+	return s.Inputs[i], s.Targets[i], nil
+}
+
+//Layout is the order of the values of an image sample
+type Layout int
+
+const (
+	//CHW holds each channel one after the other.  It is the sample of an NCHW batch.
+	CHW Layout = iota
+	//HWC holds the channels of each pixel together.  It is the sample of an NHWC batch.
+	HWC
+)
+
+//OneHot returns a target of length classes with a 1 at label
+func OneHot(label, classes int) []float32 {
+	target := make([]float32, classes)
+	if label >= 0 && label < classes {
+		target[label] = 1
+	}
+	return target
+}
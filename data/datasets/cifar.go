@@ -0,0 +1,107 @@
+package datasets
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	cifarsize  = 32
+	cifarpixel = 3 * cifarsize * cifarsize
+)
+
+//CIFAR is a CIFAR-10 or CIFAR-100 dataset read from the binary batches.
+//Inputs are 3x32x32 in CHW order, which is how they are held in the files.
+type CIFAR struct {
+	images []byte
+	labeled
+}
+
+//ReadCIFAR10 reads CIFAR-10 binary batches.  Each record is a label byte and then the 3072 bytes of the image.
+func ReadCIFAR10(label Label, rs ...io.Reader) (*CIFAR, error) {
+	return readcifar(label, 10, 1, 0, rs)
+}
+
+//ReadCIFAR100 reads CIFAR-100 binary batches.  Each record is a coarse label byte, a fine label byte and then the image.
+//If fine is true the 100 fine classes are used, otherwise the 20 coarse classes are.
+func ReadCIFAR100(label Label, fine bool, rs ...io.Reader) (*CIFAR, error) {
+	if fine {
+		return readcifar(label, 100, 2, 1, rs)
+	}
+	return readcifar(label, 20, 2, 0, rs)
+}
+
+//LoadCIFAR10 reads the CIFAR-10 binary batches at paths
+func LoadCIFAR10(label Label, paths ...string) (*CIFAR, error) {
+	return loadcifar(paths, func(rs []io.Reader) (*CIFAR, error) { return ReadCIFAR10(label, rs...) })
+}
+
+//LoadCIFAR100 reads the CIFAR-100 binary batches at paths
+func LoadCIFAR100(label Label, fine bool, paths ...string) (*CIFAR, error) {
+	return loadcifar(paths, func(rs []io.Reader) (*CIFAR, error) { return ReadCIFAR100(label, fine, rs...) })
+}
+
+func loadcifar(paths []string, read func([]io.Reader) (*CIFAR, error)) (*CIFAR, error) {
+	rs := make([]io.Reader, len(paths))
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		rs[i] = f
+	}
+	return read(rs)
+}
+
+//readcifar reads the records of rs.  Each record has labelbytes label bytes and the class is the one at labelpos.
+func readcifar(label Label, classes, labelbytes, labelpos int, rs []io.Reader) (*CIFAR, error) {
+	err := label.check()
+	if err != nil {
+		return nil, err
+	}
+	if len(rs) == 0 {
+		return nil, errors.New("readcifar: no batches")
+	}
+	c := &CIFAR{labeled: labeled{label: label, classes: classes}}
+	record := make([]byte, labelbytes+cifarpixel)
+	for _, r := range rs {
+		br := bufio.NewReader(r)
+		for {
+			_, err = io.ReadFull(br, record)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("readcifar: record %d: %v", len(c.labels), err)
+			}
+			class := int(record[labelpos])
+			if class >= classes {
+				return nil, fmt.Errorf("readcifar: record %d has class %d", len(c.labels), class)
+			}
+			c.labels = append(c.labels, class)
+			c.images = append(c.images, record[labelbytes:]...)
+		}
+	}
+	return c, nil
+}
+
+//Len satisfies data.Dataset
+func (c *CIFAR) Len() int {
+	return len(c.labels)
+}
+
+//Get satisfies data.Dataset
+func (c *CIFAR) Get(i int) (input, target []float32, err error) {
+	if err = c.checkindex(i); err != nil {
+		return nil, nil, fmt.Errorf("(c *CIFAR) Get: %v", err)
+	}
+	input = make([]float32, cifarpixel)
+	for j, p := range c.images[i*cifarpixel : (i+1)*cifarpixel] {
+		input[j] = float32(p)
+	}
+	return input, c.label.target(c.labels[i], c.classes), nil
+}
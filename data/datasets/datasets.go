@@ -0,0 +1,60 @@
+//Package datasets reads datasets from IDX files, CIFAR-10 and CIFAR-100 binary batches, and ImageFolder directory trees.
+//Every dataset satisfies data.Dataset.
+//
+//Values are not scaled.  Pixels are from 0 to 255.
+package datasets
+
+import (
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/data"
+)
+
+//Label says how the class of a sample is put into its target
+type Label int
+
+const (
+	//OneHot makes the target a one hot vector that is the length of the number of classes
+	OneHot Label = iota
+	//Index makes the target a single value that is the class
+	Index
+)
+
+//target makes the target of class
+func (l Label) target(class, classes int) []float32 {
+	if l == Index {
+		return []float32{float32(class)}
+	}
+	return data.OneHot(class, classes)
+}
+
+func (l Label) check() error {
+	if l != OneHot && l != Index {
+		return fmt.Errorf("unsupported Label %d", l)
+	}
+	return nil
+}
+
+//labeled is the part that the datasets that have a class for each sample share
+type labeled struct {
+	label   Label
+	classes int
+	labels  []int
+}
+
+//Classes returns the number of classes
+func (l *labeled) Classes() int {
+	return l.classes
+}
+
+//Class returns the class of sample i.  An IDXDataset only has classes if its targets are rank 1.
+func (l *labeled) Class(i int) int {
+	return l.labels[i]
+}
+
+func (l *labeled) checkindex(i int) error {
+	if i < 0 || i >= len(l.labels) {
+		return fmt.Errorf("index %d out of range of %d samples", i, len(l.labels))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package datasets
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/data"
+)
+
+func TestIDX(t *testing.T) {
+	for _, typ := range []byte{IDXUint8, IDXInt8, IDXInt16, IDXInt32, IDXFloat32, IDXFloat64} {
+		x := &IDX{Type: typ, Dims: []int{2, 3, 2}, Data: []float32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}}
+		if typ != IDXUint8 {
+			x.Data[1] = -1
+		}
+		buf := new(bytes.Buffer)
+		if err := WriteIDX(buf, x); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadIDX(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(read, x) {
+			t.Errorf("type 0x%02x read back as %v expected %v", typ, read, x)
+		}
+	}
+
+	//readers that can't tell how much they have left only have Read
+	type plainreader struct{ io.Reader }
+	overflow := []byte{0, 0, IDXUint8, 3, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	short := append([]byte{0, 0, IDXFloat32, 1, 0, 0, 0x10, 0}, make([]byte, 10)...)
+	for _, file := range [][]byte{overflow, short} {
+		if _, err := ReadIDX(bytes.NewReader(file)); err == nil {
+			t.Errorf("reading % x should fail", file[:8])
+		}
+		if _, err := ReadIDX(plainreader{bytes.NewReader(file)}); err == nil {
+			t.Errorf("reading % x without a size should fail", file[:8])
+		}
+	}
+
+	images := &IDX{Type: IDXUint8, Dims: []int{3, 2, 2}, Data: []float32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}}
+	labels := &IDX{Type: IDXUint8, Dims: []int{3}, Data: []float32{2, 0, 1}}
+	ds, err := CreateIDXDataset(images, labels, 0, OneHot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, target, err := ds.Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Len() != 3 || ds.Classes() != 3 || !reflect.DeepEqual(input, []float32{8, 9, 10, 11}) || !reflect.DeepEqual(target, []float32{0, 1, 0}) {
+		t.Errorf("sample 2 is %v %v of %d samples and %d classes", input, target, ds.Len(), ds.Classes())
+	}
+	ds, err = CreateIDXDataset(images, labels, 10, Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, target, _ = ds.Get(0); !reflect.DeepEqual(target, []float32{2}) {
+		t.Errorf("index target is %v", target)
+	}
+	if _, err = CreateIDXDataset(images, labels, 2, OneHot); err == nil {
+		t.Error("class 2 of 2 classes should fail")
+	}
+}
+
+func TestCIFAR(t *testing.T) {
+	record := func(labels ...byte) []byte {
+		r := append([]byte(nil), labels...)
+		for i := 0; i < cifarpixel; i++ {
+			r = append(r, byte(i))
+		}
+		return r
+	}
+	batch := append(record(3), record(9)...)
+	c, err := ReadCIFAR10(OneHot, bytes.NewReader(batch), bytes.NewReader(record(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 3 || c.Class(1) != 9 || c.Class(2) != 1 {
+		t.Fatalf("read %d records with classes %v", c.Len(), c.labels)
+	}
+	input, target, err := c.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(input) != cifarpixel || input[1024] != 0 || input[1025] != 1 || len(target) != 10 || target[9] != 1 {
+		t.Errorf("sample 1 is wrong")
+	}
+	if _, err = ReadCIFAR10(OneHot, bytes.NewReader(batch[:100])); err == nil {
+		t.Error("a short record should fail")
+	}
+
+	c, err = ReadCIFAR100(Index, true, bytes.NewReader(record(4, 77)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, target, _ = c.Get(0); c.Classes() != 100 || target[0] != 77 {
+		t.Errorf("fine target is %v of %d classes", target, c.Classes())
+	}
+	c, err = ReadCIFAR100(Index, false, bytes.NewReader(record(4, 77)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, target, _ = c.Get(0); c.Classes() != 20 || target[0] != 4 {
+		t.Errorf("coarse target is %v of %d classes", target, c.Classes())
+	}
+}
+
+func TestImageFolder(t *testing.T) {
+	root, err := ioutil.TempDir("", "imagefolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	write := func(class, name string, c color.RGBA) {
+		dir := filepath.Join(root, class)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 4; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err = png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("dog", "a.png", color.RGBA{10, 20, 30, 255})
+	write("cat", "a.png", color.RGBA{1, 2, 3, 255})
+	write("cat", "b.png", color.RGBA{4, 5, 6, 255})
+	if err = ioutil.WriteFile(filepath.Join(root, "cat", "notes.txt"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := CreateImageFolder(root, 2, 2, 3, data.CHW, OneHot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 3 || !reflect.DeepEqual(f.ClassNames, []string{"cat", "dog"}) {
+		t.Fatalf("found %d images with classes %v", f.Len(), f.ClassNames)
+	}
+	input, target, err := f.Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, []float32{10, 10, 10, 10, 20, 20, 20, 20, 30, 30, 30, 30}) || !reflect.DeepEqual(target, []float32{0, 1}) {
+		t.Errorf("CHW sample is %v %v", input, target)
+	}
+
+	f, err = CreateImageFolder(root, 3, 1, 3, data.HWC, Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, target, err = f.Get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, []float32{1, 2, 3, 1, 2, 3, 1, 2, 3}) || target[0] != 0 {
+		t.Errorf("HWC sample is %v %v", input, target)
+	}
+}
@@ -0,0 +1,292 @@
+package datasets
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+)
+
+//IDX data types
+const (
+	IDXUint8   = 0x08
+	IDXInt8    = 0x09
+	IDXInt16   = 0x0B
+	IDXInt32   = 0x0C
+	IDXFloat32 = 0x0D
+	IDXFloat64 = 0x0E
+)
+
+//IDX is an IDX file.  The values of any data type are held as float32.  The first dim is the number of samples.
+type IDX struct {
+	Type byte
+	Dims []int
+	Data []float32
+}
+
+//Samples returns the first dim
+func (x *IDX) Samples() int {
+	if len(x.Dims) == 0 {
+		return 0
+	}
+	return x.Dims[0]
+}
+
+//SampleSize returns the number of values in a sample
+func (x *IDX) SampleSize() int {
+	size := 1
+	for _, d := range x.Dims[1:] {
+		size *= d
+	}
+	return size
+}
+
+//Sample returns the values of sample i.  It is a slice of Data.
+func (x *IDX) Sample(i int) []float32 {
+	size := x.SampleSize()
+	return x.Data[i*size : (i+1)*size]
+}
+
+func idxsize(typ byte) (int, error) {
+	switch typ {
+	case IDXUint8, IDXInt8:
+		return 1, nil
+	case IDXInt16:
+		return 2, nil
+	case IDXInt32, IDXFloat32:
+		return 4, nil
+	case IDXFloat64:
+		return 8, nil
+	}
+	return 0, fmt.Errorf("unsupported IDX type 0x%02x", typ)
+}
+
+//ReadIDX reads an IDX file of any rank and data type.
+//The dims are checked against what r has left when r can tell, like a *bytes.Reader or an *os.File,
+//and the data is read as it comes otherwise, so a header with dims that are too large returns an error instead of
+//making a slice the size of the dims.
+func ReadIDX(r io.Reader) (*IDX, error) {
+	avail, known := remaining(r)
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	_, err := io.ReadFull(br, magic[:])
+	if err != nil {
+		return nil, err
+	}
+	if magic[0] != 0 || magic[1] != 0 {
+		return nil, errors.New("ReadIDX: not an IDX file")
+	}
+	x := &IDX{Type: magic[2], Dims: make([]int, magic[3])}
+	size, err := idxsize(x.Type)
+	if err != nil {
+		return nil, fmt.Errorf("ReadIDX: %v", err)
+	}
+	if len(x.Dims) == 0 {
+		return nil, errors.New("ReadIDX: file has no dims")
+	}
+	maxvol := int64(maxint) / int64(size)
+	vol := int64(1)
+	for i := range x.Dims {
+		var d uint32
+		err = binary.Read(br, binary.BigEndian, &d)
+		if err != nil {
+			return nil, err
+		}
+		if d != 0 && vol > maxvol/int64(d) {
+			return nil, fmt.Errorf("ReadIDX: dims %v and %d overflow", x.Dims[:i], d)
+		}
+		x.Dims[i] = int(d)
+		vol *= int64(d)
+	}
+	nbytes := vol * int64(size)
+	if header := int64(4 + 4*len(x.Dims)); known && nbytes > avail-header {
+		return nil, fmt.Errorf("ReadIDX: dims %v need %d bytes but there are %d", x.Dims, nbytes, avail-header)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(br, nbytes))
+	if err != nil {
+		return nil, fmt.Errorf("ReadIDX: reading %d values: %v", vol, err)
+	}
+	if int64(len(raw)) != nbytes {
+		return nil, fmt.Errorf("ReadIDX: reading %d values: %v", vol, io.ErrUnexpectedEOF)
+	}
+	x.Data = make([]float32, vol)
+	for i := range x.Data {
+		b := raw[i*size:]
+		switch x.Type {
+		case IDXUint8:
+			x.Data[i] = float32(b[0])
+		case IDXInt8:
+			x.Data[i] = float32(int8(b[0]))
+		case IDXInt16:
+			x.Data[i] = float32(int16(binary.BigEndian.Uint16(b)))
+		case IDXInt32:
+			x.Data[i] = float32(int32(binary.BigEndian.Uint32(b)))
+		case IDXFloat32:
+			x.Data[i] = math.Float32frombits(binary.BigEndian.Uint32(b))
+		case IDXFloat64:
+			x.Data[i] = float32(math.Float64frombits(binary.BigEndian.Uint64(b)))
+		}
+	}
+	return x, nil
+}
+
+const maxint = int(^uint(0) >> 1)
+
+//remaining returns the number of bytes r has left if r can tell
+func remaining(r io.Reader) (n int64, ok bool) {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len()), true
+	case interface{ Stat() (os.FileInfo, error) }:
+		fi, err := v.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0, false
+		}
+		n = fi.Size()
+		if sk, isseeker := r.(io.Seeker); isseeker {
+			offset, err := sk.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return 0, false
+			}
+			n -= offset
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+//WriteIDX writes x as an IDX file of type x.Type
+func WriteIDX(w io.Writer, x *IDX) error {
+	size, err := idxsize(x.Type)
+	if err != nil {
+		return fmt.Errorf("WriteIDX: %v", err)
+	}
+	vol := 1
+	for _, d := range x.Dims {
+		vol *= d
+	}
+	if vol != len(x.Data) || len(x.Dims) == 0 || len(x.Dims) > 255 {
+		return errors.New("WriteIDX: dims don't match the data")
+	}
+	bw := bufio.NewWriter(w)
+	bw.Write([]byte{0, 0, x.Type, byte(len(x.Dims))})
+	for _, d := range x.Dims {
+		binary.Write(bw, binary.BigEndian, uint32(d))
+	}
+	b := make([]byte, size)
+	for _, v := range x.Data {
+		switch x.Type {
+		case IDXUint8:
+			b[0] = uint8(v)
+		case IDXInt8:
+			b[0] = uint8(int8(v))
+		case IDXInt16:
+			binary.BigEndian.PutUint16(b, uint16(int16(v)))
+		case IDXInt32:
+			binary.BigEndian.PutUint32(b, uint32(int32(v)))
+		case IDXFloat32:
+			binary.BigEndian.PutUint32(b, math.Float32bits(v))
+		case IDXFloat64:
+			binary.BigEndian.PutUint64(b, math.Float64bits(float64(v)))
+		}
+		bw.Write(b)
+	}
+	return bw.Flush()
+}
+
+//LoadIDX reads the IDX file at path.  Files that end in .gz are gunzipped.
+func LoadIDX(path string) (*IDX, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	x, err := ReadIDX(r)
+	if err != nil {
+		return nil, fmt.Errorf("LoadIDX: %s: %v", path, err)
+	}
+	return x, nil
+}
+
+//IDXDataset is a dataset made of an IDX of inputs and an IDX of targets with the same number of samples.
+//If the targets are rank 1 they are classes and are made into targets with a Label.  Otherwise they are used as they are.
+type IDXDataset struct {
+	Inputs  *IDX
+	Targets *IDX
+	labeled
+}
+
+//CreateIDXDataset creates an IDXDataset.  If classes is 0 and the targets are classes, it is one more than the largest class.
+func CreateIDXDataset(inputs, targets *IDX, classes int, label Label) (*IDXDataset, error) {
+	if inputs.Samples() != targets.Samples() {
+		return nil, fmt.Errorf("CreateIDXDataset: %d inputs but %d targets", inputs.Samples(), targets.Samples())
+	}
+	err := label.check()
+	if err != nil {
+		return nil, fmt.Errorf("CreateIDXDataset: %v", err)
+	}
+	d := &IDXDataset{Inputs: inputs, Targets: targets}
+	if len(targets.Dims) != 1 {
+		return d, nil
+	}
+	d.label = label
+	d.labels = make([]int, len(targets.Data))
+	for i, v := range targets.Data {
+		d.labels[i] = int(v)
+		if d.labels[i] < 0 || (classes > 0 && d.labels[i] >= classes) {
+			return nil, fmt.Errorf("CreateIDXDataset: sample %d has class %d", i, d.labels[i])
+		}
+		if classes == 0 && d.labels[i] >= d.classes {
+			d.classes = d.labels[i] + 1
+		}
+	}
+	if classes > 0 {
+		d.classes = classes
+	}
+	return d, nil
+}
+
+//LoadIDXDataset loads the IDX files at inputpath and targetpath into an IDXDataset.
+//For MNIST it is the images and labels files with 10 classes.
+func LoadIDXDataset(inputpath, targetpath string, classes int, label Label) (*IDXDataset, error) {
+	inputs, err := LoadIDX(inputpath)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := LoadIDX(targetpath)
+	if err != nil {
+		return nil, err
+	}
+	return CreateIDXDataset(inputs, targets, classes, label)
+}
+
+//Len satisfies data.Dataset
+func (d *IDXDataset) Len() int {
+	return d.Inputs.Samples()
+}
+
+//Get satisfies data.Dataset
+func (d *IDXDataset) Get(i int) (input, target []float32, err error) {
+	if i < 0 || i >= d.Len() {
+		return nil, nil, fmt.Errorf("(d *IDXDataset) Get: index %d out of range of %d samples", i, d.Len())
+	}
+	if d.labels == nil {
+		return d.Inputs.Sample(i), d.Targets.Sample(i), nil
+	}
+	return d.Inputs.Sample(i), d.label.target(d.labels[i], d.classes), nil
+}
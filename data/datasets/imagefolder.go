@@ -0,0 +1,189 @@
+package datasets
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  //registers the gif decoder
+	_ "image/jpeg" //registers the jpeg decoder
+	_ "image/png"  //registers the png decoder
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dereklstinson/gocunets/data"
+)
+
+//ImageFolder is a dataset of the images in a directory tree where each subdirectory of the root is a class.
+//
+//	root/cat/001.png
+//	root/cat/002.jpg
+//	root/dog/001.png
+//
+//The classes are the names of the subdirectories in sorted order.  Images are read when Get is called and scaled to the size of the dataset.
+type ImageFolder struct {
+	ClassNames []string
+	paths      []string
+	width      int
+	height     int
+	channels   int
+	layout     data.Layout
+	labeled
+}
+
+var imageexts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true}
+
+//CreateImageFolder finds the images under root.  Images are scaled to width x height with bilinear interpolation.
+//channels is 1 for gray images or 3 for RGB.  layout is the order the values are put in the input.
+func CreateImageFolder(root string, width, height, channels int, layout data.Layout, label Label) (*ImageFolder, error) {
+	if width < 1 || height < 1 {
+		return nil, errors.New("CreateImageFolder: width and height need to be at least 1")
+	}
+	if channels != 1 && channels != 3 {
+		return nil, errors.New("CreateImageFolder: channels needs to be 1 or 3")
+	}
+	if layout != data.CHW && layout != data.HWC {
+		return nil, fmt.Errorf("CreateImageFolder: unsupported layout %d", layout)
+	}
+	err := label.check()
+	if err != nil {
+		return nil, fmt.Errorf("CreateImageFolder: %v", err)
+	}
+	dirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	f := &ImageFolder{width: width, height: height, channels: channels, layout: layout}
+	f.label = label
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		class := len(f.ClassNames)
+		f.ClassNames = append(f.ClassNames, dir.Name())
+		err = filepath.Walk(filepath.Join(root, dir.Name()), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !imageexts[strings.ToLower(filepath.Ext(path))] {
+				return nil
+			}
+			f.paths = append(f.paths, path)
+			f.labels = append(f.labels, class)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(f.paths) == 0 {
+		return nil, fmt.Errorf("CreateImageFolder: no images found in %s", root)
+	}
+	f.classes = len(f.ClassNames)
+	return f, nil
+}
+
+//Len satisfies data.Dataset
+func (f *ImageFolder) Len() int {
+	return len(f.paths)
+}
+
+//Path returns the file of sample i
+func (f *ImageFolder) Path(i int) string {
+	return f.paths[i]
+}
+
+//Dims returns the dims of the input of a sample in the order of the layout
+func (f *ImageFolder) Dims() []int32 {
+	if f.layout == data.HWC {
+		return []int32{int32(f.height), int32(f.width), int32(f.channels)}
+	}
+	return []int32{int32(f.channels), int32(f.height), int32(f.width)}
+}
+
+//Get satisfies data.Dataset.  It reads and decodes the image of sample i.
+func (f *ImageFolder) Get(i int) (input, target []float32, err error) {
+	if err = f.checkindex(i); err != nil {
+		return nil, nil, fmt.Errorf("(f *ImageFolder) Get: %v", err)
+	}
+	file, err := os.Open(f.paths[i])
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("(f *ImageFolder) Get: %s: %v", f.paths[i], err)
+	}
+	return ImageValues(img, f.width, f.height, f.channels, f.layout), f.label.target(f.labels[i], f.classes), nil
+}
+
+//ImageValues returns the pixels of img scaled to width x height with bilinear interpolation.
+//channels is 1 for gray or 3 for RGB.  Values are from 0 to 255.
+func ImageValues(img image.Image, width, height, channels int, layout data.Layout) []float32 {
+	b := img.Bounds()
+	values := make([]float32, width*height*channels)
+	pixel := func(x, y int) (r, g, bl float32) {
+		c := img.At(b.Min.X+x, b.Min.Y+y)
+		if channels == 1 {
+			v := float32(color.GrayModel.Convert(c).(color.Gray).Y)
+			return v, v, v
+		}
+		r32, g32, b32, _ := c.RGBA()
+		return float32(r32 >> 8), float32(g32 >> 8), float32(b32 >> 8)
+	}
+	sx := float32(b.Dx()) / float32(width)
+	sy := float32(b.Dy()) / float32(height)
+	for y := 0; y < height; y++ {
+		fy := (float32(y)+.5)*sy - .5
+		y0, wy := split(fy, b.Dy())
+		y1 := clamp(y0+1, b.Dy())
+		for x := 0; x < width; x++ {
+			fx := (float32(x)+.5)*sx - .5
+			x0, wx := split(fx, b.Dx())
+			x1 := clamp(x0+1, b.Dx())
+			var rgb [3]float32
+			for _, p := range []struct {
+				x, y int
+				w    float32
+			}{{x0, y0, (1 - wx) * (1 - wy)}, {x1, y0, wx * (1 - wy)}, {x0, y1, (1 - wx) * wy}, {x1, y1, wx * wy}} {
+				if p.w == 0 {
+					continue
+				}
+				r, g, bl := pixel(p.x, p.y)
+				rgb[0] += p.w * r
+				rgb[1] += p.w * g
+				rgb[2] += p.w * bl
+			}
+			for c := 0; c < channels; c++ {
+				if layout == data.HWC {
+					values[(y*width+x)*channels+c] = rgb[c]
+				} else {
+					values[(c*height+y)*width+x] = rgb[c]
+				}
+			}
+		}
+	}
+	return values
+}
+
+//split returns the pixel at or before f clamped to [0,n) and how far f is past it
+func split(f float32, n int) (int, float32) {
+	if f <= 0 {
+		return 0, 0
+	}
+	i := int(f)
+	if i >= n-1 {
+		return n - 1, 0
+	}
+	return i, f - float32(i)
+}
+
+func clamp(i, n int) int {
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
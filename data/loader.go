@@ -0,0 +1,272 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+//LastBatch says what a DataLoader does with the samples at the end of an epoch that don't fill a batch
+type LastBatch int
+
+const (
+	//DropLast skips the samples that don't fill a batch
+	DropLast LastBatch = iota
+	//PadLast puts the samples that don't fill a batch into a batch that is filled the rest of the way with zeros
+	PadLast
+)
+
+//Batch is a batch of samples put together on the host.
+//Inputs and Targets hold the samples one after the other.
+//Count is the number of samples from the dataset in the batch.  It is less than the batch size when the last batch is padded.
+//Indices are the dataset indexes of those samples.
+type Batch struct {
+	Inputs  []float32
+	Targets []float32
+	Indices []int
+	Count   int
+}
+
+//DataLoader makes fixed sized batches out of a dataset.  If it shuffles, the order of each epoch is made from the seed and the epoch
+//so an epoch has the same order every time it is ran.  Prefetch makes the batches of an epoch with worker goroutines.
+type DataLoader struct {
+	ds         Dataset
+	batchsize  int
+	last       LastBatch
+	shuffle    bool
+	seed       int64
+	epoch      int
+	order      []int
+	pos        int
+	inputsize  int
+	targetsize int
+	transform  Transform
+	batchtrans BatchTransform
+}
+
+//Transform changes a sample as it is put into a batch.
+//r is seeded with SampleSeed so a sample is changed the same way every time its epoch is ran, no matter how many workers are loading it.
+//
+//Apply can't change input or target in place since they can be held by the dataset.  It returns the changed sample.
+//It needs to be safe to use from more than one goroutine.
+type Transform interface {
+	Apply(input, target []float32, r *rand.Rand) (newinput, newtarget []float32, err error)
+}
+
+//Transforms applies each of its transforms one after the other.  It satisfies Transform.
+type Transforms []Transform
+
+//Apply satisfies Transform
+func (ts Transforms) Apply(input, target []float32, r *rand.Rand) (newinput, newtarget []float32, err error) {
+	for _, t := range ts {
+		input, target, err = t.Apply(input, target, r)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return input, target, nil
+}
+
+//BatchTransform changes a batch after its samples are put into it.  It is for transforms like mixup that mix the samples of a batch.
+//r is seeded with BatchSeed.  Only the first b.Count samples are from the dataset.
+//
+//ApplyBatch needs to be safe to use from more than one goroutine.
+type BatchTransform interface {
+	ApplyBatch(b *Batch, inputsize, targetsize int, r *rand.Rand) error
+}
+
+//SampleSeed returns the seed of the rng that the transform of sample index in epoch uses
+func SampleSeed(seed int64, epoch, index int) int64 {
+	x := uint64(seed)
+	for _, v := range []uint64{uint64(epoch), uint64(index)} {
+		x = splitmix64(x ^ splitmix64(v))
+	}
+	return int64(x)
+}
+
+//BatchSeed returns the seed of the rng that the batch transform of batch number batch in epoch uses
+func BatchSeed(seed int64, epoch, batch int) int64 {
+	return SampleSeed(seed, epoch, -1-batch)
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+//CreateDataLoader creates a DataLoader that starts at epoch 0.  The sizes of the inputs and targets are found from the first sample.
+func CreateDataLoader(ds Dataset, batchsize int, last LastBatch, shuffle bool, seed int64) (*DataLoader, error) {
+	if ds == nil || ds.Len() < 1 {
+		return nil, errors.New("CreateDataLoader: dataset is empty")
+	}
+	if batchsize < 1 {
+		return nil, errors.New("CreateDataLoader: batchsize needs to be at least 1")
+	}
+	if last != DropLast && last != PadLast {
+		return nil, fmt.Errorf("CreateDataLoader: unsupported LastBatch %d", last)
+	}
+	if last == DropLast && ds.Len() < batchsize {
+		return nil, fmt.Errorf("CreateDataLoader: %d samples don't fill a batch of %d", ds.Len(), batchsize)
+	}
+	input, target, err := ds.Get(0)
+	if err != nil {
+		return nil, err
+	}
+	d := &DataLoader{
+		ds:         ds,
+		batchsize:  batchsize,
+		last:       last,
+		shuffle:    shuffle,
+		seed:       seed,
+		inputsize:  len(input),
+		targetsize: len(target),
+	}
+	d.SetEpoch(0)
+	return d, nil
+}
+
+//SetTransform sets the transform that is applied to each sample.  nil removes it.
+//The sizes of the samples are found again from the first sample with t applied.
+func (d *DataLoader) SetTransform(t Transform) error {
+	input, target, err := d.ds.Get(0)
+	if err != nil {
+		return err
+	}
+	if t != nil {
+		input, target, err = t.Apply(input, target, rand.New(rand.NewSource(SampleSeed(d.seed, d.epoch, 0))))
+		if err != nil {
+			return err
+		}
+	}
+	d.transform = t
+	d.inputsize, d.targetsize = len(input), len(target)
+	return nil
+}
+
+//SetBatchTransform sets the transform that is applied to each batch after its samples are put into it.  nil removes it.
+func (d *DataLoader) SetBatchTransform(t BatchTransform) {
+	d.batchtrans = t
+}
+
+//SetEpoch starts epoch over from its first batch
+func (d *DataLoader) SetEpoch(epoch int) {
+	d.epoch = epoch
+	d.pos = 0
+	if !d.shuffle {
+		if d.order == nil {
+			d.order = make([]int, d.ds.Len())
+			for i := range d.order {
+				d.order[i] = i
+			}
+		}
+		return
+	}
+	d.order = rand.New(rand.NewSource(d.seed + int64(epoch))).Perm(d.ds.Len())
+}
+
+//Epoch returns the epoch the DataLoader is on
+func (d *DataLoader) Epoch() int {
+	return d.epoch
+}
+
+//BatchSize returns the number of samples in a batch
+func (d *DataLoader) BatchSize() int {
+	return d.batchsize
+}
+
+//Batches returns the number of batches in an epoch
+func (d *DataLoader) Batches() int {
+	if d.last == PadLast {
+		return (len(d.order) + d.batchsize - 1) / d.batchsize
+	}
+	return len(d.order) / d.batchsize
+}
+
+//SampleSizes returns the length of the input and the target of a sample
+func (d *DataLoader) SampleSizes() (input, target int) {
+	return d.inputsize, d.targetsize
+}
+
+//Order returns the dataset indexes of the epoch in the order they are batched
+func (d *DataLoader) Order() []int {
+	return d.order
+}
+
+//CreateBatch makes a Batch that is the size of the batches of d
+func (d *DataLoader) CreateBatch() *Batch {
+	return &Batch{
+		Inputs:  make([]float32, d.batchsize*d.inputsize),
+		Targets: make([]float32, d.batchsize*d.targetsize),
+		Indices: make([]int, 0, d.batchsize),
+	}
+}
+
+//Next puts the next batch of the epoch into b.  If b is nil or the wrong size it is made with CreateBatch.
+//It returns io.EOF when the epoch is done.  Use NextEpoch or SetEpoch to start another one.
+func (d *DataLoader) Next(b *Batch) (*Batch, error) {
+	count := len(d.order) - d.pos
+	if count > d.batchsize {
+		count = d.batchsize
+	}
+	if count < 1 || (count < d.batchsize && d.last == DropLast) {
+		return b, io.EOF
+	}
+	if b == nil || len(b.Inputs) != d.batchsize*d.inputsize || len(b.Targets) != d.batchsize*d.targetsize {
+		b = d.CreateBatch()
+	}
+	err := d.fill(b, d.order[d.pos:d.pos+count], d.epoch, d.pos/d.batchsize)
+	if err != nil {
+		return b, err
+	}
+	d.pos += count
+	return b, nil
+}
+
+//NextEpoch starts the epoch after the one the DataLoader is on
+func (d *DataLoader) NextEpoch() {
+	d.SetEpoch(d.epoch + 1)
+}
+
+//fill copies the samples at indices of epoch into b and zeros the rest of b.  batch is the number of the batch in the epoch.
+//It only reads d so batches can be filled by more than one goroutine.
+func (d *DataLoader) fill(b *Batch, indices []int, epoch, batch int) error {
+	b.Indices = append(b.Indices[:0], indices...)
+	b.Count = len(indices)
+	var r *rand.Rand
+	if d.transform != nil {
+		r = rand.New(rand.NewSource(0))
+	}
+	for i, idx := range indices {
+		input, target, err := d.ds.Get(idx)
+		if err != nil {
+			return err
+		}
+		if d.transform != nil {
+			r.Seed(SampleSeed(d.seed, epoch, idx))
+			input, target, err = d.transform.Apply(input, target, r)
+			if err != nil {
+				return err
+			}
+		}
+		if len(input) != d.inputsize || len(target) != d.targetsize {
+			return fmt.Errorf("(d *DataLoader) Next: sample %d has sizes %d and %d expected %d and %d", idx, len(input), len(target), d.inputsize, d.targetsize)
+		}
+		copy(b.Inputs[i*d.inputsize:], input)
+		copy(b.Targets[i*d.targetsize:], target)
+	}
+	zero(b.Inputs[len(indices)*d.inputsize:])
+	zero(b.Targets[len(indices)*d.targetsize:])
+	if d.batchtrans != nil {
+		return d.batchtrans.ApplyBatch(b, d.inputsize, d.targetsize, rand.New(rand.NewSource(BatchSeed(d.seed, epoch, batch))))
+	}
+	return nil
+}
+
+func zero(s []float32) {
+	for i := range s {
+		s[i] = 0
+	}
+}
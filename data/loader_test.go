@@ -0,0 +1,209 @@
+package data
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func testdataset(t *testing.T, n int) *SliceDataset {
+	inputs := make([][]float32, n)
+	targets := make([][]float32, n)
+	for i := range inputs {
+		inputs[i] = []float32{float32(i), float32(i) + .5}
+		targets[i] = OneHot(i%3, 3)
+	}
+	ds, err := CreateSliceDataset(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func epoch(t *testing.T, d *DataLoader) (batches []*Batch) {
+	for {
+		b, err := d.Next(nil)
+		if err == io.EOF {
+			return batches
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		batches = append(batches, b)
+	}
+}
+
+func TestDataLoaderLastBatch(t *testing.T) {
+	ds := testdataset(t, 10)
+	d, err := CreateDataLoader(ds, 4, DropLast, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := epoch(t, d)
+	if len(batches) != 2 || d.Batches() != 2 {
+		t.Fatalf("DropLast made %d batches, Batches says %d, expected 2", len(batches), d.Batches())
+	}
+	b := batches[1]
+	if b.Count != 4 || b.Inputs[0] != 4 || b.Inputs[1] != 4.5 || b.Targets[1] != 1 || b.Targets[5] != 1 {
+		t.Errorf("second batch is %v", b)
+	}
+
+	d, err = CreateDataLoader(ds, 4, PadLast, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches = epoch(t, d)
+	if len(batches) != 3 || d.Batches() != 3 {
+		t.Fatalf("PadLast made %d batches, Batches says %d, expected 3", len(batches), d.Batches())
+	}
+	b = batches[2]
+	if b.Count != 2 || len(b.Indices) != 2 || b.Indices[1] != 9 {
+		t.Errorf("padded batch has count %d and indices %v", b.Count, b.Indices)
+	}
+	for i := 2 * 2; i < len(b.Inputs); i++ {
+		if b.Inputs[i] != 0 {
+			t.Fatalf("padding input %d is %v", i, b.Inputs[i])
+		}
+	}
+
+	//A reused batch is zeroed where it is padded
+	d.SetEpoch(0)
+	reused := d.CreateBatch()
+	for {
+		reused, err = d.Next(reused)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reused.Inputs[len(reused.Inputs)-1] != 0 {
+		t.Error("reused batch wasn't padded with zeros")
+	}
+}
+
+func TestDataLoaderShuffle(t *testing.T) {
+	ds := testdataset(t, 50)
+	d, err := CreateDataLoader(ds, 5, DropLast, true, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := append([]int(nil), d.Order()...)
+	seen := make(map[int]bool)
+	for _, b := range epoch(t, d) {
+		for i, idx := range b.Indices {
+			if b.Inputs[i*2] != float32(idx) {
+				t.Fatalf("sample %d has input %v", idx, b.Inputs[i*2])
+			}
+			seen[idx] = true
+		}
+	}
+	if len(seen) != 50 {
+		t.Errorf("epoch had %d of 50 samples", len(seen))
+	}
+	d.NextEpoch()
+	second := d.Order()
+	same := true
+	for i := range first {
+		same = same && first[i] == second[i]
+	}
+	if same {
+		t.Error("epoch 1 has the same order as epoch 0")
+	}
+
+	//The same seed and epoch give the same order
+	again, err := CreateDataLoader(ds, 5, DropLast, true, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.SetEpoch(1)
+	for i := range second {
+		if again.Order()[i] != second[i] {
+			t.Fatalf("epoch 1 order isn't the same for the same seed")
+		}
+	}
+}
+
+//shifted is a Transform that adds a random value to the input
+type shifted struct{}
+
+func (shifted) Apply(input, target []float32, r *rand.Rand) ([]float32, []float32, error) {
+	out := make([]float32, len(input))
+	shift := r.Float32()
+	for i := range input {
+		out[i] = input[i] + shift
+	}
+	return out, target, nil
+}
+
+//scaled is a BatchTransform that scales the targets of a batch by a random value
+type scaled struct{}
+
+func (scaled) ApplyBatch(b *Batch, inputsize, targetsize int, r *rand.Rand) error {
+	scale := r.Float32()
+	for i := range b.Targets[:b.Count*targetsize] {
+		b.Targets[i] *= scale
+	}
+	return nil
+}
+
+func TestPrefetch(t *testing.T) {
+	ds := testdataset(t, 23)
+	loader := func() *DataLoader {
+		d, err := CreateDataLoader(ds, 4, PadLast, true, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = d.SetTransform(shifted{}); err != nil {
+			t.Fatal(err)
+		}
+		d.SetBatchTransform(scaled{})
+		d.SetEpoch(2)
+		return d
+	}
+	expected := epoch(t, loader())
+	for _, workers := range []int{1, 3, 8} {
+		p, err := Prefetch(context.Background(), loader(), workers, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; ; i++ {
+			b, err := p.Next()
+			if err == io.EOF {
+				if i != len(expected) {
+					t.Errorf("%d workers made %d batches expected %d", workers, i, len(expected))
+				}
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(b, expected[i]) {
+				t.Fatalf("%d workers: batch %d is %v expected %v", workers, i, b, expected[i])
+			}
+			p.Release(b)
+		}
+		p.Close()
+	}
+
+	//Cancelling the context stops the workers
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err := Prefetch(ctx, loader(), 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Next(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for err == nil {
+		_, err = p.Next()
+	}
+	if err != context.Canceled {
+		t.Errorf("Next after cancel returned %v", err)
+	}
+	p.Close()
+}
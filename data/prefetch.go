@@ -0,0 +1,147 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+//Prefetcher makes the batches of an epoch ahead of time with worker goroutines.
+//The batches come out of Next in the same order and with the same values that DataLoader.Next would give,
+//no matter how many workers there are.
+type Prefetcher struct {
+	d        *DataLoader
+	ctx      context.Context
+	cancel   context.CancelFunc
+	ready    chan chan prefetched
+	free     chan *Batch
+	finished bool //set before ready is closed if every batch was sent
+	wg       sync.WaitGroup
+}
+
+type prefetched struct {
+	b   *Batch
+	err error
+}
+
+type prefetchjob struct {
+	indices []int
+	batch   int
+	out     chan prefetched
+}
+
+//Prefetch starts making the rest of the batches of the epoch d is on with workers goroutines.
+//No more than depth batches are kept ready ahead of Next.  d is moved to the end of the epoch.
+//
+//The workers stop when ctx is done or Close is called.  d shouldn't be changed until Close has been called.
+func Prefetch(ctx context.Context, d *DataLoader, workers, depth int) (*Prefetcher, error) {
+	if workers < 1 {
+		return nil, errors.New("Prefetch: workers needs to be at least 1")
+	}
+	if depth < 1 {
+		return nil, errors.New("Prefetch: depth needs to be at least 1")
+	}
+	var batches [][]int
+	first := d.pos / d.batchsize
+	for pos := d.pos; pos < len(d.order); pos += d.batchsize {
+		end := pos + d.batchsize
+		if end > len(d.order) {
+			if d.last == DropLast {
+				break
+			}
+			end = len(d.order)
+		}
+		batches = append(batches, d.order[pos:end])
+	}
+	d.pos = len(d.order)
+	epoch := d.epoch
+
+	p := &Prefetcher{
+		d:     d,
+		ready: make(chan chan prefetched, depth),
+		free:  make(chan *Batch, depth+workers+1),
+	}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	jobs := make(chan prefetchjob)
+	p.wg.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for j := range jobs {
+				b := p.batch()
+				err := d.fill(b, j.indices, epoch, j.batch)
+				j.out <- prefetched{b: b, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer p.wg.Done()
+		defer close(p.ready)
+		defer close(jobs)
+		for i, indices := range batches {
+			out := make(chan prefetched, 1)
+			select {
+			case p.ready <- out:
+			case <-p.ctx.Done():
+				return
+			}
+			select {
+			case jobs <- prefetchjob{indices: indices, batch: first + i, out: out}:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+		p.finished = true
+	}()
+	return p, nil
+}
+
+//batch returns a released batch or makes a new one
+func (p *Prefetcher) batch() *Batch {
+	select {
+	case b := <-p.free:
+		return b
+	default:
+		return p.d.CreateBatch()
+	}
+}
+
+//Next returns the next batch.  It returns io.EOF when the epoch is done and the error of the context if it is done first.
+//The batch can be given back with Release when it isn't needed anymore so its memory is used again.
+func (p *Prefetcher) Next() (*Batch, error) {
+	out, ok := <-p.ready
+	if !ok {
+		if p.finished {
+			return nil, io.EOF
+		}
+		return nil, p.ctx.Err()
+	}
+	select {
+	case r := <-out:
+		if r.err != nil {
+			p.cancel()
+			return nil, r.err
+		}
+		return r.b, nil
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+}
+
+//Release gives b back to the Prefetcher to be filled again
+func (p *Prefetcher) Release(b *Batch) {
+	if b == nil {
+		return
+	}
+	select {
+	case p.free <- b:
+	default:
+	}
+}
+
+//Close stops the workers and waits for them to return
+func (p *Prefetcher) Close() {
+	p.cancel()
+	p.wg.Wait()
+}
@@ -0,0 +1,295 @@
+//Package preprocess finds statistics of the inputs of a dataset and makes Normalizers out of them.
+//
+//A Normalizer is fitted once on the training set.  It is a data.Transform so it is given to the DataLoader for training,
+//and it is saved in the model file with (m *SimpleModuleNetwork) SetPreprocess so the network scales its inputs the same way when it is deployed.
+package preprocess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/dereklstinson/gocunets/data"
+)
+
+//Stats are the per channel count, mean, standard deviation, min and max of the inputs of samples.
+//They are found in one pass with Add, so the samples don't need to be held in memory.
+//
+//Inputs that aren't images can use a channel for each value.
+type Stats struct {
+	channels int
+	layout   data.Layout
+	count    int64 //values of each channel
+	samples  int64
+	mean     []float64
+	m2       []float64 //sum of the squared differences from the mean
+	min      []float32
+	max      []float32
+}
+
+//CreateStats creates Stats for samples with channels channels in layout
+func CreateStats(channels int, layout data.Layout) (*Stats, error) {
+	if channels < 1 {
+		return nil, errors.New("CreateStats: channels needs to be at least 1")
+	}
+	if layout != data.CHW && layout != data.HWC {
+		return nil, fmt.Errorf("CreateStats: unsupported layout %d", layout)
+	}
+	s := &Stats{
+		channels: channels,
+		layout:   layout,
+		mean:     make([]float64, channels),
+		m2:       make([]float64, channels),
+		min:      make([]float32, channels),
+		max:      make([]float32, channels),
+	}
+	for c := range s.min {
+		s.min[c] = float32(math.Inf(1))
+		s.max[c] = float32(math.Inf(-1))
+	}
+	return s, nil
+}
+
+//FitStats finds the Stats of the inputs of every sample of ds
+func FitStats(ds data.Dataset, channels int, layout data.Layout) (*Stats, error) {
+	s, err := CreateStats(channels, layout)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < ds.Len(); i++ {
+		input, _, err := ds.Get(i)
+		if err != nil {
+			return nil, err
+		}
+		err = s.Add(input)
+		if err != nil {
+			return nil, fmt.Errorf("FitStats: sample %d: %v", i, err)
+		}
+	}
+	return s, nil
+}
+
+//channel returns the channel of value i of a sample of size values
+func channel(layout data.Layout, channels, size, i int) int {
+	if layout == data.HWC {
+		return i % channels
+	}
+	return i / (size / channels)
+}
+
+//Add adds the input of a sample.  The mean and variance of each channel of the sample are found first
+//and then merged with the ones of the samples before it, which keeps float error down over large datasets.
+func (s *Stats) Add(input []float32) error {
+	if len(input) == 0 || len(input)%s.channels != 0 {
+		return fmt.Errorf("(s *Stats) Add: %d values can't be split into %d channels", len(input), s.channels)
+	}
+	n := int64(len(input) / s.channels)
+	sums := make([]float64, s.channels)
+	for i, v := range input {
+		c := channel(s.layout, s.channels, len(input), i)
+		sums[c] += float64(v)
+		if v < s.min[c] {
+			s.min[c] = v
+		}
+		if v > s.max[c] {
+			s.max[c] = v
+		}
+	}
+	m2 := make([]float64, s.channels)
+	for i, v := range input {
+		c := channel(s.layout, s.channels, len(input), i)
+		d := float64(v) - sums[c]/float64(n)
+		m2[c] += d * d
+	}
+	total := s.count + n
+	for c := range s.mean {
+		mean := sums[c] / float64(n)
+		d := mean - s.mean[c]
+		s.mean[c] += d * float64(n) / float64(total)
+		s.m2[c] += m2[c] + d*d*float64(s.count)*float64(n)/float64(total)
+	}
+	s.count = total
+	s.samples++
+	return nil
+}
+
+//Samples returns the number of samples that have been added
+func (s *Stats) Samples() int64 {
+	return s.samples
+}
+
+//Mean returns the mean of each channel
+func (s *Stats) Mean() []float32 {
+	mean := make([]float32, s.channels)
+	for c, m := range s.mean {
+		mean[c] = float32(m)
+	}
+	return mean
+}
+
+//Std returns the population standard deviation of each channel
+func (s *Stats) Std() []float32 {
+	std := make([]float32, s.channels)
+	if s.count == 0 {
+		return std
+	}
+	for c, m2 := range s.m2 {
+		std[c] = float32(math.Sqrt(m2 / float64(s.count)))
+	}
+	return std
+}
+
+//Min returns the smallest value of each channel
+func (s *Stats) Min() []float32 {
+	return append([]float32(nil), s.min...)
+}
+
+//Max returns the largest value of each channel
+func (s *Stats) Max() []float32 {
+	return append([]float32(nil), s.max...)
+}
+
+//Method is how a Normalizer scales the inputs
+type Method string
+
+const (
+	//Standardize scales each channel to a mean of 0 and a standard deviation of 1
+	Standardize Method = "standardize"
+	//MinMax scales each channel to [0,1]
+	MinMax Method = "minmax"
+)
+
+//epsilon keeps a Normalizer from dividing by zero on channels that are all one value
+const epsilon = 1e-7
+
+//Normalizer scales each channel of an input by
+//
+//	out = (in - Shift[c]) * Scale[c]
+//
+//It satisfies data.Transform and can be written as json, which is how it is put into a model file.
+//Mean, Std, Min and Max are the statistics it was fitted with.  Only Shift and Scale are used to scale.
+type Normalizer struct {
+	Method   Method      `json:"method"`
+	Channels int         `json:"channels"`
+	Layout   data.Layout `json:"layout"`
+	Samples  int64       `json:"samples"`
+	Mean     []float32   `json:"mean"`
+	Std      []float32   `json:"std"`
+	Min      []float32   `json:"min"`
+	Max      []float32   `json:"max"`
+	Shift    []float32   `json:"shift"`
+	Scale    []float32   `json:"scale"`
+}
+
+//CreateNormalizer makes a Normalizer out of s that scales with method
+func CreateNormalizer(s *Stats, method Method) (*Normalizer, error) {
+	if s.samples == 0 {
+		return nil, errors.New("CreateNormalizer: no samples have been added to the stats")
+	}
+	n := &Normalizer{
+		Method:   method,
+		Channels: s.channels,
+		Layout:   s.layout,
+		Samples:  s.samples,
+		Mean:     s.Mean(),
+		Std:      s.Std(),
+		Min:      s.Min(),
+		Max:      s.Max(),
+		Shift:    make([]float32, s.channels),
+		Scale:    make([]float32, s.channels),
+	}
+	for c := 0; c < s.channels; c++ {
+		var spread float32
+		switch method {
+		case Standardize:
+			n.Shift[c], spread = n.Mean[c], n.Std[c]
+		case MinMax:
+			n.Shift[c], spread = n.Min[c], n.Max[c]-n.Min[c]
+		default:
+			return nil, fmt.Errorf("CreateNormalizer: unsupported method %q", method)
+		}
+		if spread < epsilon {
+			spread = epsilon
+		}
+		n.Scale[c] = 1 / spread
+	}
+	return n, nil
+}
+
+//FitNormalizer finds the Stats of ds and makes a Normalizer out of them
+func FitNormalizer(ds data.Dataset, channels int, layout data.Layout, method Method) (*Normalizer, error) {
+	s, err := FitStats(ds, channels, layout)
+	if err != nil {
+		return nil, err
+	}
+	return CreateNormalizer(s, method)
+}
+
+func (n *Normalizer) check(size int) error {
+	if n.Channels < 1 || len(n.Shift) != n.Channels || len(n.Scale) != n.Channels {
+		return errors.New("normalizer doesn't have a shift and scale for each channel")
+	}
+	if size == 0 || size%n.Channels != 0 {
+		return fmt.Errorf("%d values can't be split into %d channels", size, n.Channels)
+	}
+	return nil
+}
+
+//Normalize scales the input of a sample in place
+func (n *Normalizer) Normalize(input []float32) error {
+	if err := n.check(len(input)); err != nil {
+		return fmt.Errorf("(n *Normalizer) Normalize: %v", err)
+	}
+	for i, v := range input {
+		c := channel(n.Layout, n.Channels, len(input), i)
+		input[i] = (v - n.Shift[c]) * n.Scale[c]
+	}
+	return nil
+}
+
+//NormalizeBatch scales the inputs of a batch of samples in place.  inputs holds the samples one after the other.
+func (n *Normalizer) NormalizeBatch(inputs []float32, samples int) error {
+	if samples < 1 || len(inputs)%samples != 0 {
+		return fmt.Errorf("(n *Normalizer) NormalizeBatch: %d values aren't %d samples", len(inputs), samples)
+	}
+	size := len(inputs) / samples
+	for i := 0; i < samples; i++ {
+		err := n.Normalize(inputs[i*size : (i+1)*size])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Apply satisfies data.Transform.  r isn't used.
+func (n *Normalizer) Apply(input, target []float32, r *rand.Rand) (newinput, newtarget []float32, err error) {
+	newinput = make([]float32, len(input))
+	copy(newinput, input)
+	err = n.Normalize(newinput)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newinput, target, nil
+}
+
+//Write writes n as json
+func (n *Normalizer) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(n)
+}
+
+//ReadNormalizer reads a Normalizer written with Write
+func ReadNormalizer(r io.Reader) (*Normalizer, error) {
+	n := new(Normalizer)
+	err := json.NewDecoder(r).Decode(n)
+	if err != nil {
+		return nil, err
+	}
+	if err = n.check(n.Channels); err != nil {
+		return nil, fmt.Errorf("ReadNormalizer: %v", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,115 @@
+package preprocess
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/data"
+)
+
+func TestNormalizer(t *testing.T) {
+	//2 channels of 2x2 pixels in HWC.  Channel 0 is the sample index and channel 1 is 10 times it plus the pixel.
+	inputs := make([][]float32, 10)
+	targets := make([][]float32, 10)
+	for i := range inputs {
+		for p := 0; p < 4; p++ {
+			inputs[i] = append(inputs[i], float32(i), float32(10*i+p))
+		}
+		targets[i] = []float32{float32(i)}
+	}
+	ds, err := data.CreateSliceDataset(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := FitStats(ds, 2, data.HWC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mean, sq [2]float64
+	var all [2][]float64
+	for _, in := range inputs {
+		for i, v := range in {
+			all[i%2] = append(all[i%2], float64(v))
+			mean[i%2] += float64(v)
+		}
+	}
+	for c := range mean {
+		mean[c] /= float64(len(all[c]))
+		for _, v := range all[c] {
+			sq[c] += (v - mean[c]) * (v - mean[c])
+		}
+		std := math.Sqrt(sq[c] / float64(len(all[c])))
+		if math.Abs(float64(s.Mean()[c])-mean[c]) > 1e-4 || math.Abs(float64(s.Std()[c])-std) > 1e-4 {
+			t.Errorf("channel %d has mean %v std %v expected %v %v", c, s.Mean()[c], s.Std()[c], mean[c], std)
+		}
+	}
+	if s.Min()[1] != 0 || s.Max()[1] != 93 || s.Samples() != 10 {
+		t.Errorf("min %v max %v samples %d", s.Min(), s.Max(), s.Samples())
+	}
+
+	n, err := CreateNormalizer(s, MinMax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := n.Apply(inputs[9], targets[9], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0] != 1 || out[7] != 1 || inputs[9][0] != 9 {
+		t.Errorf("minmax of the largest sample is %v", out)
+	}
+
+	n, err = CreateNormalizer(s, Standardize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = n.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadNormalizer(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := data.CreateDataLoader(ds, 10, data.DropLast, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.SetTransform(data.Transforms{n}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.Next(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restats, err := CreateStats(2, data.HWC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := make([]float32, 0, len(b.Inputs))
+	for _, in := range inputs {
+		batch = append(batch, in...)
+	}
+	if err = read.NormalizeBatch(batch, 10); err != nil {
+		t.Fatal(err)
+	}
+	for i := range batch {
+		if batch[i] != b.Inputs[i] {
+			t.Fatal("read normalizer doesn't scale the same as the loader")
+		}
+	}
+	for i := 0; i < 10; i++ {
+		restats.Add(b.Inputs[i*8 : (i+1)*8])
+	}
+	for c := 0; c < 2; c++ {
+		if math.Abs(float64(restats.Mean()[c])) > 1e-5 || math.Abs(float64(restats.Std()[c])-1) > 1e-5 {
+			t.Errorf("standardized channel %d has mean %v std %v", c, restats.Mean()[c], restats.Std()[c])
+		}
+	}
+	_, _, err = n.Apply(make([]float32, 7), nil, rand.New(rand.NewSource(0)))
+	if err == nil {
+		t.Error("input that can't be split into channels should error")
+	}
+}
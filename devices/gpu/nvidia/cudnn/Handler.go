@@ -18,14 +18,15 @@ import (
 //Handler contains the handles used in gocudnn and also the xtra kernals.
 type Handler struct {
 	*gocu.Worker
-	cudnn   *gocudnn.Handle
-	xtra    *xtra.Handle
-	stream  gocu.Streamer
-	unified bool
-	device  cudart.Device
-	rngtype curand.RngType
-	curng   *curand.Generator
-	seed    uint
+	cudnn     *gocudnn.Handle
+	xtra      *xtra.Handle
+	stream    gocu.Streamer
+	streamset bool
+	unified   bool
+	device    cudart.Device
+	rngtype   curand.RngType
+	curng     *curand.Generator
+	seed      uint
 }
 
 //FindVol will find the max vol for tensor.  This is going to hold two functions
@@ -70,6 +71,15 @@ func (h *Handler) Stream() gocu.Streamer {
 	return h.stream
 }
 
+//CurrentStream returns the stream set with SetStream.  It returns nil if the handles are using the default stream.
+//The stream Stream makes when one isn't set isn't used by the handles, so it isn't returned.
+func (h *Handler) CurrentStream() gocu.Streamer {
+	if !h.streamset {
+		return nil
+	}
+	return h.stream
+}
+
 //XHandle returns a pointer to the XHandle
 func (h *Handler) XHandle() *xtra.Handle {
 	return h.xtra
@@ -163,5 +173,6 @@ func (h *Handler) SetStream(stream gocu.Streamer) error {
 		return err
 	}
 	h.stream = stream
+	h.streamset = true
 	return nil
 }
@@ -133,6 +133,12 @@ func (o *Ops) BiasScaleProperties() (gocudnn.TensorFormat, gocudnn.DataType, []i
 	return (o.bnsbmvd.Format()), (o.bnsbmvd.DataType()), o.bnsbmvd.Dims()
 }
 
+//RunningMeanVariance returns the running mean and variance that are used in ForwardInference.
+//They have the same properties as the bias and scale. They are nil until the Ops are staged.
+func (o *Ops) RunningMeanVariance() (mean, variance *nvidia.Malloced) {
+	return o.rrm, o.rrv
+}
+
 /*
 //Stage stages the bachnorm op. It also builds the memory for it so you don't have to worry about it.
 func Stage(handle *cudnn.Handler,
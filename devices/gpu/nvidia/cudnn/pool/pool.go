@@ -83,6 +83,11 @@ func (p *Ops) Properties() (gocudnn.PoolingMode, gocudnn.NANProp, []int32, []int
 
 }
 
+//Reverse returns true if the ops were staged with StageOperationReverse
+func (p *Ops) Reverse() bool {
+	return p.reverse
+}
+
 //Info returns the info struct usually used for saving the info to a jason format
 func (p *Ops) Info() (OpInfo, error) {
 	mode, nan, window, pad, stride, err := p.desc.Get()
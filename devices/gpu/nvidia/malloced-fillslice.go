@@ -31,3 +31,25 @@ func (m *Malloced) FillSlice(handle *cudnn.Handler, input interface{}) error {
 		return cudart.MemcpyUS(ptr, m.Ptr(), m.numbytes, defaultmemcopykind)
 	})
 }
+
+//LoadSlice will copy the values of the slice passed in input into the memory.
+//The size of input in bytes needs to be the same as m.SIB().
+func (m *Malloced) LoadSlice(handle *cudnn.Handler, input interface{}) error {
+	val := reflect.ValueOf(input)
+	if val.Kind() != reflect.Slice {
+		return errors.New("LoadSlice: input needs to be a slice")
+	}
+	if uint(val.Len())*uint(val.Type().Elem().Size()) != m.numbytes {
+		return errors.New("LoadSlice: size of input in bytes doesn't match the size of the memory")
+	}
+	ptr := unsafe.Pointer(val.Pointer())
+	if ptr == nil {
+		return errors.New("Nil sent as input for LoadSlice")
+	}
+	if handle.Worker == nil {
+		return cudart.MemcpyUS(m.Ptr(), ptr, m.numbytes, defaultmemcopykind)
+	}
+	return handle.Work(func() error {
+		return cudart.MemcpyUS(m.Ptr(), ptr, m.numbytes, defaultmemcopykind)
+	})
+}
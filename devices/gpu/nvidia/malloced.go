@@ -1,6 +1,7 @@
 package nvidia
 
 import (
+	"runtime"
 	"unsafe"
 
 	"github.com/dereklstinson/gocudnn/cudart"
@@ -76,11 +77,12 @@ func MallocHost(w Worker, sizebytes uint) (x *Malloced, err error) {
 	x.numbytes = sizebytes
 	x.host = true
 	if w == nil {
-		return nil, cudart.MallocManagedGlobal(x, sizebytes)
+		err = cudart.MallocManagedGlobal(x, sizebytes)
+	} else {
+		err = w.Work(func() error {
+			return cudart.MallocManagedHost(x, sizebytes)
+		})
 	}
-	err = w.Work(func() error {
-		return cudart.MallocManagedHost(x, sizebytes)
-	})
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +125,11 @@ func Memcpy(dest, src cutil.Pointer, sizeinbytes uint) error {
 	return cudart.Memcpy(dest, src, sizeinbytes, defaultmemcopykind)
 }
 
+//MemcpyAsync is like Memcpy but the copy is queued on stream s and it returns before the copy is done
+func MemcpyAsync(dest, src cutil.Pointer, sizeinbytes uint, s gocu.Streamer) error {
+	return cudart.MemcpyAsync(dest, src, sizeinbytes, defaultmemcopykind, s)
+}
+
 //SetAll sets the memory to whatever integer value passed
 func (m *Malloced) SetAll(val int32) error {
 	//if w != nil {
@@ -133,6 +140,18 @@ func (m *Malloced) SetAll(val int32) error {
 	return cudart.Memset(m, val, m.numbytes)
 }
 
+//Free frees the memory now instead of leaving it for the garbage collector.  m can't be used after it is freed.
+//Free shouldn't be called on memory made with OffSet.
+func (m *Malloced) Free() error {
+	if m == nil || m.ptr == nil {
+		return nil
+	}
+	runtime.SetFinalizer(m, nil)
+	err := cudart.Free(m)
+	m.ptr, m.numbytes = nil, 0
+	return err
+}
+
 //MallocGlobal allocates memory to the nvidia gpu
 //Handler will set the device it is allocating to. Besure to set back if wanting to use another device
 func MallocGlobal(w Worker, sizebytes uint) (x *Malloced, err error) {
@@ -0,0 +1,239 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+//The flags of a build without the cuda tag have the same methods as the gocudnn wrappers in flags.go,
+//so the flags of a Builder are set the same way with or without cuda.
+
+//DataType is the data type flag of a Builder.  Only Float is supported by the cpu layers.
+type DataType struct {
+	flag int32
+}
+
+//Float sets and returns the Float flag
+func (d *DataType) Float() DataType {
+	d.flag = 1
+	return *d
+}
+
+//Half sets and returns the Half flag
+func (d *DataType) Half() DataType {
+	d.flag = 2
+	return *d
+}
+
+//Double sets and returns the Double flag
+func (d *DataType) Double() DataType {
+	d.flag = 3
+	return *d
+}
+
+//UInt8 sets and returns the UInt8 flag
+func (d *DataType) UInt8() DataType {
+	d.flag = 4
+	return *d
+}
+
+//Int8 sets and returns the Int8 flag
+func (d *DataType) Int8() DataType {
+	d.flag = 5
+	return *d
+}
+
+//Int32 sets and returns the Int32 flag
+func (d *DataType) Int32() DataType {
+	d.flag = 6
+	return *d
+}
+
+//TensorFormat is the tensor format flag of a Builder.  NCHW and NHWC are supported by the cpu layers.
+type TensorFormat struct {
+	flag int32
+}
+
+//NCHW sets and returns the NCHW flag
+func (t *TensorFormat) NCHW() TensorFormat {
+	t.flag = 1
+	return *t
+}
+
+//NHWC sets and returns the NHWC flag
+func (t *TensorFormat) NHWC() TensorFormat {
+	t.flag = 2
+	return *t
+}
+
+//NCHWvectC sets and returns the NCHWvectC flag
+func (t *TensorFormat) NCHWvectC() TensorFormat {
+	t.flag = 3
+	return *t
+}
+
+//ConvolutionMode is the convolution mode flag of a Builder.  Only CrossCorrelation is supported by the cpu layers.
+type ConvolutionMode struct {
+	flag int32
+}
+
+//Convolution sets and returns the Convolution flag
+func (c *ConvolutionMode) Convolution() ConvolutionMode {
+	c.flag = 1
+	return *c
+}
+
+//CrossCorrelation sets and returns the CrossCorrelation flag
+func (c *ConvolutionMode) CrossCorrelation() ConvolutionMode {
+	c.flag = 2
+	return *c
+}
+
+//NanProp is the nan propagation flag of a Builder.  It isn't used by the cpu layers.
+type NanProp struct {
+	flag int32
+}
+
+//Propigate sets and returns the Propigate flag
+func (n *NanProp) Propigate() NanProp {
+	n.flag = 1
+	return *n
+}
+
+//NotPropigate sets and returns the NotPropigate flag
+func (n *NanProp) NotPropigate() NanProp {
+	n.flag = 2
+	return *n
+}
+
+//BatchNormMode is the batch norm mode flag of a Builder.
+type BatchNormMode struct {
+	flag int32
+}
+
+//PerActivation sets and returns the PerActivation flag
+func (b *BatchNormMode) PerActivation() BatchNormMode {
+	b.flag = 1
+	return *b
+}
+
+//Spatial sets and returns the Spatial flag
+func (b *BatchNormMode) Spatial() BatchNormMode {
+	b.flag = 2
+	return *b
+}
+
+//SpatialPersistent sets and returns the SpatialPersistent flag
+func (b *BatchNormMode) SpatialPersistent() BatchNormMode {
+	b.flag = 3
+	return *b
+}
+
+//PoolingMode is the pooling mode flag of a Builder.
+type PoolingMode struct {
+	flag int32
+}
+
+//AverageCountExcludePadding sets and returns the AverageCountExcludePadding flag
+func (p *PoolingMode) AverageCountExcludePadding() PoolingMode {
+	p.flag = 1
+	return *p
+}
+
+//AverageCountIncludePadding sets and returns the AverageCountIncludePadding flag
+func (p *PoolingMode) AverageCountIncludePadding() PoolingMode {
+	p.flag = 2
+	return *p
+}
+
+//Max sets and returns the Max flag
+func (p *PoolingMode) Max() PoolingMode {
+	p.flag = 3
+	return *p
+}
+
+//MaxDeterministic sets and returns the MaxDeterministic flag
+func (p *PoolingMode) MaxDeterministic() PoolingMode {
+	p.flag = 4
+	return *p
+}
+
+//ActivationMode is the activation mode flag of a Builder.
+type ActivationMode struct {
+	flag int32
+}
+
+//ClippedRelu sets and returns the ClippedRelu flag
+func (a *ActivationMode) ClippedRelu() ActivationMode {
+	a.flag = 1
+	return *a
+}
+
+//Elu sets and returns the Elu flag
+func (a *ActivationMode) Elu() ActivationMode {
+	a.flag = 2
+	return *a
+}
+
+//Identity sets and returns the Identity flag
+func (a *ActivationMode) Identity() ActivationMode {
+	a.flag = 3
+	return *a
+}
+
+//Leaky sets and returns the Leaky flag
+func (a *ActivationMode) Leaky() ActivationMode {
+	a.flag = 4
+	return *a
+}
+
+//PRelu sets and returns the PRelu flag
+func (a *ActivationMode) PRelu() ActivationMode {
+	a.flag = 5
+	return *a
+}
+
+//Relu sets and returns the Relu flag
+func (a *ActivationMode) Relu() ActivationMode {
+	a.flag = 6
+	return *a
+}
+
+//Sigmoid sets and returns the Sigmoid flag
+func (a *ActivationMode) Sigmoid() ActivationMode {
+	a.flag = 7
+	return *a
+}
+
+//Tanh sets and returns the Tanh flag
+func (a *ActivationMode) Tanh() ActivationMode {
+	a.flag = 8
+	return *a
+}
+
+//Threshhold sets and returns the Threshhold flag
+func (a *ActivationMode) Threshhold() ActivationMode {
+	a.flag = 9
+	return *a
+}
+
+//MathType is the math type flag of a Builder.  It isn't used by the cpu layers.
+type MathType struct {
+	flag int32
+}
+
+//AllowConversion sets and returns the AllowConversion flag
+func (m *MathType) AllowConversion() MathType {
+	m.flag = 1
+	return *m
+}
+
+//Default sets and returns the Default flag
+func (m *MathType) Default() MathType {
+	m.flag = 2
+	return *m
+}
+
+//TensorOpMath sets and returns the TensorOpMath flag
+func (m *MathType) TensorOpMath() MathType {
+	m.flag = 3
+	return *m
+}
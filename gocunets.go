@@ -1,3 +1,6 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 import (
@@ -5,14 +8,25 @@ import (
 
 	"github.com/dereklstinson/gocudnn/cudart"
 	"github.com/dereklstinson/gocudnn/gocu"
+	"github.com/dereklstinson/gocunets/cpu"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/layers"
 	"github.com/dereklstinson/nccl"
 )
 
 //Tensor is contains 2 tensors the x and dx.  Input IOs will contain only the X tensor.
+//Tensors made by a cpu Builder hold a host tensor instead.
 type Tensor struct {
 	*layers.Tensor
+	host *cpu.Tensor
+}
+
+//Dims returns the dims of the tensor
+func (t *Tensor) Dims() []int32 {
+	if t.host != nil {
+		return t.host.Dims()
+	}
+	return t.Tensor.Dims()
 }
 
 //type Workspace struct {
@@ -0,0 +1,139 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+)
+
+//accscalar is the scalars a layer had for its weight gradients before SetAccumulation changed them
+type accscalar struct {
+	l           *Layer
+	alpha, beta float64
+}
+
+//SetAccumulation makes Backward add the weight gradients of steps micro batches together before Update uses them.
+//Update doesn't do anything until Backward has been ran steps times.  Then it divides the gradients by the batch size times steps,
+//updates the weights and sets the gradients to zero.  A steps of 1 turns accumulation off.
+//
+//While accumulating, the layers with weights have the beta of their weight gradients set to 1 so that
+//the gradients are added to instead of written over.  The scalars they had are put back when accumulation is turned off.
+//Batchnorm layers keep their running mean and variance by each Forward, so their statistics are from the micro batches like
+//they would be if each micro batch was trained on its own.  The gradients of the batchnorm scale and bias are added to like the rest,
+//and activation layers with trainable coefficients already add to theirs.
+//
+//InitHiddenLayers needs to have been ran.  The gradients in the network are set to zero.
+//It returns an error if there are gradients that Update hasn't used.
+func (m *SimpleModuleNetwork) SetAccumulation(steps int) error {
+	if steps < 1 {
+		return errors.New("(m *SimpleModuleNetwork) SetAccumulation: steps needs to be at least 1")
+	}
+	if m.accumulated > 0 {
+		return fmt.Errorf("(m *SimpleModuleNetwork) SetAccumulation: gradients of %d micro batches haven't been used by Update", m.accumulated)
+	}
+	for _, s := range m.accscalars {
+		s.l.setotherscalars(s.alpha, s.beta)
+	}
+	m.accscalars = nil
+	m.accumulate = 0
+	if steps == 1 {
+		return nil
+	}
+	ls, err := m.hiddenlayers(nil)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) SetAccumulation: %v", err)
+	}
+	for _, l := range ls {
+		if l == nil {
+			continue
+		}
+		alpha, beta, ok := l.otherscalars()
+		if !ok {
+			continue
+		}
+		m.accscalars = append(m.accscalars, accscalar{l: l, alpha: alpha, beta: beta})
+		l.setotherscalars(alpha, 1)
+	}
+	m.accumulate = steps
+	return m.zerogradients()
+}
+
+//Accumulated returns the number of micro batches that have gradients in the network that Update hasn't used.
+func (m *SimpleModuleNetwork) Accumulated() int {
+	return m.accumulated
+}
+
+//UpdateAccumulated updates the weights with the gradients of the micro batches that have been accumulated so far
+//and sets the gradients to zero.  It is used at the end of an epoch where there are less than the steps passed to SetAccumulation.
+func (m *SimpleModuleNetwork) UpdateAccumulated(counter int) (err error) {
+	if m.accumulated == 0 {
+		return nil
+	}
+	ls, err := m.hiddenlayers(nil)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) UpdateAccumulated: %v", err)
+	}
+	batchsizes := make([]int, len(ls))
+	for i, l := range ls {
+		if l != nil {
+			batchsizes[i] = l.batchsize
+		}
+	}
+	for i, l := range ls {
+		if l != nil {
+			l.batchsize = batchsizes[i] * m.accumulated
+		}
+	}
+	err = m.update(counter)
+	for i := len(ls) - 1; i >= 0; i-- {
+		if ls[i] != nil {
+			ls[i].batchsize = batchsizes[i]
+		}
+	}
+	if err != nil {
+		return err
+	}
+	m.accumulated = 0
+	return m.zerogradients()
+}
+
+func (m *SimpleModuleNetwork) zerogradients() error {
+	ts, err := m.gradients()
+	if err != nil {
+		return err
+	}
+	h := m.b.h.Handler
+	for _, t := range ts {
+		err = t.SetValues(h, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//otherscalars returns the scalars of the weight gradients of the layer. ok is false if the layer doesn't have them.
+func (l *Layer) otherscalars() (alpha, beta float64, ok bool) {
+	switch {
+	case l.cnn != nil:
+		alpha, beta = l.cnn.OtherScalars()
+	case l.cnntranspose != nil:
+		alpha, beta = l.cnntranspose.OtherScalars()
+	case l.batch != nil:
+		alpha, beta = l.batch.OtherScalars()
+	default:
+		return 0, 0, false
+	}
+	return alpha, beta, true
+}
+
+//setotherscalars is like SetOtherScalars but it also sets the scalars of a batchnorm layer
+func (l *Layer) setotherscalars(alpha, beta float64) {
+	if l.batch != nil {
+		l.batch.SetOtherScalars(alpha, beta)
+		return
+	}
+	l.SetOtherScalars(alpha, beta)
+}
@@ -0,0 +1,54 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAccumulation(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+
+	m := testnetwork(t, handle, testnetworkspec)
+	check(m.GetTensorX().NormalRand(handle.Handler, 0, 1))
+	check(m.SetAccumulation(2))
+
+	check(m.Forward())
+	check(m.Backward())
+	once, err := m.GradientNorm()
+	check(err)
+	check(m.Update(0))
+	if m.Accumulated() != 1 {
+		t.Fatalf("Update used the gradients of %d micro batches before there were 2", 1-m.Accumulated())
+	}
+
+	//The same micro batch again should double the gradients
+	check(m.Forward())
+	check(m.Backward())
+	twice, err := m.GradientNorm()
+	check(err)
+	if math.Abs(float64(twice-2*once)) > 1e-3*float64(twice) {
+		t.Errorf("norm of two micro batches is %v expected %v", twice, 2*once)
+	}
+	if err = m.SetAccumulation(1); err == nil {
+		t.Error("SetAccumulation should not drop gradients that haven't been used")
+	}
+	check(m.Update(0))
+	if m.Accumulated() != 0 {
+		t.Errorf("%d micro batches are left after Update", m.Accumulated())
+	}
+	norm, err := m.GradientNorm()
+	check(err)
+	if norm != 0 {
+		t.Errorf("gradient norm after Update is %v expected 0", norm)
+	}
+	check(m.SetAccumulation(1))
+}
@@ -0,0 +1,108 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dereklstinson/gocunets/trainer"
+)
+
+//TrainerInfo is the state of a trainer held in a checkpoint.
+//Tensor is the name of the layer tensor that the trainer updates.
+//Tensors are the hidden memory of the trainer. Adam has gsum and xsum. Momentum has gsum.
+type TrainerInfo struct {
+	Tensor   string           `json:"tensor"`
+	Type     string           `json:"type"`
+	Settings trainer.Settings `json:"settings"`
+	Tensors  []TensorInfo     `json:"tensors,omitempty"`
+}
+
+//WriteCheckpoint writes everything SaveModel does plus the settings and hidden memory of every trainer
+//and the training counter.  counter is the value passed to Update, and is returned by ReadCheckpoint.
+//
+//InitHiddenLayers needs to have been ran.
+func (m *SimpleModuleNetwork) WriteCheckpoint(w io.Writer, counter int) error {
+	header, ts, err := m.modelheader(true)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) WriteCheckpoint: %v", err)
+	}
+	header.Checkpoint = true
+	header.Counter = counter
+	return writemodel(w, m.b.h.Handler, header, ts)
+}
+
+//ReadCheckpoint reads a checkpoint written by WriteCheckpoint and returns the counter it was written with.
+//
+//Like LoadModel, if m has no modules the network is built from the checkpoint.
+//The trainers are then set to the saved state so training can resume where it stopped.
+func (m *SimpleModuleNetwork) ReadCheckpoint(r io.Reader) (counter int, err error) {
+	header, payload, err := readmodel(r)
+	if err != nil {
+		return 0, err
+	}
+	if !header.Checkpoint {
+		return 0, errors.New("(m *SimpleModuleNetwork) ReadCheckpoint: file is a model file not a checkpoint")
+	}
+	err = m.load(header, payload, true)
+	if err != nil {
+		return 0, fmt.Errorf("(m *SimpleModuleNetwork) ReadCheckpoint: %v", err)
+	}
+	return header.Counter, nil
+}
+
+//SaveCheckpoint writes a checkpoint to path.
+//
+//The checkpoint is written to a temp file in the same directory and then renamed over path.
+//If anything fails the file at path is left as it was.
+func (m *SimpleModuleNetwork) SaveCheckpoint(path string, counter int) (err error) {
+	dir := filepath.Dir(path)
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	err = m.WriteCheckpoint(f, counter)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return err
+	}
+	//The rename is done.  Syncing the directory is only to make it durable so it isn't treated as an error.
+	if d, derr := os.Open(dir); derr == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+//LoadCheckpoint reads the checkpoint at path. See ReadCheckpoint.
+func (m *SimpleModuleNetwork) LoadCheckpoint(path string) (counter int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return m.ReadCheckpoint(f)
+}
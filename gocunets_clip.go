@@ -0,0 +1,160 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dereklstinson/gocunets/layers"
+)
+
+//gradients returns the weight gradients of the layer that a trainer uses.  Layers without hidden values return nil.
+func (l *Layer) gradients() (ts []*layers.Tensor) {
+	switch {
+	case l.cnn != nil:
+		ts = []*layers.Tensor{l.cnn.DeltaWeights(), l.cnn.DeltaBias()}
+	case l.cnntranspose != nil:
+		ts = []*layers.Tensor{l.cnntranspose.DeltaWeights(), l.cnntranspose.DeltaBias()}
+	case l.batch != nil:
+		ts = []*layers.Tensor{l.batch.DeltaScale(), l.batch.DeltaBias()}
+	case l.activation != nil:
+		ts = []*layers.Tensor{l.activation.DeltaNegCoefs(), l.activation.DeltaPosCoefs(), l.activation.DeltaThreshhold()}
+	}
+	grads := ts[:0]
+	for _, t := range ts {
+		if t != nil {
+			grads = append(grads, t)
+		}
+	}
+	return grads
+}
+
+//gradients returns every weight gradient in the network
+func (m *SimpleModuleNetwork) gradients() (ts []*layers.Tensor, err error) {
+	ls, err := m.hiddenlayers(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range ls {
+		if l != nil {
+			ts = append(ts, l.gradients()...)
+		}
+	}
+	return ts, nil
+}
+
+//GradientNorm returns the l2 norm of all the weight gradients of the network put together.
+//
+//The gradients are the sums over the batch that are in the layers after Backward, before the trainers divide them by the batch size.
+func (m *SimpleModuleNetwork) GradientNorm() (norm float32, err error) {
+	ts, err := m.gradients()
+	if err != nil {
+		return 0, fmt.Errorf("(m *SimpleModuleNetwork) GradientNorm: %v", err)
+	}
+	return m.gradientnorm(ts)
+}
+
+func (m *SimpleModuleNetwork) gradientnorm(ts []*layers.Tensor) (norm float32, err error) {
+	h := m.b.h.Handler
+	var sum float64
+	for _, t := range ts {
+		n, err := t.Norm2X(h)
+		if err != nil {
+			return 0, err
+		}
+		sum += float64(n) * float64(n)
+	}
+	return float32(math.Sqrt(sum)), nil
+}
+
+//ClipGradients scales all the weight gradients of the network by the same amount so that their l2 norm put together is no more than maxnorm.
+//It is ran between Backward and Update.  It returns the norm from before the gradients were clipped so that it can be watched.
+//
+//Like GradientNorm, the gradients are sums over the batch, so maxnorm needs to grow with the batch size.
+func (m *SimpleModuleNetwork) ClipGradients(maxnorm float32) (norm float32, err error) {
+	if maxnorm <= 0 {
+		return 0, errors.New("(m *SimpleModuleNetwork) ClipGradients: maxnorm needs to be greater than 0")
+	}
+	ts, err := m.gradients()
+	if err != nil {
+		return 0, fmt.Errorf("(m *SimpleModuleNetwork) ClipGradients: %v", err)
+	}
+	norm, err = m.gradientnorm(ts)
+	if err != nil {
+		return 0, err
+	}
+	if norm <= maxnorm {
+		return norm, nil
+	}
+	h := m.b.h.Handler
+	scale := float64(maxnorm) / (float64(norm) + 1e-6)
+	for _, t := range ts {
+		err = t.ScaleValues(h, scale)
+		if err != nil {
+			return norm, err
+		}
+	}
+	return norm, h.Sync()
+}
+
+//ClipGradientValues clamps every element of every weight gradient of the network to [-value, value].
+//It is ran between Backward and Update.
+func (m *SimpleModuleNetwork) ClipGradientValues(value float32) error {
+	if value <= 0 {
+		return errors.New("(m *SimpleModuleNetwork) ClipGradientValues: value needs to be greater than 0")
+	}
+	ts, err := m.gradients()
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) ClipGradientValues: %v", err)
+	}
+	h := m.b.h.Handler
+	for _, t := range ts {
+		ones, err := m.clipones(t)
+		if err != nil {
+			return err
+		}
+		err = t.OpMin(h, t.Volume, ones.Volume, 1, float64(value), 0)
+		if err != nil {
+			return err
+		}
+		err = t.OpMax(h, t.Volume, ones.Volume, 1, -float64(value), 0)
+		if err != nil {
+			return err
+		}
+	}
+	return h.Sync()
+}
+
+//clipones returns a tensor of ones with the same number of dims as t where each dim is 1, so it is broadcast over t.
+//They are made once for each number of dims and data type.
+func (m *SimpleModuleNetwork) clipones(t *layers.Tensor) (*Tensor, error) {
+	frmt, dtype, dims, err := t.Properties()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%d %v", len(dims), dtype)
+	if ones := m.ones[key]; ones != nil {
+		return ones, nil
+	}
+	onedims := make([]int32, len(dims))
+	for i := range onedims {
+		onedims[i] = 1
+	}
+	h := m.b.h.Handler
+	ones, err := layers.CreateTensor(h, frmt, dtype, onedims)
+	if err != nil {
+		return nil, err
+	}
+	err = ones.SetValues(h, 1)
+	if err != nil {
+		return nil, err
+	}
+	if m.ones == nil {
+		m.ones = make(map[string]*Tensor)
+	}
+	m.ones[key] = &Tensor{Tensor: ones}
+	return m.ones[key], nil
+}
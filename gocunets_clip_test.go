@@ -0,0 +1,54 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClipGradients(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+
+	m := testnetwork(t, handle, testnetworkspec)
+	check(m.GetTensorX().NormalRand(handle.Handler, 0, 1))
+	check(m.Forward())
+	check(m.Backward())
+
+	norm, err := m.GradientNorm()
+	check(err)
+	if norm <= 0 {
+		t.Fatalf("gradient norm is %v", norm)
+	}
+	prenorm, err := m.ClipGradients(norm / 2)
+	check(err)
+	if prenorm != norm {
+		t.Errorf("ClipGradients returned %v expected the norm before clipping %v", prenorm, norm)
+	}
+	clipped, err := m.GradientNorm()
+	check(err)
+	if math.Abs(float64(clipped-norm/2)) > 1e-3*float64(norm) {
+		t.Errorf("clipped norm is %v expected %v", clipped, norm/2)
+	}
+
+	ts, err := m.gradients()
+	check(err)
+	value := float32(1e-4)
+	check(m.ClipGradientValues(value))
+	for _, g := range ts {
+		max, err := g.MaxX(handle.Handler)
+		check(err)
+		min, err := g.MinX(handle.Handler)
+		check(err)
+		if max > value || min < -value {
+			t.Errorf("gradient is in [%v, %v] after clipping to %v", min, max, value)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/dereklstinson/gocunets/cpu"
+)
+
+//CreateCPUBuilder creates a Builder that makes tensors and layers that run on the host with the cpu package.
+//Networks made with it don't need a gpu, so a SimpleModuleNetwork made out of VanillaModules, an OutputModule
+//and the SoftMax classifier can be built and trained in CI or on a machine without an nvidia card.
+//
+//It has the same default flags as CreateBuilder.  Only the Float data type and the CrossCorrelation convolution mode are supported.
+//seed is used to init the weights and the random layers.
+//
+//Modules and features that need cudnn return an error when they are used with a cpu Builder.
+//Without the cuda build tag only the cpu path is built, so the package can be built and tested without cuda or cgo.
+func CreateCPUBuilder(seed int64) (b *Builder) {
+	b = new(Builder)
+	b.cpu = true
+	b.rng = rand.New(rand.NewSource(seed))
+	b.Frmt.NCHW()
+	b.Mtype.Default()
+	b.Cmode.CrossCorrelation()
+	b.Nan.NotPropigate()
+	b.Dtype.Float()
+	b.AMode.Leaky()
+	b.Pmode.AverageCountExcludePadding()
+	b.BNMode.Spatial()
+	return b
+}
+
+//OnCPU returns true if the Builder was made with CreateCPUBuilder
+func (l *Builder) OnCPU() bool {
+	return l.cpu
+}
+
+//hostformat returns the cpu format of the flags set in the builder
+func (l *Builder) hostformat() (frmt cpu.TensorFormat, err error) {
+	if l.Dtype != bprflags.Dtype.Float() {
+		return frmt, errors.New("(l *Builder) hostformat: only the Float datatype is supported on the cpu")
+	}
+	switch l.Frmt {
+	case bprflags.Frmt.NCHW():
+		return frmt.NCHW(), nil
+	case bprflags.Frmt.NHWC():
+		return frmt.NHWC(), nil
+	}
+	return frmt, errors.New("(l *Builder) hostformat: unsupported tensor format on the cpu")
+}
+
+func (l *Builder) createhosttensor(dims []int32) (t *Tensor, err error) {
+	frmt, err := l.hostformat()
+	if err != nil {
+		return nil, err
+	}
+	t = new(Tensor)
+	t.host, err = cpu.CreateTensor(frmt, dims)
+	if err != nil {
+		return nil, fmt.Errorf(" (l *Builder) CreateTensor, Err: %v, input dims: %v", err, dims)
+	}
+	return t, nil
+}
+
+func (l *Builder) createhostrandomtensor(dims []int32, mean, std float32, seed uint64) (t *Tensor, err error) {
+	t, err = l.createhosttensor(dims)
+	if err != nil {
+		return nil, err
+	}
+	t.host.SetRandomNormal(rand.New(rand.NewSource(int64(seed))), mean, std)
+	return t, nil
+}
+
+//hosttensors returns the host tensors of ts.  Every one of them needs to have been made by a cpu Builder.
+func hosttensors(ts ...*Tensor) ([]*cpu.Tensor, error) {
+	hts := make([]*cpu.Tensor, len(ts))
+	for i, t := range ts {
+		if t == nil || t.host == nil {
+			return nil, errors.New("tensor wasn't made by a cpu builder")
+		}
+		hts[i] = t.host
+	}
+	return hts, nil
+}
+
+func (l *Builder) hostpooling(id int64, window, padding, stride []int32) (*Layer, error) {
+	var mode cpu.PoolingMode
+	switch l.Pmode {
+	case bprflags.Pmode.Max(), bprflags.Pmode.MaxDeterministic():
+		mode.Max()
+	case bprflags.Pmode.AverageCountIncludePadding():
+		mode.AverageCountIncludePadding()
+	case bprflags.Pmode.AverageCountExcludePadding():
+		mode.AverageCountExcludePadding()
+	default:
+		return nil, errors.New("(l *Builder) PoolingLayer: unsupported pooling mode on the cpu")
+	}
+	op, err := cpu.CreatePooling(mode, window, padding, stride)
+	if err != nil {
+		return nil, err
+	}
+	return createhostlayer(id, op)
+}
+
+func (l *Builder) hostbatchnorm(id int64) (*Layer, error) {
+	var mode cpu.BatchNormMode
+	switch l.BNMode {
+	case bprflags.BNMode.PerActivation():
+		mode.PerActivation()
+	case bprflags.BNMode.Spatial(), bprflags.BNMode.SpatialPersistent():
+		mode.Spatial()
+	default:
+		return nil, errors.New("(l *Builder) BatchNorm: unsupported batch norm mode on the cpu")
+	}
+	op, err := cpu.CreateBatchNorm(mode)
+	if err != nil {
+		return nil, err
+	}
+	return createhostlayer(id, op)
+}
+
+func (l *Builder) hostconvolution(id int64, groupcount int32, w, dw, b, db *Tensor, pad, stride, dilation []int32, transpose bool) (*Layer, error) {
+	if l.Cmode != bprflags.Cmode.CrossCorrelation() {
+		return nil, errors.New("(l *Builder) ConvolutionLayer: only the CrossCorrelation mode is supported on the cpu")
+	}
+	ts, err := hosttensors(w, dw, b, db)
+	if err != nil {
+		return nil, fmt.Errorf("(l *Builder) ConvolutionLayer: %v", err)
+	}
+	if transpose {
+		op, err := cpu.CreateConvolutionTranspose(groupcount, ts[0], ts[1], ts[2], ts[3], pad, stride, dilation)
+		if err != nil {
+			return nil, err
+		}
+		return createhostlayer(id, op)
+	}
+	op, err := cpu.CreateConvolution(groupcount, ts[0], ts[1], ts[2], ts[3], pad, stride, dilation)
+	if err != nil {
+		return nil, err
+	}
+	return createhostlayer(id, op)
+}
+
+func (l *Builder) hostdropout(id int64, dropoutpercent float32, seed uint64) (*Layer, error) {
+	op, err := cpu.CreateDropout(dropoutpercent, int64(seed))
+	if err != nil {
+		return nil, err
+	}
+	return createhostlayer(id, op)
+}
+
+//hostactivation makes an activation layer with the AMode flag.  If coef is less than 0 the same coefs the gpu layers use are used.
+func (l *Builder) hostactivation(id int64, coef float64) (*Layer, error) {
+	var mode cpu.ActivationMode
+	aflg := l.AMode
+	switch l.AMode {
+	case aflg.Leaky():
+		mode.Leaky()
+		if coef < 0 {
+			coef = .01
+		}
+	case aflg.ClippedRelu():
+		mode.ClippedRelu()
+	case aflg.Relu():
+		mode.Relu()
+	case aflg.Elu():
+		mode.Elu()
+	case aflg.Threshhold():
+		mode.Threshhold()
+	case aflg.Sigmoid():
+		mode.Sigmoid()
+	case aflg.Tanh():
+		mode.Tanh()
+	case aflg.PRelu():
+		mode.PRelu()
+	case aflg.Identity():
+		mode.Identity()
+	default:
+		return nil, errors.New("AppendActivation:  Not supported Activation Layer")
+	}
+	if coef < 0 {
+		coef = 6
+	}
+	op, err := cpu.CreateActivation(mode, coef, l.rng.Int63())
+	if err != nil {
+		return nil, err
+	}
+	return createhostlayer(id, op)
+}
+
+//Host returns the host tensor of t.  It is nil if t wasn't made by a cpu Builder.
+//The values can be read and changed with its Data method.
+func (t *Tensor) Host() *cpu.Tensor {
+	return t.host
+}
+
+//hostlayername returns the name that createlayer gives the gpu layer that op is the host version of
+func hostlayername(op cpu.Operation) string {
+	switch op.(type) {
+	case *cpu.Activation:
+		return "Activation"
+	case *cpu.Convolution:
+		return "CNN"
+	case *cpu.Pooling:
+		return "Pooling"
+	case *cpu.Dropout:
+		return "DropOut"
+	case *cpu.BatchNorm:
+		return "BatchNorm"
+	case *cpu.Reshape:
+		return "Reshape"
+	case *cpu.ConvolutionTranspose:
+		return "CNN-Transpose"
+	}
+	return "Operation"
+}
+
+//createhostlayer makes a layer for a host operation
+func createhostlayer(id int64, op cpu.Operation) (*Layer, error) {
+	l := new(Layer)
+	l.host = op
+	l.name = hostlayername(op)
+	l.id = id
+	return l, nil
+}
+
+//hostio returns the host tensors of the x, dx, y and dy of the layer.  Tensors that aren't set are nil.
+func (l *Layer) hostio() (x, dx, y, dy *cpu.Tensor) {
+	if l.x != nil {
+		x = l.x.host
+	}
+	if l.dx != nil {
+		dx = l.dx.host
+	}
+	if l.y != nil {
+		y = l.y.host
+	}
+	if l.dy != nil {
+		dy = l.dy.host
+	}
+	return x, dx, y, dy
+}
+
+//LoadHostTrainer loads cpu trainers into a layer made by a cpu Builder
+func (l *Layer) LoadHostTrainer(batchsize int, trainers ...cpu.Trainer) error {
+	if l.host == nil {
+		return errors.New("(l *Layer) LoadHostTrainer: layer wasn't made by a cpu builder")
+	}
+	l.batchsize = batchsize
+	tneed := l.host.TrainersNeeded()
+	if len(trainers) != tneed {
+		return fmt.Errorf("l.host got %d should get %d", len(trainers), tneed)
+	}
+	return l.host.LoadTrainers(trainers...)
+}
+
+//inithost makes random weights for a host layer with weights and loads adam trainers into it.
+//It is the host version of what InitHiddenLayers does to the cnn layers of a module.
+func (l *Layer) inithost(rng *rand.Rand, rate, decay1, decay2 float32, batchsize int) error {
+	if l.x == nil {
+		return errors.New("(l *Layer) inithost: x tensor is not set")
+	}
+	weights, ok := l.host.(interface{ Weights() (w, b *cpu.Tensor) })
+	if ok {
+		w, _ := weights.Weights()
+		fanin := int32(1)
+		xdims := l.x.Dims()
+		for i := 1; i < len(xdims); i++ {
+			fanin *= xdims[i]
+		}
+		w.SetRandom(rng, fanin)
+	}
+	trainers := make([]cpu.Trainer, l.host.TrainersNeeded())
+	for i := range trainers {
+		a := cpu.CreateAdam(decay1, decay2)
+		a.SetRates(rate, 1)
+		trainers[i] = a
+	}
+	return l.LoadHostTrainer(batchsize, trainers...)
+}
+
+//hostsoftmax is the softmax cross entropy loss used by a SoftMax ClassifierModule made with a cpu Builder.
+//Like loss.SoftMax dx is y - target and the loss is the cross entropy averaged over the batch.
+type hostsoftmax struct {
+	op   *cpu.SoftMax
+	loss float32
+}
+
+func createhostsoftmax() (*hostsoftmax, error) {
+	var algo cpu.SoftMaxAlgo
+	var mode cpu.SoftMaxMode
+	op, err := cpu.CreateSoftMax(algo.Accurate(), mode.Channel())
+	if err != nil {
+		return nil, err
+	}
+	return &hostsoftmax{op: op}, nil
+}
+
+func (s *hostsoftmax) performerror(x, dx, y, target *cpu.Tensor) error {
+	err := s.testforward(x, y, target)
+	if err != nil {
+		return err
+	}
+	yv, tv, dxv := y.Data(), target.Data(), dx.Data()
+	for i := range dxv {
+		dxv[i] = yv[i] - tv[i]
+	}
+	return nil
+}
+
+func (s *hostsoftmax) inference(x, y *cpu.Tensor) error {
+	return s.op.Inference(x, y)
+}
+
+func (s *hostsoftmax) testforward(x, y, target *cpu.Tensor) error {
+	err := s.op.Forward(x, y)
+	if err != nil {
+		return err
+	}
+	yv, tv := y.Data(), target.Data()
+	if len(yv) != len(tv) {
+		return errors.New("(s *hostsoftmax) testforward: y and target need the same volume")
+	}
+	var loss float64
+	for i, t := range tv {
+		if t != 0 {
+			loss -= float64(t) * math.Log(math.Max(float64(yv[i]), 1e-12))
+		}
+	}
+	s.loss = float32(loss / float64(y.Dims()[0]))
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCPUBuilderGPUOnly(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	spec, err := ReadNetworkSpec(strings.NewReader(testcpuspec))
+	check(err)
+	b := CreateCPUBuilder(1)
+	m, err := spec.Build(b)
+	check(err)
+	if err = m.SaveModel(new(bytes.Buffer)); err == nil {
+		t.Error("saving a network on the cpu should error")
+	}
+	if _, err = CreateResidualModule(10, b, 8, m.Modules[0]); err == nil {
+		t.Error("residual modules aren't supported on the cpu and should error")
+	}
+}
@@ -0,0 +1,78 @@
+package gocunets
+
+import (
+	"strings"
+	"testing"
+)
+
+const testcpuspec = `{
+	"flags": {"frmt": "NCHW", "amode": "Leaky"},
+	"input_dims": [8, 1, 4, 4],
+	"modules": [
+		{"type": "VanillaModule", "filter_dims": [4, 1, 3, 3], "pad": [1, 1], "stride": [1, 1], "dilation": [1, 1], "balpha": 1, "falpha": 1}
+	],
+	"output": {"type": "OutputModule", "filter_dims": [2, 4, 4, 4], "pad": [0, 0], "stride": [1, 1], "dilation": [1, 1], "balpha": 1, "falpha": 1},
+	"classifier": "SoftMax",
+	"rate": 0.01
+}`
+
+func TestCPUBuilderTrains(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	spec, err := ReadNetworkSpec(strings.NewReader(testcpuspec))
+	check(err)
+	b := CreateCPUBuilder(1)
+	m, err := spec.Build(b)
+	check(err)
+	if m.GetTensorX().Host() == nil || m.GetTensorY().Host() == nil {
+		t.Fatal("network tensors should be on the host")
+	}
+
+	//class 0 is a vertical line and class 1 is a horizontal line
+	const batch = 8
+	inputs := make([]float32, batch*16)
+	targets := make([]float32, batch*2)
+	for i := 0; i < batch; i++ {
+		for j := 0; j < 4; j++ {
+			if i%2 == 0 {
+				inputs[i*16+j*4+i%4] = 1
+			} else {
+				inputs[i*16+(i%4)*4+j] = 1
+			}
+		}
+		targets[i*2+i%2] = 1
+	}
+	check(m.LoadInput(inputs))
+	check(m.b.loadvalues(m.GetTensorDY(), targets))
+
+	var first, last float32
+	for i := 0; i < 200; i++ {
+		check(m.Forward())
+		check(m.Backward())
+		check(m.Update(i))
+		if i == 0 {
+			first = m.GetLoss()
+		}
+		last = m.GetLoss()
+	}
+	if last > first/4 {
+		t.Errorf("network didn't train: loss went from %v to %v", first, last)
+	}
+	check(m.Inference())
+	y := m.GetTensorY().Host().Data()
+	for i := 0; i < batch; i++ {
+		if y[i*2+i%2] < .5 {
+			t.Errorf("sample %d is classified wrong %v", i, y[i*2:i*2+2])
+		}
+	}
+
+	b.Cmode.Convolution()
+	w, dw, bias, db, err := b.CreateConvolutionWeights([]int32{2, 1, 3, 3})
+	check(err)
+	if _, err = b.ConvolutionLayer(0, 1, w, dw, bias, db, []int32{1, 1}, []int32{1, 1}, []int32{1, 1}); err == nil {
+		t.Error("only CrossCorrelation should be supported on the cpu")
+	}
+}
@@ -0,0 +1,43 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/data"
+	"github.com/dereklstinson/half"
+)
+
+//LoadBatch loads the inputs of b into x and the targets of b into y.  y can be nil if the targets aren't used.
+//Half tensors are loaded with the values converted to float16.  h isn't used by tensors made by a cpu Builder and can be nil.
+//The inputs are loaded as they are.  (m *SimpleModuleNetwork) LoadBatch scales them with the Normalizer of the network.
+func LoadBatch(h *Handle, b *data.Batch, x, y *Tensor) error {
+	err := loadslice(h, x, b.Inputs)
+	if err != nil {
+		return fmt.Errorf("LoadBatch: inputs: %v", err)
+	}
+	if y == nil {
+		return nil
+	}
+	err = loadslice(h, y, b.Targets)
+	if err != nil {
+		return fmt.Errorf("LoadBatch: targets: %v", err)
+	}
+	return nil
+}
+
+func loadslice(h *Handle, t *Tensor, values []float32) error {
+	if t.host != nil {
+		return t.host.LoadValuesFromSLice(values)
+	}
+	dtype := t.DataType()
+	switch dtype {
+	case dtype.Float():
+		return t.LoadValuesFromSLice(h.Handler, values, int32(len(values)))
+	case dtype.Half():
+		return t.LoadValuesFromSLice(h.Handler, half.NewFloat16Array(values), int32(len(values)))
+	}
+	return fmt.Errorf("unsupported datatype %v", dtype)
+}
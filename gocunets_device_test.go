@@ -0,0 +1,44 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+//testhandle locks the thread and makes a handle on the first device.  The caller needs to close it.
+func testhandle(t *testing.T) *Handle {
+	t.Helper()
+	runtime.LockOSThread()
+	dlist, err := GetDeviceList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dlist) == 0 {
+		t.Fatal("no devices found")
+	}
+	dev := dlist[0]
+	if err = dev.Set(); err != nil {
+		t.Fatal(err)
+	}
+	w := CreateWorker(dev)
+	return CreateHandle(w, dev, rand.Uint64())
+}
+
+//testnetwork builds the json NetworkSpec spec with a new Builder for handle
+func testnetwork(t *testing.T, handle *Handle, spec string) *SimpleModuleNetwork {
+	t.Helper()
+	s, err := ReadNetworkSpec(strings.NewReader(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.Build(CreateBuilder(handle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
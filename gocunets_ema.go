@@ -0,0 +1,237 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/layers"
+)
+
+//EMAInfo is the exponential moving average of the hidden values held in a model file.
+//Tensors are in the same order as the tensors of the layers of the modules and the output.
+type EMAInfo struct {
+	Decay   float32      `json:"decay"`
+	Tensors []TensorInfo `json:"tensors"`
+}
+
+//ema keeps an exponential moving average of the hidden values of a network
+type ema struct {
+	decay   float32
+	names   []string
+	tensors []*layers.Tensor //the hidden values of the network
+	shadow  []*layers.Tensor //the average
+	backup  []*layers.Tensor //the trained values while the average is swapped in
+	swapped bool
+}
+
+//SetEMA makes the network keep an exponential moving average of its hidden values.  After each Update the average is
+//
+//	average = decay*average + (1-decay)*values
+//
+//The average starts at the values the network has when SetEMA is called.
+//It is of the same values that are saved in a model file, so the running mean and variance of batchnorm layers are averaged too.
+//SaveModel and WriteCheckpoint write the average, and LoadModel and ReadCheckpoint read it back.
+//
+//Use SwapEMA to run Inference or TestForward with the average.  A decay of 0 stops keeping the average.
+//InitHiddenLayers needs to have been ran.
+func (m *SimpleModuleNetwork) SetEMA(decay float32) error {
+	if decay < 0 || decay >= 1 {
+		return errors.New("(m *SimpleModuleNetwork) SetEMA: decay needs to be in [0,1)")
+	}
+	if m.ema != nil && m.ema.swapped {
+		return errors.New("(m *SimpleModuleNetwork) SetEMA: the average is swapped in")
+	}
+	if decay == 0 {
+		m.ema = nil
+		return nil
+	}
+	if m.ema != nil {
+		m.ema.decay = decay
+		return nil
+	}
+	e, err := m.createema(decay)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) SetEMA: %v", err)
+	}
+	m.ema = e
+	return nil
+}
+
+//EMADecay returns the decay of the moving average. It is 0 if the network isn't keeping one.
+func (m *SimpleModuleNetwork) EMADecay() float32 {
+	if m.ema == nil {
+		return 0
+	}
+	return m.ema.decay
+}
+
+//SwapEMA puts the moving average into the layers in place of the trained values, or puts the trained values back if the average is swapped in.
+//Update returns an error while the average is swapped in.
+//
+//SaveModel writes the average as the hidden values of the network while it is swapped in, so it can be used to save the average as a model on its own.
+func (m *SimpleModuleNetwork) SwapEMA() (err error) {
+	if m.ema == nil {
+		return errors.New("(m *SimpleModuleNetwork) SwapEMA: SetEMA hasn't been ran")
+	}
+	h := m.b.h.Handler
+	e := m.ema
+	if e.swapped {
+		for i, t := range e.tensors {
+			err = t.AddTo(h, e.backup[i].Volume, 1, 0)
+			if err != nil {
+				return err
+			}
+		}
+		e.swapped = false
+		return h.Sync()
+	}
+	if e.backup == nil {
+		e.backup = make([]*layers.Tensor, len(e.tensors))
+		for i, t := range e.tensors {
+			e.backup[i], err = layers.ZeroClone(h, t)
+			if err != nil {
+				e.backup = nil
+				return err
+			}
+		}
+	}
+	for i, t := range e.tensors {
+		err = e.backup[i].AddTo(h, t.Volume, 1, 0)
+		if err != nil {
+			return err
+		}
+		err = t.AddTo(h, e.shadow[i].Volume, 1, 0)
+		if err != nil {
+			return err
+		}
+	}
+	e.swapped = true
+	return h.Sync()
+}
+
+//EMASwapped returns true if the moving average is in the layers in place of the trained values.
+func (m *SimpleModuleNetwork) EMASwapped() bool {
+	return m.ema != nil && m.ema.swapped
+}
+
+//createema makes the average of the saved tensors of the hidden layers starting at their values
+func (m *SimpleModuleNetwork) createema(decay float32) (e *ema, err error) {
+	ls, err := m.hiddenlayers(nil)
+	if err != nil {
+		return nil, err
+	}
+	h := m.b.h.Handler
+	e = &ema{decay: decay}
+	for _, l := range ls {
+		if l == nil {
+			continue
+		}
+		sts, err := l.savedtensors()
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range sts {
+			if st.mem == nil {
+				return nil, fmt.Errorf("%s of %s layer hasn't been allocated", st.name, l.layername())
+			}
+			t, err := layers.CreateTensorEX(h, st.frmt, st.dtype, st.dims, st.mem)
+			if err != nil {
+				return nil, err
+			}
+			shadow, err := layers.ZeroClone(h, t)
+			if err != nil {
+				return nil, err
+			}
+			e.names = append(e.names, st.name)
+			e.tensors = append(e.tensors, t)
+			e.shadow = append(e.shadow, shadow)
+		}
+	}
+	return e, m.resetema(e)
+}
+
+//resetema sets the average to the values of the network
+func (m *SimpleModuleNetwork) resetema(e *ema) error {
+	h := m.b.h.Handler
+	for i, t := range e.tensors {
+		err := e.shadow[i].AddTo(h, t.Volume, 1, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return h.Sync()
+}
+
+//updateema moves the average towards the values of the network.  It is ran by Update.
+func (m *SimpleModuleNetwork) updateema() error {
+	e := m.ema
+	if e == nil {
+		return nil
+	}
+	h := m.b.h.Handler
+	for i, t := range e.tensors {
+		err := e.shadow[i].AddTo(h, t.Volume, float64(1-e.decay), float64(e.decay))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//emainfo makes the EMAInfo of the average with its tensors placed in the payload starting at offset.
+//Nothing is returned if the network isn't keeping an average or if the average is swapped in.
+func (m *SimpleModuleNetwork) emainfo(offset int64) (info *EMAInfo, ts []savedtensor, err error) {
+	e := m.ema
+	if e == nil || e.swapped {
+		return nil, nil, nil
+	}
+	info = &EMAInfo{Decay: e.decay}
+	for i, t := range e.shadow {
+		st := savedfromtensor(e.names[i], t)
+		tinfo, err := st.info(offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		offset += tinfo.Length
+		info.Tensors = append(info.Tensors, tinfo)
+		ts = append(ts, st)
+	}
+	return info, ts, nil
+}
+
+//loadema loads the saved average.  If nothing was saved and the network is keeping an average, the average is set to the loaded values.
+func (m *SimpleModuleNetwork) loadema(info *EMAInfo, payload []byte) (err error) {
+	if info == nil {
+		if m.ema == nil {
+			return nil
+		}
+		return m.resetema(m.ema)
+	}
+	e := m.ema
+	if e == nil {
+		e, err = m.createema(info.Decay)
+		if err != nil {
+			return err
+		}
+	}
+	if len(e.shadow) != len(info.Tensors) {
+		return fmt.Errorf("network has %d averaged tensors but %d were saved", len(e.shadow), len(info.Tensors))
+	}
+	h := m.b.h.Handler
+	for i, t := range e.shadow {
+		saved := info.Tensors[i]
+		if saved.Name != e.names[i] {
+			return fmt.Errorf("expected averaged tensor %s got %s", e.names[i], saved.Name)
+		}
+		err = loadsaved(h, t.Malloced, saved, payload)
+		if err != nil {
+			return err
+		}
+	}
+	e.decay = info.Decay
+	m.ema = e
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestEMA(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+
+	m := testnetwork(t, handle, testnetworkspec)
+	check(m.SetEMA(.5))
+	weights := func(m *SimpleModuleNetwork) []float32 {
+		ls, err := m.hiddenlayers(nil)
+		check(err)
+		wt := ls[0].cnn.Weights()
+		values := make([]float32, wt.Vol())
+		check(wt.FillSlice(handle.Handler, values))
+		return values
+	}
+	compare := func(name string, values, expected []float32) {
+		for i := range expected {
+			if math.Abs(float64(values[i]-expected[i])) > 1e-6 {
+				t.Fatalf("%s: weight %d is %v expected %v", name, i, values[i], expected[i])
+			}
+		}
+	}
+	before := weights(m)
+	check(m.GetTensorX().NormalRand(handle.Handler, 0, 1))
+	check(m.Forward())
+	check(m.Backward())
+	check(m.Update(0))
+	trained := weights(m)
+	average := make([]float32, len(before))
+	for i := range average {
+		average[i] = .5*before[i] + .5*trained[i]
+	}
+
+	check(m.SwapEMA())
+	compare("swapped in", weights(m), average)
+	if err := m.Update(1); err == nil {
+		t.Error("Update should fail while the average is swapped in")
+	}
+	check(m.SwapEMA())
+	compare("swapped back", weights(m), trained)
+
+	buf := new(bytes.Buffer)
+	check(m.SaveModel(buf))
+	loaded := CreateSimpleModuleNetwork(1, CreateBuilder(handle))
+	check(loaded.LoadModel(buf))
+	if loaded.EMADecay() != .5 {
+		t.Errorf("loaded decay is %v", loaded.EMADecay())
+	}
+	compare("loaded", weights(loaded), trained)
+	check(loaded.SwapEMA())
+	compare("loaded average", weights(loaded), average)
+}
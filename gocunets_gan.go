@@ -0,0 +1,247 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/loss"
+	"github.com/dereklstinson/gocunets/loss/gansloss"
+)
+
+//GAN trains a generator and a discriminator against each other.
+//
+//The generator is a SimpleModuleNetwork without a classifier. Its output is the input of the discriminator, so they need to have the same dims.
+//The discriminator is a SimpleModuleNetwork with a classifier. The classifier picks the mode:
+//
+//Binary classifier: the discriminator has one output for each sample that is the logit of it being real.
+//
+//SoftMax classifier: the discriminator is semi-supervised with K real classes and a fake class that is the last output.
+//The labels of the real samples are given to DiscriminatorStep with a 0 for the fake class.
+//
+//The gradient of the discriminator's input is the gradient of the generator's output, so the generator is trained through the discriminator.
+//The input of the generator is filled with normal noise on every step.
+//
+//GeneratorStep runs the discriminator's Backward to get the gradient of the fakes.  The weight gradients that it adds to the
+//discriminator's hidden layers are zeroed after, so the discriminator's next update only has the gradients of its own steps.
+type GAN struct {
+	gen, dis   *SimpleModuleNetwork
+	h          *Handle
+	semi       bool
+	real       *Tensor //targets for real samples in binary mode
+	fake       *Tensor //targets for fake samples
+	gentarget  *Tensor //targets for fake samples when the generator is trained
+	mean, std  float32
+	dloss      float32
+	gloss      float32
+	realloss   float32
+	fakeloss   float32
+	classcount int
+}
+
+//CreateGAN creates a GAN from a generator and a discriminator that have been built.
+func CreateGAN(generator, discriminator *SimpleModuleNetwork) (g *GAN, err error) {
+	if generator != nil {
+		if err = generator.b.gpuonly("CreateGAN"); err != nil {
+			return nil, err
+		}
+	}
+	if generator == nil || generator.Output == nil || generator.Output.GetTensorY() == nil || generator.Output.GetTensorDY() == nil {
+		return nil, errors.New("CreateGAN: generator needs to be built with an output")
+	}
+	if generator.Classifier != nil {
+		return nil, errors.New("CreateGAN: generator can't have a classifier")
+	}
+	if discriminator == nil || discriminator.Classifier == nil || discriminator.GetTensorX() == nil {
+		return nil, errors.New("CreateGAN: discriminator needs to be built with a classifier")
+	}
+	gy := generator.Output.GetTensorY()
+	if !int32sequal(gy.Dims(), discriminator.GetTensorX().Dims()) {
+		return nil, fmt.Errorf("CreateGAN: generator output %v and discriminator input %v are not the same", gy.Dims(), discriminator.GetTensorX().Dims())
+	}
+	g = &GAN{
+		gen:  generator,
+		dis:  discriminator,
+		h:    discriminator.b.h,
+		mean: 0,
+		std:  1,
+	}
+	target := discriminator.Classifier.GetTensorDY()
+	batch := int(target.Dims()[0])
+	g.classcount = int(target.Vol()) / batch
+	switch discriminator.Classifier.l.(type) {
+	case *loss.BinaryCrossEntropy:
+		if g.classcount != 1 {
+			return nil, fmt.Errorf("CreateGAN: binary discriminator needs one output for each sample but has %d", g.classcount)
+		}
+		g.real, err = g.targets(target.Dims(), nil, 1)
+		if err != nil {
+			return nil, err
+		}
+		g.fake, err = g.targets(target.Dims(), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		g.gentarget = g.real
+	case *loss.SoftMax:
+		if g.classcount < 2 {
+			return nil, errors.New("CreateGAN: semi-supervised discriminator needs at least one real class and the fake class")
+		}
+		g.semi = true
+		var s gansloss.SemiSuper
+		g.fake, err = g.targets(target.Dims(), s.FakeTargets(batch, g.classcount), 0)
+		if err != nil {
+			return nil, err
+		}
+		g.gentarget, err = g.targets(target.Dims(), s.GeneratorTargets(batch, g.classcount), 0)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("CreateGAN: discriminator needs a binary or softmax classifier")
+	}
+	discriminator.SetTensorDX(generator.Output.GetTensorDY())
+	return g, nil
+}
+
+//targets makes a tensor with values or with every element set to value if values is nil
+func (g *GAN) targets(dims []int32, values []float32, value float64) (t *Tensor, err error) {
+	t, err = g.dis.b.CreateTensor(dims)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		return t, t.SetValues(g.h.Handler, value)
+	}
+	return t, t.LoadValuesFromSLice(g.h.Handler, values, int32(len(values)))
+}
+
+//SetNoise sets the mean and standard deviation of the noise that is the generator's input. The default is 0 and 1.
+func (g *GAN) SetNoise(mean, std float32) {
+	g.mean, g.std = mean, std
+}
+
+//Step does a DiscriminatorStep and then a GeneratorStep
+func (g *GAN) Step(real, labels *Tensor, counter int) (err error) {
+	err = g.DiscriminatorStep(real, labels, counter)
+	if err != nil {
+		return err
+	}
+	return g.GeneratorStep(counter)
+}
+
+//DiscriminatorStep trains the discriminator on a batch of real samples and then on a batch of fakes from the generator.
+//labels are the targets of the real samples in semi-supervised mode and are ignored in binary mode.
+func (g *GAN) DiscriminatorStep(real, labels *Tensor, counter int) (err error) {
+	if g.semi && labels == nil {
+		return errors.New("(g *GAN) DiscriminatorStep: semi-supervised mode needs labels")
+	}
+	if !g.semi {
+		labels = g.real
+	}
+	g.realloss, err = g.discriminate(real, labels, counter)
+	if err != nil {
+		return fmt.Errorf("(g *GAN) DiscriminatorStep: real: %v", err)
+	}
+	err = g.generate()
+	if err != nil {
+		return fmt.Errorf("(g *GAN) DiscriminatorStep: %v", err)
+	}
+	g.fakeloss, err = g.discriminate(g.gen.Output.GetTensorY(), g.fake, counter)
+	if err != nil {
+		return fmt.Errorf("(g *GAN) DiscriminatorStep: fake: %v", err)
+	}
+	g.dloss = (g.realloss + g.fakeloss) / 2
+	return nil
+}
+
+//GeneratorStep trains the generator to make fakes that the discriminator takes as real. The discriminator isn't updated
+//and its weight gradients are zeroed.
+func (g *GAN) GeneratorStep(counter int) (err error) {
+	err = g.generate()
+	if err != nil {
+		return fmt.Errorf("(g *GAN) GeneratorStep: %v", err)
+	}
+	err = g.load(g.gen.Output.GetTensorY(), g.gentarget)
+	if err != nil {
+		return fmt.Errorf("(g *GAN) GeneratorStep: %v", err)
+	}
+	err = g.dis.Forward()
+	if err != nil {
+		return fmt.Errorf("(g *GAN) GeneratorStep: discriminator: %v", err)
+	}
+	g.gloss = g.dis.GetLoss()
+	//This puts the gradient of the discriminator's input into the gradient of the generator's output
+	err = g.dis.Backward()
+	if err != nil {
+		return fmt.Errorf("(g *GAN) GeneratorStep: discriminator: %v", err)
+	}
+	err = g.gen.Backward()
+	if err != nil {
+		return fmt.Errorf("(g *GAN) GeneratorStep: generator: %v", err)
+	}
+	err = g.dis.zerogradients()
+	if err != nil {
+		return fmt.Errorf("(g *GAN) GeneratorStep: discriminator: %v", err)
+	}
+	return g.gen.Update(counter)
+}
+
+//generate fills the generator's input with noise and runs the generator
+func (g *GAN) generate() (err error) {
+	err = g.gen.GetTensorX().NormalRand(g.h.Handler, g.mean, g.std)
+	if err != nil {
+		return err
+	}
+	return g.gen.Forward()
+}
+
+//load copies x and target into the discriminator's input and target
+func (g *GAN) load(x, target *Tensor) (err error) {
+	h := g.h.Handler
+	if dx := g.dis.GetTensorX(); dx != x {
+		err = dx.AddTo(h, x.Volume, 1, 0)
+		if err != nil {
+			return err
+		}
+	}
+	if dt := g.dis.Classifier.GetTensorDY(); dt != target {
+		err = dt.AddTo(h, target.Volume, 1, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//discriminate trains the discriminator on x and target and returns its loss
+func (g *GAN) discriminate(x, target *Tensor, counter int) (loss float32, err error) {
+	err = g.load(x, target)
+	if err != nil {
+		return 0, err
+	}
+	err = g.dis.Forward()
+	if err != nil {
+		return 0, err
+	}
+	loss = g.dis.GetLoss()
+	err = g.dis.Backward()
+	if err != nil {
+		return 0, err
+	}
+	return loss, g.dis.Update(counter)
+}
+
+//DiscriminatorLoss returns the average of the real and fake losses of the last DiscriminatorStep
+func (g *GAN) DiscriminatorLoss() float32 { return g.dloss }
+
+//RealLoss returns the loss of the real samples of the last DiscriminatorStep
+func (g *GAN) RealLoss() float32 { return g.realloss }
+
+//FakeLoss returns the loss of the fakes of the last DiscriminatorStep
+func (g *GAN) FakeLoss() float32 { return g.fakeloss }
+
+//GeneratorLoss returns the loss of the discriminator on the fakes of the last GeneratorStep with the targets the generator wants
+func (g *GAN) GeneratorLoss() float32 { return g.gloss }
@@ -0,0 +1,102 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const testgeneratorspec = `{
+	"flags": {"frmt": "NCHW", "amode": "Relu"},
+	"input_dims": [4, 1, 9, 9],
+	"modules": [
+		{"type": "VanillaModule", "filter_dims": [8, 1, 3, 3], "pad": [1, 1], "stride": [1, 1], "dilation": [1, 1], "balpha": 1, "falpha": 1}
+	],
+	"output": {"type": "OutputModule", "filter_dims": [1, 8, 3, 3], "pad": [1, 1], "stride": [1, 1], "dilation": [1, 1], "balpha": 1, "falpha": 1},
+	"rate": 0.001
+}`
+
+func TestGANSemiSupervised(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+
+	generator := testnetwork(t, handle, testgeneratorspec)
+	//The discriminator has 2 real classes and the fake class
+	discriminator := testnetwork(t, handle, testnetworkspec)
+	gan, err := CreateGAN(generator, discriminator)
+	check(err)
+	if discriminator.GetTensorDX() != generator.Output.GetTensorDY() {
+		t.Fatal("discriminator dx should be the generator's output dy")
+	}
+
+	real, err := discriminator.b.CreateTensor(discriminator.GetTensorX().Dims())
+	check(err)
+	check(real.NormalRand(handle.Handler, 1, .5))
+	labels, err := discriminator.b.CreateTensor(discriminator.Classifier.GetTensorDY().Dims())
+	check(err)
+	values := make([]float32, labels.Vol())
+	for i := 0; i < len(values); i += 3 {
+		values[i+rand.Intn(2)] = 1
+	}
+	check(labels.LoadValuesFromSLice(handle.Handler, values, int32(len(values))))
+
+	if err = gan.DiscriminatorStep(real, nil, 0); err == nil {
+		t.Error("semi-supervised step without labels should fail")
+	}
+	for i := 0; i < 3; i++ {
+		check(gan.Step(real, labels, i))
+	}
+	if gan.RealLoss() <= 0 || gan.FakeLoss() <= 0 || gan.GeneratorLoss() <= 0 {
+		t.Errorf("real %v fake %v generator %v", gan.RealLoss(), gan.FakeLoss(), gan.GeneratorLoss())
+	}
+	if gan.DiscriminatorLoss() != (gan.RealLoss()+gan.FakeLoss())/2 {
+		t.Error("discriminator loss should be the average of the real and fake losses")
+	}
+
+	//GeneratorStep doesn't move the discriminator's weights and leaves it no gradients for its next update
+	weights := func() (values [][]float32) {
+		ls, err := discriminator.hiddenlayers(nil)
+		check(err)
+		for _, l := range ls {
+			if l == nil || l.cnn == nil {
+				continue
+			}
+			wt := l.cnn.Weights()
+			v := make([]float32, wt.Vol())
+			check(wt.FillSlice(handle.Handler, v))
+			values = append(values, v)
+		}
+		return values
+	}
+	before := weights()
+	check(gan.GeneratorStep(3))
+	check(handle.Sync())
+	for i, after := range weights() {
+		for j := range after {
+			if after[j] != before[i][j] {
+				t.Fatalf("GeneratorStep changed weight %d of discriminator layer %d", j, i)
+			}
+		}
+	}
+	grads, err := discriminator.gradients()
+	check(err)
+	for _, g := range grads {
+		max, err := g.MaxX(handle.Handler)
+		check(err)
+		min, err := g.MinX(handle.Handler)
+		check(err)
+		if max != 0 || min != 0 {
+			t.Fatalf("GeneratorStep left discriminator gradients in [%v, %v]", min, max)
+		}
+	}
+	if _, err = CreateGAN(discriminator, generator); err == nil {
+		t.Error("swapped networks should not make a GAN")
+	}
+}
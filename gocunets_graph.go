@@ -0,0 +1,688 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/trainer"
+)
+
+//Graph is a network whose nodes are Modules, Layers, Concats and ReverseConcats connected by named tensors.
+//
+//Nodes are ran in topological order on the forward pass and in reverse order on the backward pass.
+//When a tensor feeds more than one node, each of those nodes writes its gradient into a tensor of its own and the gradients are summed
+//into the gradient of the tensor before the node that made it is ran backward.  Every node needs to write, not add to, the gradient of its input.
+//The modules and layers made by Builder do that with the scalars they are made with.
+//
+//Graph satisfies the Module interface using its first input and first output, so a Graph with one input and one output can be
+//placed in a SimpleModuleNetwork.
+//
+//After the graph is made, the input tensors are set with SetTensorX or SetInput.  Then FindOutputDims, InitHiddenLayers and InitWorkspace are ran like any other module.
+//Output tensors that aren't set with SetTensorY/SetTensorDY or SetOutput are made by InitHiddenLayers.
+//An output that is also the input of another node is made by FindOutputDims, since that node needs it to find its dims.
+//It can still be replaced with SetOutput before InitHiddenLayers is ran.
+type Graph struct {
+	id      int64
+	b       *Builder
+	nodes   []*graphnode
+	order   []*graphnode
+	tensors map[string]*graphtensor
+	inputs  []string
+	outputs []string
+	found   bool
+}
+
+//graphtensor is a named tensor of a graph and its gradient
+type graphtensor struct {
+	name      string
+	x, dx     *Tensor
+	dims      []int32
+	producer  *graphnode
+	consumers []graphedge
+	input     bool
+	output    bool
+}
+
+//graphedge is the input index of a node that uses a tensor
+type graphedge struct {
+	n     *graphnode
+	index int
+}
+
+//graphnode is a node of a graph. Only one of mod, layer, concat or reverse is used.
+type graphnode struct {
+	name    string
+	inputs  []string
+	outputs []string
+	dxs     []*Tensor //the gradient each input gets from the node
+	mod     Module
+	layer   *Layer
+	concat  *Concat
+	reverse *ReverseConcat
+}
+
+//CreateGraph creates an empty graph
+func CreateGraph(id int64, b *Builder) *Graph {
+	return &Graph{
+		id:      id,
+		b:       b,
+		tensors: make(map[string]*graphtensor),
+	}
+}
+
+//AddInput adds an input tensor to the graph.  Its tensors are set with SetInput or with SetTensorX and SetTensorDX for the first input.
+//If the dx of an input isn't set it is made by FindOutputDims.
+func (g *Graph) AddInput(name string) error {
+	t, err := g.tensor(name)
+	if err != nil {
+		return fmt.Errorf("(g *Graph) AddInput: %v", err)
+	}
+	if t.input || t.producer != nil {
+		return fmt.Errorf("(g *Graph) AddInput: tensor %s is already made by the graph", name)
+	}
+	t.input = true
+	g.inputs = append(g.inputs, name)
+	g.found = false
+	return nil
+}
+
+//AddOutput marks a tensor as an output of the graph.  The gradient of an output is set outside of the graph, usually by a classifier.
+func (g *Graph) AddOutput(name string) error {
+	t, err := g.tensor(name)
+	if err != nil {
+		return fmt.Errorf("(g *Graph) AddOutput: %v", err)
+	}
+	if t.output {
+		return fmt.Errorf("(g *Graph) AddOutput: tensor %s is already an output", name)
+	}
+	t.output = true
+	g.outputs = append(g.outputs, name)
+	g.found = false
+	return nil
+}
+
+//AddModule adds a node that runs mod with input and makes output
+func (g *Graph) AddModule(name string, mod Module, input, output string) error {
+	if mod == nil {
+		return errors.New("(g *Graph) AddModule: mod is nil")
+	}
+	return g.addnode(&graphnode{name: name, inputs: []string{input}, outputs: []string{output}, mod: mod})
+}
+
+//AddLayer adds a node that runs l with input and makes output
+func (g *Graph) AddLayer(name string, l *Layer, input, output string) error {
+	if l == nil {
+		return errors.New("(g *Graph) AddLayer: l is nil")
+	}
+	return g.addnode(&graphnode{name: name, inputs: []string{input}, outputs: []string{output}, layer: l})
+}
+
+//AddConcat adds a node that concats inputs along the channel dim to make output
+func (g *Graph) AddConcat(name string, c *Concat, inputs []string, output string) error {
+	if c == nil {
+		return errors.New("(g *Graph) AddConcat: c is nil")
+	}
+	return g.addnode(&graphnode{name: name, inputs: inputs, outputs: []string{output}, concat: c})
+}
+
+//AddReverseConcat adds a node that splits the channels of input into outputs
+func (g *Graph) AddReverseConcat(name string, c *ReverseConcat, input string, outputs []string) error {
+	if c == nil {
+		return errors.New("(g *Graph) AddReverseConcat: c is nil")
+	}
+	return g.addnode(&graphnode{name: name, inputs: []string{input}, outputs: outputs, reverse: c})
+}
+
+func (g *Graph) addnode(n *graphnode) error {
+	if n.name == "" {
+		return errors.New("(g *Graph) addnode: node needs a name")
+	}
+	for _, o := range g.nodes {
+		if o.name == n.name {
+			return fmt.Errorf("(g *Graph) addnode: node %s already added", n.name)
+		}
+	}
+	if len(n.inputs) == 0 || len(n.outputs) == 0 {
+		return fmt.Errorf("(g *Graph) addnode: node %s needs an input and an output", n.name)
+	}
+	for _, name := range n.outputs {
+		t, err := g.tensor(name)
+		if err != nil {
+			return fmt.Errorf("(g *Graph) addnode: node %s: %v", n.name, err)
+		}
+		if t.input || t.producer != nil {
+			return fmt.Errorf("(g *Graph) addnode: node %s: tensor %s is already made by the graph", n.name, name)
+		}
+	}
+	for _, name := range n.inputs {
+		if _, err := g.tensor(name); err != nil {
+			return fmt.Errorf("(g *Graph) addnode: node %s: %v", n.name, err)
+		}
+	}
+	for _, name := range n.outputs {
+		g.tensors[name].producer = n
+	}
+	for i, name := range n.inputs {
+		t := g.tensors[name]
+		t.consumers = append(t.consumers, graphedge{n: n, index: i})
+	}
+	n.dxs = make([]*Tensor, len(n.inputs))
+	g.nodes = append(g.nodes, n)
+	g.found = false
+	return nil
+}
+
+//tensor returns the graph tensor called name and adds it if the graph doesn't have it
+func (g *Graph) tensor(name string) (*graphtensor, error) {
+	if name == "" {
+		return nil, errors.New("tensor needs a name")
+	}
+	t, ok := g.tensors[name]
+	if !ok {
+		t = &graphtensor{name: name}
+		g.tensors[name] = t
+	}
+	return t, nil
+}
+
+//sort checks that the graph is complete and puts the nodes in topological order.
+//Nodes that can run at the same time are kept in the order they were added.
+func (g *Graph) sort() error {
+	if len(g.inputs) == 0 || len(g.outputs) == 0 {
+		return errors.New("graph needs an input and an output")
+	}
+	for _, t := range g.tensors {
+		if !t.input && t.producer == nil {
+			return fmt.Errorf("tensor %s isn't an input and no node makes it", t.name)
+		}
+		if !t.output && len(t.consumers) == 0 {
+			return fmt.Errorf("tensor %s isn't an output and no node uses it", t.name)
+		}
+	}
+	waiting := make(map[*graphnode]int, len(g.nodes))
+	for _, n := range g.nodes {
+		for _, name := range n.inputs {
+			if g.tensors[name].producer != nil {
+				waiting[n]++
+			}
+		}
+	}
+	g.order = make([]*graphnode, 0, len(g.nodes))
+	done := make(map[*graphnode]bool, len(g.nodes))
+	for len(g.order) < len(g.nodes) {
+		var next *graphnode
+		for _, n := range g.nodes {
+			if !done[n] && waiting[n] == 0 {
+				next = n
+				break
+			}
+		}
+		if next == nil {
+			var cycle []string
+			for _, n := range g.nodes {
+				if !done[n] {
+					cycle = append(cycle, n.name)
+				}
+			}
+			return fmt.Errorf("nodes %v are in or after a cycle", cycle)
+		}
+		done[next] = true
+		g.order = append(g.order, next)
+		for _, name := range next.outputs {
+			for _, c := range g.tensors[name].consumers {
+				waiting[c.n]--
+			}
+		}
+	}
+	return nil
+}
+
+//sharesgradient is true when the one node that uses t can write its gradient straight into t.dx
+func (t *graphtensor) sharesgradient() bool {
+	return len(t.consumers) == 1 && !t.output
+}
+
+//FindOutputDims sorts the nodes and makes the tensors between them.  It returns the dims of the first output.
+//If it is ran again after an input or a node was changed, the tensors that still have the right dims are kept.
+func (g *Graph) FindOutputDims() ([]int32, error) {
+	if g.found {
+		return g.outputdims()
+	}
+	err := g.sort()
+	if err != nil {
+		return nil, fmt.Errorf("(g *Graph) FindOutputDims: %v", err)
+	}
+	for _, name := range g.inputs {
+		t := g.tensors[name]
+		if t.x == nil {
+			return nil, fmt.Errorf("(g *Graph) FindOutputDims: input %s is not set", name)
+		}
+		t.dims = t.x.Dims()
+		if t.dx, err = g.reusetensor(t.dx, t.dims); err != nil {
+			return nil, err
+		}
+	}
+	h := g.b.h.Handler
+	for _, n := range g.order {
+		for i, name := range n.inputs {
+			t := g.tensors[name]
+			if t.sharesgradient() {
+				n.dxs[i] = t.dx
+				continue
+			}
+			if n.dxs[i] == t.dx {
+				n.dxs[i] = nil
+			}
+			if n.dxs[i], err = g.reusetensor(n.dxs[i], t.dims); err != nil {
+				return nil, err
+			}
+		}
+		x := g.tensors[n.inputs[0]]
+		var dims [][]int32
+		switch {
+		case n.mod != nil:
+			n.mod.SetTensorX(x.x)
+			n.mod.SetTensorDX(n.dxs[0])
+			var d []int32
+			d, err = n.mod.FindOutputDims()
+			dims = [][]int32{d}
+		case n.layer != nil:
+			var d []int32
+			d, err = n.layer.GetOutputDims(x.x)
+			dims = [][]int32{d}
+			if err == nil && n.layer.batch != nil {
+				err = n.layer.batch.SetupPreset(h, x.x.Tensor)
+			}
+			if err == nil && n.layer.drop != nil {
+				err = n.layer.drop.BuildFromPreset(h, x.x.Tensor)
+			}
+		case n.concat != nil:
+			xs := make([]*Tensor, len(n.inputs))
+			for i, name := range n.inputs {
+				xs[i] = g.tensors[name].x
+			}
+			var d []int32
+			d, err = n.concat.FindOutputDims(xs)
+			dims = [][]int32{d}
+		case n.reverse != nil:
+			dims, err = n.reverse.FindOutputDims(x.x, int32(len(n.outputs)))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("(g *Graph) FindOutputDims: node %s: %v", n.name, err)
+		}
+		for i, name := range n.outputs {
+			t := g.tensors[name]
+			t.dims = dims[i]
+			if t.output && len(t.consumers) == 0 {
+				continue
+			}
+			if t.x, err = g.reusetensor(t.x, t.dims); err != nil {
+				return nil, err
+			}
+			if t.dx, err = g.reusetensor(t.dx, t.dims); err != nil {
+				return nil, err
+			}
+		}
+	}
+	g.found = true
+	return g.outputdims()
+}
+
+//reusetensor returns t if it already has dims. Otherwise it makes a new tensor with dims.
+//It keeps FindOutputDims from making new tensors for the parts of the graph whose dims didn't change.
+func (g *Graph) reusetensor(t *Tensor, dims []int32) (*Tensor, error) {
+	if t != nil && int32sequal(t.Dims(), dims) {
+		return t, nil
+	}
+	return g.b.CreateTensor(dims)
+}
+
+func (g *Graph) outputdims() ([]int32, error) {
+	dims := g.tensors[g.outputs[0]].dims
+	return copyint32s(dims), nil
+}
+
+//InitHiddenLayers makes the output tensors that haven't been set, connects the nodes to their tensors and inits the hidden layers of the nodes.
+//The weights of convolution layers are randomized and the layers that have weights get adam trainers.
+func (g *Graph) InitHiddenLayers(rate, decay1, decay2 float32) (err error) {
+	if !g.found {
+		if _, err = g.FindOutputDims(); err != nil {
+			return err
+		}
+	}
+	for _, name := range g.outputs {
+		t := g.tensors[name]
+		if t.x == nil {
+			if t.x, err = g.b.CreateTensor(t.dims); err != nil {
+				return err
+			}
+		}
+		if t.dx == nil {
+			if t.dx, err = g.b.CreateTensor(t.dims); err != nil {
+				return err
+			}
+		}
+	}
+	h := g.b.h.Handler
+	for _, n := range g.order {
+		x := g.tensors[n.inputs[0]]
+		y := g.tensors[n.outputs[0]]
+		switch {
+		case n.mod != nil:
+			n.mod.SetTensorX(x.x)
+			n.mod.SetTensorDX(n.dxs[0])
+			n.mod.SetTensorY(y.x)
+			n.mod.SetTensorDY(y.dx)
+			err = n.mod.InitHiddenLayers(rate, decay1, decay2)
+		case n.layer != nil:
+			n.layer.SetIOs(x.x, n.dxs[0], y.x, y.dx)
+			err = g.initlayer(n.layer, rate, decay1, decay2)
+		case n.concat != nil:
+			xs := make([]*Tensor, len(n.inputs))
+			for i, name := range n.inputs {
+				xs[i] = g.tensors[name].x
+			}
+			n.concat.SetInputSrcs(xs)
+			n.concat.SetInputDeltaSrcs(n.dxs)
+			n.concat.SetDest(y.x)
+			n.concat.SetDeltaDest(y.dx)
+		case n.reverse != nil:
+			ys := make([]*Tensor, len(n.outputs))
+			dys := make([]*Tensor, len(n.outputs))
+			for i, name := range n.outputs {
+				ys[i], dys[i] = g.tensors[name].x, g.tensors[name].dx
+			}
+			n.reverse.SetInputSource(x.x)
+			n.reverse.SetInputDeltaSource(n.dxs[0])
+			n.reverse.SetOutputDests(ys)
+			n.reverse.SetOutputDeltaDests(dys)
+		}
+		if err != nil {
+			return fmt.Errorf("(g *Graph) InitHiddenLayers: node %s: %v", n.name, err)
+		}
+	}
+	return h.Sync()
+}
+
+func (g *Graph) initlayer(l *Layer, rate, decay1, decay2 float32) (err error) {
+	h := g.b.h.Handler
+	switch {
+	case l.cnn != nil:
+		err = l.cnn.MakeRandom(h, l.x.Dims())
+	case l.cnntranspose != nil:
+		err = l.cnntranspose.MakeRandom(h, l.x.Dims())
+	}
+	if err != nil {
+		return err
+	}
+	needed := l.trainersneeded()
+	if needed == 0 {
+		return nil
+	}
+	batch := l.x.Dims()[0]
+	trainers := make([]trainer.Trainer, needed)
+	for i := range trainers {
+		adam, err := trainer.SetupAdam(g.b.h.XHandle(), decay1, decay2, batch)
+		if err != nil {
+			return err
+		}
+		adam.SetRates(rate, 0)
+		trainers[i] = adam
+	}
+	return l.LoadTrainer(h, int(batch), trainers...)
+}
+
+//InitWorkspace inits the workspaces of the modules and the convolution layers
+func (g *Graph) InitWorkspace() (err error) {
+	for _, n := range g.order {
+		switch {
+		case n.mod != nil:
+			err = n.mod.InitWorkspace()
+		case n.layer != nil:
+			err = n.layer.initconvworkspace()
+		}
+		if err != nil {
+			return fmt.Errorf("(g *Graph) InitWorkspace: node %s: %v", n.name, err)
+		}
+	}
+	return nil
+}
+
+//Forward runs the nodes in topological order
+func (g *Graph) Forward() error {
+	return g.forward(false)
+}
+
+//Inference runs the nodes in topological order without training.  Batch norm layers use their running mean and variance.
+func (g *Graph) Inference() error {
+	return g.forward(true)
+}
+
+func (g *Graph) forward(inference bool) (err error) {
+	h := g.b.h.Handler
+	for _, n := range g.order {
+		switch {
+		case inference && n.mod != nil:
+			err = n.mod.Inference()
+		case n.mod != nil:
+			err = n.mod.Forward()
+		case inference && n.layer != nil && n.layer.batch != nil:
+			err = n.layer.batch.ForwardInference(h, n.layer.x.Tensor, n.layer.y.Tensor)
+		case n.layer != nil:
+			err = n.layer.forwardprop()
+		case n.concat != nil:
+			err = n.concat.Forward()
+		case n.reverse != nil:
+			err = n.reverse.Forward()
+		}
+		if err != nil {
+			return fmt.Errorf("(g *Graph) Forward: node %s: %v", n.name, err)
+		}
+	}
+	return nil
+}
+
+//Backward runs the nodes in reverse topological order.
+//Before a node is ran, the gradients of its outputs that feed more than one node are summed.
+func (g *Graph) Backward() (err error) {
+	for i := len(g.order) - 1; i >= 0; i-- {
+		n := g.order[i]
+		for _, name := range n.outputs {
+			if err = g.sumgradients(g.tensors[name]); err != nil {
+				return fmt.Errorf("(g *Graph) Backward: tensor %s: %v", name, err)
+			}
+		}
+		switch {
+		case n.mod != nil:
+			err = n.mod.Backward()
+		case n.layer != nil:
+			err = n.layer.backpropfilterdata()
+		case n.concat != nil:
+			err = n.concat.Backward()
+		case n.reverse != nil:
+			err = n.reverse.Backward()
+		}
+		if err != nil {
+			return fmt.Errorf("(g *Graph) Backward: node %s: %v", n.name, err)
+		}
+	}
+	for _, name := range g.inputs {
+		if err = g.sumgradients(g.tensors[name]); err != nil {
+			return fmt.Errorf("(g *Graph) Backward: tensor %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+//sumgradients sums the gradients the consumers of t wrote into t.dx.
+//The gradient of an output is added to since it was set outside of the graph.
+func (g *Graph) sumgradients(t *graphtensor) (err error) {
+	if t.sharesgradient() || len(t.consumers) == 0 {
+		return nil
+	}
+	h := g.b.h.Handler
+	beta := 0.0
+	if t.output {
+		beta = 1
+	}
+	for _, c := range t.consumers {
+		if err = t.dx.AddTo(h, c.n.dxs[c.index].Volume, 1, beta); err != nil {
+			return err
+		}
+		beta = 1
+	}
+	return nil
+}
+
+//Update updates the weights of the modules and layers
+func (g *Graph) Update(counter int) (err error) {
+	for _, n := range g.order {
+		switch {
+		case n.mod != nil:
+			err = n.mod.Update(counter)
+		case n.layer != nil:
+			err = n.layer.updateWeights(counter)
+		}
+		if err != nil {
+			return fmt.Errorf("(g *Graph) Update: node %s: %v", n.name, err)
+		}
+	}
+	return nil
+}
+
+//Summary returns the Summary of the nodes in the order they are ran
+func (g *Graph) Summary() *Summary {
+	s := &Summary{ID: g.id}
+	nodes := g.order
+	if len(nodes) != len(g.nodes) {
+		nodes = g.nodes
+	}
+	for _, n := range nodes {
+		var l LayerSummary
+		switch {
+		case n.mod != nil:
+			s.merge(ModuleSummary(n.mod))
+			continue
+		case n.layer != nil:
+			l = n.layer.summary()
+		case n.concat != nil:
+			l = n.concat.summary()
+		case n.reverse != nil:
+			l = n.reverse.summary()
+		}
+		l.Module = g.id
+		s.add(l)
+	}
+	return s
+}
+
+//SetInput sets the tensors of the input called name.  dx can be nil.
+func (g *Graph) SetInput(name string, x, dx *Tensor) error {
+	t, ok := g.tensors[name]
+	if !ok || !t.input {
+		return fmt.Errorf("(g *Graph) SetInput: %s isn't an input", name)
+	}
+	t.x, t.dx = x, dx
+	g.found = false
+	return nil
+}
+
+//Input returns the tensors of the input called name
+func (g *Graph) Input(name string) (x, dx *Tensor) {
+	t, ok := g.tensors[name]
+	if !ok || !t.input {
+		return nil, nil
+	}
+	return t.x, t.dx
+}
+
+//SetOutput sets the tensors of the output called name.  It needs to be called before InitHiddenLayers.
+func (g *Graph) SetOutput(name string, y, dy *Tensor) error {
+	t, ok := g.tensors[name]
+	if !ok || !t.output {
+		return fmt.Errorf("(g *Graph) SetOutput: %s isn't an output", name)
+	}
+	t.x, t.dx = y, dy
+	return nil
+}
+
+//Output returns the tensors of the output called name
+func (g *Graph) Output(name string) (y, dy *Tensor) {
+	t, ok := g.tensors[name]
+	if !ok || !t.output {
+		return nil, nil
+	}
+	return t.x, t.dx
+}
+
+//ID satisfies the Module interface
+func (g *Graph) ID() int64 { return g.id }
+
+//GetTensorX returns the x tensor of the first input
+func (g *Graph) GetTensorX() (x *Tensor) {
+	if len(g.inputs) == 0 {
+		return nil
+	}
+	x, _ = g.Input(g.inputs[0])
+	return x
+}
+
+//GetTensorDX returns the dx tensor of the first input
+func (g *Graph) GetTensorDX() (dx *Tensor) {
+	if len(g.inputs) == 0 {
+		return nil
+	}
+	_, dx = g.Input(g.inputs[0])
+	return dx
+}
+
+//GetTensorY returns the y tensor of the first output
+func (g *Graph) GetTensorY() (y *Tensor) {
+	if len(g.outputs) == 0 {
+		return nil
+	}
+	y, _ = g.Output(g.outputs[0])
+	return y
+}
+
+//GetTensorDY returns the dy tensor of the first output
+func (g *Graph) GetTensorDY() (dy *Tensor) {
+	if len(g.outputs) == 0 {
+		return nil
+	}
+	_, dy = g.Output(g.outputs[0])
+	return dy
+}
+
+//SetTensorX sets the x tensor of the first input
+func (g *Graph) SetTensorX(x *Tensor) {
+	if len(g.inputs) > 0 {
+		g.tensors[g.inputs[0]].x = x
+		g.found = false
+	}
+}
+
+//SetTensorDX sets the dx tensor of the first input
+func (g *Graph) SetTensorDX(dx *Tensor) {
+	if len(g.inputs) > 0 {
+		g.tensors[g.inputs[0]].dx = dx
+		g.found = false
+	}
+}
+
+//SetTensorY sets the y tensor of the first output
+func (g *Graph) SetTensorY(y *Tensor) {
+	if len(g.outputs) > 0 {
+		g.tensors[g.outputs[0]].x = y
+	}
+}
+
+//SetTensorDY sets the dy tensor of the first output
+func (g *Graph) SetTensorDY(dy *Tensor) {
+	if len(g.outputs) > 0 {
+		g.tensors[g.outputs[0]].dx = dy
+	}
+}
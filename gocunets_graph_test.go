@@ -0,0 +1,219 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestGraphSort(t *testing.T) {
+	g := CreateGraph(0, nil)
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	check(g.AddInput("x"))
+	check(g.AddOutput("y"))
+	check(g.AddLayer("c", new(Layer), "b", "y"))
+	check(g.AddLayer("a", new(Layer), "x", "a"))
+	check(g.AddLayer("b", new(Layer), "a", "b"))
+	check(g.sort())
+	var order []string
+	for _, n := range g.order {
+		order = append(order, n.name)
+	}
+	if strings.Join(order, " ") != "a b c" {
+		t.Errorf("order is %v", order)
+	}
+	if err := g.AddLayer("d", new(Layer), "x", "a"); err == nil {
+		t.Error("a tensor should only be made by one node")
+	}
+
+	g = CreateGraph(0, nil)
+	check(g.AddInput("x"))
+	check(g.AddOutput("y"))
+	check(g.AddConcat("a", new(Concat), []string{"x", "b"}, "a"))
+	check(g.AddReverseConcat("b", new(ReverseConcat), "a", []string{"b", "y"}))
+	err := g.sort()
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("cycle should not sort, got %v", err)
+	}
+
+	g = CreateGraph(0, nil)
+	check(g.AddInput("x"))
+	check(g.AddOutput("y"))
+	check(g.AddConcat("a", new(Concat), []string{"x", "z"}, "y"))
+	err = g.sort()
+	if err == nil || !strings.Contains(err.Error(), "tensor z") {
+		t.Errorf("missing tensor should not sort, got %v", err)
+	}
+}
+
+func TestGraphFanOut(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+	bldr := CreateBuilder(handle)
+
+	//x is split into a and b and then concated with itself, so it gets the gradient of both halves of y
+	g := CreateGraph(0, bldr)
+	split, err := CreateReverseConcat(handle)
+	check(err)
+	join, err := CreateConcat(handle)
+	check(err)
+	check(g.AddInput("x"))
+	check(g.AddOutput("y"))
+	check(g.AddConcat("join", join, []string{"a", "b", "x"}, "y"))
+	check(g.AddReverseConcat("split", split, "x", []string{"a", "b"}))
+
+	x, err := bldr.CreateTensor([]int32{1, 2, 3, 3})
+	check(err)
+	g.SetTensorX(x)
+	dims, err := g.FindOutputDims()
+	check(err)
+	if !int32sequal(dims, []int32{1, 4, 3, 3}) {
+		t.Fatalf("output dims are %v", dims)
+	}
+	a, inputdx := g.tensors["a"], g.GetTensorDX()
+	ax, adx := a.x, a.dx
+	g.SetTensorX(x)
+	_, err = g.FindOutputDims()
+	check(err)
+	if a.x != ax || a.dx != adx || g.GetTensorDX() != inputdx {
+		t.Error("tensors whose dims didn't change should be kept")
+	}
+	check(g.InitHiddenLayers(.001, 0, 0))
+	check(g.InitWorkspace())
+
+	input := make([]float32, x.Vol())
+	for i := range input {
+		input[i] = rand.Float32()
+	}
+	check(x.LoadValuesFromSLice(handle.Handler, input, int32(len(input))))
+	check(g.Forward())
+	check(handle.Sync())
+	output := make([]float32, g.GetTensorY().Vol())
+	check(g.GetTensorY().FillSlice(handle.Handler, output))
+	for i := range output {
+		if output[i] != input[i%len(input)] {
+			t.Fatalf("output at %d is %v, expected %v", i, output[i], input[i%len(input)])
+		}
+	}
+
+	gradient := make([]float32, len(output))
+	for i := range gradient {
+		gradient[i] = rand.Float32()
+	}
+	check(g.GetTensorDY().LoadValuesFromSLice(handle.Handler, gradient, int32(len(gradient))))
+	check(g.Backward())
+	check(handle.Sync())
+	dx := make([]float32, len(input))
+	check(g.GetTensorDX().FillSlice(handle.Handler, dx))
+	for i := range dx {
+		expected := gradient[i] + gradient[i+len(input)]
+		if math.Abs(float64(dx[i]-expected)) > 1e-5 {
+			t.Fatalf("dx at %d is %v, expected %v", i, dx[i], expected)
+		}
+	}
+	if len(g.Summary().Layers) != 2 {
+		t.Error("summary should have a row for each node")
+	}
+}
+
+func TestGraphOutputConsumer(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+	bldr := CreateBuilder(handle)
+
+	//x is split into a and b and then concated back into y.  a is an output that join also uses.
+	g := CreateGraph(0, bldr)
+	split, err := CreateReverseConcat(handle)
+	check(err)
+	join, err := CreateConcat(handle)
+	check(err)
+	check(g.AddInput("x"))
+	check(g.AddOutput("y"))
+	check(g.AddOutput("a"))
+	check(g.AddReverseConcat("split", split, "x", []string{"a", "b"}))
+	check(g.AddConcat("join", join, []string{"a", "b"}, "y"))
+
+	x, err := bldr.CreateTensor([]int32{1, 2, 3, 3})
+	check(err)
+	g.SetTensorX(x)
+	dims, err := g.FindOutputDims()
+	check(err)
+	if !int32sequal(dims, []int32{1, 2, 3, 3}) {
+		t.Fatalf("output dims are %v", dims)
+	}
+	if ay, ady := g.Output("a"); ay == nil || ady == nil {
+		t.Fatal("an output that another node uses should be made by FindOutputDims")
+	}
+	ay, err := bldr.CreateTensor([]int32{1, 1, 3, 3})
+	check(err)
+	ady, err := bldr.CreateTensor([]int32{1, 1, 3, 3})
+	check(err)
+	check(g.SetOutput("a", ay, ady))
+	check(g.InitHiddenLayers(.001, 0, 0))
+	check(g.InitWorkspace())
+
+	input := make([]float32, x.Vol())
+	for i := range input {
+		input[i] = rand.Float32()
+	}
+	check(x.LoadValuesFromSLice(handle.Handler, input, int32(len(input))))
+	check(g.Forward())
+	check(handle.Sync())
+	output := make([]float32, g.GetTensorY().Vol())
+	check(g.GetTensorY().FillSlice(handle.Handler, output))
+	a := make([]float32, ay.Vol())
+	check(ay.FillSlice(handle.Handler, a))
+	for i := range output {
+		if output[i] != input[i] {
+			t.Fatalf("output at %d is %v, expected %v", i, output[i], input[i])
+		}
+	}
+	for i := range a {
+		if a[i] != input[i] {
+			t.Fatalf("a at %d is %v, expected %v", i, a[i], input[i])
+		}
+	}
+
+	//the gradient of a is set outside of the graph and the gradient join gives a is added to it
+	gradient := make([]float32, len(output))
+	for i := range gradient {
+		gradient[i] = rand.Float32()
+	}
+	agradient := make([]float32, len(a))
+	for i := range agradient {
+		agradient[i] = rand.Float32()
+	}
+	check(g.GetTensorDY().LoadValuesFromSLice(handle.Handler, gradient, int32(len(gradient))))
+	check(ady.LoadValuesFromSLice(handle.Handler, agradient, int32(len(agradient))))
+	check(g.Backward())
+	check(handle.Sync())
+	dx := make([]float32, len(input))
+	check(g.GetTensorDX().FillSlice(handle.Handler, dx))
+	for i := range dx {
+		expected := gradient[i]
+		if i < len(agradient) {
+			expected += agradient[i]
+		}
+		if math.Abs(float64(dx[i]-expected)) > 1e-5 {
+			t.Fatalf("dx at %d is %v, expected %v", i, dx[i], expected)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+import "github.com/dereklstinson/gocunets/cpu"
+
+//Tensor holds the host tensor of a tensor made by a cpu Builder.
+//A build with the cuda tag adds the gpu tensor to it.
+type Tensor struct {
+	host *cpu.Tensor
+}
+
+//Dims returns the dims of the tensor
+func (t *Tensor) Dims() []int32 {
+	return t.host.Dims()
+}
@@ -0,0 +1,250 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+	"github.com/dereklstinson/gocunets/loss"
+	"github.com/dereklstinson/gocunets/onnx"
+	gocudnn "github.com/dereklstinson/gocudnn"
+)
+
+//ExportONNX writes the network to w as an ONNX model. See ONNXModel.
+func (m *SimpleModuleNetwork) ExportONNX(w io.Writer) error {
+	model, err := m.ONNXModel()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(model.Marshal())
+	return err
+}
+
+//ONNXModel builds an ONNX model of the network with the weights copied from the device as initializers.
+//
+//The network needs to be in the NCHW format, its convolutions need to be in the CrossCorrelation mode and FindOutputDims needs to have been ran.
+//A SoftMax classifier is exported as a Softmax on the channel axis. An MSE classifier adds nothing.
+func (m *SimpleModuleNetwork) ONNXModel() (*onnx.Model, error) {
+	if err := m.b.gpuonly("(m *SimpleModuleNetwork) ONNXModel"); err != nil {
+		return nil, err
+	}
+	var frmt TensorFormat
+	if m.b.Frmt != frmt.NCHW() {
+		return nil, errors.New("(m *SimpleModuleNetwork) ONNXModel: only NCHW networks can be exported")
+	}
+	if len(m.Modules) == 0 || m.Output == nil {
+		return nil, errors.New("(m *SimpleModuleNetwork) ONNXModel: Modules and Output need to be set")
+	}
+	x, y := m.GetTensorX(), m.GetTensorY()
+	if x == nil || y == nil {
+		return nil, errors.New("(m *SimpleModuleNetwork) ONNXModel: FindOutputDims needs to be ran")
+	}
+	dtype, err := onnxdatatype(x.DataType())
+	if err != nil {
+		return nil, err
+	}
+	h := m.b.h.Handler
+	b := onnx.CreateGraphBuilder(fmt.Sprintf("SimpleModuleNetwork_%d", m.Id))
+	name := b.AddInput("x", dtype, x.Dims())
+	for i, mod := range m.Modules {
+		name, err = onnxmodule(b, h, mod, name)
+		if err != nil {
+			return nil, fmt.Errorf("(m *SimpleModuleNetwork) ONNXModel: module %d: %v", i, err)
+		}
+	}
+	name, err = onnxmodule(b, h, m.Output, name)
+	if err != nil {
+		return nil, fmt.Errorf("(m *SimpleModuleNetwork) ONNXModel: Output: %v", err)
+	}
+	if m.Classifier != nil {
+		switch m.Classifier.l.(type) {
+		case *loss.SoftMax:
+			name = b.Softmax(name, 1)
+		case *loss.MSE2:
+		default:
+			return nil, fmt.Errorf("(m *SimpleModuleNetwork) ONNXModel: unsupported classifier %T", m.Classifier.l)
+		}
+	}
+	b.AddOutput(name, dtype, y.Dims())
+	return b.Model("gocunets", ""), nil
+}
+
+//onnxmodule adds the nodes of mod to b with x being the name of its input.  It returns the name of the output.
+func onnxmodule(b *onnx.GraphBuilder, h *cudnn.Handler, mod Module, x string) (y string, err error) {
+	switch mod := mod.(type) {
+	case *VanillaModule:
+		y, err = mod.conv.onnxnode(b, h, x)
+		if err != nil {
+			return "", err
+		}
+		return mod.act.onnxnode(b, h, y)
+	case *OutputModule:
+		return mod.op.onnxnode(b, h, x)
+	case *module:
+		ys := make([]string, len(mod.layers))
+		for i, l := range mod.layers {
+			ys[i], err = l.onnxnode(b, h, x)
+			if err != nil {
+				return "", err
+			}
+		}
+		return mod.activ.onnxnode(b, h, b.Concat(ys, 1))
+	}
+	return "", fmt.Errorf("module %d (%T) can't be exported", mod.ID(), mod)
+}
+
+//onnxcrosscorrelation returns an error if mode isn't CrossCorrelation.  ONNX Conv and ConvTranspose are cross correlations,
+//so the weights of a layer in the Convolution mode would be flipped by anything that runs the model.
+func onnxcrosscorrelation(mode gocudnn.ConvolutionMode, err error) error {
+	if err != nil {
+		return err
+	}
+	if (ConvolutionMode{mode}) != bprflags.Cmode.CrossCorrelation() {
+		return errors.New("only CrossCorrelation convolutions can be exported")
+	}
+	return nil
+}
+
+//onnxnode adds the node for the layer to b with x being the name of its input. It returns the name of the output.
+func (l *Layer) onnxnode(b *onnx.GraphBuilder, h *cudnn.Handler, x string) (string, error) {
+	switch {
+	case l.cnn != nil:
+		err := onnxcrosscorrelation(l.cnn.ConvolutionMode())
+		if err != nil {
+			return "", err
+		}
+		w, err := onnxtensor(h, l.cnn.Weights())
+		if err != nil {
+			return "", err
+		}
+		bias, err := onnxtensor(h, l.cnn.Bias())
+		if err != nil {
+			return "", err
+		}
+		pad, stride, dilation := l.cnn.Properties()
+		return b.Conv(x, w, bias, pad, stride, dilation), nil
+	case l.cnntranspose != nil:
+		err := onnxcrosscorrelation(l.cnntranspose.ConvolutionMode())
+		if err != nil {
+			return "", err
+		}
+		w, err := onnxtensor(h, l.cnntranspose.Weights())
+		if err != nil {
+			return "", err
+		}
+		bias, err := onnxtensor(h, l.cnntranspose.Bias())
+		if err != nil {
+			return "", err
+		}
+		pad, stride, dilation := l.cnntranspose.Properties()
+		return b.ConvTranspose(x, w, bias, pad, stride, dilation), nil
+	case l.activation != nil:
+		mode, err := activationmodetostring(ActivationMode{l.activation.Mode()})
+		if err != nil {
+			return "", err
+		}
+		var slope *onnx.Tensor
+		if l.activation.NegCoefs() != nil {
+			slope, err = onnxtensor(h, l.activation.NegCoefs())
+			if err != nil {
+				return "", err
+			}
+		}
+		return b.Activation(x, mode, l.activation.Coef(), slope)
+	case l.batch != nil:
+		return l.onnxbatchnorm(b, h, x)
+	case l.pool != nil:
+		pmode, window, pad, stride, reverse, err := l.pool.Properties()
+		if err != nil {
+			return "", err
+		}
+		if reverse {
+			return "", errors.New("reverse pooling can't be exported")
+		}
+		mode, err := poolingmodetostring(PoolingMode{pmode})
+		if err != nil {
+			return "", err
+		}
+		return b.Pool(x, mode, window, pad, stride)
+	case l.drop != nil:
+		return b.Dropout(x), nil
+	}
+	return "", fmt.Errorf("%s layer can't be exported", l.layername())
+}
+
+//onnxbatchnorm adds a BatchNormalization. Only the spatial mode can be exported since ONNX normalizes per channel.
+func (l *Layer) onnxbatchnorm(b *onnx.GraphBuilder, h *cudnn.Handler, x string) (string, error) {
+	scale := l.batch.Scale()
+	if scale == nil {
+		return "", errors.New("batch norm hasn't been setup")
+	}
+	dims := scale.Dims()
+	for i := range dims {
+		if i != 1 && dims[i] != 1 {
+			return "", errors.New("only spatial batch norm can be exported")
+		}
+	}
+	channels := []int64{int64(dims[1])}
+	mems := []*nvidia.Malloced{scale.Malloced, l.batch.Bias().Malloced, l.batch.RunningMean(), l.batch.RunningVariance()}
+	ts := make([]*onnx.Tensor, len(mems))
+	for i := range mems {
+		if mems[i] == nil {
+			return "", errors.New("batch norm hasn't been setup")
+		}
+		var err error
+		ts[i], err = onnxmalloced(h, mems[i], scale.DataType(), channels)
+		if err != nil {
+			return "", err
+		}
+	}
+	return b.BatchNormalization(x, ts[0], ts[1], ts[2], ts[3], l.batch.Eps()), nil
+}
+
+//onnxtensor copies t from the device into an onnx.Tensor
+func onnxtensor(h *cudnn.Handler, t *layers.Tensor) (*onnx.Tensor, error) {
+	dims := t.Dims()
+	odims := make([]int64, len(dims))
+	for i := range dims {
+		odims[i] = int64(dims[i])
+	}
+	return onnxmalloced(h, t.Malloced, t.DataType(), odims)
+}
+
+func onnxmalloced(h *cudnn.Handler, mem *nvidia.Malloced, dtype gocudnn.DataType, dims []int64) (*onnx.Tensor, error) {
+	odtype, err := onnxdatatype(dtype)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, mem.SIB())
+	err = mem.FillSlice(h, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &onnx.Tensor{Dims: dims, DataType: odtype, RawData: raw}, nil
+}
+
+func onnxdatatype(dtype gocudnn.DataType) (onnx.DataType, error) {
+	var flg gocudnn.DataType
+	var oflg onnx.DataType
+	switch dtype {
+	case flg.Float():
+		return oflg.Float(), nil
+	case flg.Half():
+		return oflg.Half(), nil
+	case flg.Double():
+		return oflg.Double(), nil
+	case flg.Int8():
+		return oflg.Int8(), nil
+	case flg.UInt8():
+		return oflg.UInt8(), nil
+	case flg.Int32():
+		return oflg.Int32(), nil
+	}
+	return oflg, errors.New("onnxdatatype: unsupported datatype")
+}
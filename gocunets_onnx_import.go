@@ -0,0 +1,1015 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+	"github.com/dereklstinson/gocunets/layers/softmax"
+	"github.com/dereklstinson/gocunets/onnx"
+	"github.com/dereklstinson/gocunets/trainer"
+	gocudnn "github.com/dereklstinson/gocudnn"
+)
+
+//ONNXModule is a network imported from an ONNX model. It satisfies the Module interface.
+//
+//The nodes are ran in the order they are in the model, which ONNX requires to be topologically sorted.
+//A tensor that feeds more than one node gets the sum of their gradients on the backward pass.
+type ONNXModule struct {
+	id           int64
+	b            *Builder
+	batchsize    int
+	nodes        []*onnxnode
+	input        string
+	output       string
+	ys, dys      map[string]*Tensor
+	x, dx, y, dy *Tensor
+}
+
+//onnxnode is a node of an imported graph.  Only one of layer, concat, softmax or view is used.
+type onnxnode struct {
+	name    string
+	optype  string
+	inputs  []string
+	output  string
+	layer   *Layer
+	concat  *Concat
+	softmax *softmax.Layer
+	view    func(dims []int32) ([]int32, error) //Reshape, Flatten and Identity share memory with their input
+	bn      []*onnx.Tensor                      //scale, bias, mean and variance loaded once the batch norm is setup
+}
+
+//ImportONNX reads an ONNX model from r and builds its layers with b.
+//
+//Supported ops are Conv, ConvTranspose, BatchNormalization, Relu, LeakyRelu, Elu, Sigmoid, Tanh, Clip (min of 0),
+//MaxPool, AveragePool, Concat, Softmax, Dropout, Reshape, Flatten and Identity.  The graph needs one input and one output.
+//Weights are loaded from the initializers. batch is the batch size given to the trainers.
+//Unsupported ops return an error that names the node.
+//
+//b needs to be set to NCHW and the datatype of the initializers.  Its AMode and Pmode flags are left as they were.
+//
+//After the input tensor is set with SetTensorX, FindOutputDims, InitHiddenLayers and InitWorkspace need to be ran like any other module.
+//InitHiddenLayers loads trainers but doesn't randomize the weights.
+func ImportONNX(r io.Reader, b *Builder, id int64, batch int32) (m *ONNXModule, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	model, err := onnx.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("ImportONNX: %v", err)
+	}
+	return CreateONNXModule(model, b, id, batch)
+}
+
+//CreateONNXModule builds the layers of model with b. See ImportONNX.
+func CreateONNXModule(model *onnx.Model, b *Builder, id int64, batch int32) (m *ONNXModule, err error) {
+	if err = b.gpuonly("CreateONNXModule"); err != nil {
+		return nil, err
+	}
+	if b.Frmt != bprflags.Frmt.NCHW() {
+		return nil, errors.New("CreateONNXModule: builder needs to be set to NCHW")
+	}
+	if b.Cmode != bprflags.Cmode.CrossCorrelation() {
+		return nil, errors.New("CreateONNXModule: ONNX convolutions are cross correlations so Cmode needs to be CrossCorrelation")
+	}
+	if b.BNMode == bprflags.BNMode.PerActivation() {
+		return nil, errors.New("CreateONNXModule: ONNX batch norm is per channel so BNMode can't be PerActivation")
+	}
+	dtype, err := onnxdatatype(b.Dtype.DataType)
+	if err != nil {
+		return nil, fmt.Errorf("CreateONNXModule: %v", err)
+	}
+	g := model.Graph
+	var inputs []*onnx.ValueInfo
+	for _, in := range g.Inputs {
+		if g.Initializer(in.Name) == nil {
+			inputs = append(inputs, in)
+		}
+	}
+	if len(inputs) != 1 || len(g.Outputs) != 1 {
+		return nil, fmt.Errorf("CreateONNXModule: graph needs 1 input and 1 output. It has %d and %d", len(inputs), len(g.Outputs))
+	}
+	var opset int64
+	for _, o := range model.Opsets {
+		if o.Domain == "" || o.Domain == "ai.onnx" {
+			opset = o.Version
+		}
+	}
+	amode, pmode := b.AMode, b.Pmode
+	defer func() { b.AMode, b.Pmode = amode, pmode }()
+
+	imp := &onnximporter{
+		g:      g,
+		b:      b,
+		dtype:  dtype,
+		opset:  opset,
+		ranks:  map[string]int{inputs[0].Name: len(inputs[0].Dims)},
+		bases:  map[string]string{inputs[0].Name: inputs[0].Name},
+		counts: make(map[string]int),
+	}
+	m = &ONNXModule{
+		id:        id,
+		b:         b,
+		batchsize: int(batch),
+		input:     inputs[0].Name,
+		output:    g.Outputs[0].Name,
+	}
+	for i, n := range g.Nodes {
+		node, err := imp.node(int64(i), n)
+		if err != nil {
+			return nil, fmt.Errorf("CreateONNXModule: node %s (%s): %v", nodename(n), n.OpType, err)
+		}
+		m.nodes = append(m.nodes, node)
+	}
+	for _, n := range m.nodes {
+		if n.layer != nil && n.layer.drop != nil && imp.counts[imp.bases[n.inputs[0]]] > 1 {
+			return nil, fmt.Errorf("CreateONNXModule: node %s (%s): input %s feeds other nodes and dropout can't add to their gradients", n.name, n.optype, n.inputs[0])
+		}
+	}
+	if len(m.nodes) == 0 {
+		return nil, errors.New("CreateONNXModule: graph doesn't have any nodes")
+	}
+	if last := m.nodes[len(m.nodes)-1]; last.output != m.output || last.view != nil {
+		return nil, fmt.Errorf("CreateONNXModule: graph output %s needs to be made by the last node and can't be a Reshape, Flatten or Identity", m.output)
+	}
+	return m, nil
+}
+
+func nodename(n *onnx.Node) string {
+	if n.Name != "" {
+		return n.Name
+	}
+	if len(n.Outputs) > 0 {
+		return n.Outputs[0]
+	}
+	return "unnamed"
+}
+
+//onnximporter holds what is known about the graph while its nodes are made
+type onnximporter struct {
+	g      *onnx.Graph
+	b      *Builder
+	dtype  onnx.DataType
+	opset  int64
+	ranks  map[string]int    //onnx rank of each tensor
+	bases  map[string]string //tensor that owns the memory of each tensor
+	counts map[string]int    //number of nodes reading each base tensor
+}
+
+func (imp *onnximporter) node(id int64, n *onnx.Node) (node *onnxnode, err error) {
+	if n.Domain != "" && n.Domain != "ai.onnx" {
+		return nil, fmt.Errorf("domain %s isn't supported", n.Domain)
+	}
+	if len(n.Inputs) == 0 || len(n.Outputs) == 0 {
+		return nil, errors.New("node needs an input and an output")
+	}
+	node = &onnxnode{name: nodename(n), optype: n.OpType, inputs: n.Inputs[:1], output: n.Outputs[0]}
+	x := n.Inputs[0]
+	rank, ok := imp.ranks[x]
+	if !ok {
+		return nil, fmt.Errorf("input %s isn't the graph input or made by an earlier node", x)
+	}
+	b := imp.b
+	switch n.OpType {
+	case "Conv", "ConvTranspose":
+		node.layer, err = imp.conv(id, n)
+	case "Relu":
+		b.AMode.Relu()
+		node.layer, err = b.Activation(id)
+	case "LeakyRelu":
+		b.AMode.Leaky()
+		node.layer, err = b.ActivationWithCoef(id, float64(attrfloat(n, "alpha", .01)))
+	case "Elu":
+		b.AMode.Elu()
+		node.layer, err = b.ActivationWithCoef(id, float64(attrfloat(n, "alpha", 1)))
+	case "Sigmoid":
+		b.AMode.Sigmoid()
+		node.layer, err = b.Activation(id)
+	case "Tanh":
+		b.AMode.Tanh()
+		node.layer, err = b.Activation(id)
+	case "Clip":
+		node.layer, err = imp.clip(id, n)
+	case "BatchNormalization":
+		node.layer, node.bn, err = imp.batchnorm(id, n)
+	case "MaxPool", "AveragePool":
+		node.layer, err = imp.pool(id, n)
+	case "Dropout":
+		node.layer, err = imp.dropout(id, n)
+	case "Concat":
+		node.inputs = n.Inputs
+		node.concat, err = imp.concat(n, rank)
+	case "Softmax":
+		node.softmax, err = imp.softmax(n, rank)
+	case "Reshape", "Flatten", "Identity":
+		node.view, rank, err = imp.view(n, rank)
+	default:
+		return nil, errors.New("op isn't supported")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, in := range node.inputs {
+		if _, ok := imp.ranks[in]; !ok {
+			return nil, fmt.Errorf("input %s isn't the graph input or made by an earlier node", in)
+		}
+		imp.counts[imp.bases[in]]++
+	}
+	if node.layer != nil {
+		accumulatebackward(node.layer)
+	}
+	imp.ranks[node.output] = rank
+	imp.bases[node.output] = node.output
+	if node.view != nil {
+		imp.bases[node.output] = imp.bases[x]
+	}
+	return node, nil
+}
+
+//initializer returns the initializer of input i of n.  It is an error if the input isn't an initializer.
+func (imp *onnximporter) initializer(n *onnx.Node, i int) (*onnx.Tensor, error) {
+	if len(n.Inputs) <= i || n.Inputs[i] == "" {
+		return nil, fmt.Errorf("input %d is missing", i)
+	}
+	t := imp.g.Initializer(n.Inputs[i])
+	if t == nil {
+		return nil, fmt.Errorf("input %s needs to be an initializer", n.Inputs[i])
+	}
+	return t, nil
+}
+
+//weights returns the initializer of input i of n and checks it is the datatype of the builder
+func (imp *onnximporter) weights(n *onnx.Node, i int) (*onnx.Tensor, error) {
+	t, err := imp.initializer(n, i)
+	if err != nil {
+		return nil, err
+	}
+	if t.DataType != imp.dtype {
+		return nil, fmt.Errorf("initializer %s has datatype %d and the builder uses %d", t.Name, t.DataType, imp.dtype)
+	}
+	return t, nil
+}
+
+func (imp *onnximporter) conv(id int64, n *onnx.Node) (l *Layer, err error) {
+	transpose := n.OpType == "ConvTranspose"
+	w, err := imp.weights(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.Dims) < 3 {
+		return nil, fmt.Errorf("weights %s need spacial dims", w.Name)
+	}
+	spacial := len(w.Dims) - 2
+	pad, stride, dilation, err := windowattributes(n, spacial)
+	if err != nil {
+		return nil, err
+	}
+	if transpose {
+		if a := n.Attribute("output_shape"); a != nil {
+			return nil, errors.New("output_shape isn't supported")
+		}
+		for _, p := range attrints(n, "output_padding", 0, spacial) {
+			if p != 0 {
+				return nil, errors.New("output_padding isn't supported")
+			}
+		}
+	}
+	group := int32(attrint(n, "group", 1))
+	dims := int32s(w.Dims)
+	b := imp.b
+	var wt, dw, bias, db *Tensor
+	if transpose {
+		wt, dw, bias, db, err = b.CreateDeconvolutionWeights(dims)
+	} else {
+		wt, dw, bias, db, err = b.CreateConvolutionWeights(dims)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := b.h.Handler
+	if err = wt.LoadSlice(h, w.RawData); err != nil {
+		return nil, fmt.Errorf("weights %s: %v", w.Name, err)
+	}
+	if len(n.Inputs) > 2 && n.Inputs[2] != "" {
+		bt, err := imp.weights(n, 2)
+		if err != nil {
+			return nil, err
+		}
+		if err = bias.LoadSlice(h, bt.RawData); err != nil {
+			return nil, fmt.Errorf("bias %s: %v", bt.Name, err)
+		}
+	} else if err = bias.SetValues(h, 0); err != nil {
+		return nil, err
+	}
+	if transpose {
+		l, err = b.ReverseConvolutionLayer(id, group, wt, dw, bias, db, pad, stride, dilation)
+	} else {
+		l, err = b.ConvolutionLayer(id, group, wt, dw, bias, db, pad, stride, dilation)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.SetForwardScalars(1, 0)
+	l.SetOtherScalars(1, 0)
+	return l, nil
+}
+
+//clip is imported as a clipped relu so min needs to be 0
+func (imp *onnximporter) clip(id int64, n *onnx.Node) (*Layer, error) {
+	min, max := attrfloat(n, "min", 0), float32(0)
+	hasmax := n.Attribute("max") != nil
+	if hasmax {
+		max = attrfloat(n, "max", 0)
+	}
+	if imp.opset >= 11 {
+		for i := 1; i < 3 && i < len(n.Inputs); i++ {
+			if n.Inputs[i] == "" {
+				continue
+			}
+			t, err := imp.initializer(n, i)
+			if err != nil {
+				return nil, err
+			}
+			v := t.Floats()
+			if len(v) != 1 {
+				return nil, fmt.Errorf("%s needs to be a float scalar", t.Name)
+			}
+			if i == 1 {
+				min = v[0]
+			} else {
+				max, hasmax = v[0], true
+			}
+		}
+	}
+	if min != 0 || !hasmax {
+		return nil, errors.New("only a min of 0 with a max is supported")
+	}
+	b := imp.b
+	b.AMode.ClippedRelu()
+	return b.ActivationWithCoef(id, float64(max))
+}
+
+func (imp *onnximporter) batchnorm(id int64, n *onnx.Node) (*Layer, []*onnx.Tensor, error) {
+	if attrint(n, "training_mode", 0) != 0 || len(n.Outputs) > 1 {
+		return nil, nil, errors.New("training mode outputs aren't supported")
+	}
+	ts := make([]*onnx.Tensor, 4)
+	for i := range ts {
+		var err error
+		ts[i], err = imp.weights(n, i+1)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	l, err := imp.b.BatchNorm(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	l.batch.SetEps(float64(attrfloat(n, "epsilon", 1e-5)))
+	return l, ts, nil
+}
+
+func (imp *onnximporter) pool(id int64, n *onnx.Node) (*Layer, error) {
+	a := n.Attribute("kernel_shape")
+	if a == nil {
+		return nil, errors.New("kernel_shape is needed")
+	}
+	window := int32s(a.Ints)
+	pad, stride, dilation, err := windowattributes(n, len(window))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dilation {
+		if d != 1 {
+			return nil, errors.New("dilations aren't supported")
+		}
+	}
+	if attrint(n, "ceil_mode", 0) != 0 {
+		return nil, errors.New("ceil_mode isn't supported")
+	}
+	if len(n.Outputs) > 1 {
+		return nil, errors.New("indices output isn't supported")
+	}
+	b := imp.b
+	switch {
+	case n.OpType == "MaxPool":
+		b.Pmode.Max()
+	case attrint(n, "count_include_pad", 0) != 0:
+		b.Pmode.AverageCountIncludePadding()
+	default:
+		b.Pmode.AverageCountExcludePadding()
+	}
+	l, err := b.PoolingLayer(id, window, pad, stride)
+	if err != nil {
+		return nil, err
+	}
+	l.SetForwardScalars(1, 0)
+	return l, nil
+}
+
+func (imp *onnximporter) dropout(id int64, n *onnx.Node) (*Layer, error) {
+	ratio := attrfloat(n, "ratio", .5)
+	if len(n.Inputs) > 1 && n.Inputs[1] != "" {
+		t, err := imp.initializer(n, 1)
+		if err != nil {
+			return nil, err
+		}
+		v := t.Floats()
+		if len(v) != 1 {
+			return nil, fmt.Errorf("ratio %s needs to be a float scalar", t.Name)
+		}
+		ratio = v[0]
+	}
+	return imp.b.Dropout(id, ratio, uint64(id))
+}
+
+func (imp *onnximporter) concat(n *onnx.Node, rank int) (*Concat, error) {
+	axis := attrint(n, "axis", 1)
+	if axis < 0 {
+		axis += int64(rank)
+	}
+	if axis != 1 {
+		return nil, errors.New("only concat on the channel axis is supported")
+	}
+	c, err := CreateConcat(imp.b.h)
+	if err != nil {
+		return nil, err
+	}
+	c.c.SetForwardAlpha(1)
+	c.c.SetForwardBeta(0)
+	c.c.SetBackwardAlpha(1)
+	c.c.SetBackwardBeta(1)
+	return c, nil
+}
+
+//softmax is done on the channels.  Before opset 13 the axis flattened the dims after it, so it is done per instance.
+func (imp *onnximporter) softmax(n *onnx.Node, rank int) (*softmax.Layer, error) {
+	axis := int64(1)
+	if imp.opset >= 13 {
+		axis = -1
+	}
+	axis = attrint(n, "axis", axis)
+	if axis < 0 {
+		axis += int64(rank)
+	}
+	if axis != 1 {
+		return nil, errors.New("only softmax on the channel axis is supported")
+	}
+	options := &softmax.OpMultiplier{ForwardAlpha: 1, BackwardAlpha: 1, BackwardBeta: 1}
+	if imp.opset < 13 && rank > 2 {
+		return softmax.StageAccuratePerInstance(options), nil
+	}
+	return softmax.StageAccuratePerChannel(options), nil
+}
+
+//view returns the function that finds the dims of the view and the onnx rank of the output
+func (imp *onnximporter) view(n *onnx.Node, rank int) (func(dims []int32) ([]int32, error), int, error) {
+	switch n.OpType {
+	case "Identity":
+		return func(dims []int32) ([]int32, error) { return dims, nil }, rank, nil
+	case "Flatten":
+		axis := attrint(n, "axis", 1)
+		if axis < 0 {
+			axis += int64(rank)
+		}
+		if axis < 0 || axis > int64(rank) {
+			return nil, 0, fmt.Errorf("axis %d is out of range", axis)
+		}
+		return func(dims []int32) ([]int32, error) {
+			outer := int32(1)
+			for _, d := range dims[:axis] {
+				outer *= d
+			}
+			return []int32{outer, volume(dims) / outer}, nil
+		}, 2, nil
+	}
+	t, err := imp.initializer(n, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	shape := t.Int64s()
+	if shape == nil {
+		return nil, 0, fmt.Errorf("shape %s needs to be int64", t.Name)
+	}
+	if attrint(n, "allowzero", 0) != 0 {
+		return nil, 0, errors.New("allowzero isn't supported")
+	}
+	return func(dims []int32) ([]int32, error) {
+		out := make([]int32, len(shape))
+		infer := -1
+		known := int32(1)
+		for i, s := range shape {
+			switch {
+			case s == 0 && i < len(dims):
+				out[i] = dims[i]
+			case s == -1 && infer < 0:
+				infer = i
+				continue
+			case s > 0:
+				out[i] = int32(s)
+			default:
+				return nil, fmt.Errorf("shape %v can't be used on %v", shape, dims)
+			}
+			known *= out[i]
+		}
+		vol := volume(dims)
+		if infer >= 0 && known > 0 {
+			out[infer] = vol / known
+			known *= out[infer]
+		}
+		if known != vol {
+			return nil, fmt.Errorf("shape %v can't be used on %v", shape, dims)
+		}
+		return out, nil
+	}, len(shape), nil
+}
+
+//accumulatebackward makes the layer add to its dx on the backward pass so tensors feeding more than one node get the sum of the gradients
+func accumulatebackward(l *Layer) {
+	switch {
+	case l.activation != nil:
+		l.activation.SetBackwardScalars(1, 1)
+	case l.batch != nil:
+		l.batch.SetBackwardScalars(1, 1)
+	default:
+		l.SetBackwardScalars(1, 1)
+	}
+}
+
+//windowattributes returns the pads, strides and dilations of a conv or pool.  Pads need to be the same at the start and end.
+func windowattributes(n *onnx.Node, spacial int) (pad, stride, dilation []int32, err error) {
+	if a := n.Attribute("auto_pad"); a != nil && a.S != "" && a.S != "NOTSET" {
+		return nil, nil, nil, fmt.Errorf("auto_pad %s isn't supported", a.S)
+	}
+	pads := attrints(n, "pads", 0, 2*spacial)
+	if len(pads) != 2*spacial {
+		return nil, nil, nil, errors.New("pads don't match the spacial dims")
+	}
+	pad = int32s(pads[:spacial])
+	for i := range pad {
+		if pads[i] != pads[i+spacial] {
+			return nil, nil, nil, errors.New("pads need to be the same at the start and end")
+		}
+	}
+	stride = int32s(attrints(n, "strides", 1, spacial))
+	dilation = int32s(attrints(n, "dilations", 1, spacial))
+	if len(stride) != spacial || len(dilation) != spacial {
+		return nil, nil, nil, errors.New("strides or dilations don't match the spacial dims")
+	}
+	return pad, stride, dilation, nil
+}
+
+func attrint(n *onnx.Node, name string, def int64) int64 {
+	if a := n.Attribute(name); a != nil {
+		return a.I
+	}
+	return def
+}
+func attrfloat(n *onnx.Node, name string, def float32) float32 {
+	if a := n.Attribute(name); a != nil {
+		return a.F
+	}
+	return def
+}
+
+//attrints returns the ints of the attribute or n values of def if the node doesn't have it
+func attrints(n *onnx.Node, name string, def int64, length int) []int64 {
+	if a := n.Attribute(name); a != nil {
+		return a.Ints
+	}
+	ints := make([]int64, length)
+	for i := range ints {
+		ints[i] = def
+	}
+	return ints
+}
+
+func int32s(x []int64) []int32 {
+	y := make([]int32, len(x))
+	for i := range x {
+		y[i] = int32(x[i])
+	}
+	return y
+}
+
+func volume(dims []int32) int32 {
+	vol := int32(1)
+	for _, d := range dims {
+		vol *= d
+	}
+	return vol
+}
+
+//fourdims pads dims with trailing ones so cudnn can use it.  In NCHW this doesn't change the layout in memory.
+func fourdims(dims []int32) []int32 {
+	for len(dims) < 4 {
+		dims = append(dims, 1)
+	}
+	return dims
+}
+
+//ID satisfies the Module interface
+func (m *ONNXModule) ID() int64 {
+	return m.id
+}
+
+//FindOutputDims satisfies the Module interface.
+//The first time it is ran it makes the tensors between the nodes and loads the batch norm weights.
+func (m *ONNXModule) FindOutputDims() ([]int32, error) {
+	if m.x == nil {
+		return nil, errors.New("(m *ONNXModule) FindOutputDims: X tensor is not set")
+	}
+	if m.ys != nil {
+		return m.outputdims()
+	}
+	var err error
+	if m.dx == nil {
+		m.dx, err = m.b.CreateTensor(m.x.Dims())
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.ys = map[string]*Tensor{m.input: m.x}
+	m.dys = map[string]*Tensor{m.input: m.dx}
+	h := m.b.h.Handler
+	for _, n := range m.nodes[:len(m.nodes)-1] {
+		dims, err := m.nodedims(n)
+		if err != nil {
+			m.ys, m.dys = nil, nil
+			return nil, fmt.Errorf("(m *ONNXModule) FindOutputDims: node %s (%s): %v", n.name, n.optype, err)
+		}
+		x, dx := m.ys[n.inputs[0]], m.dys[n.inputs[0]]
+		var y, dy *Tensor
+		if n.view != nil {
+			y, err = m.createview(dims, x.Malloced)
+			if err == nil {
+				dy, err = m.createview(dims, dx.Malloced)
+			}
+		} else {
+			y, err = m.b.CreateTensor(dims)
+			if err == nil {
+				dy, err = m.b.CreateTensor(dims)
+			}
+		}
+		if err != nil {
+			m.ys, m.dys = nil, nil
+			return nil, err
+		}
+		m.ys[n.output], m.dys[n.output] = y, dy
+		if n.layer != nil {
+			n.layer.SetIOs(x, dx, y, dy)
+		}
+	}
+	dims, err := m.outputdims()
+	if err != nil {
+		m.ys, m.dys = nil, nil
+		return nil, err
+	}
+	for _, n := range m.nodes {
+		if n.layer == nil {
+			continue
+		}
+		switch {
+		case n.layer.batch != nil:
+			err = n.layer.batch.SetupPreset(h, m.ys[n.inputs[0]].Tensor)
+			if err == nil {
+				err = loadbatchnorm(h, n.layer, n.bn)
+			}
+		case n.layer.drop != nil:
+			err = n.layer.drop.BuildFromPreset(h, m.ys[n.inputs[0]].Tensor)
+		}
+		if err != nil {
+			m.ys, m.dys = nil, nil
+			return nil, fmt.Errorf("(m *ONNXModule) FindOutputDims: node %s (%s): %v", n.name, n.optype, err)
+		}
+	}
+	return dims, nil
+}
+
+func (m *ONNXModule) outputdims() ([]int32, error) {
+	n := m.nodes[len(m.nodes)-1]
+	dims, err := m.nodedims(n)
+	if err != nil {
+		return nil, fmt.Errorf("(m *ONNXModule) FindOutputDims: node %s (%s): %v", n.name, n.optype, err)
+	}
+	return dims, nil
+}
+
+func (m *ONNXModule) createview(dims []int32, mem *nvidia.Malloced) (*Tensor, error) {
+	t, err := layers.CreateTensorEX(m.b.h.Handler, m.b.Frmt.TensorFormat, m.b.Dtype.DataType, dims, mem)
+	return &Tensor{Tensor: t}, err
+}
+
+//nodedims returns the output dims of n.  The input tensors of n need to have been made.
+func (m *ONNXModule) nodedims(n *onnxnode) ([]int32, error) {
+	x := m.ys[n.inputs[0]]
+	switch {
+	case n.layer != nil:
+		return n.layer.GetOutputDims(x)
+	case n.concat != nil:
+		xs := make([]*Tensor, len(n.inputs))
+		for i, in := range n.inputs {
+			xs[i] = m.ys[in]
+		}
+		return n.concat.FindOutputDims(xs)
+	case n.view != nil:
+		dims, err := n.view(x.Dims())
+		if err != nil {
+			return nil, err
+		}
+		return fourdims(dims), nil
+	}
+	return copyint32s(x.Dims()), nil
+}
+
+//loadbatchnorm copies the onnx scale, bias, mean and variance into the batch norm
+func loadbatchnorm(h *cudnn.Handler, l *Layer, ts []*onnx.Tensor) error {
+	mems := []*nvidia.Malloced{l.batch.Scale().Malloced, l.batch.Bias().Malloced, l.batch.RunningMean(), l.batch.RunningVariance()}
+	for i := range mems {
+		if mems[i] == nil {
+			return errors.New("batch norm hasn't been setup")
+		}
+		if err := mems[i].LoadSlice(h, ts[i].RawData); err != nil {
+			return fmt.Errorf("%s: %v", ts[i].Name, err)
+		}
+	}
+	return nil
+}
+
+//InitHiddenLayers connects the last node to the Y tensors and loads the trainers. The imported weights are kept.
+func (m *ONNXModule) InitHiddenLayers(rate, decay1, decay2 float32) (err error) {
+	if m.ys == nil {
+		if _, err = m.FindOutputDims(); err != nil {
+			return err
+		}
+	}
+	if m.y == nil || m.dy == nil {
+		return errors.New("(m *ONNXModule) InitHiddenLayers: y or dy is nil")
+	}
+	m.ys[m.output], m.dys[m.output] = m.y, m.dy
+	for _, n := range m.nodes {
+		switch {
+		case n.layer != nil:
+			n.layer.SetIOs(m.ys[n.inputs[0]], m.dys[n.inputs[0]], m.ys[n.output], m.dys[n.output])
+			if n.layer.cnn == nil && n.layer.cnntranspose == nil && n.layer.batch == nil {
+				continue
+			}
+			w, bias, err := trainer.SetupAdamWandB(m.b.h.XHandle(), decay1, decay2, int32(m.batchsize))
+			if err != nil {
+				return fmt.Errorf("(m *ONNXModule) InitHiddenLayers: node %s: %v", n.name, err)
+			}
+			w.SetRates(rate, 0)
+			bias.SetRates(rate, 0)
+			err = n.layer.LoadTrainer(m.b.h.Handler, m.batchsize, w, bias)
+			if err != nil {
+				return fmt.Errorf("(m *ONNXModule) InitHiddenLayers: node %s: %v", n.name, err)
+			}
+		case n.concat != nil:
+			xs := make([]*Tensor, len(n.inputs))
+			dxs := make([]*Tensor, len(n.inputs))
+			for i, in := range n.inputs {
+				xs[i], dxs[i] = m.ys[in], m.dys[in]
+			}
+			n.concat.SetInputSrcs(xs)
+			n.concat.SetInputDeltaSrcs(dxs)
+			n.concat.SetDest(m.ys[n.output])
+			n.concat.SetDeltaDest(m.dys[n.output])
+		}
+	}
+	return nil
+}
+
+//InitWorkspace finds the algos and workspaces of the convolutions
+func (m *ONNXModule) InitWorkspace() (err error) {
+	for _, n := range m.nodes {
+		if n.layer == nil {
+			continue
+		}
+		if err = n.layer.initconvworkspace(); err != nil {
+			return fmt.Errorf("(m *ONNXModule) InitWorkspace: node %s: %v", n.name, err)
+		}
+	}
+	return nil
+}
+
+//Forward satisfies the Module interface
+func (m *ONNXModule) Forward() error {
+	return m.forward(false)
+}
+
+//Inference satisfies the Module interface.  Batch norms use the running mean and variance.
+func (m *ONNXModule) Inference() error {
+	return m.forward(true)
+}
+
+func (m *ONNXModule) forward(inference bool) (err error) {
+	h := m.b.h.Handler
+	for _, n := range m.nodes {
+		switch {
+		case inference && n.layer != nil && n.layer.batch != nil:
+			err = n.layer.batch.ForwardInference(h, m.ys[n.inputs[0]].Tensor, m.ys[n.output].Tensor)
+		case n.layer != nil:
+			err = n.layer.forwardprop()
+		case n.concat != nil:
+			err = n.concat.Forward()
+		case n.softmax != nil:
+			err = n.softmax.ForwardProp(h, m.ys[n.inputs[0]].Tensor, m.ys[n.output].Tensor)
+		}
+		if err != nil {
+			return fmt.Errorf("(m *ONNXModule) Forward: node %s (%s): %v", n.name, n.optype, err)
+		}
+	}
+	return nil
+}
+
+//Backward satisfies the Module interface.  The gradients of the inner tensors and dx are zeroed first since every node adds to them.
+func (m *ONNXModule) Backward() (err error) {
+	h := m.b.h.Handler
+	if err = m.dx.SetValues(h, 0); err != nil {
+		return err
+	}
+	for _, n := range m.nodes[:len(m.nodes)-1] {
+		if n.view != nil {
+			continue
+		}
+		if err = m.dys[n.output].SetValues(h, 0); err != nil {
+			return err
+		}
+	}
+	for i := len(m.nodes) - 1; i >= 0; i-- {
+		n := m.nodes[i]
+		switch {
+		case n.layer != nil:
+			err = n.layer.backpropfilterdata()
+		case n.concat != nil:
+			err = n.concat.Backward()
+		case n.softmax != nil:
+			err = n.softmax.BackProp(h, m.dys[n.inputs[0]].Tensor, m.dys[n.output].Tensor, m.ys[n.output].Tensor)
+		}
+		if err != nil {
+			return fmt.Errorf("(m *ONNXModule) Backward: node %s (%s): %v", n.name, n.optype, err)
+		}
+	}
+	return nil
+}
+
+//Update satisfies the Module interface
+func (m *ONNXModule) Update(epoch int) (err error) {
+	for _, n := range m.nodes {
+		if n.layer == nil {
+			continue
+		}
+		if err = n.layer.updateWeights(epoch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//GetTensorX returns set x tensor
+func (m *ONNXModule) GetTensorX() (x *Tensor) { return m.x }
+
+//GetTensorDX returns set dx tensor
+func (m *ONNXModule) GetTensorDX() (dx *Tensor) { return m.dx }
+
+//GetTensorY returns set y tensor
+func (m *ONNXModule) GetTensorY() (y *Tensor) { return m.y }
+
+//GetTensorDY returns set dy tensor
+func (m *ONNXModule) GetTensorDY() (dy *Tensor) { return m.dy }
+
+//SetTensorX sets x tensor
+func (m *ONNXModule) SetTensorX(x *Tensor) { m.x = x }
+
+//SetTensorDX sets dx tensor
+func (m *ONNXModule) SetTensorDX(dx *Tensor) { m.dx = dx }
+
+//SetTensorY sets y tensor
+func (m *ONNXModule) SetTensorY(y *Tensor) { m.y = y }
+
+//SetTensorDY sets dy tensor
+func (m *ONNXModule) SetTensorDY(dy *Tensor) { m.dy = dy }
+
+//initconvworkspace picks the first working cudnn algos for a convolution or transposed convolution and allocates their workspaces.
+//It does nothing for other layers.
+func (l *Layer) initconvworkspace() (err error) {
+	noerror := gocudnn.Status(0)
+	h := l.h.Handler
+	x, y := l.x.Tensor, l.y.Tensor
+	var fwdmem, bwdmem, bwfmem uint
+	var found bool
+	switch {
+	case l.cnn != nil:
+		fwds, err := l.cnn.GetFwdAlgoPerfList(h, x, y, nil)
+		if err != nil {
+			return err
+		}
+		for _, fwd := range fwds {
+			if found = fwd.Status == noerror; found {
+				l.cnn.SetFwdAlgoPerformance(fwd)
+				fwdmem = fwd.Memory
+				break
+			}
+		}
+		if !found {
+			return errors.New("cnnInitForwardPerformanceFail")
+		}
+		bwds, err := l.cnn.GetBwdDataAlgoPerfList(h, x, y, nil)
+		if err != nil {
+			return err
+		}
+		for _, bwd := range bwds {
+			if found = bwd.Status == noerror; found {
+				l.cnn.SetBwdDataAlgoPerformance(bwd)
+				bwdmem = bwd.Memory
+				break
+			}
+		}
+		if !found {
+			return errors.New("cnnInitBackwardDataPerformanceFail")
+		}
+		bwfs, err := l.cnn.GetBwdFiltAlgoPerfList(h, x, y, nil)
+		if err != nil {
+			return err
+		}
+		for _, bwf := range bwfs {
+			if found = bwf.Status == noerror; found {
+				l.cnn.SetBwdFiltAlgoPerformance(bwf)
+				bwfmem = bwf.Memory
+				break
+			}
+		}
+		if !found {
+			return errors.New("cnnInitBackwardFilterPerformanceFail")
+		}
+	case l.cnntranspose != nil:
+		fwds, err := l.cnntranspose.GetFwdAlgoPerfList(h, x, y, nil)
+		if err != nil {
+			return err
+		}
+		for _, fwd := range fwds {
+			if found = fwd.Status == noerror; found {
+				l.cnntranspose.SetFwdAlgoPerformance(fwd)
+				fwdmem = fwd.Memory
+				break
+			}
+		}
+		if !found {
+			return errors.New("cnntransposeInitForwardPerformanceFail")
+		}
+		bwds, err := l.cnntranspose.GetBwdDataAlgoPerfList(h, x, y, nil)
+		if err != nil {
+			return err
+		}
+		for _, bwd := range bwds {
+			if found = bwd.Status == noerror; found {
+				l.cnntranspose.SetBwdDataAlgoPerformance(bwd)
+				bwdmem = bwd.Memory
+				break
+			}
+		}
+		if !found {
+			return errors.New("cnntransposeInitBackwardDataPerformanceFail")
+		}
+		bwfs, err := l.cnntranspose.GetBwdFiltAlgoPerfList(h, x, y, nil)
+		if err != nil {
+			return err
+		}
+		for _, bwf := range bwfs {
+			if found = bwf.Status == noerror; found {
+				l.cnntranspose.SetBwdFiltAlgoPerformance(bwf)
+				bwfmem = bwf.Memory
+				break
+			}
+		}
+		if !found {
+			return errors.New("cnntransposeInitBackwardFilterPerformanceFail")
+		}
+	default:
+		return nil
+	}
+	if fwdmem > 0 {
+		if l.workspacefwd, err = nvidia.MallocGlobal(h, fwdmem); err != nil {
+			return err
+		}
+	}
+	if bwdmem > 0 {
+		if l.workspacebwd, err = nvidia.MallocGlobal(h, bwdmem); err != nil {
+			return err
+		}
+	}
+	if bwfmem > 0 {
+		if l.workspacebwf, err = nvidia.MallocGlobal(h, bwfmem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
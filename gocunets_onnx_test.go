@@ -0,0 +1,153 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/onnx"
+)
+
+//onnxtestnetwork builds a network with a VanillaModule, a CompressionModule with two convolutions, an OutputModule and a SoftMax classifier
+func onnxtestnetwork(t *testing.T, bldr *Builder, batch int32) *SimpleModuleNetwork {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	mnet := CreateSimpleModuleNetwork(0, bldr)
+	mods := make([]Module, 2)
+	var err error
+	mods[0], err = CreateVanillaModule(0, bldr, batch, []int32{8, 1, 3, 3}, []int32{1, 1}, []int32{1, 1}, []int32{1, 1}, 1, 0, 1, 0)
+	check(err)
+	mods[1], err = CreateCompressionModule(1, bldr, batch, 8, []int32{4, 4}, []int32{2, 2}, 0, 1, 0)
+	check(err)
+	mnet.SetModules(mods)
+	x, err := bldr.CreateTensor([]int32{batch, 1, 9, 9})
+	check(err)
+	mnet.SetTensorX(x)
+	outputdims, err := mnet.FindOutputDims()
+	check(err)
+	mnet.Output, err = CreateOutputModule(2, bldr, batch, []int32{3, outputdims[1], outputdims[2], outputdims[3]}, []int32{0, 0}, []int32{1, 1}, []int32{1, 1}, 1, 0, 1, 0)
+	check(err)
+	check(mnet.SetSoftMaxClassifier())
+	_, err = mnet.FindOutputDims()
+	check(err)
+	check(mnet.InitHiddenLayers(.001, 0, 0))
+	check(mnet.InitWorkspace())
+	return mnet
+}
+
+func TestONNXLayerNodes(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+	batch := int32(4)
+
+	model, err := onnxtestnetwork(t, CreateBuilder(handle), batch).ONNXModel()
+	check(err)
+	//vanilla: conv and activation. compression: a conv for each parallel output, their concat and the activation.
+	//output: conv. classifier: softmax.
+	expected := []string{"Conv", "LeakyRelu", "Conv", "Conv", "Concat", "LeakyRelu", "Conv", "Softmax"}
+	nodes := model.Graph.Nodes
+	if len(nodes) != len(expected) {
+		t.Fatalf("got %d nodes, expected %d", len(nodes), len(expected))
+	}
+	for i, n := range nodes {
+		if n.OpType != expected[i] {
+			t.Errorf("node %d is %s, expected %s", i, n.OpType, expected[i])
+		}
+		if i > 0 && n.OpType != "Conv" && n.Inputs[0] != nodes[i-1].Outputs[0] {
+			t.Errorf("node %d (%s) doesn't take the output of node %d", i, n.OpType, i-1)
+		}
+	}
+	if concat := nodes[4]; len(concat.Inputs) != 2 || concat.Inputs[0] != nodes[2].Outputs[0] || concat.Inputs[1] != nodes[3].Outputs[0] {
+		t.Errorf("concat inputs are %v, expected the outputs of the two compression convolutions", concat.Inputs)
+	}
+	if nodes[2].Inputs[0] != nodes[1].Outputs[0] || nodes[3].Inputs[0] != nodes[1].Outputs[0] {
+		t.Error("both compression convolutions should take the output of the vanilla module")
+	}
+
+	convbldr := CreateBuilder(handle)
+	convbldr.Cmode.Convolution()
+	if _, err = onnxtestnetwork(t, convbldr, batch).ONNXModel(); err == nil {
+		t.Error("a network in the Convolution mode should not be exported")
+	}
+}
+
+func TestONNXExportImport(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+	bldr := CreateBuilder(handle)
+	batch := int32(4)
+
+	mnet := onnxtestnetwork(t, bldr, batch)
+	x := mnet.GetTensorX()
+
+	input := make([]float32, batch*9*9)
+	for i := range input {
+		input[i] = rand.Float32()
+	}
+	check(x.LoadValuesFromSLice(handle.Handler, input, int32(len(input))))
+	check(mnet.Inference())
+	check(handle.Sync())
+	y := mnet.GetTensorY()
+	expected := make([]float32, y.Vol())
+	check(y.FillSlice(handle.Handler, expected))
+
+	buf := new(bytes.Buffer)
+	check(mnet.ExportONNX(buf))
+	imported, err := ImportONNX(buf, CreateBuilder(handle), 3, batch)
+	check(err)
+	ix, err := bldr.CreateTensor([]int32{batch, 1, 9, 9})
+	check(err)
+	imported.SetTensorX(ix)
+	dims, err := imported.FindOutputDims()
+	check(err)
+	iy, err := bldr.CreateTensor(dims)
+	check(err)
+	idy, err := bldr.CreateTensor(dims)
+	check(err)
+	imported.SetTensorY(iy)
+	imported.SetTensorDY(idy)
+	check(imported.InitHiddenLayers(.001, 0, 0))
+	check(imported.InitWorkspace())
+	check(ix.LoadValuesFromSLice(handle.Handler, input, int32(len(input))))
+	check(imported.Inference())
+	check(handle.Sync())
+	output := make([]float32, iy.Vol())
+	check(iy.FillSlice(handle.Handler, output))
+	if len(output) != len(expected) {
+		t.Fatalf("imported output has %d values, expected %d", len(output), len(expected))
+	}
+	for i := range expected {
+		if math.Abs(float64(expected[i]-output[i])) > 1e-4 {
+			t.Fatalf("output at %d is %v, expected %v", i, output[i], expected[i])
+		}
+	}
+	check(imported.Backward())
+
+	var dtype onnx.DataType
+	gb := onnx.CreateGraphBuilder("unsupported")
+	gb.AddInput("x", dtype.Float(), []int32{batch, 8})
+	gb.Graph().Nodes = append(gb.Graph().Nodes, &onnx.Node{Name: "fc", OpType: "Gemm", Inputs: []string{"x", "w"}, Outputs: []string{"y"}})
+	gb.AddOutput("y", dtype.Float(), []int32{batch, 2})
+	_, err = CreateONNXModule(gb.Model("test", ""), CreateBuilder(handle), 4, batch)
+	if err == nil || !strings.Contains(err.Error(), "node fc (Gemm)") {
+		t.Errorf("unsupported op error should name the node, got %v", err)
+	}
+}
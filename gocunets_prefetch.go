@@ -0,0 +1,241 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dereklstinson/gocunets/data"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
+	"github.com/dereklstinson/half"
+)
+
+//PinnedLoader moves the batches of a data.Prefetcher into the x and y tensors of a network through two buffers of pinned host memory.
+//While the network runs on one batch, a goroutine puts the next batch into a buffer, so Next only has to queue a copy
+//from pinned memory to the tensors.
+//
+//The copy is queued on the stream set on the handle with SetStream, so it is done before the Forward that is queued after Next,
+//and Next doesn't wait for it.  If the handle doesn't have a stream set the copy is done on the default stream and Next waits for it.
+type PinnedLoader struct {
+	h      *Handle
+	x, y   *Tensor
+	p      *data.Prefetcher
+	bufs   [2]pinnedbuffer
+	queued int
+	free   chan int
+	ready  chan pinnedresult
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+type pinnedbuffer struct {
+	x, y *nvidia.Malloced
+}
+
+type pinnedresult struct {
+	buf   int
+	count int
+	err   error
+}
+
+//CreatePinnedLoader creates a PinnedLoader that loads the batches of p into x and y and starts filling the buffers.
+//y can be nil if the targets aren't used.  The batches of p need to be the size of x and y.
+//
+//Close needs to be called to stop the goroutine and free the buffers.  It doesn't close p.
+func CreatePinnedLoader(h *Handle, p *data.Prefetcher, x, y *Tensor) (l *PinnedLoader, err error) {
+	if x == nil {
+		return nil, errors.New("CreatePinnedLoader: x is nil")
+	}
+	for _, t := range []*Tensor{x, y} {
+		if t == nil {
+			continue
+		}
+		dtype := t.DataType()
+		if dtype != dtype.Float() && dtype != dtype.Half() {
+			return nil, fmt.Errorf("CreatePinnedLoader: unsupported datatype %v", dtype)
+		}
+	}
+	var w nvidia.Worker
+	if h.Worker != nil {
+		w = h.Handler
+	}
+	l = &PinnedLoader{
+		h:      h,
+		x:      x,
+		y:      y,
+		p:      p,
+		queued: -1,
+		free:   make(chan int, 2),
+		ready:  make(chan pinnedresult, 1),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for i := range l.bufs {
+		l.bufs[i].x, err = nvidia.MallocHost(w, x.SIB())
+		if err != nil {
+			l.freebuffers()
+			return nil, err
+		}
+		if y != nil {
+			l.bufs[i].y, err = nvidia.MallocHost(w, y.SIB())
+			if err != nil {
+				l.freebuffers()
+				return nil, err
+			}
+		}
+		l.free <- i
+	}
+	go l.fill()
+	return l, nil
+}
+
+//fill puts batches from p into the free buffers until p is out of batches or Close is called
+func (l *PinnedLoader) fill() {
+	defer close(l.done)
+	defer close(l.ready)
+	for {
+		var buf int
+		select {
+		case buf = <-l.free:
+		case <-l.quit:
+			return
+		}
+		r := pinnedresult{buf: buf}
+		b, err := l.p.Next()
+		if err == nil {
+			r.count = b.Count
+			err = l.stage(buf, b)
+			l.p.Release(b)
+		}
+		r.err = err
+		select {
+		case l.ready <- r:
+		case <-l.quit:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+//stage copies b into buffer buf
+func (l *PinnedLoader) stage(buf int, b *data.Batch) error {
+	err := stageslice(l.bufs[buf].x, l.x, b.Inputs)
+	if err != nil {
+		return fmt.Errorf("inputs: %v", err)
+	}
+	if l.y == nil {
+		return nil
+	}
+	err = stageslice(l.bufs[buf].y, l.y, b.Targets)
+	if err != nil {
+		return fmt.Errorf("targets: %v", err)
+	}
+	return nil
+}
+
+//stageslice copies values into the pinned memory through its host pointer.  Half values are converted as they are copied.
+func stageslice(mem *nvidia.Malloced, t *Tensor, values []float32) error {
+	n := int(t.Vol())
+	if len(values) != n {
+		return fmt.Errorf("batch has %d values but the tensor has %d", len(values), n)
+	}
+	dtype := t.DataType()
+	switch dtype {
+	case dtype.Float():
+		copy((*[1 << 30]float32)(mem.Ptr())[:n:n], values)
+		return nil
+	case dtype.Half():
+		dst := (*[1 << 30]half.Float16)(mem.Ptr())[:n:n]
+		for i, v := range values {
+			dst[i] = half.NewFloat16(v)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported datatype %v", dtype)
+}
+
+//Next queues the copy of the next batch into x and y and returns the number of samples from the dataset in it.
+//It returns io.EOF when the epoch is done.
+//
+//The handle is synced first so the copy of the last batch is done and its buffer can be filled again.
+func (l *PinnedLoader) Next() (count int, err error) {
+	r, ok := <-l.ready
+	if !ok {
+		return 0, io.EOF
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	err = l.h.Sync()
+	if err != nil {
+		l.free <- r.buf
+		return 0, err
+	}
+	if l.queued >= 0 {
+		l.free <- l.queued
+		l.queued = -1
+	}
+	err = l.copy(r.buf)
+	if err != nil {
+		l.free <- r.buf
+		return 0, err
+	}
+	l.queued = r.buf
+	return r.count, nil
+}
+
+//copy queues the copy of buffer buf to x and y on the stream of the handle
+func (l *PinnedLoader) copy(buf int) error {
+	s := l.h.CurrentStream()
+	memcpy := func(dest, src *nvidia.Malloced, sib uint) error {
+		if s == nil {
+			return nvidia.Memcpy(dest, src, sib)
+		}
+		return nvidia.MemcpyAsync(dest, src, sib, s)
+	}
+	copies := func() error {
+		err := memcpy(l.x.Malloced, l.bufs[buf].x, l.x.SIB())
+		if err != nil || l.y == nil {
+			return err
+		}
+		return memcpy(l.y.Malloced, l.bufs[buf].y, l.y.SIB())
+	}
+	if l.h.Worker == nil {
+		return copies()
+	}
+	return l.h.Work(copies)
+}
+
+//Close stops the goroutine that fills the buffers, waits for the last copy and frees the buffers.
+//The loader can't be used after.
+func (l *PinnedLoader) Close() error {
+	select {
+	case <-l.quit:
+		return nil
+	default:
+		close(l.quit)
+	}
+	<-l.done
+	err := l.h.Sync()
+	if err != nil {
+		return err
+	}
+	return l.freebuffers()
+}
+
+func (l *PinnedLoader) freebuffers() (err error) {
+	for i := range l.bufs {
+		for _, m := range []*nvidia.Malloced{l.bufs[i].x, l.bufs[i].y} {
+			if ferr := m.Free(); ferr != nil && err == nil {
+				err = ferr
+			}
+		}
+		l.bufs[i] = pinnedbuffer{}
+	}
+	return err
+}
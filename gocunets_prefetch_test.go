@@ -0,0 +1,92 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/data"
+)
+
+func TestPinnedLoader(t *testing.T) {
+	pinnedloadertest(t, false)
+}
+
+//TestPinnedLoaderStream has the copies queued on a stream set on the handle
+func TestPinnedLoaderStream(t *testing.T) {
+	pinnedloadertest(t, true)
+}
+
+func pinnedloadertest(t *testing.T, stream bool) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+	if stream {
+		s, err := CreateStream()
+		check(err)
+		check(handle.SetStream(s))
+	}
+	bldr := CreateBuilder(handle)
+
+	inputs := make([][]float32, 10)
+	targets := make([][]float32, 10)
+	for i := range inputs {
+		inputs[i] = []float32{rand.Float32(), rand.Float32(), rand.Float32(), rand.Float32()}
+		targets[i] = data.OneHot(i%2, 2)
+	}
+	ds, err := data.CreateSliceDataset(inputs, targets)
+	check(err)
+	expected, err := data.CreateDataLoader(ds, 3, data.PadLast, true, 1)
+	check(err)
+	d, err := data.CreateDataLoader(ds, 3, data.PadLast, true, 1)
+	check(err)
+	p, err := data.Prefetch(context.Background(), d, 2, 2)
+	check(err)
+	defer p.Close()
+
+	x, err := bldr.CreateTensor([]int32{3, 4, 1, 1})
+	check(err)
+	y, err := bldr.CreateTensor([]int32{3, 2, 1, 1})
+	check(err)
+	l, err := CreatePinnedLoader(handle, p, x, y)
+	check(err)
+	xvalues := make([]float32, 12)
+	yvalues := make([]float32, 6)
+	for {
+		count, err := l.Next()
+		b, experr := expected.Next(nil)
+		if err == io.EOF {
+			if experr != io.EOF {
+				t.Fatal("PinnedLoader ran out of batches early")
+			}
+			break
+		}
+		check(err)
+		check(experr)
+		if count != b.Count {
+			t.Errorf("count is %d expected %d", count, b.Count)
+		}
+		check(handle.Sync())
+		check(x.FillSlice(handle.Handler, xvalues))
+		check(y.FillSlice(handle.Handler, yvalues))
+		for i := range xvalues {
+			if xvalues[i] != b.Inputs[i] {
+				t.Fatalf("x[%d] is %v expected %v", i, xvalues[i], b.Inputs[i])
+			}
+		}
+		for i := range yvalues {
+			if yvalues[i] != b.Targets[i] {
+				t.Fatalf("y[%d] is %v expected %v", i, yvalues[i], b.Targets[i])
+			}
+		}
+	}
+	check(l.Close())
+}
@@ -0,0 +1,73 @@
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/data"
+	"github.com/dereklstinson/gocunets/data/preprocess"
+)
+
+//SetPreprocess sets the Normalizer that scales the inputs of the network.  nil removes it.
+//
+//It is saved in the model file by SaveModel and WriteCheckpoint and is set again by LoadModel and ReadCheckpoint,
+//so a deployed network scales its inputs the same way it did in training.
+//LoadInput and LoadBatch of the network scale the inputs with it as they load them.  Forward, Inference and TestForward
+//use TensorX as it is, so inputs loaded another way, like with the LoadBatch function or a PinnedLoader, need to be scaled
+//by giving the Normalizer to the DataLoader instead.
+func (m *SimpleModuleNetwork) SetPreprocess(n *preprocess.Normalizer) {
+	m.preprocess = n
+}
+
+//Preprocess returns the Normalizer of the network.  It is nil if one hasn't been set or loaded.
+func (m *SimpleModuleNetwork) Preprocess() *preprocess.Normalizer {
+	return m.preprocess
+}
+
+//LoadInput scales a copy of inputs with the Normalizer of the network and loads it into TensorX.
+//inputs holds the samples of the batch one after the other.  If there isn't a Normalizer the inputs are loaded as they are.
+func (m *SimpleModuleNetwork) LoadInput(inputs []float32) error {
+	err := m.loadinput(inputs)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) LoadInput: %v", err)
+	}
+	return nil
+}
+
+//LoadBatch is like the LoadBatch function, but the inputs of b are scaled with the Normalizer of the network as they are
+//loaded into TensorX.  The targets are loaded into y as they are.  y is usually GetTensorDY, which the classifier uses
+//as the target.  y can be nil if the targets aren't used.
+//
+//The DataLoader of b shouldn't have the Normalizer as a transform too, or the inputs are scaled twice.
+func (m *SimpleModuleNetwork) LoadBatch(b *data.Batch, y *Tensor) error {
+	err := m.loadinput(b.Inputs)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) LoadBatch: inputs: %v", err)
+	}
+	if y == nil {
+		return nil
+	}
+	err = m.b.loadvalues(y, b.Targets)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) LoadBatch: targets: %v", err)
+	}
+	return nil
+}
+
+//loadinput scales a copy of inputs with the Normalizer and loads it into TensorX
+func (m *SimpleModuleNetwork) loadinput(inputs []float32) error {
+	x := m.GetTensorX()
+	if x == nil {
+		return errors.New("TensorX hasn't been set")
+	}
+	if m.preprocess != nil {
+		scaled := make([]float32, len(inputs))
+		copy(scaled, inputs)
+		err := m.preprocess.NormalizeBatch(scaled, int(x.Dims()[0]))
+		if err != nil {
+			return err
+		}
+		inputs = scaled
+	}
+	return m.b.loadvalues(x, inputs)
+}
@@ -0,0 +1,55 @@
+package gocunets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/data"
+	"github.com/dereklstinson/gocunets/data/preprocess"
+)
+
+func TestLoadBatchNormalizes(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	spec, err := ReadNetworkSpec(strings.NewReader(testcpuspec))
+	check(err)
+	m, err := spec.Build(CreateCPUBuilder(1))
+	check(err)
+	const batch = 8
+	inputs := make([]float32, batch*16)
+	targets := make([]float32, batch*2)
+	for i := range inputs {
+		inputs[i] = float32(i%5) / 4
+	}
+	for i := 0; i < batch; i++ {
+		targets[i*2+i%2] = 1
+	}
+
+	//LoadBatch scales the inputs with the Normalizer of the network like LoadInput
+	m.SetPreprocess(&preprocess.Normalizer{Channels: 1, Shift: []float32{.5}, Scale: []float32{2}})
+	check(m.LoadBatch(&data.Batch{Inputs: inputs, Targets: targets, Count: batch}, m.GetTensorDY()))
+	x := m.GetTensorX().Host().Data()
+	for i := range inputs {
+		if x[i] != (inputs[i]-.5)*2 {
+			t.Fatalf("input %d was loaded as %v, expected %v", i, x[i], (inputs[i]-.5)*2)
+		}
+	}
+	dy := m.GetTensorDY().Host().Data()
+	for i := range targets {
+		if dy[i] != targets[i] {
+			t.Fatalf("target %d was loaded as %v, expected %v", i, dy[i], targets[i])
+		}
+	}
+
+	m.SetPreprocess(nil)
+	check(m.LoadBatch(&data.Batch{Inputs: inputs, Targets: targets, Count: batch}, m.GetTensorDY()))
+	x = m.GetTensorX().Host().Data()
+	for i := range inputs {
+		if x[i] != inputs[i] {
+			t.Fatalf("without a Normalizer input %d was loaded as %v, expected %v", i, x[i], inputs[i])
+		}
+	}
+}
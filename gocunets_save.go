@@ -1,264 +1,493 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
-//import (
-//	"bufio"
-//	"encoding/json"
-//	"errors"
-//	"fmt"
-//	"io"
-//	"io/ioutil"
-//	"strings"
-//
-//	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
-//	"github.com/dereklstinson/gocunets/layers"
-//	"github.com/dereklstinson/gocunets/utils"
-//	gocudnn "github.com/dereklstinson/gocudnn"
-//	"github.com/dereklstinson/gocudnn/gocu"
-//)
-//
-////TensorInfo are the Tensor that are used to save and load data to a layer
-//type TensorInfo struct {
-//	Format   string  `json:"format,omitempty"`
-//	Datatype string  `json:"datatype,omitempty"`
-//	Dims     []int32 `json:"dims,omitempty"`
-//	Stride   []int32 `json:"stride,omitempty"` //Stride is a holder for now
-//	Data     []byte  `json:"data,omitempty"`
-//}
-//
-////Params are a layers paramters or weights
-//type Params struct {
-//	Layer  string     `json:"layer,omitempty"`
-//	Weight TensorInfo `json:"weight,omitempty"`
-//	Bias   TensorInfo `json:"bias,omitempty"`
-//	Xtra   TensorInfo `json:"xtra,omitempty"`
-//}
-//
-////NetworkSavedTensor is a bunch of saved Tensor
-//type NetworkSavedTensor struct {
-//	TestLoss float32   `json:"test_loss,omitempty"`
-//	Layers   []*Params `json:"Layers,omitempty"`
-//}
-//
-////GetTensorJSON gets the Tensor from data
-//func GetTensorJSON(data []byte) (*Tensor, error) {
-//	x := new(Tensor)
-//	err := json.Unmarshal(data, x)
-//
-//	return x, err
-//}
-//
-////LoadWeights loads the weights from file returns the previous Loss for the weights.  It none was saved or an error then it will return 999999999
-//func (n *Network) LoadWeights(handle *cudnn.Handler, r io.Reader, size int64) (float32, error) {
-//	var err error
-//	data := make([]byte, size)
-//	_, err = r.Read(data)
-//	if err != nil {
-//		return 999999999, err
-//	}
-//	n.savedparams, err = GetNetworkSavedTensorJSON(data)
-//	if err != nil {
-//		return 999999999, err
-//	}
-//	return n.savedparams.TestLoss, nil
-//}
-//
-////LoadNetworkTensorparams - Loads the weights from Networksavedtensor
-//func (n *Network) LoadNetworkTensorparams(handle *cudnn.Handler, netsavedparams *NetworkSavedTensor) error {
-//	if netsavedparams == nil {
-//		return errors.New("netsavedparams is nil")
-//	}
-//	paramcounter := 0
-//
-//	var err error
-//	for i := range n.layers {
-//		if n.layers[i].hasweights() {
-//			err = n.layers[i].loadparams(handle, netsavedparams.Layers[paramcounter])
-//			if err != nil {
-//				return err
-//			}
-//			paramcounter++
-//
-//		}
-//	}
-//	if paramcounter == 0 {
-//		return errors.New("LoadNetworkTensorparams loaded nothing because n.layers[i].hasweights() didn't return true on anything")
-//	}
-//	return nil
-//}
-//
-////SaveNetworkTensorParams saves network params to the writer
-//func (n *Network) SaveNetworkTensorParams(w io.Writer) (int64, error) {
-//	layers := make([]*Layer, 0)
-//	for i := range n.layers {
-//		if n.layers[i].hasweights() {
-//			layers = append(layers, n.layers[i])
-//		}
-//	}
-//	netparams := make([]*Params, len(layers))
-//	var err error
-//	for i := range layers {
-//		netparams[i], err = layers[i].params()
-//		if err != nil {
-//			return 0, err
-//		}
-//	}
-//	x := NetworkSavedTensor{Layers: netparams}
-//	return x.WriteTo(w)
-//}
-//
-////GetNetworkSavedTensorJSON takes data and converts it to a NetworkSavedTensor
-//func GetNetworkSavedTensorJSON(data []byte) (*NetworkSavedTensor, error) {
-//	x := new(NetworkSavedTensor)
-//	err := json.Unmarshal(data, x)
-//
-//	return x, err
-//}
-//
-////WriteTo takes a writer and writes the NetworkSavedTensor in json format
-//func (val *NetworkSavedTensor) WriteTo(w io.Writer) (n int64, err error) {
-//	bytes, err := json.Marshal(val)
-//	if err != nil {
-//		return 0, err
-//	}
-//	x, err := w.Write(bytes)
-//	return int64(x), err
-//}
-//
-////WriteTo takes a writer and writes the Tensor in json format
-//func (val *TensorInfo) WriteTo(w io.Writer) (n int64, err error) {
-//	bytes, err := json.Marshal(val)
-//	if err != nil {
-//		return 0, err
-//	}
-//	x, err := w.Write(bytes)
-//	return int64(x), err
-//}
-//
-////GetTensor gets the weight info from a tensor.Volume
-//func getTensor(tensor *layers.Tensor, s gocu.Streamer) (TensorInfo, error) {
-//	if tensor == nil {
-//		return TensorInfo{}, errors.New("Tensor is ni")
-//	}
-//	frmt, err := formattostring(tensor.Format())
-//	if err != nil {
-//		return TensorInfo{}, err
-//	}
-//	dtype, err := datatypetostring(tensor.DataType())
-//	if err != nil {
-//		return TensorInfo{}, err
-//	}
-//	dims := tensor.Dims()
-//	buf := tensor.Malloced.NewReadWriter(s)
-//
-//	data, err := ioutil.ReadAll(buf)
-//	if err != nil {
-//		return TensorInfo{}, err
-//	}
-//
-//	//	tensor.FillSlice()
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/dereklstinson/gocunets/data/preprocess"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/loss"
+)
+
+//ModelFileVersion is the schema version that SaveModel writes.
+//Files with a version up to and including this one can be loaded.
 //
-//	return TensorInfo{
-//		Format:   frmt,
-//		Datatype: dtype,
-//		Dims:     dims,
-//		Data:     data,
-//	}, nil
+//A model file is laid out as:
 //
-//}
-//func datatypetostring(dtype gocudnn.DataType) (string, error) {
-//	var flg gocudnn.DataType
-//	switch dtype {
-//	case flg.Double():
-//		return "Double", nil
-//	case flg.Float():
-//		return "Float", nil
-//	case flg.Int32():
-//		return "Int32", nil
-//	case flg.Int8():
-//		return "Int8", nil
-//	case flg.UInt8():
-//		return "UInt8", nil
+//	magic       8 bytes "GOCUNETS"
 //
-//	}
-//	return "Unsupported", errors.New("Unsupported Datatype")
-//}
-//func stringtodatatype(dtype string) (gocudnn.DataType, error) {
-//	dtype = strings.ToUpper(dtype)
-//	var flg gocudnn.DataType
-//	switch dtype {
-//	case "DOUBLE":
-//		return flg.Double(), nil
-//	case "FLOAT":
-//		return flg.Float(), nil
-//	case "INT32":
-//		return flg.Int32(), nil
-//	case "INT8":
-//		return flg.Int8(), nil
-//	case "UINT8":
-//		return flg.UInt8(), nil
-//	default:
-//		return gocudnn.DataType(9999999), errors.New("Unsupported String")
-//	}
-//}
+//	version     uint32 little endian
 //
-////LoadTensor will load the Tensor into a tensor.Volume passed.
-//// Dims don't need to be the same, but the volume does need to be the same
-//// Also Datatype Needs to be the same
-//func (val *TensorInfo) LoadTensor(handle *cudnn.Handler, t *layers.Tensor) error {
+//	headersize  uint32 little endian
 //
-//	tdtype, err := stringtodatatype(val.Datatype)
-//	if err != nil {
-//		return err
-//	}
-//	if tdtype != t.DataType() {
-//		return errors.New("Datatype Not the same")
-//	}
-//	if utils.FindVolumeInt32(t.Dims(), nil) != utils.FindVolumeInt32(val.Dims, nil) {
-//		return errors.New("LoadTensor-Volumes Don't Match")
-//	}
-//	rw := t.NewReadWriter(handle.Stream())
+//	header      headersize bytes of json holding a ModelHeader
 //
-//	bw := bufio.NewWriter(rw)
-//	if err != nil {
-//		return nil
-//	}
-//	written, err := bw.Write(val.Data)
+//	payload     the raw bytes of the tensors. TensorInfo.Offset is from the start of the payload.
+const ModelFileVersion = 1
+
+const maxmodelheadersize = 1 << 26
+
+var modelfilemagic = [8]byte{'G', 'O', 'C', 'U', 'N', 'E', 'T', 'S'}
+
+//TensorInfo describes a tensor that is held in the payload of a model file
+type TensorInfo struct {
+	Name     string  `json:"name"`
+	Format   string  `json:"format"`
+	Datatype string  `json:"datatype"`
+	Dims     []int32 `json:"dims"`
+	Offset   int64   `json:"offset"`
+	Length   int64   `json:"length"`
+}
+
+//Params are the saved tensors of a layer.  Trainers are only written in checkpoints.
+type Params struct {
+	Layer    string        `json:"layer"`
+	Tensors  []TensorInfo  `json:"tensors,omitempty"`
+	Trainers []TrainerInfo `json:"trainers,omitempty"`
+}
+
+//ModuleInfo is the spec of a module and the params of each of its layers
+type ModuleInfo struct {
+	Spec   ModuleSpec `json:"spec"`
+	Params []Params   `json:"params,omitempty"`
+}
+
+//ModelHeader is the self describing part of a model file.
+//Files written by a module's SaveModel only use Version, Flags and Modules.
+//Loss is only set if the classifier has settings other than its type.
+//EMA is only set if the network keeps a moving average of its hidden values.
+//Preprocess is only set if the network was given a Normalizer for its inputs.
+//Checkpoint and Counter are only set in files written by WriteCheckpoint.
+type ModelHeader struct {
+	Version    uint32                 `json:"version"`
+	Flags      BuilderFlags           `json:"flags"`
+	ID         int64                  `json:"id,omitempty"`
+	Rate       float32                `json:"rate,omitempty"`
+	Decay1     float32                `json:"decay1,omitempty"`
+	Decay2     float32                `json:"decay2,omitempty"`
+	InputDims  []int32                `json:"input_dims,omitempty"`
+	Modules    []ModuleInfo           `json:"modules,omitempty"`
+	Output     *ModuleInfo            `json:"output,omitempty"`
+	Classifier string                 `json:"classifier,omitempty"`
+	Loss       *LossParams            `json:"loss,omitempty"`
+	EMA        *EMAInfo               `json:"ema,omitempty"`
+	Preprocess *preprocess.Normalizer `json:"preprocess,omitempty"`
+	Checkpoint bool                   `json:"checkpoint,omitempty"`
+	Counter    int                    `json:"counter,omitempty"`
+}
+
+//savablemodule is a module that can be written to a model file
+type savablemodule interface {
+	Spec() ModuleSpec
+	savedlayers() []*Layer
+}
+
+func (m *VanillaModule) savedlayers() []*Layer { return []*Layer{m.conv, m.act} }
+func (m *OutputModule) savedlayers() []*Layer  { return []*Layer{m.op} }
+func (m *module) savedlayers() []*Layer {
+	layers := make([]*Layer, len(m.layers), len(m.layers)+1)
+	copy(layers, m.layers)
+	return append(layers, m.activ)
+}
+
+//moduleinfo makes the ModuleInfo for mod with its tensors placed in the payload starting at offset.
+//It returns the tensors in the order they need to be written and the offset after them.
+//If withtrainers is true the state of the trainers of each layer is placed after the layer's tensors.
+func moduleinfo(mod Module, offset int64, withtrainers bool) (info ModuleInfo, ts []savedtensor, next int64, err error) {
+	smod, ok := mod.(savablemodule)
+	if !ok {
+		return info, nil, offset, fmt.Errorf("moduleinfo: module %d (%T) can't be saved", mod.ID(), mod)
+	}
+	info.Spec = smod.Spec()
+	if info.Spec.Type == "" {
+		return info, nil, offset, fmt.Errorf("moduleinfo: module %d wasn't made with a Create function", mod.ID())
+	}
+	for _, l := range smod.savedlayers() {
+		lts, err := l.savedtensors()
+		if err != nil {
+			return info, nil, offset, err
+		}
+		p := Params{Layer: l.layername()}
+		for _, t := range lts {
+			tinfo, err := t.info(offset)
+			if err != nil {
+				return info, nil, offset, err
+			}
+			offset += tinfo.Length
+			p.Tensors = append(p.Tensors, tinfo)
+		}
+		ts = append(ts, lts...)
+		if withtrainers {
+			p.Trainers, lts, offset, err = l.trainerinfos(offset)
+			if err != nil {
+				return info, nil, offset, err
+			}
+			ts = append(ts, lts...)
+		}
+		info.Params = append(info.Params, p)
+	}
+	return info, ts, offset, nil
+}
+
+//loadmodule loads the hidden values in payload into mod.  mod needs to have the same spec as info.
+//If withtrainers is true the state of the trainers is loaded too.
+func loadmodule(handle *cudnn.Handler, mod Module, info ModuleInfo, payload []byte, withtrainers bool) error {
+	smod, ok := mod.(savablemodule)
+	if !ok {
+		return fmt.Errorf("loadmodule: module %d (%T) can't be loaded", mod.ID(), mod)
+	}
+	if !info.Spec.equal(smod.Spec()) {
+		return fmt.Errorf("loadmodule: module %d spec doesn't match the saved %s spec", mod.ID(), info.Spec.Type)
+	}
+	layers := smod.savedlayers()
+	if len(layers) != len(info.Params) {
+		return fmt.Errorf("loadmodule: module %d has %d layers but %d were saved", mod.ID(), len(layers), len(info.Params))
+	}
+	for i, l := range layers {
+		err := l.loadparams(handle, info.Params[i], payload)
+		if err != nil {
+			return fmt.Errorf("loadmodule: module %d layer %d: %v", mod.ID(), i, err)
+		}
+		if !withtrainers {
+			continue
+		}
+		err = l.loadtrainers(handle, info.Params[i], payload)
+		if err != nil {
+			return fmt.Errorf("loadmodule: module %d layer %d: %v", mod.ID(), i, err)
+		}
+	}
+	return nil
+}
+
+func (s ModuleSpec) equal(o ModuleSpec) bool {
+	sj, err := json.Marshal(s)
+	if err != nil {
+		return false
+	}
+	oj, err := json.Marshal(o)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(sj, oj)
+}
+
+//writemodel writes the header and then the values of ts to w.
+func writemodel(w io.Writer, handle *cudnn.Handler, header *ModelHeader, ts []savedtensor) error {
+	hdr, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if _, err = bw.Write(modelfilemagic[:]); err != nil {
+		return err
+	}
+	if err = binary.Write(bw, binary.LittleEndian, header.Version); err != nil {
+		return err
+	}
+	if err = binary.Write(bw, binary.LittleEndian, uint32(len(hdr))); err != nil {
+		return err
+	}
+	if _, err = bw.Write(hdr); err != nil {
+		return err
+	}
+	for _, t := range ts {
+		data := make([]byte, t.mem.SIB())
+		err = t.mem.FillSlice(handle, data)
+		if err != nil {
+			return err
+		}
+		if _, err = bw.Write(data); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+//ReadModelHeader reads the header of a model file.  r is left at the start of the payload.
+func ReadModelHeader(r io.Reader) (*ModelHeader, error) {
+	var magic [8]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != modelfilemagic {
+		return nil, errors.New("ReadModelHeader: not a gocunets model file")
+	}
+	var version, size uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version == 0 || version > ModelFileVersion {
+		return nil, fmt.Errorf("ReadModelHeader: unsupported model file version %d", version)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > maxmodelheadersize {
+		return nil, fmt.Errorf("ReadModelHeader: header size %d is too large", size)
+	}
+	hdr := make([]byte, size)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	header := new(ModelHeader)
+	err := json.Unmarshal(hdr, header)
+	if err != nil {
+		return nil, err
+	}
+	if header.Version != version {
+		return nil, errors.New("ReadModelHeader: header version doesn't match file version")
+	}
+	return header, nil
+}
+
+func readmodel(r io.Reader) (*ModelHeader, []byte, error) {
+	header, err := ReadModelHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, payload, nil
+}
+
+//savemodule writes a model file that only holds mod
+func savemodule(w io.Writer, b *Builder, mod Module) error {
+	flags, err := b.Flags()
+	if err != nil {
+		return err
+	}
+	info, ts, _, err := moduleinfo(mod, 0, false)
+	if err != nil {
+		return err
+	}
+	header := &ModelHeader{
+		Version: ModelFileVersion,
+		Flags:   flags,
+		Modules: []ModuleInfo{info},
+	}
+	return writemodel(w, b.h.Handler, header, ts)
+}
+
+//readmodule loads the hidden values of a model file written by savemodule into mod
+func readmodule(r io.Reader, b *Builder, mod Module) error {
+	header, payload, err := readmodel(r)
+	if err != nil {
+		return err
+	}
+	if len(header.Modules) != 1 || header.Output != nil {
+		return errors.New("LoadModel: file doesn't hold a single module")
+	}
+	return loadmodule(b.h.Handler, mod, header.Modules[0], payload, false)
+}
+
+//SaveModel writes the spec and the hidden values of the module to w.
+func (m *VanillaModule) SaveModel(w io.Writer) error { return savemodule(w, m.b, m) }
+
+//LoadModel loads the hidden values saved with SaveModel.  The module needs to have been created with the same parameters
+//and InitHiddenLayers needs to have been ran.
+func (m *VanillaModule) LoadModel(r io.Reader) error { return readmodule(r, m.b, m) }
+
+//SaveModel writes the spec and the hidden values of the module to w.
+func (m *OutputModule) SaveModel(w io.Writer) error { return savemodule(w, m.b, m) }
+
+//LoadModel loads the hidden values saved with SaveModel.  The module needs to have been created with the same parameters
+//and InitHiddenLayers needs to have been ran.
+func (m *OutputModule) LoadModel(r io.Reader) error { return readmodule(r, m.b, m) }
+
+//SaveModel writes the spec and the hidden values of the module to w.
+func (m *module) SaveModel(w io.Writer) error { return savemodule(w, m.b, m) }
+
+//LoadModel loads the hidden values saved with SaveModel.  The module needs to have been created with the same parameters
+//and InitHiddenLayers needs to have been ran.
+func (m *module) LoadModel(r io.Reader) error { return readmodule(r, m.b, m) }
+
+//SaveModel writes the architecture, the Builder flags and all the hidden values of the network to w.
 //
-//	if err != nil {
-//		fmt.Println("Written amount and size of data", written, len(val.Data))
-//	}
-//	return err
-//}
-//func stringtoformat(frmt string) (gocudnn.TensorFormat, error) {
-//	var flgs gocudnn.TensorFormat
-//	frmt = strings.ToUpper(frmt)
-//	switch frmt {
-//	case "NCHW":
-//		return flgs.NCHW(), nil
-//	case "NHWC":
-//		return flgs.NHWC(), nil
-//	case "NCHWVECTC":
-//		return flgs.NCHWvectC(), nil
-//	}
-//	return gocudnn.TensorFormat(999999), errors.New("Unsupported string name")
-//}
-//func formattostring(frmt gocudnn.TensorFormat) (string, error) {
-//	var flgs gocudnn.TensorFormat
-//	switch frmt {
-//	case flgs.NCHW():
-//		return "NCHW", nil
-//	case flgs.NHWC():
-//		return "NHWC", nil
-//	case flgs.NCHWvectC():
-//		return "NCHWvectC", nil
-//	}
-//	return "Unsupported", errors.New("Unsupported Tensor Format")
-//}
+//The tensor x of the network and the OutputModule need to be set.  The type and settings of the classifier are recorded.
+//A classifier made with CreateCustomLossLayer can't be recorded so it is an error.
+//Trainer states are not saved.  Use WriteCheckpoint for that.
+func (m *SimpleModuleNetwork) SaveModel(w io.Writer) error {
+	header, ts, err := m.modelheader(false)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) SaveModel: %v", err)
+	}
+	return writemodel(w, m.b.h.Handler, header, ts)
+}
+
+//modelheader makes the header of the network and returns it with the tensors that go into the payload.
+func (m *SimpleModuleNetwork) modelheader(withtrainers bool) (header *ModelHeader, ts []savedtensor, err error) {
+	if err = m.b.gpuonly("saving"); err != nil {
+		return nil, nil, err
+	}
+	if len(m.Modules) == 0 || m.Output == nil {
+		return nil, nil, errors.New("Modules and Output need to be set")
+	}
+	x := m.GetTensorX()
+	if x == nil {
+		return nil, nil, errors.New("TensorX hasn't been set")
+	}
+	flags, err := m.b.Flags()
+	if err != nil {
+		return nil, nil, err
+	}
+	header = &ModelHeader{
+		Version:   ModelFileVersion,
+		Flags:     flags,
+		ID:        m.Id,
+		Rate:      m.Rate,
+		Decay1:    m.Decay1,
+		Decay2:    m.Decay2,
+		InputDims: x.Dims(),
+	}
+	var offset int64
+	for _, mod := range m.Modules {
+		info, mts, next, err := moduleinfo(mod, offset, withtrainers)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Modules = append(header.Modules, info)
+		ts = append(ts, mts...)
+		offset = next
+	}
+	info, mts, offset, err := moduleinfo(m.Output, offset, withtrainers)
+	if err != nil {
+		return nil, nil, err
+	}
+	header.Output = &info
+	ts = append(ts, mts...)
+	if withtrainers && m.EMASwapped() {
+		return nil, nil, errors.New("the moving average is swapped in")
+	}
+	header.EMA, mts, err = m.emainfo(offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts = append(ts, mts...)
+	header.Preprocess = m.preprocess
+	if m.Classifier != nil {
+		header.Classifier, header.Loss, err = m.Classifier.lossinfo()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return header, ts, nil
+}
+
+//lossinfo returns the name of the classifier's loss and its settings.  params is nil if the loss doesn't have any settings.
+func (m *ClassifierModule) lossinfo() (classifier string, params *LossParams, err error) {
+	switch l := m.l.(type) {
+	case *loss.SoftMax:
+		if l.LabelSmoothing() != 0 {
+			params = &LossParams{LabelSmoothing: l.LabelSmoothing()}
+		}
+		return "SoftMax", params, nil
+	case *loss.MSE2:
+		return "MSE", nil, nil
+	case *loss.HuberLoss:
+		return "Huber", &LossParams{Delta: l.Delta()}, nil
+	case *loss.BinaryCrossEntropy:
+		return "BCE", nil, nil
+	case *loss.FocalLoss:
+		return "Focal", &LossParams{Gamma: l.Gamma(), Weights: l.Weights()}, nil
+	case *loss.WeightedSoftMax:
+		return "WeightedSoftMax", &LossParams{Weights: l.Weights()}, nil
+	}
+	return "", nil, fmt.Errorf("classifier %d has a loss (%T) that can't be saved", m.id, m.l)
+}
+
+//LoadModel loads a model file written by (m *SimpleModuleNetwork) SaveModel.
 //
-//func tofloat32(input interface{}) []float32 {
-//	return utils.ToFloat32Slice(input)
-//}
-//func tofloat64(input interface{}) []float64 {
-//	return utils.ToFLoat64Slice(input)
-//}
+//If m has no modules the network is built from the file.  The flags of the Builder m was created with are set to the ones in the file,
+//TensorX is created, and the classifier is set if one was saved.
+//FindOutputDims, InitHiddenLayers and InitWorkspace are ran before the hidden values are loaded so the network is ready to use.
 //
+//If m already has modules they need to have been created with the same parameters as the ones in the file
+//and InitHiddenLayers needs to have been ran.  Only the hidden values are loaded.
+func (m *SimpleModuleNetwork) LoadModel(r io.Reader) error {
+	header, payload, err := readmodel(r)
+	if err != nil {
+		return err
+	}
+	err = m.load(header, payload, false)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) LoadModel: %v", err)
+	}
+	return nil
+}
+
+//load builds the network from header if m has no modules and then loads the payload into it.
+func (m *SimpleModuleNetwork) load(header *ModelHeader, payload []byte, withtrainers bool) (err error) {
+	if err = m.b.gpuonly("loading"); err != nil {
+		return err
+	}
+	if len(header.Modules) == 0 || header.Output == nil {
+		return errors.New("file doesn't hold a SimpleModuleNetwork")
+	}
+	if m.EMASwapped() {
+		return errors.New("the moving average is swapped in")
+	}
+	if len(m.Modules) == 0 {
+		err = m.buildfromheader(header)
+		if err != nil {
+			return err
+		}
+	}
+	if len(m.Modules) != len(header.Modules) {
+		return fmt.Errorf("network has %d modules but %d were saved", len(m.Modules), len(header.Modules))
+	}
+	if m.Output == nil {
+		return errors.New("Output hasn't been set")
+	}
+	for i, mod := range m.Modules {
+		err = loadmodule(m.b.h.Handler, mod, header.Modules[i], payload, withtrainers)
+		if err != nil {
+			return fmt.Errorf("index %d: %v", i, err)
+		}
+	}
+	err = loadmodule(m.b.h.Handler, m.Output, *header.Output, payload, withtrainers)
+	if err != nil {
+		return fmt.Errorf("m.Output: %v", err)
+	}
+	err = m.loadema(header.EMA, payload)
+	if err != nil {
+		return fmt.Errorf("moving average: %v", err)
+	}
+	if header.Preprocess != nil {
+		m.preprocess = header.Preprocess
+	}
+	return nil
+}
+
+//buildfromheader builds the modules in header and inits them.
+func (m *SimpleModuleNetwork) buildfromheader(header *ModelHeader) (err error) {
+	if len(header.InputDims) == 0 {
+		return errors.New("file doesn't have the input dims")
+	}
+	spec := &NetworkSpec{
+		ID:         header.ID,
+		Flags:      header.Flags,
+		InputDims:  header.InputDims,
+		Modules:    make([]ModuleSpec, len(header.Modules)),
+		Output:     header.Output.Spec,
+		Classifier: header.Classifier,
+		Loss:       header.Loss,
+		Rate:       header.Rate,
+		Decay1:     header.Decay1,
+		Decay2:     header.Decay2,
+	}
+	for i := range header.Modules {
+		spec.Modules[i] = header.Modules[i].Spec
+	}
+	return m.buildfromspec(spec)
+}
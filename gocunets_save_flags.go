@@ -0,0 +1,311 @@
+package gocunets
+
+import (
+	"errors"
+	"strings"
+)
+
+//BuilderFlags are the flags of a Builder written as strings so that a model file doesn't depend on the values of the cudnn enums.
+type BuilderFlags struct {
+	Frmt   string `json:"frmt"`
+	Dtype  string `json:"dtype"`
+	Cmode  string `json:"cmode"`
+	Mtype  string `json:"mtype"`
+	Pmode  string `json:"pmode"`
+	AMode  string `json:"amode"`
+	BNMode string `json:"bnmode"`
+	Nan    string `json:"nan"`
+}
+
+//Flags returns the flags of the builder as strings
+func (l *Builder) Flags() (f BuilderFlags, err error) {
+	if f.Frmt, err = formattostring(l.Frmt); err != nil {
+		return f, err
+	}
+	if f.Dtype, err = datatypetostring(l.Dtype); err != nil {
+		return f, err
+	}
+	if f.Cmode, err = convolutionmodetostring(l.Cmode); err != nil {
+		return f, err
+	}
+	if f.Mtype, err = mathtypetostring(l.Mtype); err != nil {
+		return f, err
+	}
+	if f.Pmode, err = poolingmodetostring(l.Pmode); err != nil {
+		return f, err
+	}
+	if f.AMode, err = activationmodetostring(l.AMode); err != nil {
+		return f, err
+	}
+	if f.BNMode, err = batchnormmodetostring(l.BNMode); err != nil {
+		return f, err
+	}
+	f.Nan, err = nanproptostring(l.Nan)
+	return f, err
+}
+
+//SetFlags sets the flags of the builder to the ones in f. Nothing is changed if an error is returned.
+func (l *Builder) SetFlags(f BuilderFlags) (err error) {
+	frmt, err := stringtoformat(f.Frmt)
+	if err != nil {
+		return err
+	}
+	dtype, err := stringtodatatype(f.Dtype)
+	if err != nil {
+		return err
+	}
+	cmode, err := stringtoconvolutionmode(f.Cmode)
+	if err != nil {
+		return err
+	}
+	mtype, err := stringtomathtype(f.Mtype)
+	if err != nil {
+		return err
+	}
+	pmode, err := stringtopoolingmode(f.Pmode)
+	if err != nil {
+		return err
+	}
+	amode, err := stringtoactivationmode(f.AMode)
+	if err != nil {
+		return err
+	}
+	bnmode, err := stringtobatchnormmode(f.BNMode)
+	if err != nil {
+		return err
+	}
+	nan, err := stringtonanprop(f.Nan)
+	if err != nil {
+		return err
+	}
+	l.Frmt, l.Dtype = frmt, dtype
+	l.Cmode, l.Mtype, l.Pmode, l.AMode, l.BNMode, l.Nan = cmode, mtype, pmode, amode, bnmode, nan
+	return nil
+}
+
+func formattostring(frmt TensorFormat) (string, error) {
+	var flgs TensorFormat
+	switch frmt {
+	case flgs.NCHW():
+		return "NCHW", nil
+	case flgs.NHWC():
+		return "NHWC", nil
+	case flgs.NCHWvectC():
+		return "NCHWvectC", nil
+	}
+	return "Unsupported", errors.New("Unsupported Tensor Format")
+}
+func stringtoformat(frmt string) (TensorFormat, error) {
+	var flgs TensorFormat
+	switch strings.ToUpper(frmt) {
+	case "NCHW":
+		return flgs.NCHW(), nil
+	case "NHWC":
+		return flgs.NHWC(), nil
+	case "NCHWVECTC":
+		return flgs.NCHWvectC(), nil
+	}
+	return flgs, errors.New("Unsupported Tensor Format string: " + frmt)
+}
+func datatypetostring(dtype DataType) (string, error) {
+	var flg DataType
+	switch dtype {
+	case flg.Double():
+		return "Double", nil
+	case flg.Float():
+		return "Float", nil
+	case flg.Half():
+		return "Half", nil
+	case flg.Int32():
+		return "Int32", nil
+	case flg.Int8():
+		return "Int8", nil
+	case flg.UInt8():
+		return "UInt8", nil
+	}
+	return "Unsupported", errors.New("Unsupported Datatype")
+}
+func stringtodatatype(dtype string) (DataType, error) {
+	var flg DataType
+	switch strings.ToUpper(dtype) {
+	case "DOUBLE":
+		return flg.Double(), nil
+	case "FLOAT":
+		return flg.Float(), nil
+	case "HALF":
+		return flg.Half(), nil
+	case "INT32":
+		return flg.Int32(), nil
+	case "INT8":
+		return flg.Int8(), nil
+	case "UINT8":
+		return flg.UInt8(), nil
+	}
+	return flg, errors.New("Unsupported Datatype string: " + dtype)
+}
+func convolutionmodetostring(c ConvolutionMode) (string, error) {
+	var flg ConvolutionMode
+	switch c {
+	case flg.Convolution():
+		return "Convolution", nil
+	case flg.CrossCorrelation():
+		return "CrossCorrelation", nil
+	}
+	return "Unsupported", errors.New("Unsupported ConvolutionMode")
+}
+func stringtoconvolutionmode(c string) (ConvolutionMode, error) {
+	var flg ConvolutionMode
+	switch strings.ToUpper(c) {
+	case "CONVOLUTION":
+		return flg.Convolution(), nil
+	case "CROSSCORRELATION":
+		return flg.CrossCorrelation(), nil
+	}
+	return flg, errors.New("Unsupported ConvolutionMode string: " + c)
+}
+func mathtypetostring(m MathType) (string, error) {
+	var flg MathType
+	switch m {
+	case flg.Default():
+		return "Default", nil
+	case flg.TensorOpMath():
+		return "TensorOpMath", nil
+	case flg.AllowConversion():
+		return "AllowConversion", nil
+	}
+	return "Unsupported", errors.New("Unsupported MathType")
+}
+func stringtomathtype(m string) (MathType, error) {
+	var flg MathType
+	switch strings.ToUpper(m) {
+	case "DEFAULT":
+		return flg.Default(), nil
+	case "TENSOROPMATH":
+		return flg.TensorOpMath(), nil
+	case "ALLOWCONVERSION":
+		return flg.AllowConversion(), nil
+	}
+	return flg, errors.New("Unsupported MathType string: " + m)
+}
+func poolingmodetostring(p PoolingMode) (string, error) {
+	var flg PoolingMode
+	switch p {
+	case flg.Max():
+		return "Max", nil
+	case flg.MaxDeterministic():
+		return "MaxDeterministic", nil
+	case flg.AverageCountIncludePadding():
+		return "AverageCountIncludePadding", nil
+	case flg.AverageCountExcludePadding():
+		return "AverageCountExcludePadding", nil
+	}
+	return "Unsupported", errors.New("Unsupported PoolingMode")
+}
+func stringtopoolingmode(p string) (PoolingMode, error) {
+	var flg PoolingMode
+	switch strings.ToUpper(p) {
+	case "MAX":
+		return flg.Max(), nil
+	case "MAXDETERMINISTIC":
+		return flg.MaxDeterministic(), nil
+	case "AVERAGECOUNTINCLUDEPADDING":
+		return flg.AverageCountIncludePadding(), nil
+	case "AVERAGECOUNTEXCLUDEPADDING":
+		return flg.AverageCountExcludePadding(), nil
+	}
+	return flg, errors.New("Unsupported PoolingMode string: " + p)
+}
+
+//activationmodetostring checks the flags in the same order as (l *Builder) Activation
+func activationmodetostring(a ActivationMode) (string, error) {
+	var flg ActivationMode
+	switch a {
+	case flg.Leaky():
+		return "Leaky", nil
+	case flg.ClippedRelu():
+		return "ClippedRelu", nil
+	case flg.Relu():
+		return "Relu", nil
+	case flg.Elu():
+		return "Elu", nil
+	case flg.Threshhold():
+		return "Threshhold", nil
+	case flg.Sigmoid():
+		return "Sigmoid", nil
+	case flg.Tanh():
+		return "Tanh", nil
+	case flg.PRelu():
+		return "PRelu", nil
+	case flg.Identity():
+		return "Identity", nil
+	}
+	return "Unsupported", errors.New("Unsupported ActivationMode")
+}
+func stringtoactivationmode(a string) (ActivationMode, error) {
+	var flg ActivationMode
+	switch strings.ToUpper(a) {
+	case "LEAKY":
+		return flg.Leaky(), nil
+	case "CLIPPEDRELU":
+		return flg.ClippedRelu(), nil
+	case "RELU":
+		return flg.Relu(), nil
+	case "ELU":
+		return flg.Elu(), nil
+	case "THRESHHOLD":
+		return flg.Threshhold(), nil
+	case "SIGMOID":
+		return flg.Sigmoid(), nil
+	case "TANH":
+		return flg.Tanh(), nil
+	case "PRELU":
+		return flg.PRelu(), nil
+	case "IDENTITY":
+		return flg.Identity(), nil
+	}
+	return flg, errors.New("Unsupported ActivationMode string: " + a)
+}
+func batchnormmodetostring(b BatchNormMode) (string, error) {
+	var flg BatchNormMode
+	switch b {
+	case flg.PerActivation():
+		return "PerActivation", nil
+	case flg.Spatial():
+		return "Spatial", nil
+	case flg.SpatialPersistent():
+		return "SpatialPersistent", nil
+	}
+	return "Unsupported", errors.New("Unsupported BatchNormMode")
+}
+func stringtobatchnormmode(b string) (BatchNormMode, error) {
+	var flg BatchNormMode
+	switch strings.ToUpper(b) {
+	case "PERACTIVATION":
+		return flg.PerActivation(), nil
+	case "SPATIAL":
+		return flg.Spatial(), nil
+	case "SPATIALPERSISTENT":
+		return flg.SpatialPersistent(), nil
+	}
+	return flg, errors.New("Unsupported BatchNormMode string: " + b)
+}
+func nanproptostring(n NanProp) (string, error) {
+	var flg NanProp
+	switch n {
+	case flg.Propigate():
+		return "Propigate", nil
+	case flg.NotPropigate():
+		return "NotPropigate", nil
+	}
+	return "Unsupported", errors.New("Unsupported NanProp")
+}
+func stringtonanprop(n string) (NanProp, error) {
+	var flg NanProp
+	switch strings.ToUpper(n) {
+	case "PROPIGATE":
+		return flg.Propigate(), nil
+	case "NOTPROPIGATE":
+		return flg.NotPropigate(), nil
+	}
+	return flg, errors.New("Unsupported NanProp string: " + n)
+}
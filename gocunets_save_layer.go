@@ -1,170 +1,320 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
-//import (
-//	"errors"
-//	"strings"
-//
-//	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
-//)
-//
-//func (l *Layer) loadparams(handle *cudnn.Handler, params *Params) error {
-//	var err error
-//	if l.cnn != nil {
-//		params.Layer = strings.ToUpper(params.Layer)
-//		if params.Layer != "CNN" {
-//			return errors.New("not a " + params.Layer + " layer")
-//		}
-//		err = params.Weight.LoadTensor(handle, l.cnn.Weights())
-//		if err != nil {
-//			return err
-//		}
-//		return params.Bias.LoadTensor(handle, l.cnn.Bias())
-//
-//	}
-//	if l.batch != nil {
-//		params.Layer = strings.ToUpper(params.Layer)
-//		if params.Layer != "BATCH" {
-//			return errors.New("not a " + params.Layer + " layer")
-//		}
-//		err = params.Weight.LoadTensor(handle, l.batch.Scale())
-//		if err != nil {
-//			return err
-//		}
-//		return params.Bias.LoadTensor(handle, l.batch.Bias())
-//	}
-//	if l.cnntranspose != nil {
-//
-//		params.Layer = strings.ToUpper(params.Layer)
-//		if params.Layer != "CNNTRANSPOSE" {
-//			return errors.New("not a " + params.Layer + " layer")
-//		}
-//		err = params.Weight.LoadTensor(handle, l.cnntranspose.Weights())
-//		if err != nil {
-//			return err
-//		}
-//		return params.Bias.LoadTensor(handle, l.cnntranspose.Bias())
-//	}
-//	if l.activation != nil {
-//
-//		params.Layer = strings.ToUpper(params.Layer)
-//		if params.Layer != "ACTIVATION" {
-//			return errors.New("not a " + params.Layer + " layer")
-//		}
-//		if l.activation.NegCoefs() == nil {
-//			return errors.New("Activation doesn't have weights")
-//		}
-//		err = params.Weight.LoadTensor(handle, l.activation.NegCoefs())
-//		if err != nil {
-//			return err
-//		}
-//		if l.activation.PosCoefs() == nil {
-//			return nil
-//		}
-//		err = params.Bias.LoadTensor(handle, l.activation.PosCoefs())
-//		if err != nil {
-//			return err
-//		}
-//		err = params.Xtra.LoadTensor(handle, l.activation.Threshhold())
-//		if err != nil {
-//			return err
-//		}
-//	}
-//
-//	return nil
-//}
-//func (l *Layer) hasweights() bool {
-//	if l.cnn != nil {
-//		return true
-//	}
-//	if l.batch != nil {
-//		return true
-//	}
-//	if l.cnntranspose != nil {
-//		return true
-//	}
-//	if l.activation != nil {
-//		if l.activation.TrainersNeeded() > 0 {
-//			return true
-//		}
-//
-//	}
-//	return false
-//}
-//func (l *Layer) params() (*Params, error) {
-//	if l.cnn != nil {
-//		weights, err := getTensor(l.cnn.Weights(), l.s)
-//		if err != nil {
-//			return nil, err
-//		}
-//		bias, err := getTensor(l.cnn.Bias(), l.s)
-//		if err != nil {
-//			return nil, err
-//		}
-//		return &Params{
-//			Layer:  "CNN",
-//			Weight: weights,
-//			Bias:   bias,
-//		}, nil
-//	}
-//	if l.batch != nil {
-//		weights, err := getTensor(l.batch.Scale(), l.s)
-//		if err != nil {
-//			return nil, err
-//		}
-//		bias, err := getTensor(l.batch.Bias(), l.s)
-//		if err != nil {
-//			return nil, err
-//		}
-//		return &Params{
-//			Layer:  "BATCH",
-//			Weight: weights,
-//			Bias:   bias,
-//		}, nil
-//	}
-//	if l.cnntranspose != nil {
-//		weights, err := getTensor(l.cnntranspose.Weights(), l.s)
-//		if err != nil {
-//			return nil, err
-//		}
-//		bias, err := getTensor(l.cnntranspose.Bias(), l.s)
-//		if err != nil {
-//			return nil, err
-//		}
-//		return &Params{
-//			Layer:  "CNNTRANSPOSE",
-//			Weight: weights,
-//			Bias:   bias,
-//		}, nil
-//	}
-//	if l.activation != nil {
-//		if l.activation.TrainersNeeded() > 0 {
-//
-//			negcoef, err := getTensor(l.activation.NegCoefs(), l.s)
-//			if err != nil {
-//				return nil, err
-//			}
-//			if l.activation.PosCoefs() == nil {
-//				return &Params{
-//					Layer:  "ACTIVATION",
-//					Weight: negcoef,
-//				}, nil
-//			}
-//			thresh, err := getTensor(l.activation.Threshhold(), l.s)
-//			if err != nil {
-//				return nil, err
-//			}
-//			poscoef, err := getTensor(l.activation.PosCoefs(), l.s)
-//			if err != nil {
-//				return nil, err
-//			}
-//			return &Params{
-//				Layer:  "ACTIVATION",
-//				Weight: negcoef,
-//				Bias:   poscoef,
-//				Xtra:   thresh,
-//			}, nil
-//		}
-//	}
-//	return nil, nil
-//}
-//
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+	"github.com/dereklstinson/gocunets/trainer"
+	gocudnn "github.com/dereklstinson/gocudnn"
+)
+
+//savedtensor is a piece of device memory of a layer that is written into a model file
+type savedtensor struct {
+	name  string
+	frmt  gocudnn.TensorFormat
+	dtype gocudnn.DataType
+	dims  []int32
+	mem   *nvidia.Malloced
+}
+
+func savedfromtensor(name string, t *layers.Tensor) savedtensor {
+	return savedtensor{
+		name:  name,
+		frmt:  t.Format(),
+		dtype: t.DataType(),
+		dims:  t.Dims(),
+		mem:   t.Malloced,
+	}
+}
+
+//info makes the TensorInfo for the saved tensor. offset is where it will be placed in the payload.
+func (s savedtensor) info(offset int64) (TensorInfo, error) {
+	frmt, err := formattostring(TensorFormat{s.frmt})
+	if err != nil {
+		return TensorInfo{}, err
+	}
+	dtype, err := datatypetostring(DataType{s.dtype})
+	if err != nil {
+		return TensorInfo{}, err
+	}
+	return TensorInfo{
+		Name:     s.name,
+		Format:   frmt,
+		Datatype: dtype,
+		Dims:     s.dims,
+		Offset:   offset,
+		Length:   int64(s.mem.SIB()),
+	}, nil
+}
+
+//layername returns the name used for the layer in a model file
+func (l *Layer) layername() string {
+	switch {
+	case l.cnn != nil:
+		return "CNN"
+	case l.cnntranspose != nil:
+		return "CNNTRANSPOSE"
+	case l.batch != nil:
+		return "BATCH"
+	case l.activation != nil:
+		return "ACTIVATION"
+	case l.pool != nil:
+		return "POOLING"
+	case l.drop != nil:
+		return "DROPOUT"
+	case l.reshape != nil:
+		return "RESHAPE"
+	}
+	return "OTHER"
+}
+
+//savedtensors returns the hidden values of the layer that are needed to rebuild it.
+//The order of the tensors is the order they are placed in the payload.
+func (l *Layer) savedtensors() (ts []savedtensor, err error) {
+	switch {
+	case l.cnn != nil:
+		return []savedtensor{
+			savedfromtensor("weights", l.cnn.Weights()),
+			savedfromtensor("bias", l.cnn.Bias()),
+		}, nil
+	case l.cnntranspose != nil:
+		return []savedtensor{
+			savedfromtensor("weights", l.cnntranspose.Weights()),
+			savedfromtensor("bias", l.cnntranspose.Bias()),
+		}, nil
+	case l.batch != nil:
+		scale := l.batch.Scale()
+		if scale == nil {
+			return nil, errors.New("(l *Layer) savedtensors: batch norm hasn't been setup")
+		}
+		ts = []savedtensor{
+			savedfromtensor("scale", scale),
+			savedfromtensor("bias", l.batch.Bias()),
+		}
+		mean := savedfromtensor("running_mean", scale)
+		mean.mem = l.batch.RunningMean()
+		variance := savedfromtensor("running_variance", scale)
+		variance.mem = l.batch.RunningVariance()
+		return append(ts, mean, variance), nil
+	case l.activation != nil:
+		if l.activation.NegCoefs() != nil {
+			ts = append(ts, savedfromtensor("negcoefs", l.activation.NegCoefs()))
+		}
+		if l.activation.PosCoefs() != nil {
+			ts = append(ts, savedfromtensor("poscoefs", l.activation.PosCoefs()))
+		}
+		if l.activation.Threshhold() != nil {
+			ts = append(ts, savedfromtensor("threshold", l.activation.Threshhold()))
+		}
+		return ts, nil
+	}
+	return nil, nil
+}
+
+//loadparams loads the tensors described in p from payload into the layer.
+func (l *Layer) loadparams(handle *cudnn.Handler, p Params, payload []byte) error {
+	if p.Layer != l.layername() {
+		return fmt.Errorf("(l *Layer) loadparams: saved layer is %s but layer is %s", p.Layer, l.layername())
+	}
+	ts, err := l.savedtensors()
+	if err != nil {
+		return err
+	}
+	if len(ts) != len(p.Tensors) {
+		return fmt.Errorf("(l *Layer) loadparams: %s layer has %d tensors but %d were saved", p.Layer, len(ts), len(p.Tensors))
+	}
+	for i, t := range ts {
+		saved := p.Tensors[i]
+		if saved.Name != t.name {
+			return fmt.Errorf("(l *Layer) loadparams: expected tensor %s got %s", t.name, saved.Name)
+		}
+		dtype, err := stringtodatatype(saved.Datatype)
+		if err != nil {
+			return err
+		}
+		if dtype.DataType != t.dtype {
+			return fmt.Errorf("(l *Layer) loadparams: %s datatype not the same", t.name)
+		}
+		err = loadsaved(handle, t.mem, saved, payload)
+		if err != nil {
+			return fmt.Errorf("(l *Layer) loadparams: %v", err)
+		}
+	}
+	return nil
+}
+
+//loadsaved copies the bytes of saved in the payload into mem
+func loadsaved(handle *cudnn.Handler, mem *nvidia.Malloced, saved TensorInfo, payload []byte) error {
+	if mem == nil {
+		return fmt.Errorf("%s memory hasn't been allocated", saved.Name)
+	}
+	if saved.Length != int64(mem.SIB()) {
+		return fmt.Errorf("%s size in bytes %d doesn't match %d", saved.Name, saved.Length, mem.SIB())
+	}
+	if saved.Offset < 0 || saved.Offset+saved.Length > int64(len(payload)) {
+		return fmt.Errorf("%s is outside of the payload", saved.Name)
+	}
+	return mem.LoadSlice(handle, payload[saved.Offset:saved.Offset+saved.Length])
+}
+
+//savedtrainer is a trainer of a layer and the tensor that it trains
+type savedtrainer struct {
+	t trainer.Trainer
+	w savedtensor
+}
+
+//savedtrainers returns the trainers of the layer paired with the tensors from savedtensors that they train.
+func (l *Layer) savedtrainers() ([]savedtrainer, error) {
+	ts, err := l.savedtensors()
+	if err != nil {
+		return nil, err
+	}
+	var trainers []trainer.Trainer
+	switch {
+	case l.cnn != nil:
+		w, b := l.cnn.Trainers()
+		trainers = []trainer.Trainer{w, b}
+	case l.cnntranspose != nil:
+		w, b := l.cnntranspose.Trainers()
+		trainers = []trainer.Trainer{w, b}
+	case l.batch != nil:
+		s, b := l.batch.Trainers()
+		trainers = []trainer.Trainer{s, b}
+	case l.activation != nil:
+		trainers = l.activation.Trainers()
+		if len(trainers) > 0 && len(trainers) != len(ts) {
+			return nil, errors.New("(l *Layer) savedtrainers: activation doesn't have a trainer for each of its coefs")
+		}
+	}
+	if len(trainers) > len(ts) {
+		return nil, errors.New("(l *Layer) savedtrainers: more trainers than tensors")
+	}
+	st := make([]savedtrainer, len(trainers))
+	for i, t := range trainers {
+		if t == nil {
+			return nil, fmt.Errorf("(l *Layer) savedtrainers: %s trainer hasn't been loaded", ts[i].name)
+		}
+		st[i] = savedtrainer{t: t, w: ts[i]}
+	}
+	return st, nil
+}
+
+//trainermem returns the name of the trainer type and the memory it keeps between updates
+func trainermem(t trainer.Trainer) (typ string, names []string, mems []*nvidia.Malloced, err error) {
+	switch x := t.(type) {
+	case *trainer.Adam:
+		gsum, xsum := x.TrainingMem()
+		return "Adam", []string{"gsum", "xsum"}, []*nvidia.Malloced{gsum, xsum}, nil
+	case *trainer.Momentum:
+		return "Momentum", []string{"gsum"}, []*nvidia.Malloced{x.TrainingMem()}, nil
+	case *trainer.SGD:
+		names, mems = x.TrainingMem()
+		return "SGD", names, mems, nil
+	case *trainer.RMSProp:
+		names, mems = x.TrainingMem()
+		return "RMSProp", names, mems, nil
+	case *trainer.AdaGrad:
+		names, mems = x.TrainingMem()
+		return "AdaGrad", names, mems, nil
+	case *trainer.AdaDelta:
+		names, mems = x.TrainingMem()
+		return "AdaDelta", names, mems, nil
+	case *trainer.AdamW:
+		names, mems = x.TrainingMem()
+		return "AdamW", names, mems, nil
+	case *trainer.LAMB:
+		names, mems = x.TrainingMem()
+		return "LAMB", names, mems, nil
+	}
+	return "", nil, nil, fmt.Errorf("trainermem: unsupported trainer %T", t)
+}
+
+//settingsholder is a trainer that can give and take trainer.Settings
+type settingsholder interface {
+	Settings() trainer.Settings
+	LoadSettings(s trainer.Settings)
+}
+
+//trainerinfos makes the TrainerInfo for each trainer of the layer with the training mem placed starting at offset.
+func (l *Layer) trainerinfos(offset int64) (infos []TrainerInfo, ts []savedtensor, next int64, err error) {
+	sts, err := l.savedtrainers()
+	if err != nil {
+		return nil, nil, offset, err
+	}
+	for _, st := range sts {
+		typ, names, mems, err := trainermem(st.t)
+		if err != nil {
+			return nil, nil, offset, err
+		}
+		sh, ok := st.t.(settingsholder)
+		if !ok {
+			return nil, nil, offset, fmt.Errorf("(l *Layer) trainerinfos: %s trainer (%T) doesn't have settings that can be saved", st.w.name, st.t)
+		}
+		info := TrainerInfo{
+			Tensor:   st.w.name,
+			Type:     typ,
+			Settings: sh.Settings(),
+		}
+		for i := range mems {
+			if mems[i] == nil {
+				return nil, nil, offset, fmt.Errorf("(l *Layer) trainerinfos: %s trainer memory hasn't been allocated", st.w.name)
+			}
+			t := st.w
+			t.name, t.mem = names[i], mems[i]
+			tinfo, err := t.info(offset)
+			if err != nil {
+				return nil, nil, offset, err
+			}
+			offset += tinfo.Length
+			info.Tensors = append(info.Tensors, tinfo)
+			ts = append(ts, t)
+		}
+		infos = append(infos, info)
+	}
+	return infos, ts, offset, nil
+}
+
+//loadtrainers loads the trainer settings and memory saved in p into the trainers of the layer.
+func (l *Layer) loadtrainers(handle *cudnn.Handler, p Params, payload []byte) error {
+	sts, err := l.savedtrainers()
+	if err != nil {
+		return err
+	}
+	if len(sts) != len(p.Trainers) {
+		return fmt.Errorf("(l *Layer) loadtrainers: %s layer has %d trainers but %d were saved", p.Layer, len(sts), len(p.Trainers))
+	}
+	for i, st := range sts {
+		saved := p.Trainers[i]
+		typ, names, mems, err := trainermem(st.t)
+		if err != nil {
+			return err
+		}
+		sh, ok := st.t.(settingsholder)
+		if !ok {
+			return fmt.Errorf("(l *Layer) loadtrainers: %s trainer (%T) doesn't have settings that can be loaded", st.w.name, st.t)
+		}
+		if saved.Tensor != st.w.name || saved.Type != typ {
+			return fmt.Errorf("(l *Layer) loadtrainers: expected %s trainer for %s got %s trainer for %s", typ, st.w.name, saved.Type, saved.Tensor)
+		}
+		if len(saved.Tensors) != len(mems) {
+			return fmt.Errorf("(l *Layer) loadtrainers: %s trainer has %d tensors but %d were saved", typ, len(mems), len(saved.Tensors))
+		}
+		for j := range mems {
+			if saved.Tensors[j].Name != names[j] {
+				return fmt.Errorf("(l *Layer) loadtrainers: expected %s got %s", names[j], saved.Tensors[j].Name)
+			}
+			err = loadsaved(handle, mems[j], saved.Tensors[j], payload)
+			if err != nil {
+				return fmt.Errorf("(l *Layer) loadtrainers: %v", err)
+			}
+		}
+		sh.LoadSettings(saved.Settings)
+	}
+	return nil
+}
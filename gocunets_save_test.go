@@ -0,0 +1,266 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/data/preprocess"
+	"github.com/dereklstinson/gocunets/layers"
+	"github.com/dereklstinson/gocunets/loss"
+)
+
+func TestModelHeaderRoundTrip(t *testing.T) {
+	var frmt TensorFormat
+	var dtype DataType
+	var cmode ConvolutionMode
+	var mtype MathType
+	var pmode PoolingMode
+	var amode ActivationMode
+	var bnmode BatchNormMode
+	var nan NanProp
+	b := &Builder{
+		Frmt:   frmt.NHWC(),
+		Dtype:  dtype.Float(),
+		Cmode:  cmode.CrossCorrelation(),
+		Mtype:  mtype.TensorOpMath(),
+		Pmode:  pmode.Max(),
+		AMode:  amode.PRelu(),
+		BNMode: bnmode.Spatial(),
+		Nan:    nan.Propigate(),
+	}
+	flags, err := b.Flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &ModelHeader{
+		Version:   ModelFileVersion,
+		Flags:     flags,
+		InputDims: []int32{2, 3, 4, 5},
+		Modules: []ModuleInfo{{Spec: ModuleSpec{
+			Type:       vanillamodulespec,
+			FilterDims: []int32{4, 3, 3, 3},
+			Pad:        []int32{1, 1},
+			Stride:     []int32{1, 1},
+			Dilation:   []int32{1, 1},
+		}}},
+		Preprocess: &preprocess.Normalizer{
+			Method:   preprocess.Standardize,
+			Channels: 3,
+			Shift:    []float32{1, 2, 3},
+			Scale:    []float32{.5, .25, .125},
+		},
+	}
+	buf := new(bytes.Buffer)
+	err = writemodel(buf, nil, header, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadModelHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Flags != flags || !read.Modules[0].Spec.equal(header.Modules[0].Spec) {
+		t.Error("header not the same", read, header)
+	}
+	if read.Preprocess == nil || !reflect.DeepEqual(read.Preprocess.Scale, header.Preprocess.Scale) {
+		t.Error("preprocess not the same", read.Preprocess, header.Preprocess)
+	}
+	loaded := new(Builder)
+	err = loaded.SetFlags(read.Flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Frmt != b.Frmt || loaded.Mtype != b.Mtype || loaded.AMode != b.AMode || loaded.Nan != b.Nan {
+		t.Error("flags not the same")
+	}
+
+	newer := new(bytes.Buffer)
+	header.Version = ModelFileVersion + 1
+	err = writemodel(newer, nil, header, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadModelHeader(newer); err == nil {
+		t.Error("newer model file version should not load")
+	}
+}
+
+type testcustomloss struct{}
+
+func (testcustomloss) PerformError(x, dx, y, dy *layers.Tensor) error { return nil }
+func (testcustomloss) Inference(x, y *layers.Tensor) error            { return nil }
+func (testcustomloss) TestForward(x, y, target *layers.Tensor) error  { return nil }
+func (testcustomloss) GetAverageBatchLoss() float32                   { return 0 }
+
+func TestClassifierLossInfo(t *testing.T) {
+	focal, err := loss.CreateFocalLoss(nil, 2, []float32{1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	weighted, err := loss.CreateWeightedSoftMax(nil, []float32{2, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		l      LossLayer
+		name   string
+		params *LossParams
+	}{
+		{l: new(loss.MSE2), name: "MSE"},
+		{l: loss.CreateHuberLoss(nil, 1.5), name: "Huber", params: &LossParams{Delta: 1.5}},
+		{l: loss.CreateBinaryCrossEntropy(nil), name: "BCE"},
+		{l: focal, name: "Focal", params: &LossParams{Gamma: 2, Weights: []float32{1, 3}}},
+		{l: weighted, name: "WeightedSoftMax", params: &LossParams{Weights: []float32{2, 1}}},
+	} {
+		name, params, err := CreateCustomLossLayer(0, nil, c.l).lossinfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != c.name || !reflect.DeepEqual(params, c.params) {
+			t.Errorf("%T recorded as %s %v, expected %s %v", c.l, name, params, c.name, c.params)
+		}
+	}
+	if _, _, err = CreateCustomLossLayer(0, nil, testcustomloss{}).lossinfo(); err == nil {
+		t.Error("a custom loss can't be saved and should error")
+	}
+}
+
+func TestSimpleModuleNetworkSaveLoad(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+	bldr := CreateBuilder(handle)
+	batch := int32(4)
+	var err error
+	build := func() *SimpleModuleNetwork {
+		mnet := CreateSimpleModuleNetwork(0, bldr)
+		mods := make([]Module, 2)
+		mods[0], err = CreateVanillaModule(0, bldr, batch, []int32{8, 1, 3, 3}, []int32{1, 1}, []int32{1, 1}, []int32{1, 1}, 1, 0, 1, 0)
+		check(err)
+		mods[1], err = CreateCompressionModule(1, bldr, batch, 8, []int32{4, 4}, []int32{2, 2}, 0, 1, 0)
+		check(err)
+		mnet.SetModules(mods)
+		x, err := bldr.CreateTensor([]int32{batch, 1, 9, 9})
+		check(err)
+		mnet.SetTensorX(x)
+		outputdims, err := mnet.FindOutputDims()
+		check(err)
+		mnet.Output, err = CreateOutputModule(2, bldr, batch, []int32{3, outputdims[1], outputdims[2], outputdims[3]}, []int32{0, 0}, []int32{1, 1}, []int32{1, 1}, 1, 0, 1, 0)
+		check(err)
+		check(mnet.SetSoftMaxClassifier())
+		_, err = mnet.FindOutputDims()
+		check(err)
+		check(mnet.InitHiddenLayers(.001, 0, 0))
+		check(mnet.InitWorkspace())
+		return mnet
+	}
+	inference := func(mnet *SimpleModuleNetwork, input []float32) []float32 {
+		check(mnet.GetTensorX().LoadValuesFromSLice(handle.Handler, input, int32(len(input))))
+		check(mnet.Inference())
+		check(handle.Sync())
+		y := mnet.GetTensorY()
+		output := make([]float32, y.Vol())
+		check(y.FillSlice(handle.Handler, output))
+		return output
+	}
+	input := make([]float32, batch*9*9)
+	for i := range input {
+		input[i] = rand.Float32()
+	}
+	original := build()
+	expected := inference(original, input)
+	buf := new(bytes.Buffer)
+	check(original.SaveModel(buf))
+	saved := buf.Bytes()
+
+	loaded := CreateSimpleModuleNetwork(1, CreateBuilder(handle))
+	check(loaded.LoadModel(bytes.NewReader(saved)))
+	compare := func(name string, output []float32) {
+		for i := range expected {
+			if expected[i] != output[i] {
+				t.Fatalf("%s: output at %d is %v, expected %v", name, i, output[i], expected[i])
+			}
+		}
+	}
+	compare("built from file", inference(loaded, input))
+
+	rebuilt := build()
+	check(rebuilt.LoadModel(bytes.NewReader(saved)))
+	compare("loaded into built network", inference(rebuilt, input))
+}
+
+func TestSimpleModuleNetworkCheckpoint(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+	bldr := CreateBuilder(handle)
+	batch := int32(4)
+	var err error
+	mnet := CreateSimpleModuleNetwork(0, bldr)
+	mods := make([]Module, 1)
+	mods[0], err = CreateVanillaModule(0, bldr, batch, []int32{8, 1, 3, 3}, []int32{1, 1}, []int32{1, 1}, []int32{1, 1}, 1, 0, 1, 0)
+	check(err)
+	mnet.SetModules(mods)
+	x, err := bldr.CreateTensor([]int32{batch, 1, 9, 9})
+	check(err)
+	mnet.SetTensorX(x)
+	outputdims, err := mnet.FindOutputDims()
+	check(err)
+	mnet.Output, err = CreateOutputModule(1, bldr, batch, []int32{3, outputdims[1], outputdims[2], outputdims[3]}, []int32{0, 0}, []int32{1, 1}, []int32{1, 1}, 1, 0, 1, 0)
+	check(err)
+	check(mnet.SetSoftMaxClassifier())
+	_, err = mnet.FindOutputDims()
+	check(err)
+	check(mnet.InitHiddenLayers(.001, 0, 0))
+	check(mnet.InitWorkspace())
+
+	dir, err := ioutil.TempDir("", "gocunets")
+	check(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+	check(mnet.SaveCheckpoint(path, 7))
+	//Saving again has to replace the old checkpoint
+	check(mnet.SaveCheckpoint(path, 8))
+	files, err := ioutil.ReadDir(dir)
+	check(err)
+	if len(files) != 1 {
+		t.Error("temp file left in the checkpoint directory")
+	}
+
+	loaded := CreateSimpleModuleNetwork(1, CreateBuilder(handle))
+	counter, err := loaded.LoadCheckpoint(path)
+	check(err)
+	if counter != 8 {
+		t.Errorf("counter is %d, expected 8", counter)
+	}
+	st, err := loaded.Modules[0].(*VanillaModule).conv.savedtrainers()
+	check(err)
+	if len(st) != 2 {
+		t.Fatalf("conv layer has %d trainers after loading, expected 2", len(st))
+	}
+	if float32(st[0].t.(settingsholder).Settings().Rate) != .001 {
+		t.Error("trainer settings not loaded")
+	}
+
+	var saved bytes.Buffer
+	check(mnet.SaveModel(&saved))
+	if _, err = loaded.ReadCheckpoint(&saved); err == nil {
+		t.Error("model file should not load as a checkpoint")
+	}
+}
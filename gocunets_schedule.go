@@ -0,0 +1,120 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/trainer"
+	"github.com/dereklstinson/gocunets/trainer/schedule"
+)
+
+//modulelayers appends the layers of mod that have hidden values to ls
+func modulelayers(ls []*Layer, mod Module) (_ []*Layer, err error) {
+	switch x := mod.(type) {
+	case savablemodule:
+		return append(ls, x.savedlayers()...), nil
+	case *ResidualModule:
+		for _, inner := range x.inner {
+			ls, err = modulelayers(ls, inner)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return append(ls, x.proj), nil
+	case *Graph:
+		for _, n := range x.nodes {
+			if n.mod != nil {
+				ls, err = modulelayers(ls, n.mod)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				ls = append(ls, n.layer)
+			}
+		}
+		return ls, nil
+	case *ONNXModule:
+		for _, n := range x.nodes {
+			ls = append(ls, n.layer)
+		}
+		return ls, nil
+	case *SimpleModuleNetwork:
+		return x.hiddenlayers(ls)
+	}
+	return nil, fmt.Errorf("modulelayers: module %d (%T) doesn't give its layers", mod.ID(), mod)
+}
+
+//hiddenlayers appends the layers of the modules and the output to ls.  Some of the layers can be nil.
+func (m *SimpleModuleNetwork) hiddenlayers(ls []*Layer) (_ []*Layer, err error) {
+	if err = m.b.gpuonly("(m *SimpleModuleNetwork)"); err != nil {
+		return nil, err
+	}
+	for _, mod := range m.Modules {
+		ls, err = modulelayers(ls, mod)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if m.Output != nil {
+		ls, err = modulelayers(ls, m.Output)
+	}
+	return ls, err
+}
+
+//trainers appends the trainers of the hidden layers to ts
+func (m *SimpleModuleNetwork) trainers(ts []trainer.Trainer) ([]trainer.Trainer, error) {
+	ls, err := m.hiddenlayers(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range ls {
+		if l == nil {
+			continue
+		}
+		sts, err := l.savedtrainers()
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range sts {
+			ts = append(ts, st.t)
+		}
+	}
+	return ts, nil
+}
+
+//SetRates sets the learning rate of every trainer in the network.  The dwalpha of each trainer is kept.
+//InitHiddenLayers needs to have been ran.
+func (m *SimpleModuleNetwork) SetRates(rate float32) error {
+	ts, err := m.trainers(nil)
+	if err != nil {
+		return fmt.Errorf("(m *SimpleModuleNetwork) SetRates: %v", err)
+	}
+	for _, t := range ts {
+		var dwalpha float32
+		if s, ok := t.(settingsholder); ok {
+			dwalpha = float32(s.Settings().DWalpha)
+		}
+		t.SetRates(rate, dwalpha)
+	}
+	m.Rate = rate
+	return nil
+}
+
+//ScheduledUpdate sets the rate of every trainer to the rate s gives for counter and then does Update(counter).
+//The loss passed to s is the average batch loss of the classifier, or 0 if the network doesn't have one.
+//
+//counter should count updates.  A network resumed from a checkpoint continues the schedule
+//when it is passed the counter from ReadCheckpoint and the schedule saved with schedule.Marshal.
+func (m *SimpleModuleNetwork) ScheduledUpdate(s schedule.Schedule, counter int) error {
+	var loss float32
+	if m.Classifier != nil {
+		loss = m.Classifier.GetAverageBatchLoss()
+	}
+	err := m.SetRates(s.Rate(counter, loss))
+	if err != nil {
+		return err
+	}
+	return m.Update(counter)
+}
@@ -0,0 +1,47 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"testing"
+
+	"github.com/dereklstinson/gocunets/trainer/schedule"
+)
+
+func TestScheduledUpdate(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+
+	m := testnetwork(t, handle, testnetworkspec)
+	x := m.GetTensorX()
+	check(x.NormalRand(handle.Handler, 0, 1))
+	s := &schedule.StepDecay{Rate0: .01, Gamma: .1, StepSize: 2}
+	for counter := 0; counter < 3; counter++ {
+		expected := s.Rate(counter, 0)
+		check(m.Forward())
+		check(m.Backward())
+		check(m.ScheduledUpdate(s, counter))
+		ts, err := m.trainers(nil)
+		check(err)
+		if len(ts) == 0 {
+			t.Fatal("network has no trainers")
+		}
+		for _, tr := range ts {
+			if r := float32(tr.(settingsholder).Settings().Rate); r != expected {
+				t.Errorf("update %d: trainer rate is %v expected %v", counter, r, expected)
+			}
+		}
+		if counter == 2 && expected >= .01 {
+			t.Error("rate should have decayed")
+		}
+		if m.Rate != expected {
+			t.Errorf("update %d: network rate is %v", counter, m.Rate)
+		}
+	}
+}
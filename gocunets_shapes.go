@@ -0,0 +1,181 @@
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dereklstinson/gocunets/shapes"
+)
+
+//LayerShape is the shape of a layer found by shape inference.  See the shapes package.
+type LayerShape = shapes.LayerShape
+
+//ShapeReport holds the shape of every layer of a network in the order they are run in a forward pass.
+type ShapeReport = shapes.Report
+
+//InferShapes finds the dims, parameter count and FLOPs of every layer in modules when the first module has an input with inputdims.
+//Nothing is allocated so a device isn't needed. The specs of modules that have already been made can be found with their Spec method.
+//
+//Flags that are empty get the value that CreateBuilder uses.  A module with a Batch of 0 gets the batch of inputdims.
+//The error returned names the first module and layer that doesn't fit with its input.
+func InferShapes(flags BuilderFlags, inputdims []int32, modules []ModuleSpec) (*ShapeReport, error) {
+	var b Builder
+	flags = flags.withdefaults(defaultbuilderflags)
+	err := b.SetFlags(flags)
+	if err != nil {
+		return nil, fmt.Errorf("InferShapes: %v", err)
+	}
+	r, err := shapes.CreateReport(inputdims)
+	if err != nil {
+		return nil, fmt.Errorf("InferShapes: %v", err)
+	}
+	for i := range modules {
+		spec := modules[i]
+		err = addmodule(r, fmt.Sprintf("module %d", i), &spec, b.Frmt, flags.AMode)
+		if err != nil {
+			return nil, fmt.Errorf("InferShapes: %v", err)
+		}
+	}
+	return r, nil
+}
+
+//InferShapes finds the dims, parameter count and FLOPs of every layer of the network that s describes.
+//Nothing is allocated so a device isn't needed.
+func (s *NetworkSpec) InferShapes() (*ShapeReport, error) {
+	_, r, err := s.complete()
+	if err != nil {
+		return nil, fmt.Errorf("(s *NetworkSpec) InferShapes: %v", err)
+	}
+	return r, nil
+}
+
+//addmodule adds the layers of the module s to r.  If s.Batch is 0 it is set to the batch of the report.
+//amode is used for the activation if s.AMode isn't set.
+func addmodule(r *ShapeReport, name string, s *ModuleSpec, frmt TensorFormat, amode string) error {
+	if s.Batch == 0 {
+		s.Batch = r.OutputDims[0]
+	}
+	if s.AMode != "" {
+		amode = s.AMode
+	}
+	layers, err := s.layershapes(frmt, amode, r.OutputDims)
+	if err != nil {
+		return fmt.Errorf("%s (%s): %v", name, s.Type, err)
+	}
+	for _, l := range layers {
+		l.Module = name
+		r.Add(l)
+	}
+	return nil
+}
+
+//layershapes returns the shapes of the layers of the module that s builds when the module's input has dims x.
+//It uses the same parameters as the module constructors.
+func (s ModuleSpec) layershapes(frmt TensorFormat, amode string, x []int32) ([]LayerShape, error) {
+	if s.Batch != x[0] {
+		return nil, fmt.Errorf("batch is %d but the input batch is %d", s.Batch, x[0])
+	}
+	mode, err := stringtoactivationmode(amode)
+	if err != nil {
+		return nil, err
+	}
+	amode, err = activationmodetostring(mode)
+	if err != nil {
+		return nil, err
+	}
+	sfrmt, err := shapeformat(frmt)
+	if err != nil {
+		return nil, err
+	}
+	c, err := channelaxis(frmt, len(x))
+	if err != nil {
+		return nil, err
+	}
+	switch s.Type {
+	case vanillamodulespec, outputmodulespec:
+		conv, err := shapes.Convolution(sfrmt, x, s.FilterDims, s.Pad, s.Stride, s.Dilation, false)
+		if err != nil {
+			return nil, fmt.Errorf("convolution: %v", err)
+		}
+		if s.Type == outputmodulespec {
+			return []LayerShape{conv}, nil
+		}
+		return []LayerShape{conv, shapes.Activation(amode, conv.OutputDims)}, nil
+	case compressionmodulespec, decompressionmodulespec, neutralmodulespec:
+		strides, deconvolution := s.Strides, s.Deconvolution
+		switch s.Type {
+		case compressionmodulespec:
+			strides, deconvolution = true, false
+		case decompressionmodulespec:
+			strides, deconvolution = true, true
+		}
+		if s.InputChannels != x[c] {
+			return nil, fmt.Errorf("InputChannels is %d but the input has %d channels", s.InputChannels, x[c])
+		}
+		if len(s.SpacialDims) != len(x)-2 {
+			return nil, fmt.Errorf("SpacialDims %v need %d values", s.SpacialDims, len(x)-2)
+		}
+		if len(s.OutputChannels) == 0 {
+			return nil, errors.New("OutputChannels is empty")
+		}
+		var stride = int32(1)
+		if strides {
+			stride = 2
+		}
+		layername := "convolution"
+		if deconvolution {
+			layername = "deconvolution"
+		}
+		layers := make([]LayerShape, 0, len(s.OutputChannels)+2)
+		ys := make([][]int32, 0, len(s.OutputChannels))
+		for i := range s.OutputChannels {
+			var fdims, pads, strds, dils []int32
+			if deconvolution {
+				fdims, pads, strds, dils, err = deconvolutionparameterdims(s.InputChannels, s.OutputChannels[i], stride, s.SpacialDims, s.PaddingOffset, frmt, i)
+			} else {
+				fdims, pads, strds, dils, err = convolutionparameterdims(s.InputChannels, s.OutputChannels[i], stride, s.SpacialDims, s.PaddingOffset, frmt, i)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s %d: %v", layername, i, err)
+			}
+			conv, err := shapes.Convolution(sfrmt, x, fdims, pads, strds, dils, deconvolution)
+			if err != nil {
+				return nil, fmt.Errorf("%s %d: %v", layername, i, err)
+			}
+			conv.Layer = fmt.Sprintf("%s %d", layername, i)
+			layers = append(layers, conv)
+			ys = append(ys, conv.OutputDims)
+		}
+		concat, err := shapes.Concat(sfrmt, ys)
+		if err != nil {
+			return nil, fmt.Errorf("concat: %v", err)
+		}
+		layers = append(layers, concat)
+		return append(layers, shapes.Activation(amode, concat.OutputDims)), nil
+	case "":
+		return nil, errors.New("Type not set")
+	}
+	return nil, fmt.Errorf("Unsupported Type %s", s.Type)
+}
+
+//classifiershape is the shape of a classifier of a NetworkSpec
+func classifiershape(classifier string, x []int32) LayerShape {
+	switch classifier {
+	case "SoftMax", "Focal", "WeightedSoftMax":
+		return shapes.Loss(strings.ToLower(classifier), true, x)
+	}
+	return shapes.Loss(strings.ToLower(classifier), false, x)
+}
+
+//shapeformat returns the shapes format of frmt
+func shapeformat(frmt TensorFormat) (sfrmt shapes.TensorFormat, err error) {
+	flg := frmt
+	switch frmt {
+	case flg.NCHW():
+		return sfrmt.NCHW(), nil
+	case flg.NHWC():
+		return sfrmt.NHWC(), nil
+	}
+	return sfrmt, errors.New("Unsupported Format")
+}
@@ -0,0 +1,50 @@
+package gocunets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInferShapes(t *testing.T) {
+	spec, err := ReadNetworkSpec(strings.NewReader(testnetworkspec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := spec.InferShapes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := []string{"convolution", "activation", "convolution 0", "convolution 1", "concat", "activation", "convolution", "softmax"}
+	if len(r.Layers) != len(layers) {
+		t.Fatalf("found %d layers, expected %d", len(r.Layers), len(layers))
+	}
+	for i := range layers {
+		if r.Layers[i].Layer != layers[i] {
+			t.Errorf("layer %d is %s, expected %s", i, r.Layers[i].Layer, layers[i])
+		}
+	}
+	if !int32sequal(r.Layers[4].OutputDims, []int32{4, 8, 5, 5}) {
+		t.Errorf("concat output dims are %v", r.Layers[4].OutputDims)
+	}
+	//(8*1*3*3+8) + 2*(4*8*2*2+4) + (3*8*5*5+3)
+	if r.Params != 947 {
+		t.Errorf("params are %d, expected 947", r.Params)
+	}
+
+	modules := []ModuleSpec{
+		{Type: vanillamodulespec, FilterDims: []int32{8, 3, 3, 1}, Pad: []int32{1, 1}, Stride: []int32{1, 1}, Dilation: []int32{1, 1}},
+		{Type: decompressionmodulespec, InputChannels: 8, OutputChannels: []int32{2, 3}, SpacialDims: []int32{2, 2}},
+	}
+	r, err = InferShapes(BuilderFlags{Frmt: "NHWC"}, []int32{4, 9, 9, 1}, modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !int32sequal(r.OutputDims, []int32{4, 16, 16, 5}) {
+		t.Errorf("NHWC output dims are %v", r.OutputDims)
+	}
+	modules[1].InputChannels = 6
+	_, err = InferShapes(BuilderFlags{Frmt: "NHWC"}, []int32{4, 9, 9, 1}, modules)
+	if err == nil || !strings.Contains(err.Error(), "module 1 (DecompressionModule)") {
+		t.Errorf("channel mismatch error should name module 1, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package gocunets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dereklstinson/gocunets/shapes"
+)
+
+//NetworkSpec describes a SimpleModuleNetwork so that it can be written in a file instead of being built by hand.
+//The file format is JSON only.  YAML isn't supported, so a YAML spec needs to be converted to JSON before it is read.
+//
+//Flags that are left empty get the value that CreateBuilder uses.  A module with a Batch of 0 gets the batch of InputDims.
+//Output needs to be an OutputModule.
+//
+//Classifier can be "" for no classifier, "SoftMax", "MSE", "Huber", "BCE", "Focal" or "WeightedSoftMax".
+//Loss holds the settings of the classifier.  Huber needs a Delta and WeightedSoftMax needs Weights.
+//The LabelSmoothing of Loss is only used by SoftMax.
+type NetworkSpec struct {
+	ID         int64        `json:"id,omitempty"`
+	Flags      BuilderFlags `json:"flags"`
+	InputDims  []int32      `json:"input_dims"`
+	Modules    []ModuleSpec `json:"modules"`
+	Output     ModuleSpec   `json:"output"`
+	Classifier string       `json:"classifier,omitempty"`
+	Loss       *LossParams  `json:"loss,omitempty"`
+	Rate       float32      `json:"rate,omitempty"`
+	Decay1     float32      `json:"decay1,omitempty"`
+	Decay2     float32      `json:"decay2,omitempty"`
+}
+
+//LossParams are the settings of the loss of a classifier
+type LossParams struct {
+	Delta          float32   `json:"delta,omitempty"`           //Huber
+	Gamma          float32   `json:"gamma,omitempty"`           //Focal
+	Weights        []float32 `json:"weights,omitempty"`         //Focal and WeightedSoftMax
+	LabelSmoothing float32   `json:"label_smoothing,omitempty"` //SoftMax
+}
+
+//defaultbuilderflags are the flags that CreateBuilder sets
+var defaultbuilderflags = BuilderFlags{
+	Frmt:   "NCHW",
+	Dtype:  "Float",
+	Cmode:  "CrossCorrelation",
+	Mtype:  "Default",
+	Pmode:  "AverageCountExcludePadding",
+	AMode:  "Leaky",
+	BNMode: "Spatial",
+	Nan:    "NotPropigate",
+}
+
+//ReadNetworkSpec reads a json NetworkSpec from r.  Fields that NetworkSpec doesn't have are an error so that typos aren't ignored.
+//The spec isn't validated until Validate or Build is called.
+func ReadNetworkSpec(r io.Reader) (*NetworkSpec, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	s := new(NetworkSpec)
+	err := dec.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("ReadNetworkSpec: %v", err)
+	}
+	return s, nil
+}
+
+//Validate checks the flags, classifier and modules of the spec and returns the output dims of the network.
+//The dims of each module are found with the formulas the layers use, so nothing is allocated and a device isn't needed.
+func (s *NetworkSpec) Validate() (outputdims []int32, err error) {
+	_, r, err := s.complete()
+	if err != nil {
+		return nil, fmt.Errorf("(s *NetworkSpec) Validate: %v", err)
+	}
+	return r.OutputDims, nil
+}
+
+//Build validates the spec and then builds a SimpleModuleNetwork on b with it.
+//The flags of b are set to the flags of the spec.
+//
+//The network returned has its tensors, classifier, trainers and workspace set up, so it is ready to be trained.
+//The weights of the network are the ones the module constructors give them.
+func (s *NetworkSpec) Build(b *Builder) (m *SimpleModuleNetwork, err error) {
+	spec, _, err := s.complete()
+	if err != nil {
+		return nil, fmt.Errorf("(s *NetworkSpec) Build: %v", err)
+	}
+	m = CreateSimpleModuleNetwork(spec.ID, b)
+	err = m.buildfromspec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("(s *NetworkSpec) Build: %v", err)
+	}
+	return m, nil
+}
+
+//complete returns a copy of s with the defaults filled in along with the shapes of the layers of the network.
+func (s *NetworkSpec) complete() (spec *NetworkSpec, r *ShapeReport, err error) {
+	spec = new(NetworkSpec)
+	*spec = *s
+	spec.Flags = s.Flags.withdefaults(defaultbuilderflags)
+	var flags Builder
+	err = flags.SetFlags(spec.Flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err = shapes.CreateReport(s.InputDims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.Output.Type != outputmodulespec {
+		return nil, nil, fmt.Errorf("Output Type is %q but it needs to be %s", s.Output.Type, outputmodulespec)
+	}
+	err = s.checkclassifier()
+	if err != nil {
+		return nil, nil, err
+	}
+	spec.InputDims = copyint32s(s.InputDims)
+	spec.Modules = make([]ModuleSpec, len(s.Modules))
+	copy(spec.Modules, s.Modules)
+	for i := range spec.Modules {
+		err = addmodule(r, fmt.Sprintf("module %d", i), &spec.Modules[i], flags.Frmt, spec.Flags.AMode)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	err = addmodule(r, "Output", &spec.Output, flags.Frmt, spec.Flags.AMode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if spec.Classifier != "" {
+		l := classifiershape(spec.Classifier, r.OutputDims)
+		l.Module = "Classifier"
+		r.Add(l)
+	}
+	return spec, r, nil
+}
+
+//checkclassifier checks that the classifier is supported and that it has the settings it needs
+func (s *NetworkSpec) checkclassifier() error {
+	var params LossParams
+	if s.Loss != nil {
+		params = *s.Loss
+	}
+	switch s.Classifier {
+	case "", "MSE", "BCE", "Focal":
+	case "SoftMax":
+		if params.LabelSmoothing < 0 || params.LabelSmoothing >= 1 {
+			return errors.New("label smoothing needs to be in [0,1)")
+		}
+	case "Huber":
+		if params.Delta <= 0 {
+			return errors.New("Huber classifier needs a delta more than 0")
+		}
+	case "WeightedSoftMax":
+		if len(params.Weights) == 0 {
+			return errors.New("WeightedSoftMax classifier needs a weight for each class")
+		}
+	default:
+		return fmt.Errorf("unsupported classifier %s", s.Classifier)
+	}
+	if params.Gamma < 0 {
+		return errors.New("gamma can't be negative")
+	}
+	return nil
+}
+
+//withdefaults returns f with its empty flags set to the ones in defaults
+func (f BuilderFlags) withdefaults(defaults BuilderFlags) BuilderFlags {
+	set := func(flag *string, d string) {
+		if *flag == "" {
+			*flag = d
+		}
+	}
+	set(&f.Frmt, defaults.Frmt)
+	set(&f.Dtype, defaults.Dtype)
+	set(&f.Cmode, defaults.Cmode)
+	set(&f.Mtype, defaults.Mtype)
+	set(&f.Pmode, defaults.Pmode)
+	set(&f.AMode, defaults.AMode)
+	set(&f.BNMode, defaults.BNMode)
+	set(&f.Nan, defaults.Nan)
+	return f
+}
+
+//buildfromspec builds the modules in s and inits them. s needs to have all of its flags set.
+func (m *SimpleModuleNetwork) buildfromspec(s *NetworkSpec) (err error) {
+	err = m.b.SetFlags(s.Flags)
+	if err != nil {
+		return err
+	}
+	modules := make([]Module, len(s.Modules))
+	for i := range s.Modules {
+		modules[i], err = s.Modules[i].Build(m.b)
+		if err != nil {
+			return fmt.Errorf("index %d: %v", i, err)
+		}
+	}
+	output, err := s.Output.Build(m.b)
+	if err != nil {
+		return fmt.Errorf("m.Output: %v", err)
+	}
+	var ok bool
+	m.Output, ok = output.(*OutputModule)
+	if !ok {
+		return errors.New("output isn't an OutputModule")
+	}
+	m.Id = s.ID
+	m.SetModules(modules)
+	x, err := m.b.CreateTensor(s.InputDims)
+	if err != nil {
+		return err
+	}
+	m.SetTensorX(x)
+	_, err = m.FindOutputDims()
+	if err != nil {
+		return err
+	}
+	if s.Classifier != "" {
+		err = m.setclassifierfromspec(s.Classifier, s.Loss)
+		if err != nil {
+			return err
+		}
+		_, err = m.FindOutputDims()
+		if err != nil {
+			return err
+		}
+	}
+	err = m.InitHiddenLayers(s.Rate, s.Decay1, s.Decay2)
+	if err != nil {
+		return err
+	}
+	return m.InitWorkspace()
+}
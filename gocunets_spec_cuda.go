@@ -0,0 +1,33 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import "fmt"
+
+//setclassifierfromspec sets the classifier named by classifier with the settings in params. params can be nil.
+func (m *SimpleModuleNetwork) setclassifierfromspec(classifier string, params *LossParams) (err error) {
+	var p LossParams
+	if params != nil {
+		p = *params
+	}
+	switch classifier {
+	case "SoftMax":
+		err = m.SetSoftMaxClassifier()
+		if err == nil && p.LabelSmoothing != 0 {
+			err = m.Classifier.SetLabelSmoothing(p.LabelSmoothing)
+		}
+		return err
+	case "MSE":
+		return m.SetMSEClassifier()
+	case "Huber":
+		return m.SetHuberClassifier(p.Delta)
+	case "BCE":
+		return m.SetBinaryClassifier()
+	case "Focal":
+		return m.SetFocalClassifier(p.Gamma, p.Weights)
+	case "WeightedSoftMax":
+		return m.SetWeightedSoftMaxClassifier(p.Weights)
+	}
+	return fmt.Errorf("unsupported classifier %s", classifier)
+}
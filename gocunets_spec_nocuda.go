@@ -0,0 +1,21 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+)
+
+//setclassifierfromspec sets the classifier named by classifier with the settings in params. params can be nil.
+//Only the SoftMax classifier without label smoothing can be made without the cuda tag.
+func (m *SimpleModuleNetwork) setclassifierfromspec(classifier string, params *LossParams) (err error) {
+	if classifier != "SoftMax" {
+		return fmt.Errorf("%s classifier needs a build with the cuda tag", classifier)
+	}
+	if params != nil && params.LabelSmoothing != 0 {
+		return errors.New("label smoothing needs a build with the cuda tag")
+	}
+	return m.SetSoftMaxClassifier()
+}
@@ -0,0 +1,99 @@
+package gocunets
+
+import (
+	"strings"
+	"testing"
+)
+
+const testnetworkspec = `{
+	"flags": {"frmt": "NCHW", "amode": "Relu"},
+	"input_dims": [4, 1, 9, 9],
+	"modules": [
+		{"type": "VanillaModule", "filter_dims": [8, 1, 3, 3], "pad": [1, 1], "stride": [1, 1], "dilation": [1, 1], "balpha": 1, "falpha": 1},
+		{"type": "CompressionModule", "input_channels": 8, "output_channels": [4, 4], "spacial_dims": [2, 2], "falpha": 1}
+	],
+	"output": {"type": "OutputModule", "filter_dims": [3, 8, 5, 5], "pad": [0, 0], "stride": [1, 1], "dilation": [1, 1], "balpha": 1, "falpha": 1},
+	"classifier": "SoftMax",
+	"rate": 0.001
+}`
+
+func TestNetworkSpecValidate(t *testing.T) {
+	spec, err := ReadNetworkSpec(strings.NewReader(testnetworkspec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dims, err := spec.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !int32sequal(dims, []int32{4, 3, 1, 1}) {
+		t.Errorf("output dims are %v", dims)
+	}
+	if spec.Modules[0].Batch != 0 || spec.Flags.Dtype != "" {
+		t.Error("Validate should not change the spec")
+	}
+
+	spec.Modules[0].FilterDims = []int32{8, 2, 3, 3}
+	_, err = spec.Validate()
+	if err == nil || !strings.Contains(err.Error(), "module 0 (VanillaModule)") {
+		t.Errorf("channel mismatch error should name module 0, got %v", err)
+	}
+	spec.Modules[0].FilterDims = []int32{8, 1, 3, 3}
+	spec.Output.FilterDims = []int32{3, 8, 7, 7}
+	_, err = spec.Validate()
+	if err == nil || !strings.Contains(err.Error(), "Output (OutputModule)") {
+		t.Errorf("negative output dim error should name the Output, got %v", err)
+	}
+	spec.Output.FilterDims = []int32{3, 8, 5, 5}
+	spec.Flags.BNMode = "Sometimes"
+	if _, err = spec.Validate(); err == nil {
+		t.Error("unsupported flag should not validate")
+	}
+
+	_, err = ReadNetworkSpec(strings.NewReader(`{"input_dim": [4, 1, 9, 9]}`))
+	if err == nil {
+		t.Error("unknown field should not be read")
+	}
+}
+
+func TestNetworkSpecClassifiers(t *testing.T) {
+	spec, err := ReadNetworkSpec(strings.NewReader(testnetworkspec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		classifier string
+		params     *LossParams
+		valid      bool
+	}{
+		{classifier: "", valid: true},
+		{classifier: "SoftMax", params: &LossParams{LabelSmoothing: .1}, valid: true},
+		{classifier: "SoftMax", params: &LossParams{LabelSmoothing: 1}},
+		{classifier: "MSE", valid: true},
+		{classifier: "Huber", params: &LossParams{Delta: 1}, valid: true},
+		{classifier: "Huber"},
+		{classifier: "BCE", valid: true},
+		{classifier: "Focal", params: &LossParams{Gamma: 2}, valid: true},
+		{classifier: "Focal", params: &LossParams{Gamma: -1}},
+		{classifier: "WeightedSoftMax", params: &LossParams{Weights: []float32{1, 2, 3}}, valid: true},
+		{classifier: "WeightedSoftMax"},
+		{classifier: "Hinge"},
+	} {
+		spec.Classifier, spec.Loss = c.classifier, c.params
+		_, r, err := spec.complete()
+		if c.valid != (err == nil) {
+			t.Errorf("%s %v: valid is %v, got err %v", c.classifier, c.params, c.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		last := r.Layers[len(r.Layers)-1]
+		if c.classifier == "" && last.Module == "Classifier" {
+			t.Error("no classifier should not add a classifier layer")
+		}
+		if c.classifier != "" && (last.Module != "Classifier" || !int32sequal(last.OutputDims, []int32{4, 3, 1, 1})) {
+			t.Errorf("%s classifier layer is %v", c.classifier, last)
+		}
+	}
+}
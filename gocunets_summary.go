@@ -0,0 +1,334 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/tensor"
+	"github.com/dereklstinson/gocunets/shapes"
+)
+
+//LayerSummary describes a layer of a network.
+//
+//ID is -1 for rows that aren't a Layer, like a Concat or a classifier. ActivationBytes is the memory of the output.
+//GradientBytes is the memory of the gradient of the output and of the gradients of the weights.
+//Tensors that haven't been made yet count as 0 bytes.
+type LayerSummary struct {
+	Module          int64     `json:"module"`
+	ID              int64     `json:"id"`
+	Type            string    `json:"type"`
+	InputDims       [][]int32 `json:"input_dims,omitempty"`
+	OutputDims      []int32   `json:"output_dims,omitempty"`
+	FilterDims      []int32   `json:"filter_dims,omitempty"`
+	Pad             []int32   `json:"pad,omitempty"`
+	Stride          []int32   `json:"stride,omitempty"`
+	Dilation        []int32   `json:"dilation,omitempty"`
+	Params          int64     `json:"params"`
+	AMode           string    `json:"amode,omitempty"`
+	ActivationBytes uint      `json:"activation_bytes"`
+	GradientBytes   uint      `json:"gradient_bytes"`
+	WorkspaceBytes  uint      `json:"workspace_bytes"`
+}
+
+//Summary describes every layer of a module or a network along with the totals.
+//String prints it as a table.
+type Summary struct {
+	ID              int64          `json:"id"`
+	Layers          []LayerSummary `json:"layers"`
+	Params          int64          `json:"params"`
+	ActivationBytes uint           `json:"activation_bytes"`
+	GradientBytes   uint           `json:"gradient_bytes"`
+	WorkspaceBytes  uint           `json:"workspace_bytes"`
+}
+
+//summarizer is a module that can make its own Summary
+type summarizer interface {
+	Summary() *Summary
+}
+
+//ModuleSummary returns the Summary of mod. Modules that don't have a Summary method get one row with their input and output.
+func ModuleSummary(mod Module) *Summary {
+	if s, ok := mod.(summarizer); ok {
+		return s.Summary()
+	}
+	s := &Summary{ID: mod.ID()}
+	y := mod.GetTensorY()
+	s.add(LayerSummary{
+		Module:          mod.ID(),
+		ID:              -1,
+		Type:            strings.TrimPrefix(fmt.Sprintf("%T", mod), "*gocunets."),
+		InputDims:       [][]int32{tensordims(mod.GetTensorX())},
+		OutputDims:      tensordims(y),
+		ActivationBytes: tensorbytes(y),
+		GradientBytes:   tensorbytes(mod.GetTensorDY()),
+	})
+	return s
+}
+
+//Summary returns the Summary of the modules, output and classifier of the network
+func (m *SimpleModuleNetwork) Summary() *Summary {
+	s := &Summary{ID: m.Id}
+	for _, mod := range m.Modules {
+		s.merge(ModuleSummary(mod))
+	}
+	if m.Output != nil {
+		s.merge(m.Output.Summary())
+	}
+	if m.Classifier != nil {
+		s.merge(m.Classifier.Summary())
+	}
+	return s
+}
+
+//Summary returns the Summary of the convolution and activation of the module
+func (m *VanillaModule) Summary() *Summary {
+	return layerssummary(m.id, m.conv, m.act)
+}
+
+//Summary returns the Summary of the convolution of the module
+func (m *OutputModule) Summary() *Summary {
+	return layerssummary(m.id, m.op)
+}
+
+//Summary returns the Summary of the parallel convolutions, the concat and the activation of the module
+func (m *module) Summary() *Summary {
+	s := layerssummary(m.id, m.layers...)
+	if m.c != nil {
+		c := m.c.summary()
+		c.Module = m.id
+		s.add(c)
+	}
+	s.merge(layerssummary(m.id, m.activ))
+	return s
+}
+
+//Summary returns the Summary of the nodes of the module.
+//Reshape, Flatten and Identity nodes share memory with their input so they don't add to the memory.
+func (m *ONNXModule) Summary() *Summary {
+	s := &Summary{ID: m.id}
+	for i, n := range m.nodes {
+		var l LayerSummary
+		switch {
+		case n.layer != nil:
+			l = n.layer.summary()
+		case n.concat != nil:
+			l = n.concat.summary()
+		default:
+			l = LayerSummary{ID: -1, Type: n.optype}
+			for _, input := range n.inputs {
+				l.InputDims = append(l.InputDims, tensordims(m.ys[input]))
+			}
+			y, dy := m.ys[n.output], m.dys[n.output]
+			if i == len(m.nodes)-1 {
+				y, dy = m.y, m.dy
+			}
+			l.OutputDims = tensordims(y)
+			if n.view == nil {
+				l.ActivationBytes, l.GradientBytes = tensorbytes(y), tensorbytes(dy)
+			}
+		}
+		l.Module = m.id
+		s.add(l)
+	}
+	return s
+}
+
+//Summary returns a Summary with one row for the loss layer of the classifier
+func (m *ClassifierModule) Summary() *Summary {
+	s := &Summary{ID: m.id}
+	typ := fmt.Sprintf("%T", m.l)
+	s.add(LayerSummary{
+		Module:          m.id,
+		ID:              -1,
+		Type:            typ[strings.LastIndex(typ, ".")+1:],
+		InputDims:       [][]int32{tensordims(m.x)},
+		OutputDims:      tensordims(m.y),
+		ActivationBytes: tensorbytes(m.y),
+	})
+	return s
+}
+
+func layerssummary(id int64, ls ...*Layer) *Summary {
+	s := &Summary{ID: id}
+	for _, l := range ls {
+		if l == nil {
+			continue
+		}
+		summary := l.summary()
+		summary.Module = id
+		s.add(summary)
+	}
+	return s
+}
+
+//summary describes the layer. Running statistics of a batch norm aren't counted as parameters.
+func (l *Layer) summary() LayerSummary {
+	s := LayerSummary{
+		ID:              l.id,
+		Type:            l.name,
+		OutputDims:      tensordims(l.y),
+		ActivationBytes: tensorbytes(l.y),
+		GradientBytes:   tensorbytes(l.dy),
+		WorkspaceBytes:  mallocedbytes(l.workspacefwd) + mallocedbytes(l.workspacebwd) + mallocedbytes(l.workspacebwf),
+	}
+	if l.x != nil {
+		s.InputDims = [][]int32{tensordims(l.x)}
+	}
+	if s.Type == "" {
+		s.Type = l.layername()
+	}
+	switch {
+	case l.cnn != nil:
+		_, _, s.FilterDims, _ = l.cnn.FilterProps()
+		s.Pad, s.Stride, s.Dilation = l.cnn.Properties()
+	case l.cnntranspose != nil:
+		_, _, s.FilterDims, _ = l.cnntranspose.FilterProps()
+		s.Pad, s.Stride, s.Dilation = l.cnntranspose.Properties()
+	case l.pool != nil:
+		_, s.FilterDims, s.Pad, s.Stride, _, _ = l.pool.Properties()
+	case l.activation != nil:
+		s.AMode, _ = activationmodetostring(ActivationMode{l.activation.Mode()})
+	}
+	ts, _ := l.savedtensors()
+	for _, t := range ts {
+		if strings.HasPrefix(t.name, "running_") {
+			continue
+		}
+		s.Params += shapes.Volume(t.dims)
+	}
+	for _, g := range l.gradients() {
+		s.GradientBytes += volumebytes(g.Volume)
+	}
+	return s
+}
+
+func (c *Concat) summary() LayerSummary {
+	s := LayerSummary{
+		ID:              -1,
+		Type:            "Concat",
+		ActivationBytes: volumebytes(c.dest),
+		GradientBytes:   volumebytes(c.deltadest),
+	}
+	for _, src := range c.srcs {
+		if src != nil {
+			s.InputDims = append(s.InputDims, src.Dims())
+		}
+	}
+	if c.dest != nil {
+		s.OutputDims = c.dest.Dims()
+	}
+	return s
+}
+
+func (c *ReverseConcat) summary() LayerSummary {
+	s := LayerSummary{
+		ID:   -1,
+		Type: "ReverseConcat",
+	}
+	if c.src != nil {
+		s.InputDims = [][]int32{c.src.Dims()}
+	}
+	for i := range c.dests {
+		s.ActivationBytes += volumebytes(c.dests[i])
+	}
+	for i := range c.deltadests {
+		s.GradientBytes += volumebytes(c.deltadests[i])
+	}
+	if len(c.dests) > 0 && c.dests[0] != nil {
+		s.OutputDims = c.dests[0].Dims()
+	}
+	return s
+}
+
+func (s *Summary) add(l LayerSummary) {
+	s.Layers = append(s.Layers, l)
+	s.Params += l.Params
+	s.ActivationBytes += l.ActivationBytes
+	s.GradientBytes += l.GradientBytes
+	s.WorkspaceBytes += l.WorkspaceBytes
+}
+
+func (s *Summary) merge(o *Summary) {
+	for _, l := range o.Layers {
+		s.add(l)
+	}
+}
+
+//String returns the summary as a table with the totals on the last row
+func (s *Summary) String() string {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Module\tID\tType\tInput\tOutput\tFilter\tPad\tStride\tDilation\tParams\tAMode\tActivations\tGradients\tWorkspace\t")
+	for _, l := range s.Layers {
+		id := "-"
+		if l.ID >= 0 {
+			id = fmt.Sprint(l.ID)
+		}
+		inputs := make([]string, len(l.InputDims))
+		for i := range l.InputDims {
+			inputs[i] = dimsstring(l.InputDims[i])
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t\n",
+			l.Module, id, l.Type, strings.Join(inputs, " "), dimsstring(l.OutputDims), dimsstring(l.FilterDims),
+			dimsstring(l.Pad), dimsstring(l.Stride), dimsstring(l.Dilation), l.Params, l.AMode,
+			bytesstring(l.ActivationBytes), bytesstring(l.GradientBytes), bytesstring(l.WorkspaceBytes))
+	}
+	fmt.Fprintf(w, "Total\t\t\t\t\t\t\t\t\t%d\t\t%s\t%s\t%s\t\n",
+		s.Params, bytesstring(s.ActivationBytes), bytesstring(s.GradientBytes), bytesstring(s.WorkspaceBytes))
+	w.Flush()
+	return buf.String()
+}
+
+func dimsstring(dims []int32) string {
+	if len(dims) == 0 {
+		return ""
+	}
+	return strings.Replace(strings.Trim(fmt.Sprint(dims), "[]"), " ", "x", -1)
+}
+
+func bytesstring(b uint) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+func tensordims(t *Tensor) []int32 {
+	if t == nil || t.Tensor == nil || t.Volume == nil {
+		return nil
+	}
+	return t.Dims()
+}
+
+func tensorbytes(t *Tensor) uint {
+	if t == nil || t.Tensor == nil {
+		return 0
+	}
+	return volumebytes(t.Volume)
+}
+
+func volumebytes(v *tensor.Volume) uint {
+	if v == nil {
+		return 0
+	}
+	return mallocedbytes(v.Malloced)
+}
+
+func mallocedbytes(m *nvidia.Malloced) uint {
+	if m == nil {
+		return 0
+	}
+	return m.SIB()
+}
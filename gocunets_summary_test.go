@@ -0,0 +1,53 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSimpleModuleNetworkSummary(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+
+	spec, err := ReadNetworkSpec(strings.NewReader(testnetworkspec))
+	check(err)
+	shapes, err := spec.InferShapes()
+	check(err)
+	mnet, err := spec.Build(CreateBuilder(handle))
+	check(err)
+	summary := mnet.Summary()
+	if summary.Params != shapes.Params {
+		t.Errorf("summary has %d params but shape inference found %d", summary.Params, shapes.Params)
+	}
+	//The softmax classifier is a row in both
+	if len(summary.Layers) != len(shapes.Layers) {
+		t.Errorf("summary has %d layers but shape inference found %d", len(summary.Layers), len(shapes.Layers))
+	}
+	for i := range summary.Layers {
+		if !int32sequal(summary.Layers[i].OutputDims, shapes.Layers[i].OutputDims) {
+			t.Errorf("layer %d output dims are %v but shape inference found %v", i, summary.Layers[i].OutputDims, shapes.Layers[i].OutputDims)
+		}
+	}
+	if summary.Layers[1].AMode != "Relu" {
+		t.Errorf("activation mode is %s", summary.Layers[1].AMode)
+	}
+	if summary.ActivationBytes == 0 || summary.GradientBytes == 0 {
+		t.Error("summary doesn't have the memory of the tensors")
+	}
+	table := summary.String()
+	if !strings.HasPrefix(table, "Module") || !strings.Contains(table, "Total") {
+		t.Error(table)
+	}
+	if _, err = json.Marshal(summary); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,9 +1,13 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 import (
 	"errors"
 	"fmt"
 
+	"github.com/dereklstinson/gocunets/cpu"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/layers"
@@ -47,7 +51,8 @@ type Layer struct {
 	batch           *batchnorm.Layer
 	reshape         *reshape.Layer
 	cnntranspose    *cnntranspose.Layer
-	other           Operation //Operation will eventually take over
+	other           Operation     //Operation will eventually take over
+	host            cpu.Operation //set by the layers of a cpu Builder
 	x, dx, y, dy    *Tensor
 	memoryeffecient bool
 
@@ -147,24 +152,33 @@ func createlayer(id int64, handle *Handle, op interface{}) (l *Layer, err error)
 	switch x := op.(type) {
 	case *activation.Layer:
 		l.activation = x
+		l.name = "Activation"
 	case *cnn.Layer:
 		l.cnn = x
+		l.name = "CNN"
 	case *pooling.Layer:
 		l.pool = x
+		l.name = "Pooling"
 	case *dropout.Layer:
 		l.drop = x
+		l.name = "DropOut"
 	case *batchnorm.Layer:
 		l.batch = x
+		l.name = "BatchNorm"
 	case *reshape.Layer:
 		l.reshape = x
+		l.name = "Reshape"
 	case *cnntranspose.Layer:
 		l.cnntranspose = x
+		l.name = "CNN-Transpose"
 	case Operation:
 		l.other = x
+		l.name = "Operation"
 	default:
 		return nil, errors.New("Unsupported Layer")
 
 	}
+	l.id = id
 	l.h = handle
 	return l, err
 
@@ -217,6 +231,9 @@ func (l *Layer) LoadTrainer(handle *cudnn.Handler, batchsize int, trainers ...tr
 }
 func (l *Layer) loadtrainer(handle *cudnn.Handler, batchsize int, trainers ...trainer.Trainer) error {
 	l.batchsize = batchsize
+	if l.host != nil {
+		return errors.New("layers on the cpu need cpu trainers. Use LoadHostTrainer")
+	}
 	if l.cnn != nil {
 		if len(trainers) != 2 {
 			fmt.Println(len(trainers))
@@ -259,13 +276,16 @@ func (l *Layer) loadtrainer(handle *cudnn.Handler, batchsize int, trainers ...tr
 				return fmt.Errorf("l.other got %d should get %d", len(trainers), tneed)
 			}
 		}
-		l.other.LoadTrainers(handle, trainers...)
+		return l.other.LoadTrainers(handle, trainers...)
 	}
 
 	return errors.New("inbedded error doesn't support trainers")
 }
 
 func (l *Layer) trainersneeded() int {
+	if l.host != nil {
+		return l.host.TrainersNeeded()
+	}
 	if l.cnn != nil {
 		return 2
 	}
@@ -344,7 +364,9 @@ func wraplayer(input interface{}) (hidden *Layer, ios int) {
 
 //SetForwardScalars sets the forward scalars.
 func (l *Layer) SetForwardScalars(alpha, beta float64) {
-	if l.cnn != nil {
+	if l.host != nil {
+		l.host.SetForwardScalars(alpha, beta)
+	} else if l.cnn != nil {
 		l.cnn.SetForwardScalars(alpha, beta)
 	} else if l.cnntranspose != nil {
 		l.cnntranspose.SetForwardScalars(alpha, beta)
@@ -359,7 +381,9 @@ func (l *Layer) SetForwardScalars(alpha, beta float64) {
 
 //SetBackwardScalars sets backward scalars
 func (l *Layer) SetBackwardScalars(alpha, beta float64) {
-	if l.cnn != nil {
+	if l.host != nil {
+		l.host.SetBackwardScalars(alpha, beta)
+	} else if l.cnn != nil {
 		l.cnn.SetBackwardScalars(alpha, beta)
 	} else if l.cnntranspose != nil {
 		l.cnntranspose.SetBackwardScalars(alpha, beta)
@@ -374,7 +398,9 @@ func (l *Layer) SetBackwardScalars(alpha, beta float64) {
 
 //SetOtherScalars sets other scalars that the layer might have scalars
 func (l *Layer) SetOtherScalars(alpha, beta float64) {
-	if l.cnn != nil {
+	if l.host != nil {
+		l.host.SetOtherScalars(alpha, beta)
+	} else if l.cnn != nil {
 		l.cnn.SetOtherScalars(alpha, beta)
 	} else if l.cnntranspose != nil {
 		l.cnntranspose.SetOtherScalars(alpha, beta)
@@ -433,6 +459,12 @@ func (l *Layer) getoutputwithname(handle *cudnn.Handler, input *layers.Tensor) (
 
 //GetOutputDims gets the dims of the output tensor
 func (l *Layer) GetOutputDims(input *Tensor) (output []int32, err error) {
+	if l.host != nil {
+		if input.host == nil {
+			return nil, errors.New("(l *Layer) GetOutputDims: layer is on the cpu but input isn't")
+		}
+		return l.host.GetOutputDims(input.host)
+	}
 	if l.cnn != nil {
 		return l.cnn.FindOutputDims(input.Tensor)
 	}
@@ -550,6 +582,9 @@ func (l *Layer) getoutput(handle *cudnn.Handler, input *layers.Tensor) (io *laye
 func (l *Layer) updateWeights(epoch int) error {
 
 	batch := l.batchsize
+	if l.host != nil {
+		return l.host.UpdateWeights(batch, epoch)
+	}
 	if l.cnn != nil {
 		return l.cnn.UpdateWeights(l.h.Handler, batch, epoch)
 	}
@@ -576,6 +611,9 @@ func (l *Layer) updateWeights(epoch int) error {
 }
 
 func (l *Layer) l1l2loss() (l1, l2 float32) {
+	if h, ok := l.host.(interface{ L1L2Loss() (float32, float32) }); ok {
+		return h.L1L2Loss()
+	}
 
 	if l.cnn != nil {
 		return l.cnn.L1L2Loss()
@@ -1,3 +1,6 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 import (
@@ -7,13 +10,26 @@ import (
 	//"github.com/dereklstinson/gocunets/layers"
 )
 
+//backpropfilter only finds the gradients of the weights of the layer.  dx isn't needed and isn't changed.
 func (l *Layer) backpropfilter() error {
 	//	return l.h.w.Work(func() error {
 	var err error
-	x, dx, y, dy := l.x.Tensor, l.dx.Tensor, l.y.Tensor, l.dy.Tensor
+	var x, dx, y, dy *layers.Tensor
+	if l.x != nil {
+		x = l.x.Tensor
+	}
+	if l.dx != nil {
+		dx = l.dx.Tensor
+	}
+	if l.y != nil {
+		y = l.y.Tensor
+	}
+	if l.dy != nil {
+		dy = l.dy.Tensor
+	}
 
 	if l.cnn != nil {
-		err = l.cnn.BackPropFilter(l.h.Handler, l.workspacebwf, x, y)
+		err = l.cnn.BackPropFilter(l.h.Handler, l.workspacebwf, x, dy)
 		if err != nil {
 			println("bpfd error in cnn")
 			return err
@@ -76,7 +92,7 @@ func (l *Layer) backpropfilter() error {
 		return nil
 	}
 	if l.cnntranspose != nil {
-		err = l.cnntranspose.BackPropFilter(l.h.Handler, l.workspacebwd, x, y)
+		err = l.cnntranspose.BackPropFilter(l.h.Handler, l.workspacebwf, x, dy)
 		if err != nil {
 			println("bpfd error in cnntranspose")
 			return err
@@ -93,6 +109,9 @@ func (l *Layer) backpropfilter() error {
 //BackProp does the backprop of a layer
 func (l *Layer) backpropfilterdata() error {
 	//return l.h.w.Work(func() error {
+	if l.host != nil {
+		return l.host.Backward(l.hostio())
+	}
 	err := l.h.Sync()
 	if err != nil {
 		return err
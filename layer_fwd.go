@@ -1,3 +1,6 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 import (
@@ -9,6 +12,10 @@ import (
 //ForwardProp does the forward prop for a layer
 func (l *Layer) forwardprop() error {
 	//	return l.h.w.Work(func() error {
+	if l.host != nil {
+		x, _, y, _ := l.hostio()
+		return l.host.Forward(x, y)
+	}
 	fwdws := l.workspacefwd
 	x, y := l.x.Tensor, l.y.Tensor
 	err := l.h.Sync()
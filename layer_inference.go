@@ -1,3 +1,6 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 import (
@@ -10,6 +13,10 @@ import (
 )
 
 func (l *Layer) inference(handle *cudnn.Handler, fwdwspace, bwddwspace *nvidia.Malloced) error {
+	if l.host != nil {
+		x, _, y, _ := l.hostio()
+		return l.host.Inference(x, y)
+	}
 	x, y := l.x.Tensor, l.y.Tensor
 	err := handle.Sync()
 	if err != nil {
@@ -0,0 +1,84 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+import (
+	"errors"
+
+	"github.com/dereklstinson/gocunets/cpu"
+)
+
+//Layer is a layer inside a network it holds inputs and outputs.
+//Without the cuda tag its operation is always on the host.
+type Layer struct {
+	id           int64
+	name         string
+	host         cpu.Operation
+	x, dx, y, dy *Tensor
+	batchsize    int
+}
+
+//ID is the ID of the layer
+func (l *Layer) ID() int64 {
+	return l.id
+}
+
+//SetIOs sets the x,dx,y,dy used by the layer
+func (l *Layer) SetIOs(x, dx, y, dy *Tensor) {
+	l.x, l.dx, l.y, l.dy = x, dx, y, dy
+}
+
+//SetInputs sets the inputs
+func (l *Layer) SetInputs(x, dx *Tensor) {
+	l.x, l.dx = x, dx
+}
+
+//SetOutputs sets the outputs
+func (l *Layer) SetOutputs(y, dy *Tensor) {
+	l.y, l.dy = y, dy
+}
+
+//Forward performs the forward propagation
+func (l *Layer) Forward() error {
+	x, _, y, _ := l.hostio()
+	return l.host.Forward(x, y)
+}
+
+//Backward performs the backward propagation
+func (l *Layer) Backward() error {
+	return l.host.Backward(l.hostio())
+}
+
+//Update updates weights if layer has them
+func (l *Layer) Update(epoch int) error {
+	return l.host.UpdateWeights(l.batchsize, epoch)
+}
+
+//ChangeBatchSize will change the batch size
+func (l *Layer) ChangeBatchSize(batchsize int) {
+	l.batchsize = batchsize
+}
+
+//SetForwardScalars sets the forward scalars.
+func (l *Layer) SetForwardScalars(alpha, beta float64) {
+	l.host.SetForwardScalars(alpha, beta)
+}
+
+//SetBackwardScalars sets backward scalars
+func (l *Layer) SetBackwardScalars(alpha, beta float64) {
+	l.host.SetBackwardScalars(alpha, beta)
+}
+
+//SetOtherScalars sets other scalars that the layer might have scalars
+func (l *Layer) SetOtherScalars(alpha, beta float64) {
+	l.host.SetOtherScalars(alpha, beta)
+}
+
+//GetOutputDims gets the dims of the output tensor
+func (l *Layer) GetOutputDims(input *Tensor) (output []int32, err error) {
+	if input.host == nil {
+		return nil, errors.New("(l *Layer) GetOutputDims: input isn't on the cpu")
+	}
+	return l.host.GetOutputDims(input.host)
+}
@@ -1,6 +1,8 @@
 package activation
 
 import (
+	"errors"
+
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/activation"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/reduce"
@@ -35,6 +37,7 @@ type Layer struct {
 	dnegCoefs                    *layers.Tensor
 	dthreshold                   *layers.Tensor
 	numofios                     int
+	coef                         float64
 }
 
 //Info is a struct that contains the info that is needed to build the activation layer
@@ -71,6 +74,7 @@ func setup(handle *cudnn.Handler, mode activation.Mode, dtype gocudnn.DataType,
 	return &Layer{
 		mode: mode,
 		act:  act,
+		coef: coef,
 		fwd: Scalars{
 			Alpha: af,
 			Beta:  bf,
@@ -168,6 +172,27 @@ func Tanh(handle *cudnn.Handler, dtype gocudnn.DataType) (*Layer, error) {
 	return setup(handle, flg.Tanh(), dtype, defaultnanprop, defaultalpha, defaultbeta, defaultalpha, defaultbeta, defaultcoef)
 }
 
+//WithCoef returns an activation layer set to mode with coef.
+//coef is the alpha for Leaky and Elu and the ceiling for ClippedRelu. Modes with weights (PRelu, Threshhold) aren't supported.
+func WithCoef(handle *cudnn.Handler, mode activation.Mode, dtype gocudnn.DataType, coef float64) (*Layer, error) {
+	flg := mode
+	switch mode {
+	case flg.PRelu(), flg.Threshhold():
+		return nil, errors.New("activation.WithCoef: mode has weights")
+	}
+	return setup(handle, mode, dtype, defaultnanprop, defaultalpha, defaultbeta, defaultalpha, defaultbeta, coef)
+}
+
+//Mode returns the activation mode of the layer
+func (a *Layer) Mode() activation.Mode {
+	return a.mode
+}
+
+//Coef returns the coef the layer was setup with. It is the alpha for leaky and elu, and the ceiling for clipped relu.
+func (a *Layer) Coef() float64 {
+	return a.coef
+}
+
 //Info Returns layer info if error is not nil then values will be set to golang default
 func (a *Layer) Info() (Info, error) {
 	op, err := a.act.Info()
@@ -286,6 +311,21 @@ func (a *Layer) Threshhold() *layers.Tensor {
 	return a.threshold
 }
 
+//DeltaPosCoefs returns the gradient of PosCoefs
+func (a *Layer) DeltaPosCoefs() *layers.Tensor {
+	return a.dposCoefs
+}
+
+//DeltaNegCoefs returns the gradient of NegCoefs
+func (a *Layer) DeltaNegCoefs() *layers.Tensor {
+	return a.dnegCoefs
+}
+
+//DeltaThreshhold returns the gradient of Threshhold
+func (a *Layer) DeltaThreshhold() *layers.Tensor {
+	return a.dthreshold
+}
+
 /*
 //Destroy destroys the cuda allocated memory for activation
 func (a *Layer) Destroy() error {
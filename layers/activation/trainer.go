@@ -51,6 +51,20 @@ func (l *Layer) LoadTrainer(handle *cudnn.Handler, trainers []trainer.Trainer) e
 	return nil
 }
 
+//Trainers returns the trainers that were loaded in the order of the negcoefs, poscoefs, and threshold.
+func (l *Layer) Trainers() (trainers []trainer.Trainer) {
+	if l.negCoefs != nil && l.negcotrain != nil {
+		trainers = append(trainers, l.negcotrain)
+	}
+	if l.posCoefs != nil && l.poscotrain != nil {
+		trainers = append(trainers, l.poscotrain)
+	}
+	if l.threshold != nil && l.thresholdtrain != nil {
+		trainers = append(trainers, l.thresholdtrain)
+	}
+	return trainers
+}
+
 //UpdateWeights does the weight update
 func (l *Layer) UpdateWeights(handle *cudnn.Handler, batch, epoch int) error {
 	var err error
@@ -3,6 +3,7 @@ package batchnorm
 import (
 	"fmt"
 
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/batchnorm"
 	"github.com/dereklstinson/gocunets/layers"
@@ -161,6 +162,28 @@ func (l *Layer) Scale() *layers.Tensor {
 	return l.scale
 }
 
+//DeltaBias returns the gradient of the bias of the batch norm
+func (l *Layer) DeltaBias() *layers.Tensor {
+	return l.dbias
+}
+
+//DeltaScale returns the gradient of the scale of the batch norm
+func (l *Layer) DeltaScale() *layers.Tensor {
+	return l.dscale
+}
+
+//RunningMean returns the running mean of the batch norm. It is nil until SetupPreset is ran.
+func (l *Layer) RunningMean() *nvidia.Malloced {
+	mean, _ := l.b.RunningMeanVariance()
+	return mean
+}
+
+//RunningVariance returns the running variance of the batch norm. It is nil until SetupPreset is ran.
+func (l *Layer) RunningVariance() *nvidia.Malloced {
+	_, variance := l.b.RunningMeanVariance()
+	return variance
+}
+
 //Trainers returns the trainers
 func (l *Layer) Trainers() (scale, bias trainer.Trainer) {
 	return l.scaletrain, l.biastrain
@@ -291,6 +314,16 @@ func (l *Layer) SetOtherScalars(alpha, beta float64) {
 	l.bwp.a, l.bwp.b = alpha, beta
 }
 
+//OtherScalars returns the scalars for the weights
+func (l *Layer) OtherScalars() (alpha, beta float64) {
+	return l.bwp.a, l.bwp.b
+}
+
+//Eps returns epsilon
+func (l *Layer) Eps() float64 {
+	return l.eps
+}
+
 //SetEps sets epsilon
 func (l *Layer) SetEps(eps float64) {
 	if eps >= float64(1e-5) {
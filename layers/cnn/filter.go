@@ -116,6 +116,22 @@ func (c *Layer) LoadTrainer(handle *cudnn.Handler, forweights, forbias trainer.T
 	return err
 }
 
+//Trainers returns the trainers for the weights and bias. They are nil until LoadTrainer is ran.
+func (c *Layer) Trainers() (weights, bias trainer.Trainer) {
+	return c.train, c.btrain
+}
+
+//Properties returns the pad, stride and dilation that the layer was setup with
+func (c *Layer) Properties() (pad, stride, dilation []int32) {
+	return c.pad, c.stride, c.dilation
+}
+
+//ConvolutionMode returns the convolution mode that the layer was setup with
+func (c *Layer) ConvolutionMode() (gocudnn.ConvolutionMode, error) {
+	info, err := c.conv.Info()
+	return info.CMode, err
+}
+
 //Bias returns the Bias
 func (c *Layer) Bias() *layers.Tensor {
 	return c.bias
@@ -126,6 +142,11 @@ func (c *Layer) DeltaWeights() *layers.Tensor {
 	return c.dw
 }
 
+//DeltaBias returns the delta bias
+func (c *Layer) DeltaBias() *layers.Tensor {
+	return c.dbias
+}
+
 //Weights returns the weights
 func (c *Layer) Weights() *layers.Tensor {
 	return c.w
@@ -386,6 +407,11 @@ func (c *Layer) SetOtherScalars(alpha, beta float64) {
 	c.bwdf.alpha, c.bwdf.beta = alpha, beta
 }
 
+//OtherScalars returns the alpha and beta scalars for the weights
+func (c *Layer) OtherScalars() (alpha, beta float64) {
+	return c.bwdf.alpha, c.bwdf.beta
+}
+
 func find4doutputdims(x, w, padding, stride, dilation []int32, frmt gocudnn.TensorFormat) []int32 {
 	var flag gocudnn.TensorFormat
 	if frmt == flag.NCHW() {
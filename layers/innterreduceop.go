@@ -179,8 +179,12 @@ func (r *reduceop) Reduce(handle *cudnn.Handler, x *tensor.Volume) (float32, err
 		return 0, err
 	}
 
-	bsize := x.SIB()
-	err = nvidia.Memcpy(r.gptr, x, bsize)
+	err = handle.Sync()
+	if err != nil {
+		return 0, err
+	}
+	bsize := r.mem.SIB()
+	err = nvidia.Memcpy(r.gptr, r.mem, bsize)
 	if err != nil {
 		return 0, err
 	}
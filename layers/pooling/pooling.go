@@ -24,6 +24,13 @@ func (l *Layer) GetOutputDims(input *layers.Tensor) ([]int32, error) {
 	return l.pD.OutputDims(input.Volume)
 }
 
+//Properties returns the mode, window, padding and stride of the pooling layer.
+//reverse is true if the layer was made to do a reverse (upsampling) pooling.
+func (l *Layer) Properties() (mode gocudnn.PoolingMode, window, padding, stride []int32, reverse bool, err error) {
+	mode, _, window, padding, stride, err = l.pD.Properties()
+	return mode, window, padding, stride, l.pD.Reverse(), err
+}
+
 //MakeOutputTensor will make the outputlayer for you
 func (l *Layer) MakeOutputTensor(handle *cudnn.Handler, input *layers.Tensor) (*layers.Tensor, error) {
 	frmt, dtype, _, err := input.Properties()
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/tensor"
 	"github.com/dereklstinson/gocunets/utils"
@@ -134,6 +135,14 @@ func CreateTensor(handle *cudnn.Handler, frmt gocudnn.TensorFormat, dtype gocudn
 
 }
 
+//CreateTensorEX creates a tensor that uses mem instead of allocating its own.  If mem is nil it will allocate memory.
+//It is used for views of a tensor with different dims.
+func CreateTensorEX(handle *cudnn.Handler, frmt gocudnn.TensorFormat, dtype gocudnn.DataType, dims []int32, mem *nvidia.Malloced) (t *Tensor, err error) {
+	t = new(Tensor)
+	t.Volume, err = tensor.BuildEX(handle, frmt, dtype, dims, mem)
+	return t, err
+}
+
 //LoadValuesFromSLice takes a go slice and fills it into the tensor sitting in the gpu.  If the length of goslice doesn't fit the input it will return an error
 func (t *Tensor) LoadValuesFromSLice(handle *cudnn.Handler, input interface{}, length int32) error {
 	if utils.FindVolumeInt32(t.Dims(), nil) != length {
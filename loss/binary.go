@@ -1,6 +1,13 @@
 package loss
 
-import "math"
+import (
+	"math"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/activation"
+	"github.com/dereklstinson/gocunets/layers"
+	gocudnn "github.com/dereklstinson/gocudnn"
+)
 
 //Binary Struct holds the binary loss and derivative calculations
 type Binary struct {
@@ -52,3 +59,98 @@ func (b Binary) DerivativeNSeperated(target, predicted []float32) (alldifferent
 	}
 	return alldifferent
 }
+
+//logitloss is the binary loss of the sigmoid of logit. It doesn't overflow for large logits.
+func (b Binary) logitloss(target, logit float32) float32 {
+	t, x := float64(target), float64(logit)
+	return float32(math.Max(x, 0) - x*t + math.Log1p(math.Exp(-math.Abs(x))))
+}
+
+//sigmoid returns 1/(1+e^-x)
+func (b Binary) sigmoid(x float32) float32 {
+	return float32(1 / (1 + math.Exp(-float64(x))))
+}
+
+//BinaryCrossEntropy is the binary cross entropy loss of a sigmoid.
+//Each output is its own yes or no, so it can be used for tagging where more than one label can be true.
+//
+//The output of the layer is the sigmoid of its input and the gradient is the sigmoid minus the target.
+//The loss of a batch is the sum of the losses of its elements.
+//
+//The sigmoid and the gradient are found on the device, so Inference never leaves it.  The loss needs a log that the cudnn
+//tensor ops don't have, so PerformError and TestForward copy x and target to the host to find it.  That is a sync and
+//two copies of the input size each step.
+type BinaryCrossEntropy struct {
+	hostloss
+	c       Binary
+	sigmoid *activation.Ops
+}
+
+//CreateBinaryCrossEntropy creates a binary cross entropy loss layer
+func CreateBinaryCrossEntropy(h *cudnn.Handler) (l *BinaryCrossEntropy) {
+	l = new(BinaryCrossEntropy)
+	l.h = h
+	return l
+}
+
+//element is the host version of the layer for one element. It is used to check the layer.
+func (l *BinaryCrossEntropy) element(x, target float32) (y, dx, loss float32) {
+	y = l.c.sigmoid(x)
+	return y, y - target, l.c.logitloss(target, x)
+}
+
+func (l *BinaryCrossEntropy) loss(x, target float32) float32 {
+	return l.c.logitloss(target, x)
+}
+
+//forward puts the sigmoid of x into y
+func (l *BinaryCrossEntropy) forward(x, y *layers.Tensor) (err error) {
+	if l.sigmoid == nil {
+		var mode activation.Mode
+		var nan gocudnn.NANProp
+		l.sigmoid, err = activation.Stage(l.h, mode.Sigmoid(), x.DataType(), nan.NotPropigate(), 0)
+		if err != nil {
+			return err
+		}
+	}
+	return l.sigmoid.FwdProp(l.h, 1, x.Volume, 0, y.Volume, nil, nil, nil)
+}
+
+//PerformError puts the sigmoid of x into y, puts the gradient of the loss into dx and finds the loss.
+//PerformError satisfies the loss layer interface
+func (l *BinaryCrossEntropy) PerformError(x, dx, y, target *layers.Tensor) (err error) {
+	err = l.forward(x, y)
+	if err != nil {
+		return err
+	}
+	err = dx.OpAdd(l.h, y.Volume, target.Volume, 1, -1, 0)
+	if err != nil {
+		return err
+	}
+	return l.run(l.loss, x, target)
+}
+
+//Inference puts the sigmoid of x into y
+func (l *BinaryCrossEntropy) Inference(x, y *layers.Tensor) (err error) {
+	return l.forward(x, y)
+}
+
+//TestForward puts the sigmoid of x into y and finds the loss
+func (l *BinaryCrossEntropy) TestForward(x, y, target *layers.Tensor) (err error) {
+	err = l.forward(x, y)
+	if err != nil {
+		return err
+	}
+	return l.run(l.loss, x, target)
+}
+
+//GetAverageBatchLoss gets the averagebatchloss
+//It also satisfies the gocunets.LossLayer interface
+func (l *BinaryCrossEntropy) GetAverageBatchLoss() float32 {
+	return l.average()
+}
+
+//GetBatchLoss gets the loss by batch
+func (l *BinaryCrossEntropy) GetBatchLoss() []float32 {
+	return l.batchloss
+}
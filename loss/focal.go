@@ -0,0 +1,229 @@
+package loss
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+	"github.com/dereklstinson/gocunets/layers/softmax"
+	gocudnn "github.com/dereklstinson/gocudnn"
+)
+
+//softmaxloss is a softmax along the channels followed by a cross entropy where each class has a weight and
+//the loss of each class is scaled by (1-p)^gamma.
+//
+//The softmax is done on the device, so Inference never leaves it.  The loss and its gradient are found on the host
+//because (1-p)^gamma and the log aren't cudnn tensor ops.  Each PerformError syncs the handle, copies y and target to
+//the host and copies dx back.  TestForward does the same without dx.  For large outputs that is the cost of the layer.
+//
+//The loss is kept for each class so that the average isn't taken over by the class with the most samples.
+type softmaxloss struct {
+	h          *cudnn.Handler
+	s          *softmax.Layer
+	gamma      float64
+	alpha      []float32
+	t          []float32
+	y, dx      []float32
+	p, a       []float64
+	classloss  []float64
+	classcount []float64
+}
+
+//stage stages the device softmax of the layer
+func (l *softmaxloss) stage(h *cudnn.Handler) {
+	l.h = h
+	l.s = softmax.StageAccuratePerChannel(nil)
+	l.s.SetForwardScalars(1, 0)
+}
+
+//run puts the softmax of x in y.  If target isn't nil y and target are copied to the host to find the class losses,
+//and if dx isn't nil the gradient is put in dx.
+func (l *softmaxloss) run(x, dx, y, target *layers.Tensor) (err error) {
+	var dtype gocudnn.DataType
+	if x.DataType() != dtype.Float() {
+		return errors.New("only float tensors are supported")
+	}
+	size := x.Vol()
+	if y.Vol() != size || (dx != nil && dx.Vol() != size) || (target != nil && target.Vol() != size) {
+		return errors.New("x, dx, y and target need to have the same volume")
+	}
+	classes, cstride, nhwc := classaxis(x)
+	if l.alpha != nil && len(l.alpha) != classes {
+		return fmt.Errorf("has %d class weights but x has %d classes", len(l.alpha), classes)
+	}
+	err = l.s.ForwardProp(l.h, x, y)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return nil
+	}
+	if int32(len(l.y)) != size {
+		l.t, l.y, l.dx = make([]float32, size), make([]float32, size), make([]float32, size)
+	}
+	if len(l.p) != classes {
+		l.p, l.a = make([]float64, classes), make([]float64, classes)
+		l.classloss, l.classcount = make([]float64, classes), make([]float64, classes)
+	}
+	err = l.h.Sync()
+	if err != nil {
+		return err
+	}
+	err = y.FillSlice(l.h, l.y)
+	if err != nil {
+		return err
+	}
+	err = target.FillSlice(l.h, l.t)
+	if err != nil {
+		return err
+	}
+	for i := range l.classloss {
+		l.classloss[i], l.classcount[i] = 0, 0
+	}
+	positions := int(size) / classes
+	for pos := 0; pos < positions; pos++ {
+		base := pos * classes
+		if !nhwc {
+			base = (pos/cstride)*classes*cstride + pos%cstride
+		}
+		l.position(base, cstride)
+	}
+	if dx != nil {
+		return dx.LoadValuesFromSLice(l.h, l.dx, size)
+	}
+	return nil
+}
+
+//position finds the loss and the gradient of the classes at base, base+stride, ... from the softmax in y.
+//
+//With loss L = -sum alpha_c*t_c*(1-p_c)^gamma*log(p_c) and a_c = p_c*dL/dp_c, the gradient of the input j is a_j - p_j*sum(a).
+func (l *softmaxloss) position(base, stride int) {
+	for c := range l.p {
+		l.p[c] = float64(l.y[base+c*stride])
+	}
+	var asum float64
+	for c, p := range l.p {
+		t := float64(l.t[base+c*stride])
+		l.a[c] = 0
+		if t == 0 {
+			continue
+		}
+		w := t
+		if l.alpha != nil {
+			w *= float64(l.alpha[c])
+		}
+		logp := math.Log(math.Max(p, 1e-30))
+		scale := math.Pow(1-p, l.gamma)
+		l.a[c] = -w * scale
+		if l.gamma != 0 && p < 1 {
+			l.a[c] += w * l.gamma * math.Pow(1-p, l.gamma-1) * p * logp
+		}
+		asum += l.a[c]
+		l.classloss[c] -= w * scale * logp
+		l.classcount[c] += t
+	}
+	for c, p := range l.p {
+		l.dx[base+c*stride] = float32(l.a[c] - p*asum)
+	}
+}
+
+//ClassLoss returns the average loss of each class from the last time the loss was found.
+//A class that wasn't in the targets has a loss of 0.
+func (l *softmaxloss) ClassLoss() []float32 {
+	loss := make([]float32, len(l.classloss))
+	for i := range loss {
+		if l.classcount[i] > 0 {
+			loss[i] = float32(l.classloss[i] / l.classcount[i])
+		}
+	}
+	return loss
+}
+
+//Weights returns a copy of the weight of each class.  It is nil if each class has a weight of 1.
+func (l *softmaxloss) Weights() []float32 {
+	if l.alpha == nil {
+		return nil
+	}
+	w := make([]float32, len(l.alpha))
+	copy(w, l.alpha)
+	return w
+}
+
+//GetAverageBatchLoss returns the average of the losses of the classes that were in the targets,
+//so each class counts the same no matter how many samples it has.
+func (l *softmaxloss) GetAverageBatchLoss() float32 {
+	var loss float64
+	var n int
+	for i := range l.classloss {
+		if l.classcount[i] > 0 {
+			loss += l.classloss[i] / l.classcount[i]
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float32(loss / float64(n))
+}
+
+//PerformError puts the softmax of x into y, puts the gradient of the loss into dx and finds the loss of each class.
+//PerformError satisfies the loss layer interface
+func (l *softmaxloss) PerformError(x, dx, y, target *layers.Tensor) (err error) {
+	return l.run(x, dx, y, target)
+}
+
+//Inference puts the softmax of x into y
+func (l *softmaxloss) Inference(x, y *layers.Tensor) (err error) {
+	return l.run(x, nil, y, nil)
+}
+
+//TestForward puts the softmax of x into y and finds the loss of each class
+func (l *softmaxloss) TestForward(x, y, target *layers.Tensor) (err error) {
+	return l.run(x, nil, y, target)
+}
+
+//FocalLoss is the focal loss of a softmax along the channels.
+//The cross entropy of each class is scaled by alpha_c*(1-p_c)^gamma so that classes that are already found with confidence add little to the loss.
+type FocalLoss struct {
+	softmaxloss
+}
+
+//CreateFocalLoss creates a focal loss layer. alpha has a weight for each class. If alpha is nil each class has a weight of 1.
+//A gamma of 0 is the weighted cross entropy.
+func CreateFocalLoss(h *cudnn.Handler, gamma float32, alpha []float32) (l *FocalLoss, err error) {
+	if gamma < 0 {
+		return nil, errors.New("CreateFocalLoss: gamma can't be negative")
+	}
+	l = new(FocalLoss)
+	l.stage(h)
+	l.gamma = float64(gamma)
+	if alpha != nil {
+		l.alpha = make([]float32, len(alpha))
+		copy(l.alpha, alpha)
+	}
+	return l, nil
+}
+
+//Gamma returns the gamma the layer was created with
+func (l *FocalLoss) Gamma() float32 {
+	return float32(l.gamma)
+}
+
+//WeightedSoftMax is the cross entropy of a softmax along the channels where the loss of each class is scaled by its weight.
+type WeightedSoftMax struct {
+	softmaxloss
+}
+
+//CreateWeightedSoftMax creates a class weighted softmax cross entropy layer. weights has a weight for each class.
+func CreateWeightedSoftMax(h *cudnn.Handler, weights []float32) (l *WeightedSoftMax, err error) {
+	if len(weights) == 0 {
+		return nil, errors.New("CreateWeightedSoftMax: needs a weight for each class")
+	}
+	l = new(WeightedSoftMax)
+	l.stage(h)
+	l.alpha = make([]float32, len(weights))
+	copy(l.alpha, weights)
+	return l, nil
+}
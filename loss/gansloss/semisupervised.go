@@ -69,3 +69,25 @@ func (s SemiSuper) GeneratorBinaryError(fake []float32, classificationsize int,
 	}
 	return -summer / float32(batches)
 }
+
+//FakeTargets returns the targets of a batch of fakes for a discriminator with classsize outputs where the fake class is the last one
+func (s SemiSuper) FakeTargets(batchsize, classsize int) []float32 {
+	targets := make([]float32, batchsize*classsize)
+	for i := 0; i < batchsize; i++ {
+		targets[(i+1)*classsize-1] = 1
+	}
+	return targets
+}
+
+//GeneratorTargets returns the targets of a batch of fakes when the generator is trained.
+//The generator wants the fakes to be any of the real classes, so each real class gets an equal part.
+func (s SemiSuper) GeneratorTargets(batchsize, classsize int) []float32 {
+	targets := make([]float32, batchsize*classsize)
+	real := float32(1) / float32(classsize-1)
+	for i := 0; i < batchsize; i++ {
+		for j := 0; j < classsize-1; j++ {
+			targets[i*classsize+j] = real
+		}
+	}
+	return targets
+}
@@ -0,0 +1,85 @@
+package loss
+
+import (
+	"errors"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+	gocudnn "github.com/dereklstinson/gocudnn"
+)
+
+//elementloss finds the loss of one element from its input and target
+type elementloss func(x, target float32) float32
+
+//hostloss copies the input and target of a loss to the host so that the loss can be found element by element.
+//It is used for losses the cudnn tensor ops can't find.  Only float tensors are supported.
+type hostloss struct {
+	h         *cudnn.Handler
+	x, t      []float32
+	batchloss []float32
+}
+
+//run finds the loss of each batch with f
+func (l *hostloss) run(f elementloss, x, target *layers.Tensor) (err error) {
+	var dtype gocudnn.DataType
+	if x.DataType() != dtype.Float() {
+		return errors.New("only float tensors are supported")
+	}
+	dims := x.Dims()
+	size := x.Vol()
+	if target.Vol() != size {
+		return errors.New("x and target need to have the same volume")
+	}
+	if int32(len(l.x)) != size {
+		l.x, l.t = make([]float32, size), make([]float32, size)
+	}
+	if int32(len(l.batchloss)) != dims[0] {
+		l.batchloss = make([]float32, dims[0])
+	}
+	err = l.h.Sync()
+	if err != nil {
+		return err
+	}
+	err = x.FillSlice(l.h, l.x)
+	if err != nil {
+		return err
+	}
+	err = target.FillSlice(l.h, l.t)
+	if err != nil {
+		return err
+	}
+	per := int(size / dims[0])
+	for i := range l.batchloss {
+		l.batchloss[i] = 0
+	}
+	for i := range l.x {
+		l.batchloss[i/per] += f(l.x[i], l.t[i])
+	}
+	return nil
+}
+
+//average returns the average of the batch losses
+func (l *hostloss) average() float32 {
+	if len(l.batchloss) == 0 {
+		return 0
+	}
+	var loss float32
+	for i := range l.batchloss {
+		loss += l.batchloss[i]
+	}
+	return loss / float32(len(l.batchloss))
+}
+
+//classaxis returns the number of classes of x, the distance between the classes and if x is NHWC
+func classaxis(x *layers.Tensor) (classes, stride int, nhwc bool) {
+	var frmt gocudnn.TensorFormat
+	dims := x.Dims()
+	if x.Format() == frmt.NHWC() {
+		return int(dims[len(dims)-1]), 1, true
+	}
+	stride = 1
+	for _, d := range dims[2:] {
+		stride *= int(d)
+	}
+	return int(dims[1]), stride, false
+}
@@ -0,0 +1,131 @@
+package loss
+
+import (
+	"math"
+	"testing"
+)
+
+func TestElementLosses(t *testing.T) {
+	const step = 1e-3
+	huber := CreateHuberLoss(nil, 1)
+	bce := CreateBinaryCrossEntropy(nil)
+	for _, target := range []float32{0, .25, 1} {
+		for _, x := range []float32{-3, -.5, 0, .4, 2.5} {
+			y, dx, _ := huber.element(x, target)
+			_, _, lp := huber.element(x+step, target)
+			_, _, lm := huber.element(x-step, target)
+			if y != x || math.Abs(float64(dx-(lp-lm)/(2*step))) > 1e-2 {
+				t.Errorf("huber x %v target %v: y %v dx %v", x, target, y, dx)
+			}
+			y, dx, loss := bce.element(x, target)
+			_, _, lp = bce.element(x+step, target)
+			_, _, lm = bce.element(x-step, target)
+			if math.Abs(float64(dx-(lp-lm)/(2*step))) > 1e-2 {
+				t.Errorf("bce x %v target %v: dx %v", x, target, dx)
+			}
+			if expected := bce.c.loss32(target, y); math.Abs(float64(loss-expected)) > 1e-4 {
+				t.Errorf("bce x %v target %v: loss %v expected %v", x, target, loss, expected)
+			}
+		}
+	}
+	if _, _, loss := huber.element(5, 1); loss != 3.5 {
+		t.Errorf("huber loss of an error of 4 is %v", loss)
+	}
+	if _, _, loss := bce.element(100, 0); math.IsInf(float64(loss), 0) || loss != 100 {
+		t.Errorf("bce loss of a large logit is %v", loss)
+	}
+}
+
+func TestFocalLossGradient(t *testing.T) {
+	const step = 1e-3
+	focal, err := CreateFocalLoss(nil, 2, []float32{.25, 1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &focal.softmaxloss
+	l.p, l.a = make([]float64, 3), make([]float64, 3)
+	l.t = []float32{0, .2, .8}
+	l.dx = make([]float32, 3)
+	loss := func(x []float32) float64 {
+		l.y = hostsoftmax(x)
+		l.classloss, l.classcount = make([]float64, 3), make([]float64, 3)
+		l.position(0, 1)
+		return l.classloss[0] + l.classloss[1] + l.classloss[2]
+	}
+	x := []float32{.3, -1.2, .7}
+	loss(x)
+	dx := append([]float32(nil), l.dx...)
+	for i := range x {
+		plus := append([]float32(nil), x...)
+		minus := append([]float32(nil), x...)
+		plus[i] += step
+		minus[i] -= step
+		numeric := (loss(plus) - loss(minus)) / (2 * step)
+		if math.Abs(numeric-float64(dx[i])) > 1e-3 {
+			t.Errorf("dx %d is %v, expected %v", i, dx[i], numeric)
+		}
+	}
+	if focal.ClassLoss()[0] != 0 {
+		t.Error("a class that isn't a target should have no loss")
+	}
+
+	//With gamma 0 and weights of 1 the gradient is the softmax minus the target
+	weighted, err := CreateWeightedSoftMax(nil, []float32{1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = &weighted.softmaxloss
+	l.p, l.a = make([]float64, 3), make([]float64, 3)
+	l.classloss, l.classcount = make([]float64, 3), make([]float64, 3)
+	l.y, l.t = hostsoftmax(x), []float32{0, 0, 1}
+	l.dx = make([]float32, 3)
+	l.position(0, 1)
+	for i := range x {
+		if math.Abs(float64(l.dx[i]-(l.y[i]-l.t[i]))) > 1e-6 {
+			t.Errorf("dx %d is %v, expected %v", i, l.dx[i], l.y[i]-l.t[i])
+		}
+	}
+}
+
+//hostsoftmax is the softmax of x
+func hostsoftmax(x []float32) []float32 {
+	max := math.Inf(-1)
+	for _, v := range x {
+		max = math.Max(max, float64(v))
+	}
+	y := make([]float32, len(x))
+	var sum float64
+	for _, v := range x {
+		sum += math.Exp(float64(v) - max)
+	}
+	for i, v := range x {
+		y[i] = float32(math.Exp(float64(v)-max) / sum)
+	}
+	return y
+}
+
+func TestSoftTargetLoss(t *testing.T) {
+	//two samples of three classes in NCHW with 1x1 spacial dims
+	y := []float32{.7, .2, .1, .3, .3, .4}
+	onehot := []float32{1, 0, 0, 0, 1, 0}
+	ce, kl, accuracy := softtargetloss(y, onehot, 2, 3, 1, false)
+	expected := -(math.Log(.7) + math.Log(.3)) / 2
+	if math.Abs(float64(ce)-expected) > 1e-5 || ce != kl || accuracy != .5 {
+		t.Errorf("one hot: ce %v kl %v accuracy %v", ce, kl, accuracy)
+	}
+	ce, kl, accuracy = softtargetloss(y, y, 2, 3, 1, false)
+	if math.Abs(float64(kl)) > 1e-5 || ce <= 0 || accuracy != 1 {
+		t.Errorf("soft: ce %v kl %v accuracy %v", ce, kl, accuracy)
+	}
+	//the classes of NCHW with 2 spacial positions are 2 apart
+	y = []float32{.9, .1, .1, .9}
+	_, _, accuracy = softtargetloss(y, []float32{.6, .4, .4, .6}, 1, 2, 2, false)
+	if accuracy != 1 {
+		t.Errorf("spacial accuracy is %v", accuracy)
+	}
+	percent, loss := MakeSoftMaxLossCalculator().BatchLossCPU([]float32{.7, .3}, []float32{.8, .2}, 1, 2)
+	expected = -(.8*math.Log(.7) + .2*math.Log(.3))
+	if percent != 1 || math.Abs(float64(loss)-expected) > 1e-5 {
+		t.Errorf("BatchLossCPU percent %v loss %v", percent, loss)
+	}
+}
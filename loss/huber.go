@@ -1,6 +1,11 @@
 package loss
 
-import "github.com/dereklstinson/gocunets/utils"
+import (
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/tensor"
+	"github.com/dereklstinson/gocunets/layers"
+	"github.com/dereklstinson/gocunets/utils"
+)
 
 //Huber holds the methods to do the huber loss
 type Huber struct {
@@ -18,5 +23,135 @@ func (h Huber) huberloss(target, predicted, delta float32) float32 {
 	if x < delta {
 		return (y * y) / 2
 	}
-	return delta*y - delta/2
+	return delta * (x - delta/2)
+}
+
+//huberderivative is the derivative of the huber loss with respect to predicted
+func (h Huber) huberderivative(target, predicted, delta float32) float32 {
+	y := predicted - target
+	if y > delta {
+		return delta
+	}
+	if y < -delta {
+		return -delta
+	}
+	return y
+}
+
+//HuberLoss is the huber (smooth L1) loss. It is squared for errors smaller than delta and linear for errors larger than delta,
+//so outliers don't take over the gradient like they do with the mean squared error.
+//
+//The output of the layer is its input. The loss of a batch is the sum of the losses of its elements.
+//Everything is done on the device with cudnn tensor ops. Only the loss of each batch is copied to the host.
+type HuberLoss struct {
+	h       *cudnn.Handler
+	c       Huber
+	delta   float32
+	deltas  *tensor.Volume
+	scratch *tensor.Volume
+	sum     batchsum
+}
+
+//CreateHuberLoss creates a huber loss layer. A delta of 1 gives the smooth L1 loss.
+func CreateHuberLoss(h *cudnn.Handler, delta float32) (l *HuberLoss) {
+	l = new(HuberLoss)
+	l.h = h
+	l.delta = delta
+	return l
+}
+
+//Delta returns the delta the layer was created with
+func (l *HuberLoss) Delta() float32 {
+	return l.delta
+}
+
+//element is the host version of the layer for one element. It is used to check the layer.
+func (l *HuberLoss) element(x, target float32) (y, dx, loss float32) {
+	return x, l.c.huberderivative(target, x, l.delta), l.c.huberloss(target, x, l.delta)
+}
+
+//PerformError copies x into y, puts the gradient of the loss into dx and finds the loss.
+//PerformError satisfies the loss layer interface
+func (l *HuberLoss) PerformError(x, dx, y, target *layers.Tensor) (err error) {
+	return l.findloss(x.Volume, dx.Volume, y.Volume, target.Volume)
+}
+
+//Inference copies x into y
+func (l *HuberLoss) Inference(x, y *layers.Tensor) (err error) {
+	return y.AddTo(l.h, x.Volume, 1, 0)
+}
+
+//TestForward copies x into y and finds the loss
+func (l *HuberLoss) TestForward(x, y, target *layers.Tensor) (err error) {
+	if l.scratch == nil || !sameint32s(l.scratch.Dims(), x.Dims()) {
+		frmt, dtype, dims, err := x.Properties()
+		if err != nil {
+			return err
+		}
+		l.scratch, err = tensor.Build(l.h, frmt, dtype, dims)
+		if err != nil {
+			return err
+		}
+	}
+	return l.findloss(x.Volume, l.scratch, y.Volume, target.Volume)
+}
+
+//findloss puts the error of x clipped to [-delta,delta] into clip, finds the loss of each batch and then copies x into y.
+//y holds the error and then the loss of each element while the loss is found.
+func (l *HuberLoss) findloss(x, clip, y, target *tensor.Volume) (err error) {
+	if l.deltas == nil || len(l.deltas.Dims()) != len(x.Dims()) {
+		frmt, dtype, dims, err := x.Properties()
+		if err != nil {
+			return err
+		}
+		ones := make([]int32, len(dims))
+		for i := range ones {
+			ones[i] = 1
+		}
+		l.deltas, err = tensor.Build(l.h, frmt, dtype, ones)
+		if err != nil {
+			return err
+		}
+		err = l.deltas.SetValues(l.h, float64(l.delta))
+		if err != nil {
+			return err
+		}
+	}
+	err = y.OpAdd(l.h, x, target, 1, -1, 0)
+	if err != nil {
+		return err
+	}
+	err = clip.OpMin(l.h, y, l.deltas, 1, 1, 0)
+	if err != nil {
+		return err
+	}
+	err = clip.OpMax(l.h, clip, l.deltas, 1, -1, 0)
+	if err != nil {
+		return err
+	}
+	//The loss is clip*(err-clip/2).  That is err*err/2 when |err| < delta and delta*(|err|-delta/2) when it isn't.
+	err = y.OpAdd(l.h, y, clip, 1, -.5, 0)
+	if err != nil {
+		return err
+	}
+	err = y.OpMult(l.h, y, clip, 1, 1, 0)
+	if err != nil {
+		return err
+	}
+	err = l.sum.sum(l.h, 1, y)
+	if err != nil {
+		return err
+	}
+	return y.AddTo(l.h, x, 1, 0)
+}
+
+//GetAverageBatchLoss gets the averagebatchloss
+//It also satisfies the gocunets.LossLayer interface
+func (l *HuberLoss) GetAverageBatchLoss() float32 {
+	return l.sum.average()
+}
+
+//GetBatchLoss gets the loss by batch
+func (l *HuberLoss) GetBatchLoss() []float32 {
+	return l.sum.batch
 }
@@ -0,0 +1,70 @@
+package loss
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	gocudnn "github.com/dereklstinson/gocudnn"
+
+	"github.com/dereklstinson/gocudnn/cudart"
+	"github.com/dereklstinson/gocudnn/gocu"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+)
+
+func TestDeviceElementLosses(t *testing.T) {
+	runtime.LockOSThread()
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	dev, err := cudart.GetDevice()
+	check(err)
+	worker := gocu.NewWorker(dev)
+	h := cudnn.CreateHandler(worker, dev, 4)
+	var frmt gocudnn.TensorFormat
+	var dtype gocudnn.DataType
+	dims := []int32{2, 3, 1, 2}
+	tensors := make([]*layers.Tensor, 4)
+	for i := range tensors {
+		tensors[i], err = layers.CreateTensor(h, frmt.NCHW(), dtype.Float(), dims)
+		check(err)
+	}
+	x, dx, y, target := tensors[0], tensors[1], tensors[2], tensors[3]
+	inputs := []float32{-3, -.5, 0, .4, 2.5, 1, 7, -2, .1, .9, -1.5, 3}
+	targets := []float32{0, .25, 1, 0, 1, 1, .5, 0, 1, .25, 0, 1}
+	check(x.LoadValuesFromSLice(h, inputs, int32(len(inputs))))
+	check(target.LoadValuesFromSLice(h, targets, int32(len(targets))))
+
+	compare := func(name string, element func(x, target float32) (y, dx, loss float32), batchloss []float32) {
+		ys, dxs := make([]float32, len(inputs)), make([]float32, len(inputs))
+		check(h.Sync())
+		check(y.FillSlice(h, ys))
+		check(dx.FillSlice(h, dxs))
+		losses := make([]float32, dims[0])
+		for i := range inputs {
+			ey, edx, eloss := element(inputs[i], targets[i])
+			losses[i/6] += eloss
+			if math.Abs(float64(ys[i]-ey)) > 1e-5 || math.Abs(float64(dxs[i]-edx)) > 1e-5 {
+				t.Errorf("%s %d: y %v dx %v, expected %v and %v", name, i, ys[i], dxs[i], ey, edx)
+			}
+		}
+		for i := range losses {
+			if math.Abs(float64(batchloss[i]-losses[i])) > 1e-4 {
+				t.Errorf("%s batch %d: loss %v, expected %v", name, i, batchloss[i], losses[i])
+			}
+		}
+	}
+	huber := CreateHuberLoss(h, 1)
+	check(huber.PerformError(x, dx, y, target))
+	compare("huber", huber.element, huber.GetBatchLoss())
+	check(huber.TestForward(x, y, target))
+	compare("huber test forward", huber.element, huber.GetBatchLoss())
+
+	bce := CreateBinaryCrossEntropy(h)
+	check(bce.PerformError(x, dx, y, target))
+	compare("bce", bce.element, bce.GetBatchLoss())
+}
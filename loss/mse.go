@@ -67,18 +67,20 @@ type MSE2 struct {
 	r *reduce.Ops
 }
 
-//PerformError performs the error
+//PerformError puts the gradient x - target into dx, finds the loss of each batch and copies x into y.
 //PerformError satisfies the loss layer interface
-func (m *MSE2) PerformError(x, dx, y, dy *layers.Tensor) error {
-	err := dx.Volume.OpAdd(m.h, y.Volume, dy.Volume, 1, -1, 0)
+func (m *MSE2) PerformError(x, dx, y, target *layers.Tensor) error {
+	err := dx.Volume.OpAdd(m.h, x.Volume, target.Volume, 1, -1, 0)
 	if err != nil {
 		return err
 	}
-	err = y.SetValues(m.h, 0)
-	if err != nil {
-		return err
-	}
-	err = y.Volume.OpMult(m.h, dx.Volume, dx.Volume, .5, 1, 0)
+	return m.findloss(x, dx, y)
+}
+
+//findloss uses y to hold the squared errors of diff while the loss of each batch is found and then copies x into y.
+//diff can be y.
+func (m *MSE2) findloss(x, diff, y *layers.Tensor) error {
+	err := y.Volume.OpMult(m.h, diff.Volume, diff.Volume, .5, 1, 0)
 	if err != nil {
 		return err
 	}
@@ -110,15 +112,22 @@ func (m *MSE2) PerformError(x, dx, y, dy *layers.Tensor) error {
 		return errors.New(" (m *MSE2) PerformError() loss array are not initiated")
 	}
 
-	return nil
+	return y.AddTo(m.h, x.Volume, 1, 0)
 }
 
+//Inference copies x into y
 //Inference satisfies the gocunets.LossLayer interface
 func (m *MSE2) Inference(x, y *layers.Tensor) (err error) {
-	return nil
+	return y.AddTo(m.h, x.Volume, 1, 0)
 }
-func (m *MSE2) TestForward(x, y, dy *layers.Tensor) (err error) {
-	panic("TestForward not set")
+
+//TestForward finds the loss of each batch and copies x into y
+func (m *MSE2) TestForward(x, y, target *layers.Tensor) (err error) {
+	err = y.Volume.OpAdd(m.h, x.Volume, target.Volume, 1, -1, 0)
+	if err != nil {
+		return err
+	}
+	return m.findloss(x, y, y)
 }
 
 //GetAverageBatchLoss gets the averagebatchloss
@@ -164,6 +173,7 @@ func CreateMSE2(h *cudnn.Handler, target *layers.Tensor) (m *MSE2, err error) {
 		fp16 = true
 	}
 	m = new(MSE2)
+	m.h = h
 
 	flg := reduce.Flags
 	flg.IndFlag.NoIndices()
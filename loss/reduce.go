@@ -0,0 +1,131 @@
+package loss
+
+import (
+	"errors"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/reduce"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/tensor"
+	gocudnn "github.com/dereklstinson/gocudnn"
+	"github.com/dereklstinson/half"
+)
+
+//batchsum sums the elements of each batch of a tensor on the device.  Only the sums are copied to the host.
+//It is made again when the dims of the tensor change.  Float and half tensors are supported.
+type batchsum struct {
+	r        *reduce.Ops
+	sums     *tensor.Volume
+	wspace   *nvidia.Malloced
+	indicies *nvidia.Malloced
+	dims     []int32
+	batch    []float32
+	half     []half.Float16
+}
+
+//setup makes the reduction for x if it wasn't made for the dims of x
+func (s *batchsum) setup(h *cudnn.Handler, x *tensor.Volume) (err error) {
+	frmt, dtype, dims, err := x.Properties()
+	if err != nil {
+		return err
+	}
+	var dflg gocudnn.DataType
+	if dtype != dflg.Float() && dtype != dflg.Half() {
+		return errors.New("only float and half tensors are supported")
+	}
+	if s.r != nil && sameint32s(s.dims, dims) {
+		return nil
+	}
+	flg := reduce.Flags
+	s.r, err = reduce.Stage(flg.ReduceMode.Add(), dtype, flg.NanProp.Propigate(), flg.IndFlag.NoIndices(), flg.IndType.Type32Bit())
+	if err != nil {
+		return err
+	}
+	sumdims := make([]int32, len(dims))
+	for i := range sumdims {
+		sumdims[i] = 1
+	}
+	sumdims[0] = dims[0]
+	s.sums, err = tensor.Build(h, frmt, dtype, sumdims)
+	if err != nil {
+		return err
+	}
+	indiciessize, err := s.r.GetIndiciesSize(h, x, s.sums)
+	if err != nil {
+		return err
+	}
+	s.indicies = nil
+	if indiciessize > 0 {
+		s.indicies, err = nvidia.MallocGlobal(h, indiciessize)
+		if err != nil {
+			return err
+		}
+	}
+	wspacesize, err := s.r.GetWorkSpaceSize(h, x, s.sums)
+	if err != nil {
+		return err
+	}
+	s.wspace = nil
+	if wspacesize > 0 {
+		s.wspace, err = nvidia.MallocGlobal(h, wspacesize)
+		if err != nil {
+			return err
+		}
+	}
+	s.dims = append(s.dims[:0], dims...)
+	s.batch = make([]float32, dims[0])
+	s.half = nil
+	if dtype == dflg.Half() {
+		s.half = make([]half.Float16, dims[0])
+	}
+	return nil
+}
+
+//sum puts alpha times the sum of each batch of x into s.batch
+func (s *batchsum) sum(h *cudnn.Handler, alpha float64, x *tensor.Volume) error {
+	err := s.setup(h, x)
+	if err != nil {
+		return err
+	}
+	err = s.r.Reduce(h, s.indicies, s.wspace, alpha, x, 0, s.sums)
+	if err != nil {
+		return err
+	}
+	err = h.Sync()
+	if err != nil {
+		return err
+	}
+	if s.half != nil {
+		err = s.sums.FillSlice(h, s.half)
+		if err != nil {
+			return err
+		}
+		copy(s.batch, half.ToFloat32(s.half))
+		return nil
+	}
+	return s.sums.FillSlice(h, s.batch)
+}
+
+//average returns the average of the batch sums
+func (s *batchsum) average() float32 {
+	if len(s.batch) == 0 {
+		return 0
+	}
+	var sum float32
+	for _, b := range s.batch {
+		sum += b
+	}
+	return sum / float32(len(s.batch))
+}
+
+func sameint32s(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
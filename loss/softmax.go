@@ -1,9 +1,11 @@
 package loss
 
 import (
+	"errors"
 	"math"
 
-	"github.com/dereklstinson/gocudnn/xtra"
+	gocudnn "github.com/dereklstinson/gocudnn"
+	"github.com/dereklstinson/half"
 
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/layers"
@@ -13,9 +15,17 @@ import (
 //SoftMax Holds the methods to do softmax loss
 type SoftMax struct {
 	s                   *softmax.Layer
-	sml                 *xtra.SofMaxLogLoss
 	h                   *cudnn.Handler
 	alphaloss, betaloss float64
+	epsilon             float64
+	smoothed            *layers.Tensor
+	logs                *softmax.Layer
+	logy                *layers.Tensor
+	sum                 batchsum
+	hostmetrics         bool
+	hosty, hostt        []float32
+	hostfp16            []half.Float16
+	kl, accuracy        float32
 	//	x, y, target, dx    *layers.Tensor // I named the tensors this to keep me from getting confused while writing the functions
 	//reducetensor     *layers.Tensor
 	hostmem float32
@@ -33,10 +43,6 @@ type SoftMax struct {
 func CreateSoftMax(h *cudnn.Handler) (s *SoftMax, err error) {
 	s = new(SoftMax)
 	s.h = h
-	s.sml, err = xtra.NewSoftMaxNegLogLoss(h.XHandle())
-	if err != nil {
-		return nil, err
-	}
 	//var sfopmul softmax.OpMultiplier
 	//sfopmul.ForwardAlpha = 1
 	//sfopmul.ForwardBeta = 0
@@ -48,6 +54,8 @@ func CreateSoftMax(h *cudnn.Handler) (s *SoftMax, err error) {
 	s.s = softmax.StageAccuratePerChannel(nil)
 	s.s.SetForwardScalars(1, 0)
 	s.s.SetBackwardScalars(-1, 0)
+	s.logs = softmax.StageLogPerChannel(nil)
+	s.logs.SetForwardScalars(1, 0)
 	//	s.x = x
 	//	s.y = y
 	//	s.target = target
@@ -128,6 +136,10 @@ func (s *SoftMax) PerformError(x, dx, y, target *layers.Tensor) (err error) {
 	if err != nil {
 		return err
 	}
+	target, err = s.smooth(target)
+	if err != nil {
+		return err
+	}
 	err = dx.OpAdd(s.h, y.Volume, target.Volume, 1, -1, 0)
 	if err != nil {
 		return err
@@ -160,11 +172,7 @@ func (s *SoftMax) PerformError(x, dx, y, target *layers.Tensor) (err error) {
 	if target == nil {
 		panic("target == nil")
 	}
-	//fmt.Println("s.h.XHandle(), s.alphaloss, s.y.TD(), s.y, s.betaloss, s.target.TD(), s.target", s.h.XHandle(), s.alphaloss, s.y.TD(), s.y, s.betaloss, s.target.TD(), s.target)
-	if s.sml == nil {
-		panic("s.sml is nil")
-	}
-	s.hostmem, err = s.sml.FindAverageLogLoss(s.h.XHandle(), s.alphaloss, y.TD(), y, s.betaloss, target.TD(), target)
+	err = s.findloss(x, y, target)
 	if err != nil {
 		return err
 	}
@@ -246,8 +254,11 @@ func (s *SoftMax) TestForward(x, y, target *layers.Tensor) (err error) {
 	if err != nil {
 		return err
 	}
-	s.hostmem, err = s.sml.FindAverageLogLoss(s.h.XHandle(), s.alphaloss, y.TD(), y, s.betaloss, target.TD(), target)
-	return err
+	target, err = s.smooth(target)
+	if err != nil {
+		return err
+	}
+	return s.findloss(x, y, target)
 }
 
 //Inference just performs the forward propagation of the classifier
@@ -272,6 +283,156 @@ func (s *SoftMax) GetAverageBatchLoss() float32 {
 
 }
 
+//SetHostMetrics sets if PerformError and TestForward copy y and the targets to the host to find the KL divergence and the accuracy.
+//It is off by default.  The cross entropy is always found on the device.
+func (s *SoftMax) SetHostMetrics(on bool) {
+	s.hostmetrics = on
+}
+
+//GetAverageBatchKL returns the average KL divergence of the batch from the last PerformError or TestForward.
+//It is the cross entropy minus the entropy of the targets, so it is 0 when y matches the targets.
+//It is only found if SetHostMetrics was set.
+func (s *SoftMax) GetAverageBatchKL() float32 {
+	return s.kl
+}
+
+//GetAccuracy returns the part of the outputs from the last PerformError or TestForward where the largest value of y was at the largest value of the target.
+//It works for soft targets as well as one hot targets.  It is only found if SetHostMetrics was set.
+func (s *SoftMax) GetAccuracy() float32 {
+	return s.accuracy
+}
+
+//SetLabelSmoothing smooths the targets to (1-epsilon)*target + epsilon/classes before the loss and gradient are found.
+//An epsilon of 0 turns it off.
+func (s *SoftMax) SetLabelSmoothing(epsilon float32) error {
+	if epsilon < 0 || epsilon >= 1 {
+		return errors.New("(s *SoftMax) SetLabelSmoothing: epsilon needs to be in [0,1)")
+	}
+	s.epsilon = float64(epsilon)
+	return nil
+}
+
+//LabelSmoothing returns the epsilon set with SetLabelSmoothing
+func (s *SoftMax) LabelSmoothing() float32 {
+	return float32(s.epsilon)
+}
+
+//smooth returns target with label smoothing. If there isn't any smoothing target is returned.
+func (s *SoftMax) smooth(target *layers.Tensor) (smoothed *layers.Tensor, err error) {
+	if s.epsilon == 0 {
+		return target, nil
+	}
+	dims := target.Dims()
+	if s.smoothed == nil || s.smoothed.Vol() != target.Vol() {
+		s.smoothed, err = layers.CreateTensor(s.h, target.Format(), target.DataType(), dims)
+		if err != nil {
+			return nil, err
+		}
+	}
+	classes, _, _ := classaxis(target)
+	err = s.smoothed.SetValues(s.h, s.epsilon/float64(classes))
+	if err != nil {
+		return nil, err
+	}
+	err = s.smoothed.AddTo(s.h, target.Volume, 1-s.epsilon, 1)
+	if err != nil {
+		return nil, err
+	}
+	return s.smoothed, nil
+}
+
+//findloss finds the cross entropy of each batch on the device from the log softmax of x.  Only the loss of each batch is
+//copied to the host.  If host metrics are set y and target are copied to the host to find the KL divergence and accuracy.
+func (s *SoftMax) findloss(x, y, target *layers.Tensor) (err error) {
+	size := y.Vol()
+	if target.Vol() != size || x.Vol() != size {
+		return errors.New("(s *SoftMax) findloss: x, y and target need to have the same volume")
+	}
+	if s.logy == nil || !sameint32s(s.logy.Dims(), x.Dims()) {
+		s.logy, err = layers.CreateTensor(s.h, x.Format(), x.DataType(), x.Dims())
+		if err != nil {
+			return err
+		}
+	}
+	err = s.logs.ForwardProp(s.h, x, s.logy)
+	if err != nil {
+		return err
+	}
+	err = s.logy.OpMult(s.h, s.logy.Volume, target.Volume, 1, 1, 0)
+	if err != nil {
+		return err
+	}
+	err = s.sum.sum(s.h, -1, s.logy.Volume)
+	if err != nil {
+		return err
+	}
+	s.hostmem = s.sum.average()
+	if !s.hostmetrics {
+		return nil
+	}
+	if int32(len(s.hosty)) != size {
+		s.hosty, s.hostt = make([]float32, size), make([]float32, size)
+	}
+	var dtype gocudnn.DataType
+	if y.DataType() == dtype.Half() {
+		if int32(len(s.hostfp16)) != size {
+			s.hostfp16 = make([]half.Float16, size)
+		}
+		if err = y.FillSlice(s.h, s.hostfp16); err != nil {
+			return err
+		}
+		copy(s.hosty, half.ToFloat32(s.hostfp16))
+		if err = target.FillSlice(s.h, s.hostfp16); err != nil {
+			return err
+		}
+		copy(s.hostt, half.ToFloat32(s.hostfp16))
+	} else {
+		if err = y.FillSlice(s.h, s.hosty); err != nil {
+			return err
+		}
+		if err = target.FillSlice(s.h, s.hostt); err != nil {
+			return err
+		}
+	}
+	classes, stride, nhwc := classaxis(y)
+	_, s.kl, s.accuracy = softtargetloss(s.hosty, s.hostt, int(y.Dims()[0]), classes, stride, nhwc)
+	return nil
+}
+
+//softtargetloss returns the cross entropy and KL divergence averaged over the batch and the part of the positions
+//where the argmax of y and target are the same. target can be any distribution over the classes.
+func softtargetloss(y, target []float32, batch, classes, stride int, nhwc bool) (crossentropy, kl, accuracy float32) {
+	positions := len(y) / classes
+	var ce, entropy float64
+	var correct int
+	for pos := 0; pos < positions; pos++ {
+		base := pos * classes
+		if !nhwc {
+			base = (pos/stride)*classes*stride + pos%stride
+		}
+		ymax, tmax := base, base
+		for c := 0; c < classes; c++ {
+			i := base + c*stride
+			if y[i] > y[ymax] {
+				ymax = i
+			}
+			if target[i] > target[tmax] {
+				tmax = i
+			}
+			t := float64(target[i])
+			if t <= 0 {
+				continue
+			}
+			ce -= t * math.Log(math.Max(float64(y[i]), 1e-30))
+			entropy -= t * math.Log(t)
+		}
+		if ymax == tmax {
+			correct++
+		}
+	}
+	return float32(ce / float64(batch)), float32((ce - entropy) / float64(batch)), float32(correct) / float32(positions)
+}
+
 ////GetBatchLoss gets the loss by batch
 //func (s *SoftMax) GetBatchLoss() []float32 {
 //
@@ -288,6 +449,7 @@ func MakeSoftMaxLossCalculator() SoftMax {
 }
 
 //BatchLossCPU takes the actual and desired arrays in the form of i=batchindex, j=classindex actual[i*classificationsize+j]
+//desired can be one hot or a soft target.  The answer is the class with the largest desired value.
 func (s SoftMax) BatchLossCPU(actual, desired []float32, batchsize, classificationsize int) (percent, loss float32) {
 	percent, loss = s.batchlossandpercent(actual, desired, batchsize, classificationsize)
 	return percent, loss
@@ -336,6 +498,7 @@ func (s SoftMax) batchlossandpercent(actual, desired []float32, numofbatches, cl
 
 		maxvalue := float32(-99999)
 		ipos := i * classificationsize
+		desiredpos := ipos
 		for j := 0; j < classificationsize; j++ {
 			ijpos := ipos + j
 			if maxvalue < actual[ijpos] {
@@ -344,13 +507,19 @@ func (s SoftMax) batchlossandpercent(actual, desired []float32, numofbatches, cl
 				position = ijpos
 
 			}
+			if desired[ijpos] > desired[desiredpos] {
+				desiredpos = ijpos
+			}
 			if desired[ijpos] != 0 {
 
-				batchloss += float32(-math.Log(float64(actual[ijpos])))
+				batchloss += desired[ijpos] * float32(-math.Log(float64(actual[ijpos])))
 			}
 
 		}
-		percent += desired[position]
+		//desired can be a soft target so the largest desired value is the answer
+		if position == desiredpos {
+			percent++
+		}
 
 	}
 	if math.IsNaN(float64(batchloss)) {
@@ -79,6 +79,50 @@ func TestCreateSoftMax(t *testing.T) {
 	fmt.Println(cpudx)
 }
 
+func TestSoftMaxDeviceLoss(t *testing.T) {
+	runtime.LockOSThread()
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	dev, err := cudart.GetDevice()
+	check(err)
+	worker := gocu.NewWorker(dev)
+	h := cudnn.CreateHandler(worker, dev, 4)
+	var frmt gocudnn.TensorFormat
+	var dtype gocudnn.DataType
+	dims := []int32{2, 3, 1, 1}
+	tensors := make([]*layers.Tensor, 4)
+	for i := range tensors {
+		tensors[i], err = layers.CreateTensor(h, frmt.NCHW(), dtype.Float(), dims)
+		check(err)
+	}
+	x, dx, y, target := tensors[0], tensors[1], tensors[2], tensors[3]
+	inputs := []float32{2, -1, .5, .3, 1.2, -2}
+	targets := []float32{1, 0, 0, .2, .8, 0}
+	check(x.LoadValuesFromSLice(h, inputs, int32(len(inputs))))
+	check(target.LoadValuesFromSLice(h, targets, int32(len(targets))))
+	sm, err := CreateSoftMax(h)
+	check(err)
+	check(sm.PerformError(x, dx, y, target))
+	outputs := make([]float32, len(inputs))
+	check(h.Sync())
+	check(y.FillSlice(h, outputs))
+	ce, kl, accuracy := softtargetloss(outputs, targets, 2, 3, 1, false)
+	if math.Abs(float64(sm.GetAverageBatchLoss()-ce)) > 1e-4 {
+		t.Errorf("device cross entropy is %v, expected %v", sm.GetAverageBatchLoss(), ce)
+	}
+	if sm.GetAverageBatchKL() != 0 || sm.GetAccuracy() != 0 {
+		t.Error("kl and accuracy shouldn't be found without host metrics")
+	}
+	sm.SetHostMetrics(true)
+	check(sm.TestForward(x, y, target))
+	if math.Abs(float64(sm.GetAverageBatchKL()-kl)) > 1e-4 || sm.GetAccuracy() != accuracy {
+		t.Errorf("kl %v accuracy %v, expected %v and %v", sm.GetAverageBatchKL(), sm.GetAccuracy(), kl, accuracy)
+	}
+}
+
 func softmaxforwardcpu(input []float32) (output []float32) {
 	output = make([]float32, len(input))
 	var denom float32
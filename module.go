@@ -110,19 +110,103 @@ func dimoutputreverse(i, f, p, s, d int32) (o int32) {
 	return o
 }
 
-//SimpleModuleNetwork is a simple module network
-type SimpleModuleNetwork struct {
-	Id                   int64             `json:"id,omitempty"`
-	C                    *Concat           `json:"c,omitempty"`
-	Modules              []Module          `json:"modules,omitempty"`
-	Output               *OutputModule     `json:"output,omitempty"`
-	Classifier           *ClassifierModule `json:"classifier,omitempty"`
-	b                    *Builder
-	Rate, Decay1, Decay2 float32
-	//	x, dx, y, dy        *Tensor
-	//	firstinithiddenfirstinithidden    bool
-	//	firstinitworkspace bool
-	//	firstfindoutputdims bool
+func convolutionparameterdims(inputchannels, outputchannel, stride int32,
+	spacialdims []int32, paddingoffset int32, frmt TensorFormat, index int) (fdims, pads, strides, dils []int32, err error) {
+	fdims = make([]int32, len(spacialdims)+2)
+	fdims[0] = outputchannel
+	pads = make([]int32, len(spacialdims))
+	strides = make([]int32, len(spacialdims))
+	dils = make([]int32, len(spacialdims))
+	flg := frmt
+	switch frmt {
+	case flg.NCHW():
+		fdims[1] = inputchannels //output channel size for deconv is the neuron channels
+		for i := 0; i < len(spacialdims); i++ {
+			dim := spacialdims[i]
+
+			dilation, pad, err := recommendedpaddilation(dim, (int32)(index), stride, paddingoffset)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			fdims[i+2] = dim
+			dils[i] = dilation
+			pads[i] = pad
+			strides[i] = stride
+		}
+
+	case flg.NHWC():
+		for i := 0; i < len(spacialdims); i++ {
+			fdims[i+1] = spacialdims[i]
+			dim := spacialdims[i]
+
+			dilation, pad, err := recommendedpaddilation(dim, (int32)(index), stride, paddingoffset)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			fdims[i+1] = dim
+			dils[i] = dilation
+			pads[i] = pad
+			strides[i] = stride
+
+		}
+
+		fdims[len(fdims)-1] = inputchannels
+	default:
+		return nil, nil, nil, nil, errors.New("Unsupported Format")
+	}
+	return fdims, pads, strides, dils, nil
+}
+func deconvolutionparameterdims(inputchannels,
+	outputchannel,
+	stride int32,
+	spacialdims []int32,
+	paddingoffset int32,
+	frmt TensorFormat,
+	index int) (fdims, pads, strides, dils []int32, err error) {
+	fdims = make([]int32, len(spacialdims)+2)
+	//convolution filter of NCHW (OuputChannels,Inputchannels h,w) could actually be thought of
+	//reverse convolution would be (InputChannels,OutputChannels, h,w)
+	fdims[0] = inputchannels
+	pads = make([]int32, len(spacialdims))
+	strides = make([]int32, len(spacialdims))
+	dils = make([]int32, len(spacialdims))
+	flg := frmt
+	switch frmt {
+	case flg.NCHW():
+
+		fdims[1] = outputchannel //output channel size for deconv is the neuron channels
+		for i := 0; i < len(spacialdims); i++ {
+			dim := spacialdims[i]
+			dilation, pad, err := recommendedpaddilation(dim, (int32)(index), stride, paddingoffset)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+
+			fdims[i+2] = dim
+			dils[i] = dilation
+			pads[i] = pad
+			strides[i] = stride
+		}
+
+	case flg.NHWC():
+		for i := 0; i < len(spacialdims); i++ {
+			fdims[i+1] = spacialdims[i]
+			dim := spacialdims[i]
+			dilation, pad, err := recommendedpaddilation(dim, (int32)(index), stride, paddingoffset)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			fdims[i+2] = dim
+			dils[i] = dilation
+			pads[i] = pad
+			strides[i] = stride
+
+		}
+		fdims[len(fdims)-1] = outputchannel
+	default:
+		return nil, nil, nil, nil, errors.New("Unsupported Format")
+	}
+	return fdims, pads, strides, dils, nil
 }
 
 //CreateSimpleModuleNetwork a simple module network
@@ -134,14 +218,16 @@ func CreateSimpleModuleNetwork(id int64, b *Builder) (smn *SimpleModuleNetwork)
 	return smn
 }
 
-//SetMSEClassifier needs to be made
-func (m *SimpleModuleNetwork) SetMSEClassifier() (err error) {
-	return errors.New("(m *SimpleModuleNetwork) SetMSEClassifier() needs to be made")
-}
-
 //SetSoftMaxClassifier sets the classifier module it should be added last.
 //Should be ran after OutputModule is set
 func (m *SimpleModuleNetwork) SetSoftMaxClassifier() (err error) { //(y, dy *Tensor, err error) {
+	return m.setclassifier(func(id int64, x, dx, y, target *Tensor) (*ClassifierModule, error) {
+		return CreateSoftMaxClassifier(id, m.b, x, dx, y, target)
+	})
+}
+
+//setclassifier makes the tensors the OutputModule and the classifier need and then sets the classifier made by create.
+func (m *SimpleModuleNetwork) setclassifier(create func(id int64, x, dx, y, target *Tensor) (*ClassifierModule, error)) (err error) {
 
 	lastmod := m.Output
 	if lastmod.GetTensorDX() == nil {
@@ -185,7 +271,7 @@ func (m *SimpleModuleNetwork) SetSoftMaxClassifier() (err error) { //(y, dy *Ten
 		return err
 	}
 
-	m.Classifier, err = CreateSoftMaxClassifier(lastmod.ID()+1, m.b, lastmod.GetTensorY(), lastmod.GetTensorDY(), y, dy)
+	m.Classifier, err = create(lastmod.ID()+1, lastmod.GetTensorY(), lastmod.GetTensorDY(), y, dy)
 	if err != nil {
 		return err
 	}
@@ -422,6 +508,10 @@ func (m *SimpleModuleNetwork) FindOutputDims() (dims []int32, err error) {
 			panic("SHould be the same")
 		}
 	}
+	outputdims, err = m.Output.FindOutputDims()
+	if err != nil {
+		return nil, err
+	}
 	if m.Output.GetTensorY() == nil {
 		px, err = m.b.CreateTensor(outputdims)
 		if err != nil {
@@ -440,7 +530,6 @@ func (m *SimpleModuleNetwork) FindOutputDims() (dims []int32, err error) {
 	} else {
 		pdx = m.Output.GetTensorDY()
 	}
-	outputdims, err = m.Output.FindOutputDims()
 	if m.Classifier == nil {
 		return outputdims, nil
 	}
@@ -480,65 +569,11 @@ func (m *SimpleModuleNetwork) Forward() (err error) {
 	return m.Classifier.PerformError()
 }
 
-//Update updates the hidden weights
-//Update can count epochs or updates.  I found counting updates works the best.
-func (m *SimpleModuleNetwork) Update(counter int) (err error) {
-	err = m.Output.Update(counter)
-	if err != nil {
-		return err
-	}
-	for i := range m.Modules {
-		if i == 0 {
-			//	trainer.DebuggingAdam()
-		}
-		err = m.Modules[i].Update(counter)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-//BackPropForSharedInputForModuleNetworks is a hack to make up if two module networks share the same input.
-//It will zero out the dx values for the module and then run back propagation
-func BackPropForSharedInputForModuleNetworks(m []*SimpleModuleNetwork) (err error) {
-	err = m[0].GetTensorDX().SetValues(m[0].b.h.Handler, 0)
-	if err != nil {
-		return err
-	}
-	for i := range m {
-		err = m[i].Backward()
-		if err != nil {
-			return err
-		}
-
-	}
-	return nil
-}
-
 //GetLoss returns the loss found.
 func (m *SimpleModuleNetwork) GetLoss() float32 {
 	return m.Classifier.GetAverageBatchLoss()
 }
 
-//Backward does a forward without a concat
-func (m *SimpleModuleNetwork) Backward() (err error) {
-
-	err = m.Output.Backward()
-	if err != nil {
-		return err
-	}
-	for i := len(m.Modules) - 1; i >= 0; i-- {
-
-		err = m.Modules[i].Backward()
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 //Inference does a forward without a concat
 func (m *SimpleModuleNetwork) Inference() (err error) {
 	for i := range m.Modules {
@@ -1,6 +1,11 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 import (
+	"errors"
+
 	"github.com/dereklstinson/gocunets/layers"
 	"github.com/dereklstinson/gocunets/loss"
 )
@@ -18,6 +23,7 @@ type ClassifierModule struct {
 	id           int64
 	b            *Builder
 	l            LossLayer
+	host         *hostsoftmax //used instead of l by a cpu Builder
 	x, dx, y, dy *Tensor
 }
 
@@ -41,6 +47,13 @@ func CreateSoftMaxClassifier(id int64, bldr *Builder, x, dx, y, target *Tensor)
 	m.y = y
 	m.dx = dx
 	m.dy = target
+	if bldr.cpu {
+		m.host, err = createhostsoftmax()
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
 	m.l, err = loss.CreateSoftMax(bldr.h.Handler)
 	if err != nil {
 		return nil, err
@@ -52,30 +65,138 @@ func CreateSoftMaxClassifier(id int64, bldr *Builder, x, dx, y, target *Tensor)
 
 //PerformError does the output and error calculation of the previous layer of the network
 func (m *ClassifierModule) PerformError() error {
+	if m.host != nil {
+		return m.host.performerror(m.x.host, m.dx.host, m.y.host, m.dy.host)
+	}
 	return m.l.PerformError(m.x.Tensor, m.dx.Tensor, m.y.Tensor, m.dy.Tensor)
 }
 
 //Inference does a forward propagation without calculating errors
 func (m *ClassifierModule) Inference() error {
+	if m.host != nil {
+		return m.host.inference(m.x.host, m.y.host)
+	}
 	return m.l.Inference(m.x.Tensor, m.y.Tensor)
 }
 
 //TestForward does the testforward so that loss can be seen
 func (m *ClassifierModule) TestForward() error {
+	if m.host != nil {
+		return m.host.testforward(m.x.host, m.y.host, m.dy.host)
+	}
 	return m.l.TestForward(m.x.Tensor, m.y.Tensor, m.dy.Tensor)
 }
 
 //GetAverageBatchLoss gets the average batch loss
 func (m *ClassifierModule) GetAverageBatchLoss() float32 {
+	if m.host != nil {
+		return m.host.loss
+	}
 	return m.l.GetAverageBatchLoss()
 }
 
-//CreateMSEClassifier sets the mean squared error classifier
-func CreateMSEClassifier(id int64, bldr *Builder, x, dx, target *Tensor) (m *ClassifierModule, err error) {
+//SetLabelSmoothing sets the label smoothing of the loss layer. Only the softmax classifier has label smoothing.
+func (m *ClassifierModule) SetLabelSmoothing(epsilon float32) error {
+	l, ok := m.l.(interface{ SetLabelSmoothing(float32) error })
+	if !ok {
+		return errors.New("(m *ClassifierModule) SetLabelSmoothing: loss layer doesn't have label smoothing")
+	}
+	return l.SetLabelSmoothing(epsilon)
+}
+
+//CreateMSEClassifier creates a classifier that uses the mean squared error. The output y is a copy of x.
+func CreateMSEClassifier(id int64, bldr *Builder, x, dx, y, target *Tensor) (m *ClassifierModule, err error) {
+	if err = bldr.gpuonly("CreateMSEClassifier"); err != nil {
+		return nil, err
+	}
+	m = new(ClassifierModule)
+	m.id = id
+	m.b = bldr
+	m.x = x
+	m.y = y
+	m.dx = dx
+	m.dy = target
+	m.l, err = loss.CreateMSE2(bldr.h.Handler, target.Tensor)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//CreateHuberClassifier creates a classifier that uses the huber loss. The output y is a copy of x.
+//Errors larger than delta have a constant gradient so outliers don't take over training.
+func CreateHuberClassifier(id int64, bldr *Builder, x, dx, y, target *Tensor, delta float32) (m *ClassifierModule, err error) {
+	if err = bldr.gpuonly("CreateHuberClassifier"); err != nil {
+		return nil, err
+	}
+	if delta <= 0 {
+		return nil, errors.New("CreateHuberClassifier: delta needs to be more than 0")
+	}
+	m = new(ClassifierModule)
+	m.id = id
+	m.b = bldr
+	m.x = x
+	m.y = y
+	m.dx = dx
+	m.dy = target
+	m.l = loss.CreateHuberLoss(bldr.h.Handler, delta)
+	return m, nil
+}
+
+//CreateBinaryClassifier creates a classifier that uses the binary cross entropy of the sigmoid of each output.
+//Each output is its own yes or no, so more than one can be true.
+func CreateBinaryClassifier(id int64, bldr *Builder, x, dx, y, target *Tensor) (m *ClassifierModule, err error) {
+	if err = bldr.gpuonly("CreateBinaryClassifier"); err != nil {
+		return nil, err
+	}
+	m = new(ClassifierModule)
+	m.id = id
+	m.b = bldr
+	m.x = x
+	m.y = y
+	m.dx = dx
+	m.dy = target
+	m.l = loss.CreateBinaryCrossEntropy(bldr.h.Handler)
+	return m, nil
+}
+
+//CreateFocalClassifier creates a classifier that uses the focal loss of a softmax along the channels.
+//alpha has a weight for each class. If alpha is nil each class has a weight of 1.
+func CreateFocalClassifier(id int64, bldr *Builder, x, dx, y, target *Tensor, gamma float32, alpha []float32) (m *ClassifierModule, err error) {
+	if err = bldr.gpuonly("CreateFocalClassifier"); err != nil {
+		return nil, err
+	}
+	m = new(ClassifierModule)
+	m.id = id
+	m.b = bldr
+	m.x = x
+	m.y = y
+	m.dx = dx
+	m.dy = target
+	m.l, err = loss.CreateFocalLoss(bldr.h.Handler, gamma, alpha)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//CreateWeightedSoftMaxClassifier creates a softmax classifier where the loss of each class is scaled by its weight.
+func CreateWeightedSoftMaxClassifier(id int64, bldr *Builder, x, dx, y, target *Tensor, weights []float32) (m *ClassifierModule, err error) {
+	if err = bldr.gpuonly("CreateWeightedSoftMaxClassifier"); err != nil {
+		return nil, err
+	}
 	m = new(ClassifierModule)
+	m.id = id
 	m.b = bldr
-	m.l, err = loss.CreateMSE2(m.b.h.Handler, target.Tensor)
-	return m, err
+	m.x = x
+	m.y = y
+	m.dx = dx
+	m.dy = target
+	m.l, err = loss.CreateWeightedSoftMax(bldr.h.Handler, weights)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
 //GetTensorX returns set x tensor
@@ -0,0 +1,90 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+//ClassifierModule is used to classify outputs.  Without the cuda tag only the SoftMax classifier can be made.
+type ClassifierModule struct {
+	id           int64
+	b            *Builder
+	host         *hostsoftmax
+	x, dx, y, dy *Tensor
+}
+
+//ID returns the id set for the module
+func (m *ClassifierModule) ID() int64 { return m.id }
+
+//CreateSoftMaxClassifier will create a simple module with each of the convolution layers being in parallel.
+func CreateSoftMaxClassifier(id int64, bldr *Builder, x, dx, y, target *Tensor) (m *ClassifierModule, err error) {
+	m = new(ClassifierModule)
+	m.b = bldr
+	m.x = x
+	m.y = y
+	m.dx = dx
+	m.dy = target
+	m.host, err = createhostsoftmax()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//PerformError does the output and error calculation of the previous layer of the network
+func (m *ClassifierModule) PerformError() error {
+	return m.host.performerror(m.x.host, m.dx.host, m.y.host, m.dy.host)
+}
+
+//Inference does a forward propagation without calculating errors
+func (m *ClassifierModule) Inference() error {
+	return m.host.inference(m.x.host, m.y.host)
+}
+
+//TestForward does the testforward so that loss can be seen
+func (m *ClassifierModule) TestForward() error {
+	return m.host.testforward(m.x.host, m.y.host, m.dy.host)
+}
+
+//GetAverageBatchLoss gets the average batch loss
+func (m *ClassifierModule) GetAverageBatchLoss() float32 {
+	return m.host.loss
+}
+
+//GetTensorX returns set x tensor
+func (m *ClassifierModule) GetTensorX() (x *Tensor) {
+	return m.x
+}
+
+//GetTensorDX returns set dx tensor
+func (m *ClassifierModule) GetTensorDX() (dx *Tensor) {
+	return m.dx
+}
+
+//GetTensorY returns set y tensor
+func (m *ClassifierModule) GetTensorY() (y *Tensor) {
+	return m.y
+}
+
+//GetTensorDY returns set dy tensor
+func (m *ClassifierModule) GetTensorDY() (dy *Tensor) {
+	return m.dy
+}
+
+//SetTensorX sets x tensor
+func (m *ClassifierModule) SetTensorX(x *Tensor) {
+	m.x = x
+}
+
+//SetTensorDX sets dx tensor
+func (m *ClassifierModule) SetTensorDX(dx *Tensor) {
+	m.dx = dx
+}
+
+//SetTensorY sets y tensor
+func (m *ClassifierModule) SetTensorY(y *Tensor) {
+	m.y = y
+}
+
+//SetTensorDY sets dy tensor
+func (m *ClassifierModule) SetTensorDY(dy *Tensor) {
+	m.dy = dy
+}
@@ -1,3 +1,6 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 //DecompressionModule is a module that concats several layers together when doing the forward and backward passes
@@ -29,7 +32,11 @@ func CreateDecompressionModule(id int64, bldr *Builder, batch, inputchannel int3
 	m = new(DecompressionModule)
 
 	m.module, err = createModule(id, bldr, batch, inputchannel, outputperparallellayer, spacialdims, paddingoffset, falpha, fbeta, true, true)
-	return m, err
+	if err != nil {
+		return nil, err
+	}
+	m.spec.Type = decompressionmodulespec
+	return m, nil
 }
 
 //CompressionModule is a module that concats several layers together when doing the forward and backward passes
@@ -67,7 +74,11 @@ func CreateCompressionModule(id int64, bldr *Builder,
 		spacialdims,
 		paddingoffset,
 		falpha, fbeta, true, false)
-	return m, err
+	if err != nil {
+		return nil, err
+	}
+	m.spec.Type = compressionmodulespec
+	return m, nil
 }
 
 //NeutralModule is for nonsliding modules
@@ -91,5 +102,9 @@ func CreateSingleStridedModule(id int64, bldr *Builder,
 		spacialdims,
 		paddingoffset,
 		falpha, fbeta, strides, deconv)
-	return m, err
+	if err != nil {
+		return nil, err
+	}
+	m.spec.Type = neutralmodulespec
+	return m, nil
 }
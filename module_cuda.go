@@ -0,0 +1,142 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+
+	"github.com/dereklstinson/gocunets/data/preprocess"
+)
+
+//SimpleModuleNetwork is a simple module network
+type SimpleModuleNetwork struct {
+	Id                   int64             `json:"id,omitempty"`
+	C                    *Concat           `json:"c,omitempty"`
+	Modules              []Module          `json:"modules,omitempty"`
+	Output               *OutputModule     `json:"output,omitempty"`
+	Classifier           *ClassifierModule `json:"classifier,omitempty"`
+	b                    *Builder
+	Rate, Decay1, Decay2 float32
+	ones                 map[string]*Tensor //used by ClipGradientValues
+	accumulate           int                //used by SetAccumulation
+	accumulated          int
+	accscalars           []accscalar
+	ema                  *ema
+	preprocess           *preprocess.Normalizer //used by SetPreprocess
+	//	x, dx, y, dy        *Tensor
+	//	firstinithiddenfirstinithidden    bool
+	//	firstinitworkspace bool
+	//	firstfindoutputdims bool
+}
+
+//SetMSEClassifier sets a mean squared error classifier. The output of the network is the output of the OutputModule.
+//Should be ran after OutputModule is set
+func (m *SimpleModuleNetwork) SetMSEClassifier() (err error) {
+	return m.setclassifier(func(id int64, x, dx, y, target *Tensor) (*ClassifierModule, error) {
+		return CreateMSEClassifier(id, m.b, x, dx, y, target)
+	})
+}
+
+//SetHuberClassifier sets a huber loss classifier with delta. The output of the network is the output of the OutputModule.
+//Should be ran after OutputModule is set
+func (m *SimpleModuleNetwork) SetHuberClassifier(delta float32) (err error) {
+	return m.setclassifier(func(id int64, x, dx, y, target *Tensor) (*ClassifierModule, error) {
+		return CreateHuberClassifier(id, m.b, x, dx, y, target, delta)
+	})
+}
+
+//SetBinaryClassifier sets a binary cross entropy classifier. The output of the network is the sigmoid of the output of the OutputModule.
+//Should be ran after OutputModule is set
+func (m *SimpleModuleNetwork) SetBinaryClassifier() (err error) {
+	return m.setclassifier(func(id int64, x, dx, y, target *Tensor) (*ClassifierModule, error) {
+		return CreateBinaryClassifier(id, m.b, x, dx, y, target)
+	})
+}
+
+//SetFocalClassifier sets a focal loss classifier. alpha has a weight for each class and can be nil.
+//Should be ran after OutputModule is set
+func (m *SimpleModuleNetwork) SetFocalClassifier(gamma float32, alpha []float32) (err error) {
+	return m.setclassifier(func(id int64, x, dx, y, target *Tensor) (*ClassifierModule, error) {
+		return CreateFocalClassifier(id, m.b, x, dx, y, target, gamma, alpha)
+	})
+}
+
+//SetWeightedSoftMaxClassifier sets a softmax classifier where the loss of each class is scaled by its weight.
+//Should be ran after OutputModule is set
+func (m *SimpleModuleNetwork) SetWeightedSoftMaxClassifier(weights []float32) (err error) {
+	return m.setclassifier(func(id int64, x, dx, y, target *Tensor) (*ClassifierModule, error) {
+		return CreateWeightedSoftMaxClassifier(id, m.b, x, dx, y, target, weights)
+	})
+}
+
+//Update updates the hidden weights
+//Update can count epochs or updates.  I found counting updates works the best.
+//
+//If SetAccumulation was used Update only updates after the set number of micro batches have been through Backward.
+func (m *SimpleModuleNetwork) Update(counter int) (err error) {
+	if m.accumulate > 1 {
+		if m.accumulated < m.accumulate {
+			return nil
+		}
+		return m.UpdateAccumulated(counter)
+	}
+	return m.update(counter)
+}
+
+func (m *SimpleModuleNetwork) update(counter int) (err error) {
+	if m.EMASwapped() {
+		return errors.New("the moving average is swapped in. SwapEMA needs to be ran before training")
+	}
+	err = m.Output.Update(counter)
+	if err != nil {
+		return err
+	}
+	for i := range m.Modules {
+		if i == 0 {
+			//	trainer.DebuggingAdam()
+		}
+		err = m.Modules[i].Update(counter)
+		if err != nil {
+			return err
+		}
+	}
+	return m.updateema()
+}
+
+//BackPropForSharedInputForModuleNetworks is a hack to make up if two module networks share the same input.
+//It will zero out the dx values for the module and then run back propagation
+func BackPropForSharedInputForModuleNetworks(m []*SimpleModuleNetwork) (err error) {
+	err = m[0].GetTensorDX().SetValues(m[0].b.h.Handler, 0)
+	if err != nil {
+		return err
+	}
+	for i := range m {
+		err = m[i].Backward()
+		if err != nil {
+			return err
+		}
+
+	}
+	return nil
+}
+
+//Backward does a forward without a concat
+func (m *SimpleModuleNetwork) Backward() (err error) {
+
+	err = m.Output.Backward()
+	if err != nil {
+		return err
+	}
+	for i := len(m.Modules) - 1; i >= 0; i-- {
+
+		err = m.Modules[i].Backward()
+		if err != nil {
+			return err
+		}
+	}
+	if m.accumulate > 1 {
+		m.accumulated++
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+import "github.com/dereklstinson/gocunets/data/preprocess"
+
+//SimpleModuleNetwork is a simple module network
+type SimpleModuleNetwork struct {
+	Id                   int64             `json:"id,omitempty"`
+	Modules              []Module          `json:"modules,omitempty"`
+	Output               *OutputModule     `json:"output,omitempty"`
+	Classifier           *ClassifierModule `json:"classifier,omitempty"`
+	b                    *Builder
+	Rate, Decay1, Decay2 float32
+	preprocess           *preprocess.Normalizer //used by SetPreprocess
+}
+
+//Update updates the hidden weights
+//Update can count epochs or updates.  I found counting updates works the best.
+func (m *SimpleModuleNetwork) Update(counter int) (err error) {
+	err = m.Output.Update(counter)
+	if err != nil {
+		return err
+	}
+	for i := range m.Modules {
+		err = m.Modules[i].Update(counter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Backward does a forward without a concat
+func (m *SimpleModuleNetwork) Backward() (err error) {
+	err = m.Output.Backward()
+	if err != nil {
+		return err
+	}
+	for i := len(m.Modules) - 1; i >= 0; i-- {
+		err = m.Modules[i].Backward()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/loss"
+)
+
+//DistillationModule trains a student network to match a teacher network as well as the hard labels.
+//
+//The loss is (1-alpha)*CE(softmax(s), labels) + alpha*T^2*KL(softmax(t/T) || softmax(s/T)) where s and t are the
+//outputs of the student and teacher before their classifiers and T is the temperature.
+//The T^2 keeps the gradient of the soft part the same size when the temperature is changed.
+//
+//Both networks need to have a softmax classifier and the same output dims. The hard labels are loaded into the
+//target of the student's classifier like they would be without distillation.  The input is loaded into the student's x and
+//is copied to the teacher's x if the networks don't share it.
+//The teacher is only ran with Inference and only the student is updated.
+type DistillationModule struct {
+	id            int64
+	b             *Builder
+	teacher       *SimpleModuleNetwork
+	student       *SimpleModuleNetwork
+	soft          *loss.SoftMax
+	temperature   float32
+	alpha         float32
+	sx, sy        *Tensor //student output over the temperature and its softmax
+	tx, ty        *Tensor //teacher output over the temperature and its softmax
+	hardloss, kl  float32
+	distilledloss float32
+}
+
+//CreateDistillationModule creates a distillation module. The networks need to have had FindOutputDims, InitHiddenLayers and InitWorkspace ran.
+//alpha is the part of the loss that comes from the teacher. It is in [0,1].
+func CreateDistillationModule(id int64, teacher, student *SimpleModuleNetwork, temperature, alpha float32) (m *DistillationModule, err error) {
+	if temperature <= 0 {
+		return nil, errors.New("CreateDistillationModule: temperature needs to be more than 0")
+	}
+	if alpha < 0 || alpha > 1 {
+		return nil, errors.New("CreateDistillationModule: alpha needs to be in [0,1]")
+	}
+	for _, n := range []*SimpleModuleNetwork{teacher, student} {
+		if n != nil {
+			if err = n.b.gpuonly("CreateDistillationModule"); err != nil {
+				return nil, err
+			}
+		}
+		if n == nil || n.Output == nil || n.Output.GetTensorY() == nil || n.Classifier == nil {
+			return nil, errors.New("CreateDistillationModule: networks need an output and a classifier and need to be built")
+		}
+		if _, ok := n.Classifier.l.(*loss.SoftMax); !ok {
+			return nil, fmt.Errorf("CreateDistillationModule: network %d doesn't have a softmax classifier", n.Id)
+		}
+	}
+	dims := student.Output.GetTensorY().Dims()
+	if !int32sequal(dims, teacher.Output.GetTensorY().Dims()) {
+		return nil, fmt.Errorf("CreateDistillationModule: student output %v and teacher output %v are not the same", dims, teacher.Output.GetTensorY().Dims())
+	}
+	m = &DistillationModule{
+		id:          id,
+		b:           student.b,
+		teacher:     teacher,
+		student:     student,
+		temperature: temperature,
+		alpha:       alpha,
+	}
+	m.soft, err = loss.CreateSoftMax(m.b.h.Handler)
+	if err != nil {
+		return nil, err
+	}
+	m.soft.SetHostMetrics(true)
+	for _, t := range []**Tensor{&m.sx, &m.sy, &m.tx, &m.ty} {
+		*t, err = m.b.CreateTensor(dims)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+//ID returns the id of the module
+func (m *DistillationModule) ID() int64 { return m.id }
+
+//Forward runs the teacher with Inference and the student with Forward and puts the gradient of the blended loss
+//into the gradient of the student's output.
+func (m *DistillationModule) Forward() (err error) {
+	h := m.b.h.Handler
+	sx, tx := m.student.GetTensorX(), m.teacher.GetTensorX()
+	if sx != tx {
+		err = tx.AddTo(h, sx.Volume, 1, 0)
+		if err != nil {
+			return err
+		}
+	}
+	err = m.teacher.Inference()
+	if err != nil {
+		return fmt.Errorf("(m *DistillationModule) Forward: teacher: %v", err)
+	}
+	err = m.student.Forward()
+	if err != nil {
+		return fmt.Errorf("(m *DistillationModule) Forward: student: %v", err)
+	}
+	m.hardloss = m.student.GetLoss()
+	err = m.softentropy()
+	if err != nil {
+		return err
+	}
+	//The student's classifier left softmax(s)-labels in dx
+	t, a := float64(m.temperature), float64(m.alpha)
+	dx := m.student.Output.GetTensorDY()
+	err = dx.AddTo(h, m.sy.Volume, a*t, 1-a)
+	if err != nil {
+		return err
+	}
+	err = dx.AddTo(h, m.ty.Volume, -a*t, 1)
+	if err != nil {
+		return err
+	}
+	m.distilledloss = (1-m.alpha)*m.hardloss + m.alpha*m.temperature*m.temperature*m.kl
+	return h.Sync()
+}
+
+//TestForward finds the blended loss without training. The gradients aren't changed.
+func (m *DistillationModule) TestForward() (err error) {
+	h := m.b.h.Handler
+	sx, tx := m.student.GetTensorX(), m.teacher.GetTensorX()
+	if sx != tx {
+		err = tx.AddTo(h, sx.Volume, 1, 0)
+		if err != nil {
+			return err
+		}
+	}
+	err = m.teacher.Inference()
+	if err != nil {
+		return fmt.Errorf("(m *DistillationModule) TestForward: teacher: %v", err)
+	}
+	err = m.student.Inference()
+	if err != nil {
+		return fmt.Errorf("(m *DistillationModule) TestForward: student: %v", err)
+	}
+	c := m.student.Classifier
+	err = c.l.TestForward(c.x.Tensor, c.y.Tensor, c.dy.Tensor)
+	if err != nil {
+		return err
+	}
+	m.hardloss = m.student.GetLoss()
+	err = m.softentropy()
+	if err != nil {
+		return err
+	}
+	m.distilledloss = (1-m.alpha)*m.hardloss + m.alpha*m.temperature*m.temperature*m.kl
+	return nil
+}
+
+//softentropy finds the softmax of both outputs over the temperature and the KL divergence between them
+func (m *DistillationModule) softentropy() (err error) {
+	h := m.b.h.Handler
+	invt := 1 / float64(m.temperature)
+	err = m.sx.AddTo(h, m.student.Output.GetTensorY().Volume, invt, 0)
+	if err != nil {
+		return err
+	}
+	err = m.tx.AddTo(h, m.teacher.Output.GetTensorY().Volume, invt, 0)
+	if err != nil {
+		return err
+	}
+	err = m.soft.Inference(m.tx.Tensor, m.ty.Tensor)
+	if err != nil {
+		return err
+	}
+	err = m.soft.TestForward(m.sx.Tensor, m.sy.Tensor, m.ty.Tensor)
+	if err != nil {
+		return err
+	}
+	m.kl = m.soft.GetAverageBatchKL()
+	return nil
+}
+
+//Backward runs the student's Backward with the gradient found by Forward
+func (m *DistillationModule) Backward() error {
+	return m.student.Backward()
+}
+
+//Update updates the student's weights. The teacher isn't changed.
+func (m *DistillationModule) Update(counter int) error {
+	return m.student.Update(counter)
+}
+
+//GetLoss returns the blended loss found by the last Forward or TestForward
+func (m *DistillationModule) GetLoss() float32 { return m.distilledloss }
+
+//GetHardLoss returns the cross entropy of the student with the labels
+func (m *DistillationModule) GetHardLoss() float32 { return m.hardloss }
+
+//GetSoftLoss returns the KL divergence of the student from the teacher at the temperature
+func (m *DistillationModule) GetSoftLoss() float32 { return m.kl }
@@ -0,0 +1,50 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistillationModule(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+
+	teacher := testnetwork(t, handle, testnetworkspec)
+	student := testnetwork(t, handle, testnetworkspec)
+	d, err := CreateDistillationModule(0, teacher, student, 4, .5)
+	check(err)
+
+	x := student.GetTensorX()
+	input := make([]float32, x.Vol())
+	for i := range input {
+		input[i] = rand.Float32()
+	}
+	check(x.LoadValuesFromSLice(handle.Handler, input, int32(len(input))))
+	labels := make([]float32, student.Classifier.GetTensorDY().Vol())
+	for i := 0; i < len(labels); i += 3 {
+		labels[i+rand.Intn(3)] = 1
+	}
+	check(student.Classifier.GetTensorDY().LoadValuesFromSLice(handle.Handler, labels, int32(len(labels))))
+	check(d.Forward())
+	check(d.Backward())
+	check(d.Update(0))
+	expected := .5*d.GetHardLoss() + .5*16*d.GetSoftLoss()
+	if d.GetSoftLoss() < 0 || math.Abs(float64(d.GetLoss()-expected)) > 1e-5 {
+		t.Errorf("loss %v hard %v soft %v", d.GetLoss(), d.GetHardLoss(), d.GetSoftLoss())
+	}
+
+	//A teacher can't be distilled into a student without a softmax classifier
+	student.Classifier = nil
+	if _, err = CreateDistillationModule(1, teacher, student, 4, .5); err == nil {
+		t.Error("student without a classifier should not be distilled")
+	}
+}
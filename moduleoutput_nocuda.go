@@ -0,0 +1,28 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+import "errors"
+
+//InitHiddenLayers will init the hidden operation
+func (m *OutputModule) InitHiddenLayers(rate, decay1, decay2 float32) (err error) {
+	err = m.op.inithost(m.b.rng, rate, decay1, decay2, m.batchsize)
+	if err != nil {
+		return errors.New("(m *OutputModule) InitHiddenLayers(b *Builder, decay1,decay2 float32, batch int32)" + err.Error())
+	}
+	return nil
+}
+
+//InitWorkspace inits the workspace.  The cpu layers don't have a workspace.
+func (m *OutputModule) InitWorkspace() (err error) {
+	return nil
+}
+
+//FindOutputDims satisifies module interface
+func (m *OutputModule) FindOutputDims() ([]int32, error) {
+	if m.op.x == nil {
+		return nil, errors.New("m *OutputModule) FindOutputDims(): X tensor is not set")
+	}
+	return m.op.GetOutputDims(m.op.x)
+}
@@ -1,3 +1,6 @@
+//go:build cuda
+// +build cuda
+
 package gocunets
 
 import (
@@ -20,6 +23,7 @@ type module struct {
 	x, dx, y, dy    *Tensor
 	batchsize       int
 	deconvolutional bool
+	spec            ModuleSpec
 }
 type initialization struct {
 	dims                         []int32
@@ -50,6 +54,9 @@ func createModule(id int64, bldr *Builder,
 	paddingoffset int32,
 	falpha, fbeta float64,
 	strides, deconvolution bool) (m *module, err error) {
+	if err = bldr.gpuonly("createModule"); err != nil {
+		return nil, err
+	}
 	m = new(module)
 	m.b = bldr
 	m.id = id
@@ -135,107 +142,29 @@ func createModule(id int64, bldr *Builder,
 	}
 	m.activ.activation.SetForwardScalars(falpha, fbeta)
 	m.activ.activation.SetBackwardScalars(1, 0)
-	return m, nil
-}
-func convolutionparameterdims(inputchannels, outputchannel, stride int32,
-	spacialdims []int32, paddingoffset int32, frmt TensorFormat, index int) (fdims, pads, strides, dils []int32, err error) {
-	fdims = make([]int32, len(spacialdims)+2)
-	fdims[0] = outputchannel
-	pads = make([]int32, len(spacialdims))
-	strides = make([]int32, len(spacialdims))
-	dils = make([]int32, len(spacialdims))
-	flg := frmt
-	switch frmt {
-	case flg.NCHW():
-		fdims[1] = inputchannels //output channel size for deconv is the neuron channels
-		for i := 0; i < len(spacialdims); i++ {
-			dim := spacialdims[i]
-
-			dilation, pad, err := recommendedpaddilation(dim, (int32)(index), stride, paddingoffset)
-			if err != nil {
-				return nil, nil, nil, nil, err
-			}
-			fdims[i+2] = dim
-			dils[i] = dilation
-			pads[i] = pad
-			strides[i] = stride
-		}
-
-	case flg.NHWC():
-		for i := 0; i < len(spacialdims); i++ {
-			fdims[i+1] = spacialdims[i]
-			dim := spacialdims[i]
-
-			dilation, pad, err := recommendedpaddilation(dim, (int32)(index), stride, paddingoffset)
-			if err != nil {
-				return nil, nil, nil, nil, err
-			}
-			fdims[i+1] = dim
-			dils[i] = dilation
-			pads[i] = pad
-			strides[i] = stride
-
-		}
-
-		fdims[len(fdims)-1] = inputchannels
-	default:
-		return nil, nil, nil, nil, errors.New("Unsupported Format")
+	amode, err := activationmodetostring(bldr.AMode)
+	if err != nil {
+		return nil, err
 	}
-	return fdims, pads, strides, dils, nil
-}
-func deconvolutionparameterdims(inputchannels,
-	outputchannel,
-	stride int32,
-	spacialdims []int32,
-	paddingoffset int32,
-	frmt TensorFormat,
-	index int) (fdims, pads, strides, dils []int32, err error) {
-	fdims = make([]int32, len(spacialdims)+2)
-	//convolution filter of NCHW (OuputChannels,Inputchannels h,w) could actually be thought of
-	//reverse convolution would be (InputChannels,OutputChannels, h,w)
-	fdims[0] = inputchannels
-	pads = make([]int32, len(spacialdims))
-	strides = make([]int32, len(spacialdims))
-	dils = make([]int32, len(spacialdims))
-	flg := frmt
-	switch frmt {
-	case flg.NCHW():
-
-		fdims[1] = outputchannel //output channel size for deconv is the neuron channels
-		for i := 0; i < len(spacialdims); i++ {
-			dim := spacialdims[i]
-			dilation, pad, err := recommendedpaddilation(dim, (int32)(index), stride, paddingoffset)
-			if err != nil {
-				return nil, nil, nil, nil, err
-			}
-
-			fdims[i+2] = dim
-			dils[i] = dilation
-			pads[i] = pad
-			strides[i] = stride
-		}
-
-	case flg.NHWC():
-		for i := 0; i < len(spacialdims); i++ {
-			fdims[i+1] = spacialdims[i]
-			dim := spacialdims[i]
-			dilation, pad, err := recommendedpaddilation(dim, (int32)(index), stride, paddingoffset)
-			if err != nil {
-				return nil, nil, nil, nil, err
-			}
-			fdims[i+2] = dim
-			dils[i] = dilation
-			pads[i] = pad
-			strides[i] = stride
-
-		}
-		fdims[len(fdims)-1] = outputchannel
-	default:
-		return nil, nil, nil, nil, errors.New("Unsupported Format")
+	m.spec = ModuleSpec{
+		ID:             id,
+		Batch:          batch,
+		AMode:          amode,
+		InputChannels:  inputchannels,
+		OutputChannels: copyint32s(outputchannels),
+		SpacialDims:    copyint32s(spacialdims),
+		PaddingOffset:  paddingoffset,
+		Strides:        strides,
+		Deconvolution:  deconvolution,
+		FAlpha:         falpha,
+		FBeta:          fbeta,
 	}
-	return fdims, pads, strides, dils, nil
+	return m, nil
 }
 
+//Spec returns the parameters the module was created with
+func (m *module) Spec() ModuleSpec { return m.spec }
+
 //ID is the id
 func (m *module) ID() int64 {
 	return m.id
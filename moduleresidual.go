@@ -0,0 +1,307 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/trainer"
+)
+
+//ResidualModule adds the output of a chain of modules to the input of the chain.
+//
+//If the output of the chain doesn't have the same dims as the input, the input goes through a 1x1 convolution first.
+//The projection gets the channels of the output and a stride that makes its spacial dims match.  It is made by FindOutputDims.
+//
+//The modules in the chain need to write, not add to, their dx.
+type ResidualModule struct {
+	id        int64
+	b         *Builder
+	inner     []Module
+	proj      *Layer
+	x, dx     *Tensor
+	y, dy     *Tensor
+	batchsize int
+}
+
+//CreateResidualModule creates a residual module around inner. The modules in inner are ran one after another.
+func CreateResidualModule(id int64, bldr *Builder, batch int32, inner ...Module) (m *ResidualModule, err error) {
+	if err = bldr.gpuonly("CreateResidualModule"); err != nil {
+		return nil, err
+	}
+	if len(inner) == 0 {
+		return nil, errors.New("CreateResidualModule: needs at least one inner module")
+	}
+	m = &ResidualModule{
+		id:        id,
+		b:         bldr,
+		inner:     inner,
+		batchsize: int(batch),
+	}
+	return m, nil
+}
+
+//ID satisfies module interface
+func (m *ResidualModule) ID() int64 {
+	return m.id
+}
+
+//FindOutputDims connects the inner modules, makes the tensors between them and makes the projection if it is needed.
+func (m *ResidualModule) FindOutputDims() (outputdims []int32, err error) {
+	if m.x == nil {
+		return nil, errors.New("(m *ResidualModule) FindOutputDims: X tensor is not set")
+	}
+	px, pdx := m.x, m.dx
+	last := len(m.inner) - 1
+	for i, mod := range m.inner {
+		mod.SetTensorX(px)
+		mod.SetTensorDX(pdx)
+		outputdims, err = mod.FindOutputDims()
+		if err != nil {
+			return nil, fmt.Errorf("(m *ResidualModule) FindOutputDims: inner module %d: %v", i, err)
+		}
+		if i == last {
+			break
+		}
+		if mod.GetTensorY() == nil {
+			if px, err = m.b.CreateTensor(outputdims); err != nil {
+				return nil, err
+			}
+			mod.SetTensorY(px)
+		} else {
+			px = mod.GetTensorY()
+		}
+		if mod.GetTensorDY() == nil {
+			if pdx, err = m.b.CreateTensor(outputdims); err != nil {
+				return nil, err
+			}
+			mod.SetTensorDY(pdx)
+		} else {
+			pdx = mod.GetTensorDY()
+		}
+	}
+	xdims := m.x.Dims()
+	if int32sequal(xdims, outputdims) || m.proj != nil {
+		if m.proj != nil {
+			err = m.checkprojection(outputdims)
+		}
+		return outputdims, err
+	}
+	err = m.createprojection(xdims, outputdims)
+	if err != nil {
+		return nil, err
+	}
+	return outputdims, m.checkprojection(outputdims)
+}
+
+//createprojection makes a 1x1 convolution that takes xdims to ydims
+func (m *ResidualModule) createprojection(xdims, ydims []int32) (err error) {
+	frmt := m.b.Frmt
+	c, err := channelaxis(frmt, len(xdims))
+	if err != nil {
+		return fmt.Errorf("(m *ResidualModule) createprojection: %v", err)
+	}
+	if len(xdims) != len(ydims) || xdims[0] != ydims[0] {
+		return fmt.Errorf("(m *ResidualModule) createprojection: can't project %v to %v", xdims, ydims)
+	}
+	xspacial, _ := spacialaxes(frmt, xdims)
+	yspacial, _ := spacialaxes(frmt, ydims)
+	ones := make([]int32, len(xspacial))
+	pad := make([]int32, len(xspacial))
+	stride := make([]int32, len(xspacial))
+	for i := range xspacial {
+		if yspacial[i] < 1 {
+			return fmt.Errorf("(m *ResidualModule) createprojection: can't project %v to %v", xdims, ydims)
+		}
+		ones[i] = 1
+		stride[i] = (xspacial[i] + yspacial[i] - 1) / yspacial[i]
+	}
+	w, dw, b, db, err := m.b.CreateConvolutionWeights(joindims(frmt, ydims[c], xdims[c], ones))
+	if err != nil {
+		return err
+	}
+	m.proj, err = m.b.ConvolutionLayer(0, 1, w, dw, b, db, pad, stride, ones)
+	if err != nil {
+		return err
+	}
+	//The projection adds to the output of the inner modules and to the gradient of the input
+	m.proj.SetForwardScalars(1, 1)
+	m.proj.SetBackwardScalars(1, 1)
+	m.proj.SetOtherScalars(1, 0)
+	m.proj.x, m.proj.dx = m.x, m.dx
+	return nil
+}
+
+func (m *ResidualModule) checkprojection(ydims []int32) error {
+	m.proj.x, m.proj.dx = m.x, m.dx
+	dims, err := m.proj.GetOutputDims(m.x)
+	if err != nil {
+		return fmt.Errorf("(m *ResidualModule) FindOutputDims: projection: %v", err)
+	}
+	if !int32sequal(dims, ydims) {
+		return fmt.Errorf("(m *ResidualModule) FindOutputDims: projection makes %v but inner modules make %v", dims, ydims)
+	}
+	return nil
+}
+
+//InitHiddenLayers inits the inner modules and the projection.  The output tensors need to be set first.
+func (m *ResidualModule) InitHiddenLayers(rate, decay1, decay2 float32) (err error) {
+	if m.y == nil || m.dy == nil {
+		return errors.New("(m *ResidualModule) InitHiddenLayers: Y and DY need to be set")
+	}
+	last := m.inner[len(m.inner)-1]
+	last.SetTensorY(m.y)
+	last.SetTensorDY(m.dy)
+	for i, mod := range m.inner {
+		err = mod.InitHiddenLayers(rate, decay1, decay2)
+		if err != nil {
+			return fmt.Errorf("(m *ResidualModule) InitHiddenLayers: inner module %d: %v", i, err)
+		}
+	}
+	if m.proj == nil {
+		return nil
+	}
+	m.proj.y, m.proj.dy = m.y, m.dy
+	err = m.proj.cnn.MakeRandom(m.proj.h.Handler, m.x.Dims())
+	if err != nil {
+		return err
+	}
+	err = m.b.h.Sync()
+	if err != nil {
+		return err
+	}
+	w, bias, err := trainer.SetupAdamWandB(m.b.h.XHandle(), decay1, decay2, int32(m.batchsize))
+	if err != nil {
+		return errors.New("(m *ResidualModule) InitHiddenLayers: " + err.Error())
+	}
+	w.SetRates(rate, 0)
+	bias.SetRates(rate, 0)
+	err = m.proj.LoadTrainer(m.b.h.Handler, m.batchsize, w, bias)
+	if err != nil {
+		return errors.New("(m *ResidualModule) InitHiddenLayers: " + err.Error())
+	}
+	return nil
+}
+
+//InitWorkspace inits the workspaces of the inner modules and the projection
+func (m *ResidualModule) InitWorkspace() (err error) {
+	for _, mod := range m.inner {
+		err = mod.InitWorkspace()
+		if err != nil {
+			return err
+		}
+	}
+	if m.proj != nil {
+		return m.proj.initconvworkspace()
+	}
+	return nil
+}
+
+//Forward satisfies module interface
+func (m *ResidualModule) Forward() (err error) {
+	for _, mod := range m.inner {
+		err = mod.Forward()
+		if err != nil {
+			return err
+		}
+	}
+	return m.addinput()
+}
+
+//Inference satisfies module interface
+func (m *ResidualModule) Inference() (err error) {
+	for _, mod := range m.inner {
+		err = mod.Inference()
+		if err != nil {
+			return err
+		}
+	}
+	return m.addinput()
+}
+
+//addinput adds x or the projection of x to y
+func (m *ResidualModule) addinput() error {
+	if m.proj != nil {
+		return m.proj.Forward()
+	}
+	return m.y.AddTo(m.b.h.Handler, m.x.Volume, 1, 1)
+}
+
+//Backward satisfies module interface.
+//dx gets the gradient of the inner modules plus dy or the gradient of the projection.
+//If dx isn't set only the weight gradients of the projection are found.
+func (m *ResidualModule) Backward() (err error) {
+	for i := len(m.inner) - 1; i >= 0; i-- {
+		err = m.inner[i].Backward()
+		if err != nil {
+			return err
+		}
+	}
+	if m.proj != nil {
+		if m.dx == nil {
+			return m.proj.backpropfilter()
+		}
+		return m.proj.Backward()
+	}
+	if m.dx == nil {
+		return nil
+	}
+	return m.dx.AddTo(m.b.h.Handler, m.dy.Volume, 1, 1)
+}
+
+//Update satisfies module interface
+func (m *ResidualModule) Update(epoch int) (err error) {
+	for _, mod := range m.inner {
+		err = mod.Update(epoch)
+		if err != nil {
+			return err
+		}
+	}
+	if m.proj != nil {
+		return m.proj.Update(epoch)
+	}
+	return nil
+}
+
+//Summary returns the Summary of the inner modules, the projection and the add
+func (m *ResidualModule) Summary() *Summary {
+	s := &Summary{ID: m.id}
+	for _, mod := range m.inner {
+		s.merge(ModuleSummary(mod))
+	}
+	s.merge(layerssummary(m.id, m.proj))
+	s.add(LayerSummary{
+		Module:     m.id,
+		ID:         -1,
+		Type:       "Add",
+		InputDims:  [][]int32{tensordims(m.y), tensordims(m.x)},
+		OutputDims: tensordims(m.y),
+	})
+	return s
+}
+
+//GetTensorX returns set x tensor
+func (m *ResidualModule) GetTensorX() (x *Tensor) { return m.x }
+
+//GetTensorDX returns set dx tensor
+func (m *ResidualModule) GetTensorDX() (dx *Tensor) { return m.dx }
+
+//GetTensorY returns set y tensor
+func (m *ResidualModule) GetTensorY() (y *Tensor) { return m.y }
+
+//GetTensorDY returns set dy tensor
+func (m *ResidualModule) GetTensorDY() (dy *Tensor) { return m.dy }
+
+//SetTensorX sets x tensor
+func (m *ResidualModule) SetTensorX(x *Tensor) { m.x = x }
+
+//SetTensorDX sets dx tensor
+func (m *ResidualModule) SetTensorDX(dx *Tensor) { m.dx = dx }
+
+//SetTensorY sets y tensor
+func (m *ResidualModule) SetTensorY(y *Tensor) { m.y = y }
+
+//SetTensorDY sets dy tensor
+func (m *ResidualModule) SetTensorDY(dy *Tensor) { m.dy = dy }
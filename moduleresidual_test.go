@@ -0,0 +1,124 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestResidualModule(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	handle := testhandle(t)
+	defer handle.Close()
+	bldr := CreateBuilder(handle)
+	batch := int32(2)
+
+	//falpha and balpha of 0 zero the inner module so y is x and dx is dy
+	inner, err := CreateVanillaModule(0, bldr, batch, []int32{2, 2, 3, 3}, []int32{1, 1}, []int32{1, 1}, []int32{1, 1}, 0, 0, 0, 0)
+	check(err)
+	res, err := CreateResidualModule(1, bldr, batch, inner)
+	check(err)
+	x, err := bldr.CreateTensor([]int32{batch, 2, 6, 6})
+	check(err)
+	dx, err := bldr.CreateTensor([]int32{batch, 2, 6, 6})
+	check(err)
+	res.SetTensorX(x)
+	res.SetTensorDX(dx)
+	dims, err := res.FindOutputDims()
+	check(err)
+	if !int32sequal(dims, x.Dims()) || res.proj != nil {
+		t.Fatalf("identity residual has dims %v", dims)
+	}
+	y, err := bldr.CreateTensor(dims)
+	check(err)
+	dy, err := bldr.CreateTensor(dims)
+	check(err)
+	res.SetTensorY(y)
+	res.SetTensorDY(dy)
+	check(res.InitHiddenLayers(.001, 0, 0))
+	check(res.InitWorkspace())
+
+	input := make([]float32, x.Vol())
+	for i := range input {
+		input[i] = rand.Float32()
+	}
+	check(x.LoadValuesFromSLice(handle.Handler, input, int32(len(input))))
+	check(dy.LoadValuesFromSLice(handle.Handler, input, int32(len(input))))
+	check(res.Forward())
+	check(res.Backward())
+	check(handle.Sync())
+	output := make([]float32, y.Vol())
+	check(y.FillSlice(handle.Handler, output))
+	gradient := make([]float32, dx.Vol())
+	check(dx.FillSlice(handle.Handler, gradient))
+	for i := range input {
+		if output[i] != input[i] || gradient[i] != input[i] {
+			t.Fatalf("at %d y is %v and dx is %v, expected %v", i, output[i], gradient[i], input[i])
+		}
+	}
+
+	//A strided inner module with more channels needs a projection
+	inner, err = CreateVanillaModule(2, bldr, batch, []int32{4, 2, 3, 3}, []int32{1, 1}, []int32{2, 2}, []int32{1, 1}, 1, 0, 1, 0)
+	check(err)
+	res, err = CreateResidualModule(3, bldr, batch, inner)
+	check(err)
+	mnet := CreateSimpleModuleNetwork(4, bldr)
+	mnet.SetModules([]Module{res})
+	mnet.Output, err = CreateOutputModule(5, bldr, batch, []int32{3, 4, 3, 3}, []int32{0, 0}, []int32{1, 1}, []int32{1, 1}, 1, 0, 1, 0)
+	check(err)
+	mnet.SetTensorX(x)
+	mnet.SetTensorDX(dx)
+	check(mnet.SetSoftMaxClassifier())
+	_, err = mnet.FindOutputDims()
+	check(err)
+	if res.proj == nil || !int32sequal(res.GetTensorY().Dims(), []int32{batch, 4, 3, 3}) {
+		t.Fatalf("projected residual has dims %v", res.GetTensorY().Dims())
+	}
+	check(mnet.InitHiddenLayers(.001, 0, 0))
+	check(mnet.InitWorkspace())
+	check(mnet.Forward())
+	check(mnet.Backward())
+	check(mnet.Update(0))
+	if s := res.Summary(); len(s.Layers) != 4 || s.Params != 4*2*3*3+4+4*2+4 {
+		t.Errorf("summary has %d layers and %d params", len(s.Layers), s.Params)
+	}
+
+	//Without dx the projection only finds its weight gradients
+	inner, err = CreateVanillaModule(6, bldr, batch, []int32{4, 2, 3, 3}, []int32{1, 1}, []int32{2, 2}, []int32{1, 1}, 1, 0, 1, 0)
+	check(err)
+	res, err = CreateResidualModule(7, bldr, batch, inner)
+	check(err)
+	res.SetTensorX(x)
+	dims, err = res.FindOutputDims()
+	check(err)
+	if res.proj == nil {
+		t.Fatal("residual should have a projection")
+	}
+	y, err = bldr.CreateTensor(dims)
+	check(err)
+	dy, err = bldr.CreateTensor(dims)
+	check(err)
+	res.SetTensorY(y)
+	res.SetTensorDY(dy)
+	check(res.InitHiddenLayers(.001, 0, 0))
+	check(res.InitWorkspace())
+	check(dy.SetValues(handle.Handler, 1))
+	check(res.Forward())
+	check(res.Backward())
+	check(handle.Sync())
+	for _, g := range res.proj.gradients() {
+		max, err := g.MaxX(handle.Handler)
+		check(err)
+		min, err := g.MinX(handle.Handler)
+		check(err)
+		if max == 0 && min == 0 {
+			t.Error("projection weight gradient is zero without dx")
+		}
+	}
+}
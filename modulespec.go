@@ -0,0 +1,132 @@
+package gocunets
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Module spec types
+const (
+	vanillamodulespec       = "VanillaModule"
+	outputmodulespec        = "OutputModule"
+	compressionmodulespec   = "CompressionModule"
+	decompressionmodulespec = "DecompressionModule"
+	neutralmodulespec       = "NeutralModule"
+)
+
+//ModuleSpec holds the parameters that a module was created with.
+//It is written into model files so that the module can be rebuilt without the graph being made by hand.
+//
+//FilterDims, Pad, Stride, Dilation, BAlpha, and BBeta are used by VanillaModule and OutputModule.
+//InputChannels, OutputChannels, SpacialDims, PaddingOffset, Strides and Deconvolution are used by
+//CompressionModule, DecompressionModule and NeutralModule.
+type ModuleSpec struct {
+	Type           string  `json:"type"`
+	ID             int64   `json:"id"`
+	Batch          int32   `json:"batch"`
+	AMode          string  `json:"amode,omitempty"`
+	FilterDims     []int32 `json:"filter_dims,omitempty"`
+	Pad            []int32 `json:"pad,omitempty"`
+	Stride         []int32 `json:"stride,omitempty"`
+	Dilation       []int32 `json:"dilation,omitempty"`
+	InputChannels  int32   `json:"input_channels,omitempty"`
+	OutputChannels []int32 `json:"output_channels,omitempty"`
+	SpacialDims    []int32 `json:"spacial_dims,omitempty"`
+	PaddingOffset  int32   `json:"padding_offset,omitempty"`
+	Strides        bool    `json:"strides,omitempty"`
+	Deconvolution  bool    `json:"deconvolution,omitempty"`
+	BAlpha         float64 `json:"balpha"`
+	BBeta          float64 `json:"bbeta"`
+	FAlpha         float64 `json:"falpha"`
+	FBeta          float64 `json:"fbeta"`
+}
+
+//Spec returns the parameters the module was created with
+func (m *VanillaModule) Spec() ModuleSpec { return m.spec }
+
+//Spec returns the parameters the module was created with
+func (m *OutputModule) Spec() ModuleSpec { return m.spec }
+
+//Build builds a new module with the parameters in the spec.
+//If AMode is set then b.AMode is set to it while the module is built and then it is changed back.
+//
+//The module returned still needs its tensors set and its hidden layers initialized.
+func (s ModuleSpec) Build(b *Builder) (Module, error) {
+	if s.AMode != "" {
+		amode, err := stringtoactivationmode(s.AMode)
+		if err != nil {
+			return nil, err
+		}
+		previous := b.AMode
+		b.AMode = amode
+		defer func() { b.AMode = previous }()
+	}
+	switch s.Type {
+	case vanillamodulespec:
+		return CreateVanillaModule(s.ID, b, s.Batch, s.FilterDims, s.Pad, s.Stride, s.Dilation, s.BAlpha, s.BBeta, s.FAlpha, s.FBeta)
+	case outputmodulespec:
+		return CreateOutputModule(s.ID, b, s.Batch, s.FilterDims, s.Pad, s.Stride, s.Dilation, s.BAlpha, s.BBeta, s.FAlpha, s.FBeta)
+	case compressionmodulespec, decompressionmodulespec, neutralmodulespec:
+		return s.buildparallel(b)
+	case "":
+		return nil, errors.New("(s ModuleSpec) Build: Type not set")
+	}
+	return nil, fmt.Errorf("(s ModuleSpec) Build: Unsupported Type %s", s.Type)
+}
+
+func copyint32s(x []int32) []int32 {
+	if x == nil {
+		return nil
+	}
+	y := make([]int32, len(x))
+	copy(y, x)
+	return y
+}
+
+func int32sequal(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//channelaxis returns the index of the channel dim for frmt
+func channelaxis(frmt TensorFormat, ndims int) (int, error) {
+	flg := frmt
+	switch frmt {
+	case flg.NCHW():
+		return 1, nil
+	case flg.NHWC():
+		return ndims - 1, nil
+	}
+	return -1, errors.New("Unsupported Format")
+}
+
+//spacialaxes returns the dims of x that aren't the batch or the channel
+func spacialaxes(frmt TensorFormat, x []int32) ([]int32, error) {
+	c, err := channelaxis(frmt, len(x))
+	if err != nil {
+		return nil, err
+	}
+	if c == 1 {
+		return x[2:], nil
+	}
+	return x[1 : len(x)-1], nil
+}
+
+//joindims puts batch, channels and spacial together in the order of frmt
+func joindims(frmt TensorFormat, batch, channels int32, spacial []int32) []int32 {
+	dims := make([]int32, 0, len(spacial)+2)
+	dims = append(dims, batch)
+	if c, _ := channelaxis(frmt, len(spacial)+2); c == 1 {
+		dims = append(dims, channels)
+		return append(dims, spacial...)
+	}
+	dims = append(dims, spacial...)
+	return append(dims, channels)
+}
@@ -0,0 +1,15 @@
+//go:build cuda
+// +build cuda
+
+package gocunets
+
+//buildparallel builds the modules that have parallel convolutions
+func (s ModuleSpec) buildparallel(b *Builder) (Module, error) {
+	switch s.Type {
+	case compressionmodulespec:
+		return CreateCompressionModule(s.ID, b, s.Batch, s.InputChannels, s.OutputChannels, s.SpacialDims, s.PaddingOffset, s.FAlpha, s.FBeta)
+	case decompressionmodulespec:
+		return CreateDecompressionModule(s.ID, b, s.Batch, s.InputChannels, s.OutputChannels, s.SpacialDims, s.PaddingOffset, s.FAlpha, s.FBeta)
+	}
+	return CreateSingleStridedModule(s.ID, b, s.Batch, s.InputChannels, s.OutputChannels, s.SpacialDims, s.PaddingOffset, s.FAlpha, s.FBeta, s.Strides, s.Deconvolution)
+}
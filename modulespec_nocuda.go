@@ -0,0 +1,11 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+import "fmt"
+
+//buildparallel returns an error.  The modules with parallel convolutions need a build with the cuda tag.
+func (s ModuleSpec) buildparallel(b *Builder) (Module, error) {
+	return nil, fmt.Errorf("(s ModuleSpec) Build: %s needs a build with the cuda tag", s.Type)
+}
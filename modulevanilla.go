@@ -1,14 +1,5 @@
 package gocunets
 
-import (
-	"errors"
-	"fmt"
-
-	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
-	"github.com/dereklstinson/gocunets/trainer"
-	gocudnn "github.com/dereklstinson/gocudnn"
-)
-
 //VanillaModule has a convolution and an activation
 type VanillaModule struct {
 	id        int64
@@ -16,6 +7,7 @@ type VanillaModule struct {
 	conv      *Layer
 	act       *Layer
 	batchsize int
+	spec      ModuleSpec
 }
 
 //ID satisfies module interface
@@ -53,220 +45,25 @@ func CreateVanillaModule(id int64, bldr *Builder, batch int32, fdims, pad, strid
 	}
 	m.act.SetBackwardScalars(1, 0)
 	m.act.SetForwardScalars(falpha, fbeta)
-	return m, nil
-}
-
-//InitHiddenLayers will init the hidden operation
-func (m *VanillaModule) InitHiddenLayers(rate, decay1, decay2 float32) (err error) {
-
-	if m.conv.cnn != nil {
-		err := m.conv.cnn.MakeRandom(m.conv.h.Handler, m.conv.x.Dims())
-		if err != nil {
-			return err
-		}
-
-	} else if m.conv.cnntranspose != nil {
-		err := m.conv.cnntranspose.MakeRandom(m.conv.h.Handler, m.conv.x.Dims())
-		if err != nil {
-			return err
-		}
-
-	} else {
-		return errors.New("(m *VanillaModule)InitHiddenLayers. CreateModule needs to be ran first")
-	}
-	odims, err := m.conv.GetOutputDims(m.conv.x)
-	if err != nil {
-		return err
-	}
-	m.conv.y, err = m.b.CreateTensor(odims)
-	if err != nil {
-		return err
-	}
-	m.conv.dy, err = m.b.CreateTensor(odims)
-	if err != nil {
-		return err
-	}
-
-	m.act.x = m.conv.y
-	m.act.dx = m.conv.dy
-
-	err = m.b.h.Sync()
-	if err != nil {
-		return err
-	}
-	w, bias, err := trainer.SetupAdamWandB(m.b.h.XHandle(), decay1, decay2, int32(m.batchsize))
-	if err != nil {
-		return errors.New("(m *VanillaModule) InitHiddenLayers(b *Builder, decay1,decay2 float32, batch int32)" + err.Error())
-	}
-	w.SetRates(rate, 0)
-	bias.SetRates(rate, 0)
-	err = m.conv.LoadTrainer(m.b.h.Handler, m.batchsize, w, bias)
+	amode, err := activationmodetostring(bldr.AMode)
 	if err != nil {
-		return errors.New("(m *VanillaModule) InitHiddenLayers(b *Builder, decay1,decay2 float32, batch int32)" + err.Error())
-	}
-
-	return nil
-}
-
-//InitWorkspace inits the workspace
-func (m *VanillaModule) InitWorkspace() (err error) {
-	noerror := gocudnn.Status(0)
-	var flag bool
-	if m.conv.cnn != nil {
-		fwds, err := m.conv.cnn.GetFwdAlgoPerfList(m.conv.h.Handler, m.conv.x.Tensor, m.conv.y.Tensor, nil)
-		for _, fwd := range fwds {
-			if noerror == fwd.Status {
-
-				m.conv.cnn.SetFwdAlgoPerformance(fwd)
-				if fwd.Memory > 0 {
-					m.conv.workspacefwd, err = nvidia.MallocGlobal(m.conv.h.Handler, fwd.Memory)
-					if err != nil {
-						return err
-					}
-				}
-				flag = true
-				break
-			}
-		}
-		if !flag {
-			if performancedebugging {
-				fmt.Println("fwds tensors")
-				fmt.Println("X", m.conv.x)
-				fmt.Println("Y", m.conv.y)
-				fmt.Println("W", m.conv.cnn)
-				//for _, fwd := range fwds {
-				//
-				//fmt.Println(fwd)
-				//
-				//}
-			}
-			return errors.New("cnnInitForwardPerformanceFail")
-		}
-		flag = false
-		bwds, err := m.conv.cnn.GetBwdDataAlgoPerfList(m.conv.h.Handler, m.conv.x.Tensor, m.conv.y.Tensor, nil)
-		for _, bwd := range bwds {
-			if noerror == bwd.Status {
-				if performancedebugging {
-					fmt.Println(bwd)
-				}
-				m.conv.cnn.SetBwdDataAlgoPerformance(bwd)
-				if bwd.Memory > 0 {
-					m.conv.workspacebwd, err = nvidia.MallocGlobal(m.conv.h.Handler, bwd.Memory)
-					if err != nil {
-						return err
-					}
-				}
-				flag = true
-				break
-			}
-		}
-		if !flag {
-			if performancedebugging {
-				for _, bwd := range bwds {
-
-					fmt.Println(bwd)
-
-				}
-			}
-			return errors.New("cnnInitBackwardDataPerformanceFail")
-		}
-		flag = false
-		bwfs, err := m.conv.cnn.GetBwdFiltAlgoPerfList(m.conv.h.Handler, m.conv.x.Tensor, m.conv.y.Tensor, nil)
-		for _, bwf := range bwfs {
-			if noerror == bwf.Status {
-				if performancedebugging {
-					//	fmt.Println(bwf)
-				}
-				m.conv.cnn.SetBwdFiltAlgoPerformance(bwf)
-				if bwf.Memory > 0 {
-					m.conv.workspacebwf, err = nvidia.MallocGlobal(m.conv.h.Handler, bwf.Memory)
-					if err != nil {
-						return err
-					}
-				}
-				flag = true
-				break
-			}
-		}
-		if !flag {
-			return errors.New("cnnInitBackwardFilterPerformanceFail")
-		}
-		flag = false
-	} else if m.conv.cnntranspose != nil {
-		fwds, err := m.conv.cnntranspose.GetFwdAlgoPerfList(m.conv.h.Handler, m.conv.x.Tensor, m.conv.y.Tensor, nil)
-		for _, fwd := range fwds {
-			if noerror == fwd.Status {
-
-				m.conv.cnntranspose.SetFwdAlgoPerformance(fwd)
-				if fwd.Memory > 0 {
-					m.conv.workspacefwd, err = nvidia.MallocGlobal(m.conv.h.Handler, fwd.Memory)
-					if err != nil {
-						return err
-					}
-				}
-				flag = true
-				break
-			}
-		}
-		if !flag {
-			return errors.New("cnnInitForwardPerformanceFail")
-		}
-		flag = false
-		bwds, err := m.conv.cnntranspose.GetBwdDataAlgoPerfList(m.conv.h.Handler, m.conv.x.Tensor, m.conv.y.Tensor, nil)
-		for _, bwd := range bwds {
-			if noerror == bwd.Status {
-
-				m.conv.cnntranspose.SetBwdDataAlgoPerformance(bwd)
-				if bwd.Memory > 0 {
-					m.conv.workspacebwd, err = nvidia.MallocGlobal(m.conv.h.Handler, bwd.Memory)
-					if err != nil {
-						return err
-					}
-				}
-				flag = true
-				break
-			}
-		}
-		if !flag {
-			return errors.New("cnnInitBackwardDataPerformanceFail")
-		}
-		flag = false
-		bwfs, err := m.conv.cnntranspose.GetBwdFiltAlgoPerfList(m.conv.h.Handler, m.conv.x.Tensor, m.conv.y.Tensor, nil)
-		for _, bwf := range bwfs {
-			if noerror == bwf.Status {
-
-				m.conv.cnntranspose.SetBwdFiltAlgoPerformance(bwf)
-				if bwf.Memory > 0 {
-					m.conv.workspacebwf, err = nvidia.MallocGlobal(m.conv.h.Handler, bwf.Memory)
-					if err != nil {
-						return err
-					}
-				}
-				flag = true
-				break
-			}
-		}
-		if !flag {
-			return errors.New("cnnInitBackwardFilterPerformanceFail")
-		}
-		flag = false
-	}
-	return nil
-}
-
-//FindOutputDims satisifies module interface
-func (m *VanillaModule) FindOutputDims() ([]int32, error) {
-	if m.conv.x == nil {
-		return nil, errors.New("m *VanillaModule) FindOutputDims(): X tensor is not set")
-	}
-	if m.conv.cnn != nil {
-		return m.conv.cnn.FindOutputDims(m.conv.x.Tensor)
+		return nil, err
 	}
-	if m.conv.cnntranspose != nil {
-		return m.conv.cnntranspose.FindOutputDims(m.conv.x.Tensor)
+	m.spec = ModuleSpec{
+		Type:       vanillamodulespec,
+		ID:         id,
+		Batch:      batch,
+		AMode:      amode,
+		FilterDims: copyint32s(fdims),
+		Pad:        copyint32s(pad),
+		Stride:     copyint32s(stride),
+		Dilation:   copyint32s(dilation),
+		BAlpha:     balpha,
+		BBeta:      bbeta,
+		FAlpha:     falpha,
+		FBeta:      fbeta,
 	}
-	return nil, errors.New("(m *VanillaModule) FindOutputDims(): Major error both cnn and cnntranspose haven't been added")
-
+	return m, nil
 }
 
 //Update satisifies module interface
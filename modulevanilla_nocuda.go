@@ -0,0 +1,44 @@
+//go:build !cuda
+// +build !cuda
+
+package gocunets
+
+import "errors"
+
+//InitHiddenLayers will init the hidden operation
+func (m *VanillaModule) InitHiddenLayers(rate, decay1, decay2 float32) (err error) {
+	odims, err := m.conv.GetOutputDims(m.conv.x)
+	if err != nil {
+		return err
+	}
+	m.conv.y, err = m.b.CreateTensor(odims)
+	if err != nil {
+		return err
+	}
+	m.conv.dy, err = m.b.CreateTensor(odims)
+	if err != nil {
+		return err
+	}
+
+	m.act.x = m.conv.y
+	m.act.dx = m.conv.dy
+
+	err = m.conv.inithost(m.b.rng, rate, decay1, decay2, m.batchsize)
+	if err != nil {
+		return errors.New("(m *VanillaModule) InitHiddenLayers(b *Builder, decay1,decay2 float32, batch int32)" + err.Error())
+	}
+	return nil
+}
+
+//InitWorkspace inits the workspace.  The cpu layers don't have a workspace.
+func (m *VanillaModule) InitWorkspace() (err error) {
+	return nil
+}
+
+//FindOutputDims satisifies module interface
+func (m *VanillaModule) FindOutputDims() ([]int32, error) {
+	if m.conv.x == nil {
+		return nil, errors.New("m *VanillaModule) FindOutputDims(): X tensor is not set")
+	}
+	return m.conv.GetOutputDims(m.conv.x)
+}
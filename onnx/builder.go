@@ -0,0 +1,214 @@
+package onnx
+
+import (
+	"errors"
+	"fmt"
+)
+
+//GraphBuilder adds nodes to a graph in the order they are ran.
+//Each method takes the names of its inputs and returns the name of its output so the graph can be chained.
+//
+//Nodes are named OpType_n where n is the number of nodes added before it.
+//Outputs are named after their node with a _y suffix and initializers with the suffix of the input they are.
+type GraphBuilder struct {
+	g *Graph
+}
+
+//CreateGraphBuilder creates a builder for a graph called name
+func CreateGraphBuilder(name string) *GraphBuilder {
+	return &GraphBuilder{g: &Graph{Name: name}}
+}
+
+//Graph returns the graph that has been built
+func (b *GraphBuilder) Graph() *Graph { return b.g }
+
+//Model returns a model holding the graph that uses the default opset.
+func (b *GraphBuilder) Model(producer, version string) *Model {
+	return &Model{
+		IRVersion:       IRVersion,
+		ProducerName:    producer,
+		ProducerVersion: version,
+		Graph:           b.g,
+		Opsets:          []Opset{{Version: OpsetVersion}},
+	}
+}
+
+//AddInput adds a graph input and returns its name
+func (b *GraphBuilder) AddInput(name string, dtype DataType, dims []int32) string {
+	b.g.Inputs = append(b.g.Inputs, &ValueInfo{Name: name, ElemType: dtype, Dims: toint64s(dims)})
+	return name
+}
+
+//AddOutput marks x as an output of the graph
+func (b *GraphBuilder) AddOutput(x string, dtype DataType, dims []int32) {
+	b.g.Outputs = append(b.g.Outputs, &ValueInfo{Name: x, ElemType: dtype, Dims: toint64s(dims)})
+}
+
+//node adds a node with one output and returns the node name and the output name
+func (b *GraphBuilder) node(optype string, inputs []string, attrs ...*Attribute) (name, y string) {
+	name = fmt.Sprintf("%s_%d", optype, len(b.g.Nodes))
+	y = name + "_y"
+	b.g.Nodes = append(b.g.Nodes, &Node{
+		Inputs:     inputs,
+		Outputs:    []string{y},
+		Name:       name,
+		OpType:     optype,
+		Attributes: attrs,
+	})
+	return name, y
+}
+
+//initializer names t after the node it is an input of and adds it to the graph
+func (b *GraphBuilder) initializer(node, suffix string, t *Tensor) string {
+	t.Name = node + "_" + suffix
+	b.g.Initializers = append(b.g.Initializers, t)
+	return t.Name
+}
+
+//nextname is the name the next node of optype will get
+func (b *GraphBuilder) nextname(optype string) string {
+	return fmt.Sprintf("%s_%d", optype, len(b.g.Nodes))
+}
+
+//Conv adds a convolution. w is in KCHW order. bias can be nil.
+//The pads are the same at the start and end of each spacial dim like in cudnn.
+func (b *GraphBuilder) Conv(x string, w, bias *Tensor, pad, stride, dilation []int32) string {
+	return b.conv("Conv", x, w, bias, pad, stride, dilation)
+}
+
+//ConvTranspose adds a transposed convolution.  w is in the layout cudnn uses for the filter of the convolution it reverses,
+//which is the layout ONNX uses (input channels, output channels, spacial dims).
+func (b *GraphBuilder) ConvTranspose(x string, w, bias *Tensor, pad, stride, dilation []int32) string {
+	return b.conv("ConvTranspose", x, w, bias, pad, stride, dilation)
+}
+
+func (b *GraphBuilder) conv(optype, x string, w, bias *Tensor, pad, stride, dilation []int32) string {
+	name := b.nextname(optype)
+	inputs := []string{x, b.initializer(name, "W", w)}
+	if bias != nil {
+		inputs = append(inputs, b.initializer(name, "B", bias))
+	}
+	var kernel []int64
+	if len(w.Dims) > 2 {
+		kernel = copyint64s(w.Dims[2:])
+	}
+	_, y := b.node(optype, inputs,
+		intsattribute("dilations", toint64s(dilation)),
+		intsattribute("kernel_shape", kernel),
+		intsattribute("pads", toint64s(append(append([]int32{}, pad...), pad...))),
+		intsattribute("strides", toint64s(stride)),
+	)
+	return y
+}
+
+//Activation adds the node for a gocunets activation mode.
+//mode is the string used for the mode in gocunets model files (Relu, Leaky, Elu, ClippedRelu, Sigmoid, Tanh, PRelu, Identity).
+//coef is the alpha of Leaky and Elu and the ceiling of ClippedRelu.  slope is only used by PRelu.
+func (b *GraphBuilder) Activation(x, mode string, coef float64, slope *Tensor) (string, error) {
+	var y string
+	switch mode {
+	case "Relu", "Sigmoid", "Tanh", "Identity":
+		_, y = b.node(mode, []string{x})
+	case "Leaky":
+		_, y = b.node("LeakyRelu", []string{x}, floatattribute("alpha", float32(coef)))
+	case "Elu":
+		_, y = b.node("Elu", []string{x}, floatattribute("alpha", float32(coef)))
+	case "ClippedRelu":
+		name := b.nextname("Clip")
+		min := b.initializer(name, "min", CreateFloatTensor("", nil, []float32{0}))
+		max := b.initializer(name, "max", CreateFloatTensor("", nil, []float32{float32(coef)}))
+		_, y = b.node("Clip", []string{x, min, max})
+	case "PRelu":
+		if slope == nil {
+			return "", errors.New("(b *GraphBuilder) Activation: PRelu needs a slope")
+		}
+		name := b.nextname("PRelu")
+		_, y = b.node("PRelu", []string{x, b.initializer(name, "slope", slope)})
+	default:
+		return "", fmt.Errorf("(b *GraphBuilder) Activation: %s isn't supported", mode)
+	}
+	return y, nil
+}
+
+//BatchNormalization adds a batch norm that uses the running mean and variance. The tensors need to be 1d with a length of the channels.
+func (b *GraphBuilder) BatchNormalization(x string, scale, bias, mean, variance *Tensor, eps float64) string {
+	name := b.nextname("BatchNormalization")
+	_, y := b.node("BatchNormalization", []string{
+		x,
+		b.initializer(name, "scale", scale),
+		b.initializer(name, "B", bias),
+		b.initializer(name, "mean", mean),
+		b.initializer(name, "var", variance),
+	}, floatattribute("epsilon", float32(eps)))
+	return y
+}
+
+//Pool adds a pooling node. mode is the string used for the pooling mode in gocunets model files.
+func (b *GraphBuilder) Pool(x, mode string, window, pad, stride []int32) (string, error) {
+	pads := toint64s(append(append([]int32{}, pad...), pad...))
+	var y string
+	switch mode {
+	case "Max", "MaxDeterministic":
+		_, y = b.node("MaxPool", []string{x},
+			intsattribute("kernel_shape", toint64s(window)),
+			intsattribute("pads", pads),
+			intsattribute("strides", toint64s(stride)),
+		)
+	case "AverageCountIncludePadding", "AverageCountExcludePadding":
+		var include int64
+		if mode == "AverageCountIncludePadding" {
+			include = 1
+		}
+		_, y = b.node("AveragePool", []string{x},
+			intattribute("count_include_pad", include),
+			intsattribute("kernel_shape", toint64s(window)),
+			intsattribute("pads", pads),
+			intsattribute("strides", toint64s(stride)),
+		)
+	default:
+		return "", fmt.Errorf("(b *GraphBuilder) Pool: %s isn't supported", mode)
+	}
+	return y, nil
+}
+
+//Concat concats xs along axis
+func (b *GraphBuilder) Concat(xs []string, axis int64) string {
+	_, y := b.node("Concat", append([]string{}, xs...), intattribute("axis", axis))
+	return y
+}
+
+//Dropout adds a dropout. It does nothing at inference.
+func (b *GraphBuilder) Dropout(x string) string {
+	_, y := b.node("Dropout", []string{x})
+	return y
+}
+
+//Softmax adds a softmax along axis
+func (b *GraphBuilder) Softmax(x string, axis int64) string {
+	_, y := b.node("Softmax", []string{x}, intattribute("axis", axis))
+	return y
+}
+
+func intattribute(name string, i int64) *Attribute {
+	var flg AttributeType
+	return &Attribute{Name: name, Type: flg.Int(), I: i}
+}
+func intsattribute(name string, ints []int64) *Attribute {
+	var flg AttributeType
+	return &Attribute{Name: name, Type: flg.Ints(), Ints: ints}
+}
+func floatattribute(name string, f float32) *Attribute {
+	var flg AttributeType
+	return &Attribute{Name: name, Type: flg.Float(), F: f}
+}
+
+func toint64s(x []int32) []int64 {
+	if x == nil {
+		return nil
+	}
+	y := make([]int64, len(x))
+	for i := range x {
+		y[i] = int64(x[i])
+	}
+	return y
+}
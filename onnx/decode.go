@@ -0,0 +1,464 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+//decoder reads protobuf fields from buf
+type decoder struct {
+	buf []byte
+	i   int
+}
+
+var errtruncated = errors.New("onnx: truncated message")
+
+func (d *decoder) done() bool { return d.i >= len(d.buf) }
+
+func (d *decoder) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if d.i >= len(d.buf) {
+			return 0, errtruncated
+		}
+		b := d.buf[d.i]
+		d.i++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("onnx: varint overflow")
+}
+
+func (d *decoder) key() (field, wire int, err error) {
+	k, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(k >> 3), int(k & 7), nil
+}
+
+func (d *decoder) fixed32() (uint32, error) {
+	if d.i+4 > len(d.buf) {
+		return 0, errtruncated
+	}
+	v := binary.LittleEndian.Uint32(d.buf[d.i:])
+	d.i += 4
+	return v, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)-d.i) {
+		return nil, errtruncated
+	}
+	b := d.buf[d.i : d.i+int(n)]
+	d.i += int(n)
+	return b, nil
+}
+
+func (d *decoder) skip(wire int) error {
+	switch wire {
+	case wirevarint:
+		_, err := d.varint()
+		return err
+	case 1:
+		if d.i+8 > len(d.buf) {
+			return errtruncated
+		}
+		d.i += 8
+		return nil
+	case wirebytes:
+		_, err := d.bytes()
+		return err
+	case wirefixed32:
+		_, err := d.fixed32()
+		return err
+	}
+	return fmt.Errorf("onnx: unsupported wire type %d", wire)
+}
+
+//fields calls fn for each field of the message.  fn returns false for fields it didn't read so they are skipped.
+func (d *decoder) fields(fn func(field, wire int) (bool, error)) error {
+	for !d.done() {
+		field, wire, err := d.key()
+		if err != nil {
+			return err
+		}
+		read, err := fn(field, wire)
+		if err != nil {
+			return err
+		}
+		if !read {
+			if err = d.skip(wire); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//message decodes the next field as a message with fn
+func (d *decoder) message(fn func(sub *decoder) error) error {
+	b, err := d.bytes()
+	if err != nil {
+		return err
+	}
+	return fn(&decoder{buf: b})
+}
+
+func (d *decoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+//int64s reads a repeated varint field that can be packed or not
+func (d *decoder) int64s(wire int, x []int64) ([]int64, error) {
+	if wire == wirevarint {
+		v, err := d.varint()
+		return append(x, int64(v)), err
+	}
+	b, err := d.bytes()
+	if err != nil {
+		return x, err
+	}
+	packed := &decoder{buf: b}
+	for !packed.done() {
+		v, err := packed.varint()
+		if err != nil {
+			return x, err
+		}
+		x = append(x, int64(v))
+	}
+	return x, nil
+}
+
+//float32s reads a repeated fixed32 float field that can be packed or not
+func (d *decoder) float32s(wire int, x []float32) ([]float32, error) {
+	if wire == wirefixed32 {
+		v, err := d.fixed32()
+		return append(x, math.Float32frombits(v)), err
+	}
+	b, err := d.bytes()
+	if err != nil {
+		return x, err
+	}
+	if len(b)%4 != 0 {
+		return x, errors.New("onnx: packed floats aren't a multiple of 4 bytes")
+	}
+	for i := 0; i < len(b); i += 4 {
+		x = append(x, math.Float32frombits(binary.LittleEndian.Uint32(b[i:])))
+	}
+	return x, nil
+}
+
+//Unmarshal decodes an ONNX ModelProto. Fields that aren't held by Model are skipped.
+//Tensor values held in float_data, int32_data or int64_data are moved into RawData.
+func Unmarshal(data []byte) (*Model, error) {
+	m := new(Model)
+	d := &decoder{buf: data}
+	err := d.fields(func(field, wire int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wire == wirevarint:
+			var v uint64
+			v, err = d.varint()
+			m.IRVersion = int64(v)
+		case field == 2 && wire == wirebytes:
+			m.ProducerName, err = d.string()
+		case field == 3 && wire == wirebytes:
+			m.ProducerVersion, err = d.string()
+		case field == 4 && wire == wirebytes:
+			m.Domain, err = d.string()
+		case field == 5 && wire == wirevarint:
+			var v uint64
+			v, err = d.varint()
+			m.ModelVersion = int64(v)
+		case field == 6 && wire == wirebytes:
+			m.DocString, err = d.string()
+		case field == 7 && wire == wirebytes:
+			m.Graph = new(Graph)
+			err = d.message(m.Graph.decode)
+		case field == 8 && wire == wirebytes:
+			var o Opset
+			err = d.message(func(sub *decoder) error {
+				return sub.fields(func(field, wire int) (bool, error) {
+					var err error
+					switch {
+					case field == 1 && wire == wirebytes:
+						o.Domain, err = sub.string()
+					case field == 2 && wire == wirevarint:
+						var v uint64
+						v, err = sub.varint()
+						o.Version = int64(v)
+					default:
+						return false, nil
+					}
+					return true, err
+				})
+			})
+			m.Opsets = append(m.Opsets, o)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m.Graph == nil {
+		return nil, errors.New("onnx.Unmarshal: model doesn't have a graph")
+	}
+	return m, nil
+}
+
+func (g *Graph) decode(d *decoder) error {
+	return d.fields(func(field, wire int) (bool, error) {
+		if wire != wirebytes {
+			return false, nil
+		}
+		var err error
+		switch field {
+		case 1:
+			n := new(Node)
+			err = d.message(n.decode)
+			g.Nodes = append(g.Nodes, n)
+		case 2:
+			g.Name, err = d.string()
+		case 5:
+			t := new(Tensor)
+			err = d.message(t.decode)
+			g.Initializers = append(g.Initializers, t)
+		case 10:
+			g.DocString, err = d.string()
+		case 11:
+			v := new(ValueInfo)
+			err = d.message(v.decode)
+			g.Inputs = append(g.Inputs, v)
+		case 12:
+			v := new(ValueInfo)
+			err = d.message(v.decode)
+			g.Outputs = append(g.Outputs, v)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (n *Node) decode(d *decoder) error {
+	return d.fields(func(field, wire int) (bool, error) {
+		if wire != wirebytes {
+			return false, nil
+		}
+		var err error
+		var s string
+		switch field {
+		case 1:
+			s, err = d.string()
+			n.Inputs = append(n.Inputs, s)
+		case 2:
+			s, err = d.string()
+			n.Outputs = append(n.Outputs, s)
+		case 3:
+			n.Name, err = d.string()
+		case 4:
+			n.OpType, err = d.string()
+		case 5:
+			a := new(Attribute)
+			err = d.message(a.decode)
+			n.Attributes = append(n.Attributes, a)
+		case 7:
+			n.Domain, err = d.string()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (a *Attribute) decode(d *decoder) error {
+	return d.fields(func(field, wire int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wire == wirebytes:
+			a.Name, err = d.string()
+		case field == 2 && wire == wirefixed32:
+			var v uint32
+			v, err = d.fixed32()
+			a.F = math.Float32frombits(v)
+		case field == 3 && wire == wirevarint:
+			var v uint64
+			v, err = d.varint()
+			a.I = int64(v)
+		case field == 4 && wire == wirebytes:
+			a.S, err = d.string()
+		case field == 7:
+			a.Floats, err = d.float32s(wire, a.Floats)
+		case field == 8:
+			a.Ints, err = d.int64s(wire, a.Ints)
+		case field == 20 && wire == wirevarint:
+			var v uint64
+			v, err = d.varint()
+			a.Type = AttributeType(v)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (t *Tensor) decode(d *decoder) error {
+	var floats []float32
+	var ints []int64
+	var int32s []int64
+	err := d.fields(func(field, wire int) (bool, error) {
+		var err error
+		switch {
+		case field == 1:
+			t.Dims, err = d.int64s(wire, t.Dims)
+		case field == 2 && wire == wirevarint:
+			var v uint64
+			v, err = d.varint()
+			t.DataType = DataType(v)
+		case field == 4:
+			floats, err = d.float32s(wire, floats)
+		case field == 5:
+			int32s, err = d.int64s(wire, int32s)
+		case field == 7:
+			ints, err = d.int64s(wire, ints)
+		case field == 8 && wire == wirebytes:
+			t.Name, err = d.string()
+		case field == 9 && wire == wirebytes:
+			var b []byte
+			b, err = d.bytes()
+			t.RawData = append([]byte{}, b...)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+	if err != nil {
+		return err
+	}
+	if len(t.RawData) > 0 {
+		return nil
+	}
+	switch {
+	case len(floats) > 0:
+		t.RawData = make([]byte, 4*len(floats))
+		for i, f := range floats {
+			binary.LittleEndian.PutUint32(t.RawData[i*4:], math.Float32bits(f))
+		}
+	case len(ints) > 0:
+		t.RawData = make([]byte, 8*len(ints))
+		for i, v := range ints {
+			binary.LittleEndian.PutUint64(t.RawData[i*8:], uint64(v))
+		}
+	case len(int32s) > 0:
+		t.RawData = make([]byte, 4*len(int32s))
+		for i, v := range int32s {
+			binary.LittleEndian.PutUint32(t.RawData[i*4:], uint32(v))
+		}
+	}
+	return nil
+}
+
+func (v *ValueInfo) decode(d *decoder) error {
+	return d.fields(func(field, wire int) (bool, error) {
+		if wire != wirebytes {
+			return false, nil
+		}
+		switch field {
+		case 1:
+			var err error
+			v.Name, err = d.string()
+			return true, err
+		case 2:
+			//TypeProto
+			return true, d.message(func(tp *decoder) error {
+				return tp.fields(func(field, wire int) (bool, error) {
+					if field != 1 || wire != wirebytes {
+						return false, nil
+					}
+					//TypeProto.Tensor
+					return true, tp.message(v.decodetensortype)
+				})
+			})
+		}
+		return false, nil
+	})
+}
+
+func (v *ValueInfo) decodetensortype(tt *decoder) error {
+	return tt.fields(func(field, wire int) (bool, error) {
+		switch {
+		case field == 1 && wire == wirevarint:
+			e, err := tt.varint()
+			v.ElemType = DataType(e)
+			return true, err
+		case field == 2 && wire == wirebytes:
+			//TensorShapeProto
+			return true, tt.message(func(shape *decoder) error {
+				return shape.fields(func(field, wire int) (bool, error) {
+					if field != 1 || wire != wirebytes {
+						return false, nil
+					}
+					var dim int64
+					err := shape.message(func(dd *decoder) error {
+						return dd.fields(func(field, wire int) (bool, error) {
+							if field == 1 && wire == wirevarint {
+								d, err := dd.varint()
+								dim = int64(d)
+								return true, err
+							}
+							return false, nil
+						})
+					})
+					v.Dims = append(v.Dims, dim)
+					return true, err
+				})
+			})
+		}
+		return false, nil
+	})
+}
+
+//Initializer returns the initializer called name or nil if there isn't one
+func (g *Graph) Initializer(name string) *Tensor {
+	for _, t := range g.Initializers {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+//Attribute returns the attribute called name or nil if the node doesn't have it
+func (n *Node) Attribute(name string) *Attribute {
+	for _, a := range n.Attributes {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+//Int64s returns the values of an int64 tensor.  It returns nil if the tensor isn't an int64 tensor.
+func (t *Tensor) Int64s() []int64 {
+	var dtype DataType
+	if t.DataType != dtype.Int64() {
+		return nil
+	}
+	values := make([]int64, len(t.RawData)/8)
+	for i := range values {
+		values[i] = int64(binary.LittleEndian.Uint64(t.RawData[i*8:]))
+	}
+	return values
+}
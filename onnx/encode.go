@@ -0,0 +1,181 @@
+package onnx
+
+import "math"
+
+//protobuf wire types
+const (
+	wirevarint  = 0
+	wirebytes   = 2
+	wirefixed32 = 5
+)
+
+//symbolicbatch is the dim_param written for dims that are less than one
+const symbolicbatch = "N"
+
+//encoder appends protobuf fields to buf.
+//Repeated numeric fields are written unpacked since onnx.proto is a proto2 file.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+func (e *encoder) key(field, wire int) {
+	e.varint(uint64(field<<3 | wire))
+}
+func (e *encoder) int(field int, v int64) {
+	e.key(field, wirevarint)
+	e.varint(uint64(v))
+}
+func (e *encoder) float(field int, v float32) {
+	e.key(field, wirefixed32)
+	b := math.Float32bits(v)
+	e.buf = append(e.buf, byte(b), byte(b>>8), byte(b>>16), byte(b>>24))
+}
+func (e *encoder) bytes(field int, b []byte) {
+	e.key(field, wirebytes)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+func (e *encoder) string(field int, s string) {
+	e.key(field, wirebytes)
+	e.varint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+//message writes the message that fn encodes as field
+func (e *encoder) message(field int, fn func(sub *encoder)) {
+	var sub encoder
+	fn(&sub)
+	e.bytes(field, sub.buf)
+}
+
+//optional fields are only written when they aren't the zero value
+func (e *encoder) optint(field int, v int64) {
+	if v != 0 {
+		e.int(field, v)
+	}
+}
+func (e *encoder) optstring(field int, s string) {
+	if s != "" {
+		e.string(field, s)
+	}
+}
+
+//Marshal encodes the model as an ONNX ModelProto
+func (m *Model) Marshal() []byte {
+	var e encoder
+	m.encode(&e)
+	return e.buf
+}
+
+func (m *Model) encode(e *encoder) {
+	e.optint(1, m.IRVersion)
+	e.optstring(2, m.ProducerName)
+	e.optstring(3, m.ProducerVersion)
+	e.optstring(4, m.Domain)
+	e.optint(5, m.ModelVersion)
+	e.optstring(6, m.DocString)
+	if m.Graph != nil {
+		e.message(7, m.Graph.encode)
+	}
+	for _, o := range m.Opsets {
+		o := o
+		e.message(8, func(sub *encoder) {
+			sub.optstring(1, o.Domain)
+			sub.int(2, o.Version)
+		})
+	}
+}
+
+func (g *Graph) encode(e *encoder) {
+	for _, n := range g.Nodes {
+		e.message(1, n.encode)
+	}
+	e.optstring(2, g.Name)
+	for _, t := range g.Initializers {
+		e.message(5, t.encode)
+	}
+	e.optstring(10, g.DocString)
+	for _, v := range g.Inputs {
+		e.message(11, v.encode)
+	}
+	for _, v := range g.Outputs {
+		e.message(12, v.encode)
+	}
+}
+
+func (n *Node) encode(e *encoder) {
+	for _, s := range n.Inputs {
+		e.string(1, s)
+	}
+	for _, s := range n.Outputs {
+		e.string(2, s)
+	}
+	e.optstring(3, n.Name)
+	e.string(4, n.OpType)
+	for _, a := range n.Attributes {
+		e.message(5, a.encode)
+	}
+	e.optstring(7, n.Domain)
+}
+
+func (a *Attribute) encode(e *encoder) {
+	var flg AttributeType
+	e.string(1, a.Name)
+	switch a.Type {
+	case flg.Float():
+		e.float(2, a.F)
+	case flg.Int():
+		e.int(3, a.I)
+	case attributestring:
+		e.string(4, a.S)
+	case flg.Floats():
+		for _, f := range a.Floats {
+			e.float(7, f)
+		}
+	case flg.Ints():
+		for _, i := range a.Ints {
+			e.int(8, i)
+		}
+	}
+	e.int(20, int64(a.Type))
+}
+
+func (t *Tensor) encode(e *encoder) {
+	for _, d := range t.Dims {
+		e.int(1, d)
+	}
+	e.int(2, int64(t.DataType))
+	e.optstring(8, t.Name)
+	e.bytes(9, t.RawData)
+}
+
+func (v *ValueInfo) encode(e *encoder) {
+	e.string(1, v.Name)
+	//TypeProto
+	e.message(2, func(tp *encoder) {
+		//TypeProto.Tensor
+		tp.message(1, func(tt *encoder) {
+			tt.int(1, int64(v.ElemType))
+			//TensorShapeProto
+			tt.message(2, func(shape *encoder) {
+				for _, d := range v.Dims {
+					d := d
+					shape.message(1, func(dim *encoder) {
+						if d < 1 {
+							dim.string(2, symbolicbatch)
+							return
+						}
+						dim.int(1, d)
+					})
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,160 @@
+//Package onnx holds the parts of the ONNX protobuf schema that gocunets uses to export networks.
+//
+//The messages are encoded by hand so that the package doesn't need protobuf generated code.
+//Only the fields that are set are written, and they are written in field number order, so the
+//output for a given Model is always the same.
+package onnx
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+//IRVersion is the ONNX IR version written in exported models
+const IRVersion = 7
+
+//OpsetVersion is the version of the default operator set that exported models use
+const OpsetVersion = 13
+
+//DataType is the element type of a tensor. The values match TensorProto.DataType.
+type DataType int32
+
+//Float sets and returns the Float flag
+func (d *DataType) Float() DataType { *d = DataType(1); return *d }
+
+//UInt8 sets and returns the UInt8 flag
+func (d *DataType) UInt8() DataType { *d = DataType(2); return *d }
+
+//Int8 sets and returns the Int8 flag
+func (d *DataType) Int8() DataType { *d = DataType(3); return *d }
+
+//Int32 sets and returns the Int32 flag
+func (d *DataType) Int32() DataType { *d = DataType(6); return *d }
+
+//Int64 sets and returns the Int64 flag
+func (d *DataType) Int64() DataType { *d = DataType(7); return *d }
+
+//Half sets and returns the Half flag
+func (d *DataType) Half() DataType { *d = DataType(10); return *d }
+
+//Double sets and returns the Double flag
+func (d *DataType) Double() DataType { *d = DataType(11); return *d }
+
+//AttributeType is the type of an Attribute. The values match AttributeProto.AttributeType.
+type AttributeType int32
+
+//Float sets and returns the Float flag
+func (a *AttributeType) Float() AttributeType { *a = AttributeType(1); return *a }
+
+//Int sets and returns the Int flag
+func (a *AttributeType) Int() AttributeType { *a = AttributeType(2); return *a }
+
+//attributestring is the type of a string attribute.  Strings are only read, so there isn't a flag method for it.
+const attributestring = AttributeType(3)
+
+//Floats sets and returns the Floats flag
+func (a *AttributeType) Floats() AttributeType { *a = AttributeType(6); return *a }
+
+//Ints sets and returns the Ints flag
+func (a *AttributeType) Ints() AttributeType { *a = AttributeType(7); return *a }
+
+//Model is an ONNX ModelProto
+type Model struct {
+	IRVersion       int64
+	ProducerName    string
+	ProducerVersion string
+	Domain          string
+	ModelVersion    int64
+	DocString       string
+	Graph           *Graph
+	Opsets          []Opset
+}
+
+//Opset is an OperatorSetIdProto. An empty Domain is the default ONNX domain.
+type Opset struct {
+	Domain  string
+	Version int64
+}
+
+//Graph is an ONNX GraphProto
+type Graph struct {
+	Nodes        []*Node
+	Name         string
+	Initializers []*Tensor
+	DocString    string
+	Inputs       []*ValueInfo
+	Outputs      []*ValueInfo
+}
+
+//Node is an ONNX NodeProto
+type Node struct {
+	Inputs     []string
+	Outputs    []string
+	Name       string
+	OpType     string
+	Attributes []*Attribute
+	Domain     string
+}
+
+//Attribute is an ONNX AttributeProto. Only the value that goes with Type is written.
+type Attribute struct {
+	Name   string
+	Type   AttributeType
+	F      float32
+	I      int64
+	S      string
+	Floats []float32
+	Ints   []int64
+}
+
+//Tensor is an ONNX TensorProto. The values are always held in RawData in little endian order.
+type Tensor struct {
+	Dims     []int64
+	DataType DataType
+	Name     string
+	RawData  []byte
+}
+
+//ValueInfo is an ONNX ValueInfoProto for a tensor. A dim that is less than one is written as the symbolic dim "N".
+type ValueInfo struct {
+	Name     string
+	ElemType DataType
+	Dims     []int64
+}
+
+//CreateFloatTensor creates a float tensor that holds a copy of values.
+func CreateFloatTensor(name string, dims []int64, values []float32) *Tensor {
+	var dtype DataType
+	raw := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(v))
+	}
+	return &Tensor{
+		Dims:     copyint64s(dims),
+		DataType: dtype.Float(),
+		Name:     name,
+		RawData:  raw,
+	}
+}
+
+//Floats returns the values of a float tensor.  It returns nil if the tensor isn't a float tensor.
+func (t *Tensor) Floats() []float32 {
+	var dtype DataType
+	if t.DataType != dtype.Float() {
+		return nil
+	}
+	values := make([]float32, len(t.RawData)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(t.RawData[i*4:]))
+	}
+	return values
+}
+
+func copyint64s(x []int64) []int64 {
+	if x == nil {
+		return nil
+	}
+	y := make([]int64, len(x))
+	copy(y, x)
+	return y
+}
@@ -0,0 +1,163 @@
+package onnx_test
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/onnx"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func values(n int, scale float32) []float32 {
+	v := make([]float32, n)
+	for i := range v {
+		v[i] = float32(i%7-3) * scale
+	}
+	return v
+}
+
+//goldengraph has a node for each of the layers that gocunets exports.
+//It goes vanilla module, module with parallel convolutions, deconvolution with batch norm and pooling, and then the output module with a softmax.
+func goldengraph(t *testing.T) *onnx.Model {
+	var dtype onnx.DataType
+	b := onnx.CreateGraphBuilder("golden")
+	x := b.AddInput("x", dtype.Float(), []int32{2, 1, 6, 6})
+
+	y := b.Conv(x, onnx.CreateFloatTensor("", []int64{2, 1, 3, 3}, values(18, .1)), onnx.CreateFloatTensor("", []int64{2}, values(2, .01)), []int32{1, 1}, []int32{1, 1}, []int32{1, 1})
+	y, err := b.Activation(y, "Relu", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := b.Conv(y, onnx.CreateFloatTensor("", []int64{1, 2, 3, 3}, values(18, .2)), onnx.CreateFloatTensor("", []int64{1}, values(1, .01)), []int32{1, 1}, []int32{2, 2}, []int32{1, 1})
+	c := b.Conv(y, onnx.CreateFloatTensor("", []int64{3, 2, 1, 1}, values(6, .3)), onnx.CreateFloatTensor("", []int64{3}, values(3, .01)), []int32{0, 0}, []int32{2, 2}, []int32{1, 1})
+	y = b.Concat([]string{a, c}, 1)
+	y, err = b.Activation(y, "Leaky", .01, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	y = b.ConvTranspose(y, onnx.CreateFloatTensor("", []int64{4, 2, 2, 2}, values(32, .1)), onnx.CreateFloatTensor("", []int64{2}, values(2, .01)), []int32{0, 0}, []int32{2, 2}, []int32{1, 1})
+	y = b.BatchNormalization(y,
+		onnx.CreateFloatTensor("", []int64{2}, []float32{1, .5}),
+		onnx.CreateFloatTensor("", []int64{2}, []float32{0, .1}),
+		onnx.CreateFloatTensor("", []int64{2}, []float32{.2, -.2}),
+		onnx.CreateFloatTensor("", []int64{2}, []float32{1, 2}), 1e-5)
+	for _, mode := range []string{"Elu", "ClippedRelu", "Sigmoid", "Tanh"} {
+		y, err = b.Activation(y, mode, 6, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	y, err = b.Pool(y, "Max", []int32{2, 2}, []int32{0, 0}, []int32{2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err = b.Pool(y, "AverageCountExcludePadding", []int32{3, 3}, []int32{1, 1}, []int32{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err = b.Activation(y, "PRelu", 0, onnx.CreateFloatTensor("", []int64{1, 2, 3, 3}, values(18, .05)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	y = b.Dropout(y)
+
+	y = b.Conv(y, onnx.CreateFloatTensor("", []int64{4, 2, 3, 3}, values(72, .1)), onnx.CreateFloatTensor("", []int64{4}, values(4, .01)), []int32{0, 0}, []int32{1, 1}, []int32{1, 1})
+	y = b.Softmax(y, 1)
+	b.AddOutput(y, dtype.Float(), []int32{2, 4, 1, 1})
+	return b.Model("gocunets", "test")
+}
+
+func TestGoldenModel(t *testing.T) {
+	got := goldengraph(t).Marshal()
+	golden := filepath.Join("testdata", "golden.onnx")
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("marshaled model doesn't match %s. Run go test -update if the change was on purpose", golden)
+	}
+}
+
+func TestActivationErrors(t *testing.T) {
+	b := onnx.CreateGraphBuilder("errors")
+	if _, err := b.Activation("x", "Threshhold", 0, nil); err == nil {
+		t.Error("Threshhold should not be supported")
+	}
+	if _, err := b.Activation("x", "PRelu", 0, nil); err == nil {
+		t.Error("PRelu without a slope should fail")
+	}
+	if _, err := b.Pool("x", "Unsupported", nil, nil, nil); err == nil {
+		t.Error("unsupported pooling mode should fail")
+	}
+	if len(b.Graph().Nodes) != 0 {
+		t.Error("nodes were added for failed calls")
+	}
+}
+
+func TestUnmarshalRoundTrip(t *testing.T) {
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "golden.onnx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := onnx.Unmarshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Graph.Nodes) != len(goldengraph(t).Graph.Nodes) {
+		t.Fatalf("got %d nodes", len(m.Graph.Nodes))
+	}
+	if m.Graph.Inputs[0].Dims[0] != 2 {
+		t.Error("input dims weren't decoded")
+	}
+	if !bytes.Equal(m.Marshal(), expected) {
+		t.Error("unmarshaled model doesn't marshal back to the same bytes")
+	}
+}
+
+//TestUnmarshalPacked checks the encodings other exporters use, packed repeated fields and float_data.
+func TestUnmarshalPacked(t *testing.T) {
+	model := []byte{
+		0x08, 0x07, //ir_version 7
+		0x3a, 0x25, //graph
+		0x2a, 0x15, //initializer
+		0x0a, 0x01, 0x02, //packed dims [2]
+		0x10, 0x01, //float
+		0x22, 0x08, 0, 0, 0x80, 0x3f, 0, 0, 0, 0x40, //packed float_data [1, 2]
+		0x42, 0x01, 'w', //name
+		0xa0, 0x06, 0x01, //unknown field 100
+		0x0a, 0x0c, //node
+		0x22, 0x04, 'R', 'e', 'l', 'u', //op_type
+		0x0a, 0x01, 'x', //input
+		0x12, 0x01, 'y', //output
+	}
+	m, err := onnx.Unmarshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := m.Graph.Initializer("w")
+	if w == nil {
+		t.Fatal("initializer w wasn't found")
+	}
+	f := w.Floats()
+	if len(w.Dims) != 1 || w.Dims[0] != 2 || len(f) != 2 || f[0] != 1 || f[1] != 2 {
+		t.Errorf("got dims %v and values %v", w.Dims, f)
+	}
+	if n := m.Graph.Nodes[0]; n.OpType != "Relu" || n.Inputs[0] != "x" || n.Outputs[0] != "y" {
+		t.Errorf("node decoded as %+v", n)
+	}
+	if _, err = onnx.Unmarshal(model[:10]); err == nil {
+		t.Error("truncated model should fail")
+	}
+}
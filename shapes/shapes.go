@@ -0,0 +1,261 @@
+//Package shapes finds the output dims, parameter counts and FLOPs of layers with the formulas the layers use.
+//Nothing is allocated and it doesn't need cgo, so shapes can be found and tested on a machine without a device.
+package shapes
+
+import (
+	"errors"
+	"fmt"
+)
+
+//TensorFormat is the layout of the dims passed to the functions of the package
+type TensorFormat int32
+
+//NCHW sets and returns the NCHW flag
+func (t *TensorFormat) NCHW() TensorFormat {
+	*t = TensorFormat(0)
+	return *t
+}
+
+//NHWC sets and returns the NHWC flag
+func (t *TensorFormat) NHWC() TensorFormat {
+	*t = TensorFormat(1)
+	return *t
+}
+
+//LayerShape is the shape of a layer found by shape inference.
+//
+//Params counts the weights and biases of the layer.  FLOPs counts a multiply add as 2, an activation or a bias as 1 per element,
+//a softmax as 3 per element and an element wise loss as 1 per element.  The counts are for one forward pass of the whole batch.
+type LayerShape struct {
+	Module     string    `json:"module"`
+	Layer      string    `json:"layer"`
+	InputDims  [][]int32 `json:"input_dims"`
+	OutputDims []int32   `json:"output_dims"`
+	WeightDims []int32   `json:"weight_dims,omitempty"`
+	Params     int64     `json:"params"`
+	FLOPs      int64     `json:"flops"`
+}
+
+//Report holds the shape of every layer of a network in the order they are run in a forward pass.
+type Report struct {
+	InputDims  []int32      `json:"input_dims"`
+	OutputDims []int32      `json:"output_dims"`
+	Layers     []LayerShape `json:"layers"`
+	Params     int64        `json:"params"`
+	FLOPs      int64        `json:"flops"`
+}
+
+//CreateReport creates an empty report for a network with an input of inputdims
+func CreateReport(inputdims []int32) (*Report, error) {
+	if len(inputdims) < 3 {
+		return nil, fmt.Errorf("input dims %v need at least 3 dims", inputdims)
+	}
+	for i, d := range inputdims {
+		if d < 1 {
+			return nil, fmt.Errorf("dim %d of input dims %v is less than 1", i, inputdims)
+		}
+	}
+	return &Report{
+		InputDims:  copyint32s(inputdims),
+		OutputDims: copyint32s(inputdims),
+	}, nil
+}
+
+//Add adds l to the report and makes its output the output of the report
+func (r *Report) Add(l LayerShape) {
+	r.Layers = append(r.Layers, l)
+	r.Params += l.Params
+	r.FLOPs += l.FLOPs
+	r.OutputDims = l.OutputDims
+}
+
+//Convolution finds the output of a convolution or a deconvolution with filter w.
+//A convolution filter is (out,in,spacial...) and a deconvolution filter is (in,out,spacial...) for NCHW.  For NHWC the channel that isn't
+//first is last.
+func Convolution(frmt TensorFormat, x, w, pad, stride, dilation []int32, deconvolution bool) (l LayerShape, err error) {
+	if len(w) != len(x) {
+		return l, fmt.Errorf("filter dims %v and input dims %v don't have the same length", w, x)
+	}
+	spacial, err := spacialaxes(frmt, x)
+	if err != nil {
+		return l, err
+	}
+	if len(pad) != len(spacial) || len(stride) != len(spacial) || len(dilation) != len(spacial) {
+		return l, fmt.Errorf("pad %v, stride %v and dilation %v need %d values", pad, stride, dilation, len(spacial))
+	}
+	c, _ := channelaxis(frmt, len(x))
+	inchannels, outchannels := w[c], w[0]
+	if deconvolution {
+		inchannels, outchannels = w[0], w[c]
+	}
+	if inchannels != x[c] {
+		return l, fmt.Errorf("filter %v has %d input channels but the input %v has %d channels", w, inchannels, x, x[c])
+	}
+	fspacial, _ := spacialaxes(frmt, w)
+	out := make([]int32, len(spacial))
+	for i := range spacial {
+		if stride[i] < 1 || dilation[i] < 1 {
+			return l, fmt.Errorf("stride %v and dilation %v need to be greater than 0", stride, dilation)
+		}
+		if deconvolution {
+			out[i] = ReverseOutputDim(spacial[i], fspacial[i], pad[i], stride[i], dilation[i])
+		} else {
+			out[i] = OutputDim(spacial[i], fspacial[i], pad[i], stride[i], dilation[i])
+		}
+		if out[i] < 1 {
+			return l, fmt.Errorf("spacial dim %d has an output of %d for input %d filter %d pad %d stride %d dilation %d",
+				i, out[i], spacial[i], fspacial[i], pad[i], stride[i], dilation[i])
+		}
+	}
+	l.Layer = "convolution"
+	if deconvolution {
+		l.Layer = "deconvolution"
+	}
+	l.InputDims = [][]int32{copyint32s(x)}
+	l.OutputDims = joindims(frmt, x[0], outchannels, out)
+	l.WeightDims = copyint32s(w)
+	l.Params = Volume(w) + int64(outchannels)
+	//Each filter weight is multiplied with each input element it covers. For a convolution that is once per output element and
+	//for a deconvolution that is once per input element.
+	macs := Volume(l.OutputDims) * Volume(w) / int64(outchannels)
+	if deconvolution {
+		macs = Volume(x) * Volume(w) / int64(inchannels)
+	}
+	l.FLOPs = 2*macs + Volume(l.OutputDims)
+	return l, nil
+}
+
+//Activation is the shape of an activation with mode.  mode is the name gocunets model files use for the mode.
+//PRelu has a weight per element and Threshhold has 3.
+func Activation(mode string, x []int32) LayerShape {
+	l := LayerShape{
+		Layer:      "activation",
+		InputDims:  [][]int32{copyint32s(x)},
+		OutputDims: copyint32s(x),
+		FLOPs:      Volume(x),
+	}
+	switch mode {
+	case "PRelu":
+		l.Params = Volume(x[1:])
+	case "Threshhold":
+		l.Params = 3 * Volume(x[1:])
+	}
+	return l
+}
+
+//Concat is the shape of the concat of xs along the channels.  Every x needs to have the same batch and spacial dims.
+func Concat(frmt TensorFormat, xs [][]int32) (l LayerShape, err error) {
+	if len(xs) == 0 {
+		return l, errors.New("concat needs at least one input")
+	}
+	c, err := channelaxis(frmt, len(xs[0]))
+	if err != nil {
+		return l, err
+	}
+	first, _ := spacialaxes(frmt, xs[0])
+	var channels int32
+	for i, x := range xs {
+		if len(x) != len(xs[0]) || x[0] != xs[0][0] {
+			return l, fmt.Errorf("input %d has dims %v but input 0 has %v", i, x, xs[0])
+		}
+		spacial, _ := spacialaxes(frmt, x)
+		if !int32sequal(first, spacial) {
+			return l, fmt.Errorf("input %d has spacial dims %v but input 0 has %v", i, spacial, first)
+		}
+		channels += x[c]
+		l.InputDims = append(l.InputDims, copyint32s(x))
+	}
+	l.Layer = "concat"
+	l.OutputDims = joindims(frmt, xs[0][0], channels, first)
+	return l, nil
+}
+
+//Loss is the shape of a loss layer.  Losses with a softmax count 3 flops for each element and the element wise losses count 1.
+func Loss(name string, softmax bool, x []int32) LayerShape {
+	flops := Volume(x)
+	if softmax {
+		flops *= 3
+	}
+	return LayerShape{
+		Layer:      name,
+		InputDims:  [][]int32{copyint32s(x)},
+		OutputDims: copyint32s(x),
+		FLOPs:      flops,
+	}
+}
+
+//OutputDim is the output of a spacial dim of a convolution with input i, filter f, pad p, stride s and dilation d
+func OutputDim(i, f, p, s, d int32) int32 {
+	return 1 + (i+2*p-(((f-1)*d)+1))/s
+}
+
+//ReverseOutputDim is the output of a spacial dim of a deconvolution with input i, filter f, pad p, stride s and dilation d
+func ReverseOutputDim(i, f, p, s, d int32) int32 {
+	return (i-1)*s - 2*p + ((f - 1) * d) + 1
+}
+
+//Volume returns the product of dims
+func Volume(dims []int32) int64 {
+	v := int64(1)
+	for _, d := range dims {
+		v *= int64(d)
+	}
+	return v
+}
+
+//channelaxis returns the index of the channel dim for frmt
+func channelaxis(frmt TensorFormat, ndims int) (int, error) {
+	var flg TensorFormat
+	switch frmt {
+	case flg.NCHW():
+		return 1, nil
+	case flg.NHWC():
+		return ndims - 1, nil
+	}
+	return -1, errors.New("Unsupported Format")
+}
+
+//spacialaxes returns the dims of x that aren't the batch or the channel
+func spacialaxes(frmt TensorFormat, x []int32) ([]int32, error) {
+	c, err := channelaxis(frmt, len(x))
+	if err != nil {
+		return nil, err
+	}
+	if c == 1 {
+		return x[2:], nil
+	}
+	return x[1 : len(x)-1], nil
+}
+
+//joindims puts batch, channels and spacial together in the order of frmt
+func joindims(frmt TensorFormat, batch, channels int32, spacial []int32) []int32 {
+	dims := make([]int32, 0, len(spacial)+2)
+	dims = append(dims, batch)
+	if c, _ := channelaxis(frmt, len(spacial)+2); c == 1 {
+		dims = append(dims, channels)
+		return append(dims, spacial...)
+	}
+	dims = append(dims, spacial...)
+	return append(dims, channels)
+}
+
+func copyint32s(x []int32) []int32 {
+	if x == nil {
+		return nil
+	}
+	y := make([]int32, len(x))
+	copy(y, x)
+	return y
+}
+
+func int32sequal(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package shapes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvolution(t *testing.T) {
+	var frmt TensorFormat
+	l, err := Convolution(frmt.NCHW(), []int32{4, 1, 9, 9}, []int32{8, 1, 3, 3}, []int32{1, 1}, []int32{1, 1}, []int32{1, 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Layer != "convolution" || !int32sequal(l.OutputDims, []int32{4, 8, 9, 9}) {
+		t.Errorf("%s output dims are %v", l.Layer, l.OutputDims)
+	}
+	if l.Params != 8*9+8 {
+		t.Errorf("params are %d, expected %d", l.Params, 8*9+8)
+	}
+	//each output element is 9 multiply adds and a bias
+	if l.FLOPs != 2*9*4*8*81+4*8*81 {
+		t.Errorf("flops are %d, expected %d", l.FLOPs, 2*9*4*8*81+4*8*81)
+	}
+
+	l, err = Convolution(frmt.NHWC(), []int32{4, 5, 5, 8}, []int32{8, 2, 2, 3}, []int32{0, 0}, []int32{2, 2}, []int32{1, 1}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Layer != "deconvolution" || !int32sequal(l.OutputDims, []int32{4, 10, 10, 3}) {
+		t.Errorf("%s output dims are %v", l.Layer, l.OutputDims)
+	}
+	if l.Params != 8*2*2*3+3 {
+		t.Errorf("params are %d, expected %d", l.Params, 8*2*2*3+3)
+	}
+
+	_, err = Convolution(frmt.NCHW(), []int32{4, 2, 9, 9}, []int32{8, 1, 3, 3}, []int32{1, 1}, []int32{1, 1}, []int32{1, 1}, false)
+	if err == nil || !strings.Contains(err.Error(), "input channels") {
+		t.Errorf("channel mismatch should error, got %v", err)
+	}
+	_, err = Convolution(frmt.NCHW(), []int32{4, 1, 2, 2}, []int32{8, 1, 5, 5}, []int32{0, 0}, []int32{1, 1}, []int32{1, 1}, false)
+	if err == nil {
+		t.Error("a filter larger than the input should error")
+	}
+	_, err = Convolution(frmt.NCHW(), []int32{4, 1, 9, 9}, []int32{8, 1, 3, 3}, []int32{1, 1}, []int32{0, 1}, []int32{1, 1}, false)
+	if err == nil {
+		t.Error("a stride of 0 should error")
+	}
+}
+
+func TestConcat(t *testing.T) {
+	var frmt TensorFormat
+	l, err := Concat(frmt.NCHW(), [][]int32{{4, 2, 5, 5}, {4, 3, 5, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !int32sequal(l.OutputDims, []int32{4, 5, 5, 5}) || len(l.InputDims) != 2 {
+		t.Errorf("concat is %v", l)
+	}
+	l, err = Concat(frmt.NHWC(), [][]int32{{4, 5, 5, 2}, {4, 5, 5, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !int32sequal(l.OutputDims, []int32{4, 5, 5, 5}) {
+		t.Errorf("NHWC concat output dims are %v", l.OutputDims)
+	}
+	if _, err = Concat(frmt.NCHW(), [][]int32{{4, 2, 5, 5}, {4, 3, 4, 4}}); err == nil {
+		t.Error("inputs with different spacial dims should error")
+	}
+	if _, err = Concat(frmt.NCHW(), [][]int32{{4, 2, 5, 5}, {2, 3, 5, 5}}); err == nil {
+		t.Error("inputs with different batches should error")
+	}
+}
+
+func TestReport(t *testing.T) {
+	if _, err := CreateReport([]int32{4, 0, 9, 9}); err == nil {
+		t.Error("a dim less than 1 should error")
+	}
+	r, err := CreateReport([]int32{4, 1, 9, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frmt TensorFormat
+	conv, err := Convolution(frmt.NCHW(), r.OutputDims, []int32{8, 1, 3, 3}, []int32{1, 1}, []int32{1, 1}, []int32{1, 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Add(conv)
+	r.Add(Activation("PRelu", r.OutputDims))
+	r.Add(Loss("softmax", true, r.OutputDims))
+	if len(r.Layers) != 3 || !int32sequal(r.OutputDims, []int32{4, 8, 9, 9}) {
+		t.Errorf("report has %d layers and output dims %v", len(r.Layers), r.OutputDims)
+	}
+	//PRelu has a weight for each element of a sample
+	if r.Params != conv.Params+8*81 {
+		t.Errorf("params are %d, expected %d", r.Params, conv.Params+8*81)
+	}
+	if r.FLOPs != conv.FLOPs+4*8*81+3*4*8*81 {
+		t.Errorf("flops are %d, expected %d", r.FLOPs, conv.FLOPs+4*8*81+3*4*8*81)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"image"
 	"math/rand"
 
+	"github.com/dereklstinson/gocunets/data/augment"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/layers"
 	"github.com/dereklstinson/gocunets/loss"
@@ -14,7 +15,6 @@ import (
 	"github.com/dereklstinson/gocunets/utils/filing"
 	"github.com/dereklstinson/gocunets/utils/imaging"
 	gocudnn "github.com/dereklstinson/gocudnn"
-	"github.com/nfnt/resize"
 )
 
 func main() {
@@ -132,7 +132,7 @@ func network() {
 		}
 		somenewimages := make([]image.Image, len(images))
 		for j := range images {
-			somenewimages[j] = resize.Resize(0, 280, images[j], resize.NearestNeighbor)
+			somenewimages[j] = augment.ResizeImage(images[j], 0, 280)
 		}
 		totalrunimage = append(totalrunimage, somenewimages...)
 
@@ -10,8 +10,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/dereklstinson/gocunets/data/augment"
 	"github.com/dereklstinson/gocunets/utils"
-	"github.com/nfnt/resize"
 )
 
 //Roman is some roman numerals 1 through 9 (I through IX) with the added character N.  N == 0
@@ -123,7 +123,7 @@ func makepixels(im image.Image) []float32 {
 }
 
 func make28by28(im image.Image) image.Image {
-	return resize.Resize(28, 28, im, resize.NearestNeighbor)
+	return augment.ResizeImage(im, 28, 28)
 }
 
 func getromanimages(folder string) []image.Image {
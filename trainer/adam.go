@@ -39,6 +39,7 @@ type Adam struct {
 	regparams xtra.RegParams
 	dims      []int32
 	counter   uint64
+	settings  Settings
 }
 
 const defaultadambeta1 = 0.9
@@ -373,6 +374,15 @@ func SetupAdam(tctx *xtra.Handle, decay1, decay2 float32, batch int32) (*Adam, e
 		trainer:   t,
 		params:    x,
 		regparams: reg,
+		settings: Settings{
+			Beta1:  defaultadambeta1,
+			Beta2:  defaultadambeta2,
+			Decay1: float64(decay1),
+			Decay2: float64(decay2),
+			Rate:   defaultadamrate,
+			Eps:    float64(defaultadameps),
+			Batch:  float64(batch),
+		},
 	}, nil
 }
 
@@ -380,27 +390,32 @@ func SetupAdam(tctx *xtra.Handle, decay1, decay2 float32, batch int32) (*Adam, e
 func (a *Adam) SetDecays(l1, l2 float32) {
 	a.regparams.SetDecay1(l1)
 	a.regparams.SetDecay2(l2)
+	a.settings.Decay1, a.settings.Decay2 = float64(l1), float64(l2)
 }
 
 //SetDecay1 sets decay1
 func (a *Adam) SetDecay1(decay1 float32) {
 	a.regparams.SetDecay1(decay1)
+	a.settings.Decay1 = float64(decay1)
 }
 
 //SetDecay2 sets decay 2
 func (a *Adam) SetDecay2(decay2 float32) {
 	a.regparams.SetDecay2(decay2)
+	a.settings.Decay2 = float64(decay2)
 
 }
 
 //SetBeta1 sets beta1
 func (a *Adam) SetBeta1(beta1 float32) {
 	a.params.SetBeta1(beta1)
+	a.settings.Beta1 = float64(beta1)
 }
 
 //SetBeta2 sets beta2
 func (a *Adam) SetBeta2(beta2 float32) {
 	a.params.SetBeta2(beta2)
+	a.settings.Beta2 = float64(beta2)
 
 }
 
@@ -408,16 +423,40 @@ func (a *Adam) SetBeta2(beta2 float32) {
 func (a *Adam) SetRates(rate, dwalpha float32) {
 	a.params.SetRate(rate)
 	a.params.SetDWalpha(dwalpha)
+	a.settings.Rate, a.settings.DWalpha = float64(rate), float64(dwalpha)
 
 }
 
 //SetBatch sets batch
 func (a *Adam) SetBatch(batch float32) {
 	a.regparams.SetBatch(batch)
+	a.settings.Batch = float64(batch)
 }
 
 //SetEps sets eps
 func (a *Adam) SetEps(eps float32) {
 	a.params.SetEps(eps)
+	a.settings.Eps = float64(eps)
 
 }
+
+//Settings returns the settings of the trainer
+func (a *Adam) Settings() Settings {
+	return a.settings
+}
+
+//LoadSettings sets the trainer to the settings passed.  Momentum and Managed are not used by adam.
+func (a *Adam) LoadSettings(s Settings) {
+	a.SetBeta1(float32(s.Beta1))
+	a.SetBeta2(float32(s.Beta2))
+	a.SetDecays(float32(s.Decay1), float32(s.Decay2))
+	a.SetRates(float32(s.Rate), float32(s.DWalpha))
+	a.SetEps(float32(s.Eps))
+	a.SetBatch(float32(s.Batch))
+}
+
+//TrainingMem returns the first (gsum) and second (xsum) moments that adam keeps for the weights.
+//They are nil until SetTrainingMem is ran.
+func (a *Adam) TrainingMem() (gsum, xsum *nvidia.Malloced) {
+	return a.gsum, a.xsum
+}
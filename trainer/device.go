@@ -0,0 +1,196 @@
+package trainer
+
+import (
+	"fmt"
+
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+	gocudnn "github.com/dereklstinson/gocudnn"
+	"github.com/dereklstinson/gocudnn/gocu"
+	"github.com/dereklstinson/gocudnn/xtra"
+)
+
+//devicetrainer holds what SGD, RMSProp, AdaGrad, AdaDelta, AdamW and LAMB share.
+//Like Adam, the l1 and l2 decays are added to dw and their loss is found with the L1L2Regularization of the xtra trainer,
+//and then update changes w on the device with the xtra training kernel of mode and cudnn tensor ops.
+//Only the l1 and l2 loss are copied to the host.  Only float weights are supported.
+//
+//dwalpha is what dw is multiplied by after an update like it is with adam. 0 zeros it.
+type devicetrainer struct {
+	name      string
+	mode      xtra.TrainingMode
+	trainer   *xtra.TrainerD
+	params    xtra.TrainingParams
+	regparams xtra.RegParams
+	update    func(handle *cudnn.Handler, dw, w *layers.Tensor, counter int) error
+	load      func(s Settings)
+	settings  Settings
+	names     []string
+	mem       []*layers.Tensor
+	scratch   bool
+	tmp       *layers.Tensor
+	loss1     []float32
+	loss2     []float32
+	goptr1    *gocu.Wrapper
+	goptr2    *gocu.Wrapper
+	gpuloss1  *nvidia.Malloced
+	gpuloss2  *nvidia.Malloced
+}
+
+func makedevicetrainer(name string, mode xtra.TrainingMode, names []string, scratch bool) devicetrainer {
+	return devicetrainer{
+		name:      name,
+		mode:      mode,
+		names:     names,
+		scratch:   scratch,
+		params:    xtra.CreateParamsFloat32(defaultadameps, defaultadamrate, defaultadambeta1, defaultadambeta2, 1),
+		regparams: xtra.CreateRegParamsFloat32(0, 0, 1),
+	}
+}
+
+//SetTrainingMem makes the training memory for w
+func (d *devicetrainer) SetTrainingMem(handle *cudnn.Handler, w *layers.Tensor) (err error) {
+	var dtype gocudnn.DataType
+	if w.DataType() != dtype.Float() {
+		return fmt.Errorf("(%s) SetTrainingMem: only float weights are supported", d.name)
+	}
+	d.trainer, err = xtra.NewTrainingDescriptor(handle.XHandle(), d.mode, dtype.Float())
+	if err != nil {
+		return err
+	}
+	d.mem = make([]*layers.Tensor, len(d.names))
+	for i := range d.mem {
+		d.mem[i], err = layers.ZeroClone(handle, w)
+		if err != nil {
+			return err
+		}
+	}
+	d.tmp = nil
+	if d.scratch {
+		d.tmp, err = layers.ZeroClone(handle, w)
+		if err != nil {
+			return err
+		}
+	}
+	d.loss1, d.loss2 = make([]float32, 1), make([]float32, 1)
+	d.goptr1, err = gocu.MakeGoMem(d.loss1)
+	if err != nil {
+		return err
+	}
+	d.goptr2, err = gocu.MakeGoMem(d.loss2)
+	if err != nil {
+		return err
+	}
+	d.gpuloss1, err = nvidia.MallocGlobal(handle, 4)
+	if err != nil {
+		return err
+	}
+	d.gpuloss2, err = nvidia.MallocGlobal(handle, 4)
+	return err
+}
+
+//UpdateWeights updates w with dw. counter is the number of updates done so far starting at 0.
+func (d *devicetrainer) UpdateWeights(handle *cudnn.Handler, dw, w *layers.Tensor, batch, counter int) (err error) {
+	if d.mem == nil {
+		return fmt.Errorf("(%s) UpdateWeights: training mem hasn't been set", d.name)
+	}
+	if n := d.mem[0].Vol(); w.Vol() != n || dw.Vol() != n {
+		return fmt.Errorf("(%s) UpdateWeights: w and dw need to be the size the training mem was set for", d.name)
+	}
+	if batch < 1 {
+		return fmt.Errorf("(%s) UpdateWeights: batch needs to be greater than zero", d.name)
+	}
+	err = handle.Sync()
+	if err != nil {
+		return err
+	}
+	d.regparams.SetBatch(float32(batch))
+	d.settings.Batch = float64(batch)
+	err = d.trainer.L1L2Regularization(handle.XHandle(), dw.TD(), dw, w, d.gpuloss1, d.gpuloss2, d.regparams)
+	if err != nil {
+		return err
+	}
+	err = d.update(handle, dw, w, counter)
+	if err != nil {
+		return err
+	}
+	err = handle.Sync()
+	if err != nil {
+		return err
+	}
+	err = nvidia.Memcpy(d.goptr1, d.gpuloss1, d.goptr1.TotalBytes())
+	if err != nil {
+		return err
+	}
+	return nvidia.Memcpy(d.goptr2, d.gpuloss2, d.goptr2.TotalBytes())
+}
+
+//train runs the xtra training kernel of the trainer with gsum and xsum
+func (d *devicetrainer) train(handle *cudnn.Handler, dw, w, gsum, xsum *layers.Tensor, counter int) error {
+	return d.trainer.TrainValues(handle.XHandle(), dw.TD(), dw, w, gsum.Malloced, xsum.Malloced, d.params, int32(counter))
+}
+
+//scaledw multiplies dw by dwalpha for the updates that aren't done by a training kernel
+func (d *devicetrainer) scaledw(handle *cudnn.Handler, dw *layers.Tensor) error {
+	if d.settings.DWalpha == 0 {
+		return dw.SetValues(handle, 0)
+	}
+	return dw.ScaleValues(handle, d.settings.DWalpha)
+}
+
+//L1L2Loss returns the l1 and l2 loss of the last update
+func (d *devicetrainer) L1L2Loss() (float32, float32) {
+	if d.loss1 == nil {
+		return 0, 0
+	}
+	return d.loss1[0], d.loss2[0]
+}
+
+//SetRates sets the learning rate and dwalpha
+func (d *devicetrainer) SetRates(rate, dwalpha float32) {
+	s := d.settings
+	s.Rate, s.DWalpha = float64(rate), float64(dwalpha)
+	d.LoadSettings(s)
+}
+
+//SetDecays sets the l1 and l2 decays
+func (d *devicetrainer) SetDecays(l1, l2 float32) {
+	s := d.settings
+	s.Decay1, s.Decay2 = float64(l1), float64(l2)
+	d.LoadSettings(s)
+}
+
+//SetEps sets eps
+func (d *devicetrainer) SetEps(eps float32) {
+	s := d.settings
+	s.Eps = float64(eps)
+	d.LoadSettings(s)
+}
+
+//Settings returns the settings of the trainer
+func (d *devicetrainer) Settings() Settings {
+	return d.settings
+}
+
+//LoadSettings sets the trainer to the settings passed.  Settings the trainer doesn't have are kept but not used.
+func (d *devicetrainer) LoadSettings(s Settings) {
+	d.settings = s
+	d.regparams.SetDecay1(float32(s.Decay1))
+	d.regparams.SetDecay2(float32(s.Decay2))
+	d.regparams.SetBatch(float32(s.Batch))
+	d.params.SetRate(float32(s.Rate))
+	d.params.SetDWalpha(float32(s.DWalpha))
+	d.params.SetEps(float32(s.Eps))
+	d.load(s)
+}
+
+//TrainingMem returns the names of the memory the trainer keeps for the weights and the memory.
+//The memory is nil until SetTrainingMem is ran.
+func (d *devicetrainer) TrainingMem() (names []string, mems []*nvidia.Malloced) {
+	mems = make([]*nvidia.Malloced, len(d.names))
+	for i := range d.mem {
+		mems[i] = d.mem[i].Malloced
+	}
+	return d.names, mems
+}
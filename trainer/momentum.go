@@ -3,6 +3,7 @@ package trainer
 import (
 	"errors"
 
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/tensor"
 	"github.com/dereklstinson/gocunets/layers"
@@ -17,6 +18,7 @@ type Momentum struct {
 	gsum     *tensor.Volume
 	loss1    float64
 	loss2    float64
+	batch    float64
 }
 
 //L1L2Loss returns the loss that was previously recorded.
@@ -40,7 +42,8 @@ func SetupMomentum(decay1, decay2, rate, momentum, batch float64) *Momentum {
 		decay1:   decay1,
 		decay2:   decay2,
 		rate:     rate,
-		momentum: momentum}
+		momentum: momentum,
+		batch:    batch}
 }
 
 //SetRate the Learning Rate of momentum
@@ -48,6 +51,35 @@ func (t *Momentum) SetRate(rate float32) {
 	t.rate = float64(rate)
 }
 
+//SetRates sets the learning rate. dwalpha isn't used by momentum.  It is here so Momentum can be used as a Trainer.
+func (t *Momentum) SetRates(rate, dwalpha float32) {
+	t.rate = float64(rate)
+}
+
+//Settings returns the settings of the trainer
+func (t *Momentum) Settings() Settings {
+	return Settings{
+		Decay1:   t.decay1,
+		Decay2:   t.decay2,
+		Rate:     t.rate,
+		Momentum: t.momentum,
+		Batch:    t.batch,
+	}
+}
+
+//LoadSettings sets the trainer to the settings passed. Only Decay1, Decay2, Rate, Momentum and Batch are used.
+func (t *Momentum) LoadSettings(s Settings) {
+	t.decay1, t.decay2, t.rate, t.momentum, t.batch = s.Decay1, s.Decay2, s.Rate, s.Momentum, s.Batch
+}
+
+//TrainingMem returns the gsum that momentum keeps for the weights.  It is nil until SetTrainingMem is ran.
+func (t *Momentum) TrainingMem() *nvidia.Malloced {
+	if t.gsum == nil {
+		return nil
+	}
+	return t.gsum.Malloced
+}
+
 //SetTrainingMem will load the gsum values
 func (t *Momentum) SetTrainingMem(handle *cudnn.Handler, w *layers.Tensor) error {
 
@@ -62,7 +94,8 @@ func errorappender(comment string, err error) error {
 }
 
 //UpdateWeights for now is just the momentum operation.  I might have to make a new cuda library for gocudnn. I will have to check that out.
-func (t *Momentum) UpdateWeights(handle *cudnn.Handler, dw, w *layers.Tensor, batch int) error {
+//counter isn't used.  It is there so that Momentum satisfies the Trainer interface.
+func (t *Momentum) UpdateWeights(handle *cudnn.Handler, dw, w *layers.Tensor, batch, counter int) error {
 
 	var err error
 
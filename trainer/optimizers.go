@@ -0,0 +1,303 @@
+package trainer
+
+import (
+	"math"
+
+	"github.com/dereklstinson/gocudnn/xtra"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+)
+
+//SGD is stochastic gradient descent with momentum that can be nesterov momentum.
+//The update is done on the device with tensor ops.  cpu.SGD is the same update on the host.
+type SGD struct {
+	devicetrainer
+}
+
+//SetupSGD sets up SGD with a rate of .01.  A momentum of 0 is vanilla gradient descent.
+func SetupSGD(decay1, decay2, momentum float32, nesterov bool) *SGD {
+	s := new(SGD)
+	//The adam descriptor is only used for the regularization.
+	s.devicetrainer = makedevicetrainer("s *SGD", xtra.TrainingModeFlag{}.Adam(), []string{"v"}, false)
+	s.update, s.load = s.updateweights, s.loadsettings
+	s.LoadSettings(Settings{
+		Decay1:   float64(decay1),
+		Decay2:   float64(decay2),
+		Rate:     .01,
+		Momentum: float64(momentum),
+		Nesterov: nesterov,
+	})
+	return s
+}
+
+func (s *SGD) loadsettings(st Settings) {}
+
+//updateweights does v = momentum*v + g and w -= rate*v, or w -= rate*(g + momentum*v) with nesterov
+func (s *SGD) updateweights(handle *cudnn.Handler, dw, w *layers.Tensor, counter int) error {
+	rate, momentum := s.settings.Rate, s.settings.Momentum
+	v := s.mem[0]
+	err := v.AddTo(handle, dw.Volume, 1, momentum)
+	if err != nil {
+		return err
+	}
+	if s.settings.Nesterov {
+		err = w.AddTo(handle, dw.Volume, -rate, 1)
+		if err != nil {
+			return err
+		}
+		err = w.AddTo(handle, v.Volume, -rate*momentum, 1)
+	} else {
+		err = w.AddTo(handle, v.Volume, -rate, 1)
+	}
+	if err != nil {
+		return err
+	}
+	return s.scaledw(handle, dw)
+}
+
+//SetMomentum sets the momentum and if it is nesterov momentum
+func (s *SGD) SetMomentum(momentum float32, nesterov bool) {
+	st := s.settings
+	st.Momentum, st.Nesterov = float64(momentum), nesterov
+	s.LoadSettings(st)
+}
+
+//RMSProp divides the gradient by a running average of its size.
+//The update is done on the device with the adam kernel and a beta1 of 0.  cpu.RMSProp is the same update on the host.
+type RMSProp struct {
+	devicetrainer
+}
+
+//SetupRMSProp sets up RMSProp with a rate of .001, rho .9 and eps 1e-8
+func SetupRMSProp(decay1, decay2 float32) *RMSProp {
+	r := new(RMSProp)
+	r.devicetrainer = makedevicetrainer("r *RMSProp", xtra.TrainingModeFlag{}.Adam(), []string{"s"}, true)
+	r.update, r.load = r.updateweights, r.loadsettings
+	r.LoadSettings(Settings{
+		Decay1: float64(decay1),
+		Decay2: float64(decay2),
+		Rate:   .001,
+		Rho:    .9,
+		Eps:    1e-8,
+	})
+	return r
+}
+
+func (r *RMSProp) loadsettings(s Settings) {
+	r.params.SetBeta1(0)
+	r.params.SetBeta2(float32(s.Rho))
+}
+
+//updateweights runs the adam kernel with a beta1 of 0 and beta2 of rho.  Adam divides the running average by 1-rho^(counter+1),
+//so rate and eps are divided by the root of it to undo that.  The first moment that adam keeps is the gradient and isn't saved.
+func (r *RMSProp) updateweights(handle *cudnn.Handler, dw, w *layers.Tensor, counter int) error {
+	c := math.Sqrt(1 - math.Pow(r.settings.Rho, float64(counter+1)))
+	r.params.SetRate(float32(r.settings.Rate / c))
+	r.params.SetEps(float32(r.settings.Eps / c))
+	return r.train(handle, dw, w, r.tmp, r.mem[0], counter)
+}
+
+//SetRho sets the decay of the running average
+func (r *RMSProp) SetRho(rho float32) {
+	s := r.settings
+	s.Rho = float64(rho)
+	r.LoadSettings(s)
+}
+
+//AdaGrad divides the gradient by the root of the sum of the squared gradients.
+//The update is done on the device with the adagrad kernel.  cpu.AdaGrad is the same update on the host.
+type AdaGrad struct {
+	devicetrainer
+}
+
+//SetupAdaGrad sets up AdaGrad with a rate of .01 and eps 1e-8
+func SetupAdaGrad(decay1, decay2 float32) *AdaGrad {
+	a := new(AdaGrad)
+	a.devicetrainer = makedevicetrainer("a *AdaGrad", xtra.TrainingModeFlag{}.AdaGrad(), []string{"s"}, true)
+	a.update, a.load = a.updateweights, a.loadsettings
+	a.LoadSettings(Settings{
+		Decay1: float64(decay1),
+		Decay2: float64(decay2),
+		Rate:   .01,
+		Eps:    1e-8,
+	})
+	return a
+}
+
+func (a *AdaGrad) loadsettings(s Settings) {}
+
+func (a *AdaGrad) updateweights(handle *cudnn.Handler, dw, w *layers.Tensor, counter int) error {
+	return a.train(handle, dw, w, a.mem[0], a.tmp, counter)
+}
+
+//AdaDelta scales the gradient by the ratio of the running averages of the updates and the gradients.
+//The update is done on the device with the adadelta kernel.  cpu.AdaDelta is the same update on the host.
+type AdaDelta struct {
+	devicetrainer
+}
+
+//SetupAdaDelta sets up AdaDelta with a rate of 1, rho .95 and eps 1e-6
+func SetupAdaDelta(decay1, decay2 float32) *AdaDelta {
+	a := new(AdaDelta)
+	a.devicetrainer = makedevicetrainer("a *AdaDelta", xtra.TrainingModeFlag{}.AdaDelta(), []string{"s", "u"}, false)
+	a.update, a.load = a.updateweights, a.loadsettings
+	a.LoadSettings(Settings{
+		Decay1: float64(decay1),
+		Decay2: float64(decay2),
+		Rate:   1,
+		Rho:    .95,
+		Eps:    1e-6,
+	})
+	return a
+}
+
+func (a *AdaDelta) loadsettings(s Settings) {
+	a.params.SetBeta1(float32(s.Rho))
+	a.params.SetBeta2(float32(s.Rho))
+}
+
+func (a *AdaDelta) updateweights(handle *cudnn.Handler, dw, w *layers.Tensor, counter int) error {
+	return a.train(handle, dw, w, a.mem[0], a.mem[1], counter)
+}
+
+//SetRho sets the decay of the running averages
+func (a *AdaDelta) SetRho(rho float32) {
+	s := a.settings
+	s.Rho = float64(rho)
+	a.LoadSettings(s)
+}
+
+//AdamW is adam with decoupled weight decay.
+//The update is done on the device with the adam kernel.  cpu.AdamW is the same update on the host.
+type AdamW struct {
+	devicetrainer
+}
+
+//SetupAdamW sets up AdamW with a rate of .001, beta1 .9, beta2 .999 and eps 1e-8.
+//decay1 and decay2 are added to the gradient like they are with adam and should usually be 0.
+func SetupAdamW(decay1, decay2, weightdecay float32) *AdamW {
+	a := new(AdamW)
+	a.devicetrainer = makedevicetrainer("a *AdamW", xtra.TrainingModeFlag{}.Adam(), []string{"gsum", "xsum"}, false)
+	a.update, a.load = a.updateweights, a.loadsettings
+	a.LoadSettings(Settings{
+		Beta1:       defaultadambeta1,
+		Beta2:       defaultadambeta2,
+		Decay1:      float64(decay1),
+		Decay2:      float64(decay2),
+		Rate:        defaultadamrate,
+		Eps:         float64(defaultadameps),
+		WeightDecay: float64(weightdecay),
+	})
+	return a
+}
+
+func (a *AdamW) loadsettings(s Settings) {
+	a.params.SetBeta1(float32(s.Beta1))
+	a.params.SetBeta2(float32(s.Beta2))
+}
+
+//updateweights scales w by 1-rate*weightdecay and then runs the adam kernel
+func (a *AdamW) updateweights(handle *cudnn.Handler, dw, w *layers.Tensor, counter int) error {
+	if a.settings.WeightDecay != 0 {
+		err := w.ScaleValues(handle, 1-a.settings.Rate*a.settings.WeightDecay)
+		if err != nil {
+			return err
+		}
+	}
+	return a.train(handle, dw, w, a.mem[0], a.mem[1], counter)
+}
+
+//SetBetas sets beta1 and beta2
+func (a *AdamW) SetBetas(beta1, beta2 float32) {
+	s := a.settings
+	s.Beta1, s.Beta2 = float64(beta1), float64(beta2)
+	a.LoadSettings(s)
+}
+
+//SetWeightDecay sets the decoupled weight decay
+func (a *AdamW) SetWeightDecay(weightdecay float32) {
+	s := a.settings
+	s.WeightDecay = float64(weightdecay)
+	a.LoadSettings(s)
+}
+
+//LAMB is AdamW with the step of each tensor scaled by the trust ratio ||w||/||step||.
+//The update is done on the device with the adam kernel and tensor ops.  Only the two norms are copied to the host.
+//cpu.LAMB is the same update on the host.
+type LAMB struct {
+	devicetrainer
+	ratio float32
+}
+
+//SetupLAMB sets up LAMB with a rate of .001, beta1 .9, beta2 .999 and eps 1e-6
+func SetupLAMB(decay1, decay2, weightdecay float32) *LAMB {
+	l := new(LAMB)
+	l.devicetrainer = makedevicetrainer("l *LAMB", xtra.TrainingModeFlag{}.Adam(), []string{"gsum", "xsum"}, true)
+	l.update, l.load = l.updateweights, l.loadsettings
+	l.LoadSettings(Settings{
+		Beta1:       defaultadambeta1,
+		Beta2:       defaultadambeta2,
+		Decay1:      float64(decay1),
+		Decay2:      float64(decay2),
+		Rate:        defaultadamrate,
+		Eps:         1e-6,
+		WeightDecay: float64(weightdecay),
+	})
+	return l
+}
+
+func (l *LAMB) loadsettings(s Settings) {
+	l.params.SetBeta1(float32(s.Beta1))
+	l.params.SetBeta2(float32(s.Beta2))
+}
+
+//updateweights keeps the old weights in tmp and runs the adam kernel on w.  w is then made into -rate*(adam step + weightdecay*old)
+//so the trust ratio can be found from the norms of it and old, and w is set to old plus the step scaled by the ratio.
+func (l *LAMB) updateweights(handle *cudnn.Handler, dw, w *layers.Tensor, counter int) error {
+	rate := l.settings.Rate
+	old := l.tmp
+	err := old.AddTo(handle, w.Volume, 1, 0)
+	if err != nil {
+		return err
+	}
+	err = l.train(handle, dw, w, l.mem[0], l.mem[1], counter)
+	if err != nil {
+		return err
+	}
+	err = w.AddTo(handle, old.Volume, -1-rate*l.settings.WeightDecay, 1)
+	if err != nil {
+		return err
+	}
+	step, err := w.Norm2X(handle)
+	if err != nil {
+		return err
+	}
+	wnorm, err := old.Norm2X(handle)
+	if err != nil {
+		return err
+	}
+	l.ratio = 1
+	if wnorm > 0 && step > 0 {
+		l.ratio = float32(rate) * wnorm / step
+	}
+	return w.AddTo(handle, old.Volume, 1, float64(l.ratio))
+}
+
+//SetBetas sets beta1 and beta2
+func (l *LAMB) SetBetas(beta1, beta2 float32) {
+	s := l.settings
+	s.Beta1, s.Beta2 = float64(beta1), float64(beta2)
+	l.LoadSettings(s)
+}
+
+//SetWeightDecay sets the decoupled weight decay
+func (l *LAMB) SetWeightDecay(weightdecay float32) {
+	s := l.settings
+	s.WeightDecay = float64(weightdecay)
+	l.LoadSettings(s)
+}
+
+//TrustRatio returns the trust ratio of the last update
+func (l *LAMB) TrustRatio() float32 {
+	return l.ratio
+}
@@ -0,0 +1,89 @@
+package trainer
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/dereklstinson/gocunets/cpu"
+	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
+	"github.com/dereklstinson/gocunets/layers"
+	gocudnn "github.com/dereklstinson/gocudnn"
+	"github.com/dereklstinson/gocudnn/cudart"
+	"github.com/dereklstinson/gocudnn/gocu"
+)
+
+func TestDeviceOptimizers(t *testing.T) {
+	runtime.LockOSThread()
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	dev, err := cudart.GetDevice()
+	check(err)
+	worker := gocu.NewWorker(dev)
+	h := cudnn.CreateHandler(worker, dev, 4)
+	var frmt gocudnn.TensorFormat
+	var dtype gocudnn.DataType
+	var cfrmt cpu.TensorFormat
+	dims := []int32{2, 3, 2, 2}
+	weights := []float32{.5, -.25, 1, -1, .1, 0, .75, -.6, .3, -.05, .2, .9, -.4, .65, -.8, .05, .45, -.3, .15, -.7, .35, .55, -.15, .25}
+	grads := []float32{.2, .1, -.3, .05, -.4, .6, -.1, .25, .15, -.2, .3, -.05, .1, -.35, .4, .2, -.15, .05, .3, -.25, .1, -.1, .45, -.3}
+	batch := 4
+	type pair struct {
+		name   string
+		device Trainer
+		host   cpu.Trainer
+		rate   float32
+	}
+	pairs := []pair{
+		{"sgd", SetupSGD(.001, .01, .9, false), cpu.CreateSGD(.001, .01, .9, false), .01},
+		{"nesterov", SetupSGD(.001, .01, .9, true), cpu.CreateSGD(.001, .01, .9, true), .01},
+		{"rmsprop", SetupRMSProp(.001, .01), cpu.CreateRMSProp(.001, .01), .001},
+		{"adagrad", SetupAdaGrad(.001, .01), cpu.CreateAdaGrad(.001, .01), .01},
+		{"adadelta", SetupAdaDelta(.001, .01), cpu.CreateAdaDelta(.001, .01), 1},
+		{"adamw", SetupAdamW(0, 0, .01), cpu.CreateAdamW(0, 0, .01), .001},
+		{"lamb", SetupLAMB(0, 0, .01), cpu.CreateLAMB(0, 0, .01), .001},
+	}
+	for _, p := range pairs {
+		w, err := layers.CreateTensor(h, frmt.NCHW(), dtype.Float(), dims)
+		check(err)
+		dw, err := layers.CreateTensor(h, frmt.NCHW(), dtype.Float(), dims)
+		check(err)
+		check(w.LoadValuesFromSLice(h, weights, int32(len(weights))))
+		check(CreateTrainingMem(h, p.device, w))
+		cw, err := cpu.CreateTensorFromSlice(cfrmt.NCHW(), dims, weights)
+		check(err)
+		cdw, err := cpu.CreateTensor(cfrmt.NCHW(), dims)
+		check(err)
+		p.host.SetRates(p.rate, 1)
+		for counter := 0; counter < 3; counter++ {
+			check(dw.LoadValuesFromSLice(h, grads, int32(len(grads))))
+			check(cdw.LoadValuesFromSLice(grads))
+			check(p.device.UpdateWeights(h, dw, w, batch, counter))
+			check(p.host.UpdateWeights(cdw, cw, batch, counter))
+		}
+		check(h.Sync())
+		values := make([]float32, len(weights))
+		check(w.FillSlice(h, values))
+		for i, v := range cw.Data() {
+			if math.Abs(float64(values[i]-v)) > 1e-4 {
+				t.Errorf("%s %d: w is %v, expected %v", p.name, i, values[i], v)
+			}
+		}
+		l1, l2 := p.device.L1L2Loss()
+		cl1, cl2 := p.host.L1L2Loss()
+		if math.Abs(float64(l1-cl1)) > 1e-4 || math.Abs(float64(l2-cl2)) > 1e-4 {
+			t.Errorf("%s: l1 l2 loss is %v %v, expected %v %v", p.name, l1, l2, cl1, cl2)
+		}
+		dws := make([]float32, len(grads))
+		check(dw.FillSlice(h, dws))
+		for i := range dws {
+			if dws[i] != 0 {
+				t.Errorf("%s: dw wasn't zeroed", p.name)
+				break
+			}
+		}
+	}
+}
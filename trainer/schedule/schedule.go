@@ -0,0 +1,277 @@
+//Package schedule has learning rate schedules.  A schedule gives the rate for each update, and
+//gocunets.SimpleModuleNetwork uses it to set the rate of all of its trainers before the update.
+//
+//Schedules are written and read with Marshal and Unmarshal so a run that is resumed from a checkpoint
+//continues at the right rate.  Every schedule but Plateau gets the rate from the update counter alone.
+//Plateau keeps the state it needs in its exported fields.
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+//Schedule gives the learning rate of an update.
+type Schedule interface {
+	//Rate returns the rate of update step.  step starts at 0.  loss is the loss of the batch that will be used for the update.
+	Rate(step int, loss float32) float32
+}
+
+//StepDecay multiplies the rate by Gamma every StepSize updates.
+type StepDecay struct {
+	Rate0    float32 `json:"rate"`
+	Gamma    float32 `json:"gamma"`
+	StepSize int     `json:"step_size"`
+}
+
+//Rate satisfies Schedule
+func (s *StepDecay) Rate(step int, loss float32) float32 {
+	if s.StepSize < 1 {
+		return s.Rate0
+	}
+	return s.Rate0 * float32(math.Pow(float64(s.Gamma), float64(step/s.StepSize)))
+}
+
+//Exponential multiplies the rate by Gamma every update.
+type Exponential struct {
+	Rate0 float32 `json:"rate"`
+	Gamma float32 `json:"gamma"`
+}
+
+//Rate satisfies Schedule
+func (s *Exponential) Rate(step int, loss float32) float32 {
+	return s.Rate0 * float32(math.Pow(float64(s.Gamma), float64(step)))
+}
+
+//CosineRestarts is cosine annealing with warm restarts.  The rate goes from MaxRate to MinRate over Period updates along half a cosine
+//and then starts over at MaxRate.  Each period is Mult times longer than the last.  A Mult less than 1 is taken as 1.
+type CosineRestarts struct {
+	MaxRate float32 `json:"max_rate"`
+	MinRate float32 `json:"min_rate"`
+	Period  int     `json:"period"`
+	Mult    float32 `json:"mult,omitempty"`
+}
+
+//Rate satisfies Schedule
+func (s *CosineRestarts) Rate(step int, loss float32) float32 {
+	if s.Period < 1 {
+		return s.MaxRate
+	}
+	t, period := float64(step), float64(s.Period)
+	mult := math.Max(float64(s.Mult), 1)
+	if mult == 1 {
+		t = math.Mod(t, period)
+	} else {
+		//The cycle that step is in is the n where period*(mult^n-1)/(mult-1) <= t
+		n := math.Floor(math.Log(t*(mult-1)/period+1)/math.Log(mult) + 1e-9)
+		t -= period * (math.Pow(mult, n) - 1) / (mult - 1)
+		period *= math.Pow(mult, n)
+	}
+	return cosine(s.MaxRate, s.MinRate, t/period)
+}
+
+//cosine goes from start to end along half a cosine as pct goes from 0 to 1
+func cosine(start, end float32, pct float64) float32 {
+	return end + (start-end)*float32(1+math.Cos(math.Pi*pct))/2
+}
+
+//Warmup raises the rate along a line from Start times the rate of After to the rate of After over Steps updates.
+//After Steps updates it is After where the steps of After start when the warmup ends.
+type Warmup struct {
+	Steps int
+	Start float32
+	After Schedule
+}
+
+//Rate satisfies Schedule
+func (s *Warmup) Rate(step int, loss float32) float32 {
+	if step >= s.Steps {
+		return s.After.Rate(step-s.Steps, loss)
+	}
+	rate := s.After.Rate(0, loss)
+	pct := float32(step) / float32(s.Steps)
+	return rate * (s.Start + (1-s.Start)*pct)
+}
+
+type warmupjson struct {
+	Steps int             `json:"steps"`
+	Start float32         `json:"start"`
+	After json.RawMessage `json:"after"`
+}
+
+//MarshalJSON satisfies json.Marshaler.  After is written with its type.
+func (s *Warmup) MarshalJSON() ([]byte, error) {
+	after, err := Marshal(s.After)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(warmupjson{Steps: s.Steps, Start: s.Start, After: after})
+}
+
+//UnmarshalJSON satisfies json.Unmarshaler
+func (s *Warmup) UnmarshalJSON(data []byte) (err error) {
+	var w warmupjson
+	err = json.Unmarshal(data, &w)
+	if err != nil {
+		return err
+	}
+	s.Steps, s.Start = w.Steps, w.Start
+	s.After, err = Unmarshal(w.After)
+	return err
+}
+
+//OneCycle raises the rate from MaxRate/Div to MaxRate over the first PctStart of Steps and lowers it to MaxRate/(Div*FinalDiv) over the rest,
+//both along half a cosine.  The rate stays at the last rate after Steps updates.
+type OneCycle struct {
+	MaxRate  float32 `json:"max_rate"`
+	Steps    int     `json:"steps"`
+	PctStart float32 `json:"pct_start"`
+	Div      float32 `json:"div"`
+	FinalDiv float32 `json:"final_div"`
+}
+
+//CreateOneCycle creates a one cycle schedule with a PctStart of .3, a Div of 25 and a FinalDiv of 1e4
+func CreateOneCycle(maxrate float32, steps int) *OneCycle {
+	return &OneCycle{
+		MaxRate:  maxrate,
+		Steps:    steps,
+		PctStart: .3,
+		Div:      25,
+		FinalDiv: 1e4,
+	}
+}
+
+//Rate satisfies Schedule
+func (s *OneCycle) Rate(step int, loss float32) float32 {
+	start := s.MaxRate / s.Div
+	end := start / s.FinalDiv
+	up := int(float64(s.PctStart) * float64(s.Steps))
+	switch {
+	case step >= s.Steps:
+		return end
+	case step < up:
+		return cosine(start, s.MaxRate, float64(step)/float64(up))
+	}
+	return cosine(s.MaxRate, end, float64(step-up)/float64(s.Steps-up))
+}
+
+//Plateau multiplies the rate by Factor when the loss hasn't gone below Best*(1-Threshold) for more than Patience updates.
+//The rate won't go below MinRate.
+//
+//Current, Best, Bad and Step are the state of the schedule.  Current is set to Rate0 on the first update.
+//A step that isn't after Step doesn't change the state so an update that is done again gets the same rate.
+type Plateau struct {
+	Rate0     float32 `json:"rate"`
+	Factor    float32 `json:"factor"`
+	Patience  int     `json:"patience"`
+	Threshold float32 `json:"threshold"`
+	MinRate   float32 `json:"min_rate"`
+
+	Current float32 `json:"current"`
+	Best    float32 `json:"best"`
+	Bad     int     `json:"bad"`
+	Step    int     `json:"step"`
+	Started bool    `json:"started"`
+}
+
+//CreatePlateau creates a Plateau schedule with a Threshold of 1e-4 and a MinRate of 0
+func CreatePlateau(rate, factor float32, patience int) *Plateau {
+	return &Plateau{
+		Rate0:     rate,
+		Factor:    factor,
+		Patience:  patience,
+		Threshold: 1e-4,
+	}
+}
+
+//Rate satisfies Schedule
+func (s *Plateau) Rate(step int, loss float32) float32 {
+	if !s.Started {
+		s.Started = true
+		s.Current, s.Best, s.Bad, s.Step = s.Rate0, loss, 0, step
+		return s.Current
+	}
+	if step <= s.Step {
+		return s.Current
+	}
+	s.Step = step
+	if loss < s.Best*(1-s.Threshold) {
+		s.Best, s.Bad = loss, 0
+		return s.Current
+	}
+	s.Bad++
+	if s.Bad > s.Patience {
+		s.Current *= s.Factor
+		if s.Current < s.MinRate {
+			s.Current = s.MinRate
+		}
+		s.Bad = 0
+	}
+	return s.Current
+}
+
+//saved is a schedule with its type
+type saved struct {
+	Type     string          `json:"type"`
+	Schedule json.RawMessage `json:"schedule"`
+}
+
+//Marshal writes s as json with its type so that it can be read with Unmarshal
+func Marshal(s Schedule) ([]byte, error) {
+	var typ string
+	switch s.(type) {
+	case *StepDecay:
+		typ = "StepDecay"
+	case *Exponential:
+		typ = "Exponential"
+	case *CosineRestarts:
+		typ = "CosineRestarts"
+	case *Warmup:
+		typ = "Warmup"
+	case *OneCycle:
+		typ = "OneCycle"
+	case *Plateau:
+		typ = "Plateau"
+	case nil:
+		return nil, errors.New("schedule.Marshal: schedule is nil")
+	default:
+		return nil, fmt.Errorf("schedule.Marshal: unsupported schedule %T", s)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(saved{Type: typ, Schedule: data})
+}
+
+//Unmarshal reads a schedule written by Marshal
+func Unmarshal(data []byte) (s Schedule, err error) {
+	var sv saved
+	err = json.Unmarshal(data, &sv)
+	if err != nil {
+		return nil, err
+	}
+	switch sv.Type {
+	case "StepDecay":
+		s = new(StepDecay)
+	case "Exponential":
+		s = new(Exponential)
+	case "CosineRestarts":
+		s = new(CosineRestarts)
+	case "Warmup":
+		s = new(Warmup)
+	case "OneCycle":
+		s = new(OneCycle)
+	case "Plateau":
+		s = new(Plateau)
+	default:
+		return nil, fmt.Errorf("schedule.Unmarshal: unsupported schedule %q", sv.Type)
+	}
+	err = json.Unmarshal(sv.Schedule, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,82 @@
+package schedule
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) <= 1e-6*math.Max(1, math.Abs(float64(b)))
+}
+
+func TestSchedules(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		s     Schedule
+		steps []int
+		rates []float32
+	}{
+		{"StepDecay", &StepDecay{Rate0: 1, Gamma: .5, StepSize: 10}, []int{0, 9, 10, 25}, []float32{1, 1, .5, .25}},
+		{"Exponential", &Exponential{Rate0: 2, Gamma: .9}, []int{0, 1, 3}, []float32{2, 1.8, 2 * .729}},
+		{"Cosine", &CosineRestarts{MaxRate: 1, MinRate: 0, Period: 4, Mult: 1}, []int{0, 2, 3, 4, 6}, []float32{1, .5, float32(1+math.Cos(.75*math.Pi)) / 2, 1, .5}},
+		//Periods of 2, 4 and 8 start at 0, 2 and 6
+		{"CosineMult", &CosineRestarts{MaxRate: 1, MinRate: .5, Period: 2, Mult: 2}, []int{1, 2, 4, 6, 10}, []float32{.75, 1, .75, 1, .75}},
+		{"Warmup", &Warmup{Steps: 4, Start: 0, After: &Exponential{Rate0: 1, Gamma: .5}}, []int{0, 2, 4, 5}, []float32{0, .5, 1, .5}},
+		{"OneCycle", CreateOneCycle(1, 10), []int{0, 3, 10, 20}, []float32{.04, 1, .04 / 1e4, .04 / 1e4}},
+	} {
+		for i, step := range c.steps {
+			if r := c.s.Rate(step, 0); !near(r, c.rates[i]) {
+				t.Errorf("%s: rate of step %d is %v expected %v", c.name, step, r, c.rates[i])
+			}
+		}
+		data, err := Marshal(c.s)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		s, err := Unmarshal(data)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		for i, step := range c.steps {
+			if r := s.Rate(step, 0); !near(r, c.rates[i]) {
+				t.Errorf("%s: unmarshaled rate of step %d is %v expected %v", c.name, step, r, c.rates[i])
+			}
+		}
+	}
+}
+
+func TestPlateau(t *testing.T) {
+	p := CreatePlateau(1, .1, 2)
+	p.MinRate = .005
+	losses := []float32{5, 4, 4, 4, 4, 3, 3, 3, 3, 3, 3, 3}
+	rates := []float32{1, 1, 1, 1, .1, .1, .1, .1, .01, .01, .01, .005}
+	for i := range losses {
+		if r := p.Rate(i, losses[i]); !near(r, rates[i]) {
+			t.Errorf("rate of step %d is %v expected %v", i, r, rates[i])
+		}
+		//An update that is done again doesn't count as another bad update
+		if r := p.Rate(i, 100); !near(r, rates[i]) {
+			t.Errorf("repeated step %d changed the rate to %v", i, r)
+		}
+	}
+
+	//A resumed run continues with the same state
+	p = CreatePlateau(1, .1, 2)
+	for i := 0; i < 4; i++ {
+		p.Rate(i, losses[i])
+	}
+	data, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s.Rate(4, losses[4]); !near(r, .1) {
+		t.Errorf("resumed plateau rate is %v", r)
+	}
+	if _, err = Unmarshal([]byte(`{"type":"Linear","schedule":{}}`)); err == nil {
+		t.Error("unknown schedule should not unmarshal")
+	}
+}
@@ -7,14 +7,25 @@ type Settings struct {
 	Decay1   float64 `json:"decay_1,omitempty"`
 	Decay2   float64 `json:"decay_2,omitempty"`
 	Rate     float64 `json:"rate,omitempty"`
+	DWalpha  float64 `json:"dwalpha,omitempty"`
 	Momentum float64 `json:"momentum,omitempty"`
 	Eps      float64 `json:"eps,omitempty"`
 	Batch    float64 `json:"batch,omitempty"`
 	Managed  bool    `json:"managed,omitempty"`
+
+	Rho         float64 `json:"rho,omitempty"`
+	WeightDecay float64 `json:"weight_decay,omitempty"`
+	Nesterov    bool    `json:"nesterov,omitempty"`
 }
 
 //TSettings contains the trainer settings per trainer
 type TSettings struct {
 	Adam     Settings
 	Momentum Settings
+	SGD      Settings
+	RMSProp  Settings
+	AdaGrad  Settings
+	AdaDelta Settings
+	AdamW    Settings
+	LAMB     Settings
 }
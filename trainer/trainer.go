@@ -10,7 +10,8 @@ import (
 	"github.com/dereklstinson/gocunets/layers"
 )
 
-//Trainer will be used for updating weights.  Only momentum and adam are available right now
+//Trainer will be used for updating weights.
+//Every trainer updates on the device.  The cpu package has the same SGD, RMSProp, AdaGrad, AdaDelta, AdamW and LAMB updates on the host.
 type Trainer interface {
 	UpdateWeights(ctx *cudnn.Handler, dw, w *layers.Tensor, batch, counter int) error
 	L1L2Loss() (float32, float32)
@@ -24,8 +25,22 @@ func CreateTrainingMem(handle *cudnn.Handler, trainer Trainer, w *layers.Tensor)
 	switch x := trainer.(type) {
 	case *Adam:
 		return x.SetTrainingMem(handle, w)
+	case *Momentum:
+		return x.SetTrainingMem(handle, w)
+	case *SGD:
+		return x.SetTrainingMem(handle, w)
+	case *RMSProp:
+		return x.SetTrainingMem(handle, w)
+	case *AdaGrad:
+		return x.SetTrainingMem(handle, w)
+	case *AdaDelta:
+		return x.SetTrainingMem(handle, w)
+	case *AdamW:
+		return x.SetTrainingMem(handle, w)
+	case *LAMB:
+		return x.SetTrainingMem(handle, w)
 	default:
-		return errors.New("only adam is supported")
+		return errors.New("unsupported trainer")
 	}
 
 }
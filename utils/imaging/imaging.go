@@ -12,10 +12,10 @@ import (
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/cudnn/tensor"
 	"github.com/dereklstinson/gocunets/devices/gpu/nvidia/custom/reshapes"
-	"github.com/dereklstinson/gocunets/layers" //	"github.com/dereklstinson/gocunets/thirdparty/github.com/nfnt/resize"
+	"github.com/dereklstinson/gocunets/data/augment"
+	"github.com/dereklstinson/gocunets/layers"
 	"github.com/dereklstinson/gocunets/utils"
 	gocudnn "github.com/dereklstinson/gocudnn"
-	"github.com/nfnt/resize"
 )
 
 //Imager takes tensors and to the best its ability turn it into an image.Image
@@ -113,7 +113,7 @@ func (im *Imager) ByBatches(handle *cudnn.Handler, x *layers.Tensor, h, w uint)
 		if err != nil {
 			return nil, err
 		}
-		batchimage = resize.Resize(w, h, batchimage, resize.NearestNeighbor)
+		batchimage = augment.ResizeImage(batchimage, int(w), int(h))
 		images = append(images, batchimage)
 	}
 